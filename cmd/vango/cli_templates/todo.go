@@ -73,33 +73,33 @@ type Todo struct {
 func Index() vango.Component {
 	return vango.FC(func(ctx *vango.Context) *vdom.VNode {
 		// State
-		todos := reactive.CreateState([]Todo{})
-		inputText := reactive.CreateState("")
-		nextID := reactive.CreateState(1)
+		todos := reactive.CreateState(ctx, []Todo{})
+		inputText := reactive.CreateState(ctx, "")
+		nextID := reactive.CreateState(ctx, 1)
 		
 		// Add todo
 		addTodo := func(e vango.Event) {
-			text := inputText.Get()
+			text := inputText.Get(nil)
 			if text == "" {
 				return
 			}
 			
-			todos.Update(func(list []Todo) []Todo {
+			todos.Update(nil, func(list []Todo) []Todo {
 				return append(list, Todo{
-					ID:        nextID.Get(),
+					ID:        nextID.Get(nil),
 					Text:      text,
 					Completed: false,
 				})
 			})
 			
-			nextID.Update(func(id int) int { return id + 1 })
-			inputText.Set("")
+			nextID.Update(nil, func(id int) int { return id + 1 })
+			inputText.Set(nil, "")
 		}
 		
 		// Toggle todo
 		toggleTodo := func(id int) func(vango.Event) {
 			return func(e vango.Event) {
-				todos.Update(func(list []Todo) []Todo {
+				todos.Update(nil, func(list []Todo) []Todo {
 					for i := range list {
 						if list[i].ID == id {
 							list[i].Completed = !list[i].Completed
@@ -113,7 +113,7 @@ func Index() vango.Component {
 		// Delete todo
 		deleteTodo := func(id int) func(vango.Event) {
 			return func(e vango.Event) {
-				todos.Update(func(list []Todo) []Todo {
+				todos.Update(nil, func(list []Todo) []Todo {
 					filtered := make([]Todo, 0, len(list))
 					for _, todo := range list {
 						if todo.ID != id {
@@ -126,8 +126,8 @@ func Index() vango.Component {
 		}
 		
 		// Render todo items
-		todoItems := make([]*vdom.VNode, 0, len(todos.Get()))
-		for _, todo := range todos.Get() {
+		todoItems := make([]*vdom.VNode, 0, len(todos.Get(ctx)))
+		for _, todo := range todos.Get(ctx) {
 			todoClass := "todo-item"
 			if todo.Completed {
 				todoClass += " completed"
@@ -160,10 +160,10 @@ func Index() vango.Component {
 			},
 				vdom.NewElement("input", vdom.Props{
 					"type":        "text",
-					"value":       inputText.Get(),
+					"value":       inputText.Get(ctx),
 					"placeholder": "What needs to be done?",
 					"onInput": func(e vango.Event) {
-						inputText.Set(e.Target.Value)
+						inputText.Set(nil, e.Target.Value)
 					},
 					"onKeyDown": func(e vango.Event) {
 						if e.Key == "Enter" {
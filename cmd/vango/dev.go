@@ -783,8 +783,8 @@ func (s *devServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Check if this is a live protocol connection (path includes /vango/live/)
 	if strings.Contains(r.URL.Path, "/vango/live/") {
 		// Use the live server for server-driven components
-		log.Printf("🔌 Live WebSocket connection: %s", r.URL.Path)
-		s.liveServer.HandleWebSocket(w, r)
+		log.Printf("🔌 Live connection: %s", r.URL.Path)
+		s.liveServer.HandleLive(w, r)
 		return
 	}
 
@@ -80,16 +80,20 @@ func newSVGBuilder(tag string) *ElementBuilder {
 		props:    make(vdom.Props),
 		children: make([]*vdom.VNode, 0),
 		isVoid:   false,
-		namespace: "http://www.w3.org/2000/svg",
+		namespace: vdom.NamespaceSVG,
 	}
 }
 
 // Build creates the VNode
 func (b *ElementBuilder) Build() *vdom.VNode {
+	var node *vdom.VNode
 	if b.isVoid {
-		return vdom.NewElement(b.tag, b.props)
+		node = vdom.NewElement(b.tag, b.props)
+	} else {
+		node = vdom.NewElement(b.tag, b.props, b.children...)
 	}
-	return vdom.NewElement(b.tag, b.props, b.children...)
+	node.Namespace = b.namespace
+	return node
 }
 
 // Child adds a single child node
@@ -200,6 +200,7 @@ func (g *CodeGenerator) generateRadixTreeV2() error {
 	// Prepare imports and wrappers
 	pkgImports := map[string]struct{}{
 		fmt.Sprintf("%s/pkg/server", g.modulePath):        {},
+		fmt.Sprintf("%s/pkg/vango", g.modulePath):         {},
 		fmt.Sprintf("%s/pkg/vango/vdom", g.modulePath):    {},
 		fmt.Sprintf("%s/pkg/renderer/html", g.modulePath): {},
 		"net/http": {},
@@ -270,6 +271,9 @@ func (g *CodeGenerator) generateRadixTreeV2() error {
 		if strings.HasSuffix(p, "/pkg/server") {
 			alias = "server"
 		}
+		if strings.HasSuffix(p, "/pkg/vango") {
+			alias = "vango"
+		}
 		if strings.HasSuffix(p, "/pkg/vango/vdom") {
 			alias = "vdom"
 		}
@@ -338,6 +342,7 @@ func ServeHTTP(w http.ResponseWriter, req *http.Request) {
             ctx := server.NewContext(w, req)
             vnode, err := notFound(ctx)
             if err == nil && vnode != nil {
+                vango.RenderComponents(nil, vnode)
                 html, rerr := htmlrender.RenderToString(vnode)
                 if rerr == nil {
                     w.WriteHeader(http.StatusNotFound)
@@ -373,6 +378,7 @@ func ServeHTTP(w http.ResponseWriter, req *http.Request) {
         if internalError != nil {
             ivnode, ierr := internalError(ctx)
             if ierr == nil && ivnode != nil {
+                vango.RenderComponents(nil, ivnode)
                 html, rerr := htmlrender.RenderToString(ivnode)
                 if rerr == nil {
                     w.WriteHeader(http.StatusInternalServerError)
@@ -387,6 +393,7 @@ func ServeHTTP(w http.ResponseWriter, req *http.Request) {
         return
     }
     if vnode == nil { return }
+    vango.RenderComponents(nil, vnode)
     html, err := htmlrender.RenderToString(vnode)
     if err != nil {
         w.WriteHeader(http.StatusInternalServerError)
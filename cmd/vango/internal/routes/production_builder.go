@@ -104,19 +104,14 @@ func RegisterRoutes(router *server.Router, liveServer *live.Server, sessionMgr *
 	{{else if .HasServer}}
     // Server-driven component
     router.AddRoute("{{.URLPattern}}", func(ctx server.Ctx) (*vdom.VNode, error) {
-        // Get session
-        sessionID := getSessionID(ctx)
-        session, _ := sessionMgr.GetSession(sessionID)
-        if session == nil {
-            // Create new session if needed
-            // This would normally happen in middleware
-            log.Printf("Warning: No session for request to %s", ctx.Path())
-        }
+        // Get or start the session; its cookie binds the live connection
+        session, err := sessionMgr.SessionFromCtx(ctx)
+        if err != nil { return nil, err }
         // Call server handler
         vnode, err := {{.ImportAlias}}.{{.HandlerName}}(ctx)
         if err != nil { return nil, err }
-        // Inject minimal client for server-driven components
-        vnode = server.InjectServerDrivenClient(vnode, sessionID)
+        // Inject minimal client with the signed live session token
+        vnode = server.InjectServerDrivenClient(vnode, sessionMgr.LiveToken(session.ID))
         return vnode, nil
     })
 	{{else}}
@@ -165,14 +160,6 @@ func handle500(ctx server.Ctx) (*vdom.VNode, error) {
 }
 
 // Helper functions
-func getSessionID(ctx server.Ctx) string {
-	// Extract session ID from cookie or header
-	if cookie, err := ctx.Request().Cookie("vango-session"); err == nil {
-		return cookie.Value
-	}
-	return ""
-}
-
 func defaultNotFound(ctx server.Ctx) (*vdom.VNode, error) {
 	return &vdom.VNode{
 		Kind: vdom.KindElement,
@@ -462,6 +449,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/recera/vango/pkg/live"
 	"github.com/recera/vango/pkg/server"
@@ -483,6 +471,16 @@ func main() {
 	sessionMgr := routes.NewSessionManager(liveServer)
 	sessionMgr.StartCleanupRoutine()
 
+	// Instances behind a load balancer must share the session signing key
+	if key := os.Getenv("VANGO_SESSION_KEY"); key != "" {
+		sessionMgr.SetSigningKey([]byte(key))
+	}
+
+	// Origins other than the app's own that may open live connections
+	if origins := os.Getenv("VANGO_ALLOWED_ORIGINS"); origins != "" {
+		liveServer.SetAllowedOrigins(strings.Split(origins, ",")...)
+	}
+
 	// Create router
 	router := server.NewRouter()
 
@@ -492,8 +490,8 @@ func main() {
     // Create HTTP mux
     mux := http.NewServeMux()
 
-    // WebSocket endpoint for live updates
-    mux.HandleFunc("/vango/live/", liveServer.HandleWebSocket)
+    // Live updates endpoint (WebSocket, or SSE + POST fallback)
+    mux.HandleFunc("/vango/live/", liveServer.HandleLive)
 
     // Serve router table for client-side navigation
     mux.HandleFunc("/router/table.json", func(w http.ResponseWriter, r *http.Request) {
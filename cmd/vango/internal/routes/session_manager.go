@@ -27,6 +27,7 @@ type SessionManager struct {
 	liveServer *live.Server
 	bridge     *live.SchedulerBridge
 	registry   *server.ComponentRegistry
+	signer     *live.SessionSigner // signs session IDs for live connections
 	
 	// Configuration
 	cookieName   string
@@ -59,8 +60,10 @@ type ManagedSession struct {
 }
 
 // NewSessionManager creates a new session manager
+// Live connections must present a session token signed by the manager and
+// the session cookie it issued; see LiveToken.
 func NewSessionManager(liveServer *live.Server) *SessionManager {
-	return &SessionManager{
+	sm := &SessionManager{
 		sessions:     make(map[string]*ManagedSession),
 		liveServer:   liveServer,
 		bridge:       live.NewSchedulerBridge(liveServer),
@@ -71,10 +74,58 @@ func NewSessionManager(liveServer *live.Server) *SessionManager {
 		cookieSecure: false,
 		maxAge:       24 * time.Hour,
 	}
+	
+	// A random key works for a single instance; deployments with several
+	// instances share one via SetSigningKey
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("session manager: generating signing key: %v", err))
+	}
+	sm.SetSigningKey(key)
+	
+	// Stop the manager's per-session schedulers when live sessions end
+	if liveServer != nil {
+		liveServer.OnSessionEnd(func(session *live.Session) {
+			sm.bridge.CleanupSession(session.ID)
+		})
+	}
+	
+	return sm
+}
+
+// SetSigningKey sets the secret used to sign live session tokens
+func (sm *SessionManager) SetSigningKey(key []byte) {
+	sm.mu.Lock()
+	sm.signer = live.NewSessionSigner(key)
+	signer := sm.signer
+	sm.mu.Unlock()
+	
+	if sm.liveServer != nil {
+		sm.liveServer.RequireSignedSessions(signer, sm.cookieName)
+	}
+}
+
+// LiveToken returns the token a page embeds so its client can open the
+// live connection for sessionID
+func (sm *SessionManager) LiveToken(sessionID string) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.signer.Sign(sessionID)
 }
 
 // GetOrCreateSession gets or creates a session for a request
 func (sm *SessionManager) GetOrCreateSession(w http.ResponseWriter, r *http.Request) (*ManagedSession, error) {
+	return sm.getOrCreateSession(r, w.Header())
+}
+
+// SessionFromCtx gets or creates the session for a route handler's request
+func (sm *SessionManager) SessionFromCtx(ctx server.Ctx) (*ManagedSession, error) {
+	return sm.getOrCreateSession(ctx.Request(), ctx.Header())
+}
+
+// getOrCreateSession looks up the request's session cookie, issuing a new
+// session and cookie through header when there is none
+func (sm *SessionManager) getOrCreateSession(r *http.Request, header http.Header) (*ManagedSession, error) {
 	// Try to get session ID from cookie
 	sessionID := ""
 	if cookie, err := r.Cookie(sm.cookieName); err == nil {
@@ -112,7 +163,7 @@ func (sm *SessionManager) GetOrCreateSession(w http.ResponseWriter, r *http.Requ
 	sm.mu.Unlock()
 	
 	// Set cookie
-	http.SetCookie(w, &http.Cookie{
+	cookie := &http.Cookie{
 		Name:     sm.cookieName,
 		Value:    sessionID,
 		Path:     sm.cookiePath,
@@ -121,7 +172,8 @@ func (sm *SessionManager) GetOrCreateSession(w http.ResponseWriter, r *http.Requ
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
 		MaxAge:   int(sm.maxAge.Seconds()),
-	})
+	}
+	header.Add("Set-Cookie", cookie.String())
 	
 	return session, nil
 }
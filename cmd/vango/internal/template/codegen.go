@@ -2,6 +2,7 @@ package template
 
 import (
 	"fmt"
+	"hash/fnv"
 	"strings"
 )
 
@@ -47,8 +48,47 @@ func (n *TextNode) Generate() string {
 	return fmt.Sprintf("functional.Text(%q)", escaped)
 }
 
-// Generate for ElementNode
+// Generate for ElementNode. Elements without expressions, events or
+// components anywhere below them are wrapped in vdom.Memo keyed by their
+// content, so the differ skips them on every re-render.
 func (n *ElementNode) Generate() string {
+	if !isStatic(n) {
+		return n.generate(false)
+	}
+	code := n.generate(true)
+	hash := fnv.New64a()
+	hash.Write([]byte(code))
+	return fmt.Sprintf("vdom.Memo([]any{\"vex:%x\"}, func() *vdom.VNode { return %s })", hash.Sum64(), code)
+}
+
+// isStatic reports whether a node renders the same output every time
+func isStatic(node Node) bool {
+	switch n := node.(type) {
+	case *TextNode:
+		return !strings.Contains(n.Content, "{{")
+	case *ElementNode:
+		if len(n.Events) > 0 {
+			return false
+		}
+		for _, value := range n.Attributes {
+			if strings.Contains(value, "{{") || strings.HasPrefix(value, "{") {
+				return false
+			}
+		}
+		for _, child := range n.Children {
+			if !isStatic(child) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// generate generates the builder chain of an element; inside a static
+// element, child elements are not wrapped again
+func (n *ElementNode) generate(static bool) string {
 	var code strings.Builder
 	
 	// Use builder pattern for elements
@@ -104,7 +144,12 @@ func (n *ElementNode) Generate() string {
 	if len(n.Children) > 0 {
 		code.WriteString(".Children(\n")
 		for i, child := range n.Children {
-			childCode := child.Generate()
+			var childCode string
+			if el, ok := child.(*ElementNode); ok && static {
+				childCode = el.generate(true)
+			} else {
+				childCode = child.Generate()
+			}
 			if childCode != "" {
 				// Remove leading tabs from child code if present
 				childCode = strings.TrimPrefix(childCode, "\t")
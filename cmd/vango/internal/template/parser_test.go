@@ -85,4 +85,33 @@ package routes
 	if !strings.Contains(code, "func Page(") {
 		t.Error("Generated code missing Page function")
 	}
-}
\ No newline at end of file
+}
+func TestTemplateParser_GenerateCodeMarksStatic(t *testing.T) {
+	source := `//vango:template
+package routes
+
+//vango:props { Title string }
+
+<div>
+	<nav class="top"><a href="/">Home</a></nav>
+	<h1>{{.Title}}</h1>
+	<button @click="save()">Save</button>
+</div>`
+
+	parser := NewTemplateParser("test.vex.go", source)
+	if err := parser.Parse(); err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	code, err := parser.GenerateCode()
+	if err != nil {
+		t.Fatalf("GenerateCode() failed: %v", err)
+	}
+
+	// Only the nav is free of expressions and events, and it is wrapped once
+	if n := strings.Count(code, "vdom.Memo("); n != 1 {
+		t.Fatalf("Generated code has %d memos, want 1:\n%s", n, code)
+	}
+	if !strings.Contains(code, `vdom.Memo([]any{"vex:`) || !strings.Contains(code, `return builder.Nav()`) {
+		t.Errorf("Generated code does not memoize the nav:\n%s", code)
+	}
+}
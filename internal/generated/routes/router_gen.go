@@ -35,21 +35,18 @@ func RegisterRoutes(router *server.Router, liveServer *live.Server, sessionMgr *
 
 	// Server-driven component
 	router.AddRoute("/server_counter", func(ctx server.Ctx) (*vdom.VNode, error) {
-		// Get session
-		sessionID := getSessionID(ctx)
-		session, _ := sessionMgr.GetSession(sessionID)
-		if session == nil {
-			// Create new session if needed
-			// This would normally happen in middleware
-			log.Printf("Warning: No session for request to %s", ctx.Path())
+		// Get or start the session; its cookie binds the live connection
+		session, err := sessionMgr.SessionFromCtx(ctx)
+		if err != nil {
+			return nil, err
 		}
 		// Call server handler
 		vnode, err := routes.ServerCounterPage(ctx)
 		if err != nil {
 			return nil, err
 		}
-		// Inject minimal client for server-driven components
-		vnode = server.InjectServerDrivenClient(vnode, sessionID)
+		// Inject minimal client with the signed live session token
+		vnode = server.InjectServerDrivenClient(vnode, sessionMgr.LiveToken(session.ID))
 		return vnode, nil
 	})
 
@@ -81,14 +78,6 @@ func handle500(ctx server.Ctx) (*vdom.VNode, error) {
 }
 
 // Helper functions
-func getSessionID(ctx server.Ctx) string {
-	// Extract session ID from cookie or header
-	if cookie, err := ctx.Request().Cookie("vango-session"); err == nil {
-		return cookie.Value
-	}
-	return ""
-}
-
 func defaultNotFound(ctx server.Ctx) (*vdom.VNode, error) {
 	return &vdom.VNode{
 		Kind: vdom.KindElement,
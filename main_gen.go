@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	routes "github.com/recera/vango/internal/generated/routes"
 	"github.com/recera/vango/pkg/live"
@@ -27,6 +28,16 @@ func main() {
 	sessionMgr := routes.NewSessionManager(liveServer)
 	sessionMgr.StartCleanupRoutine()
 
+	// Instances behind a load balancer must share the session signing key
+	if key := os.Getenv("VANGO_SESSION_KEY"); key != "" {
+		sessionMgr.SetSigningKey([]byte(key))
+	}
+
+	// Origins other than the app's own that may open live connections
+	if origins := os.Getenv("VANGO_ALLOWED_ORIGINS"); origins != "" {
+		liveServer.SetAllowedOrigins(strings.Split(origins, ",")...)
+	}
+
 	// Create router
 	router := server.NewRouter()
 
@@ -36,8 +47,8 @@ func main() {
 	// Create HTTP mux
 	mux := http.NewServeMux()
 
-	// WebSocket endpoint for live updates
-	mux.HandleFunc("/vango/live/", liveServer.HandleWebSocket)
+	// Live updates endpoint (WebSocket, or SSE + POST fallback)
+	mux.HandleFunc("/vango/live/", liveServer.HandleLive)
 
 	// Serve router table for client-side navigation
 	mux.HandleFunc("/router/table.json", func(w http.ResponseWriter, r *http.Request) {
@@ -18,23 +18,23 @@ func Viewer(data Data, opts *Options) *vdom.VNode {
 	o := opts.withDefaults()
 
 	// Reactive state
-	scale := reactive.CreateState(1.0)
-	offsetX := reactive.CreateState(0.0)
-	offsetY := reactive.CreateState(0.0)
-	dragging := reactive.CreateState(false)
-	dragNode := reactive.CreateState(-1)
-	hoverIdx := reactive.CreateState(-1)
-	selectedIdx := reactive.CreateState(-1)
-	lastX := reactive.CreateState(0.0)
-	lastY := reactive.CreateState(0.0)
-	mouseDownX := reactive.CreateState(0.0)
-	mouseDownY := reactive.CreateState(0.0)
-	didMove := reactive.CreateState(false)
+	scale := reactive.CreateState(nil, 1.0)
+	offsetX := reactive.CreateState(nil, 0.0)
+	offsetY := reactive.CreateState(nil, 0.0)
+	dragging := reactive.CreateState(nil, false)
+	dragNode := reactive.CreateState(nil, -1)
+	hoverIdx := reactive.CreateState(nil, -1)
+	selectedIdx := reactive.CreateState(nil, -1)
+	lastX := reactive.CreateState(nil, 0.0)
+	lastY := reactive.CreateState(nil, 0.0)
+	mouseDownX := reactive.CreateState(nil, 0.0)
+	mouseDownY := reactive.CreateState(nil, 0.0)
+	didMove := reactive.CreateState(nil, false)
 
 	var canvasRef js.Value
 
 	screenToWorld := func(x, y float64) (wx, wy float64) {
-		return (x - offsetX.Get()) / scale.Get(), (y - offsetY.Get()) / scale.Get()
+		return (x - offsetX.Get(nil)) / scale.Get(nil), (y - offsetY.Get(nil)) / scale.Get(nil)
 	}
 
 	onWheel := func(deltaY float64) {
@@ -42,9 +42,9 @@ func Viewer(data Data, opts *Options) *vdom.VNode {
 		if c.IsUndefined() || c.IsNull() {
 			return
 		}
-		mx, my := lastX.Get(), lastY.Get()
+		mx, my := lastX.Get(nil), lastY.Get(nil)
 		factor := 1.0 - math.Max(-0.5, math.Min(0.5, deltaY/500.0))
-		newScale := scale.Get() * factor
+		newScale := scale.Get(nil) * factor
 		if newScale < o.MinScale {
 			newScale = o.MinScale
 		}
@@ -52,9 +52,9 @@ func Viewer(data Data, opts *Options) *vdom.VNode {
 			newScale = o.MaxScale
 		}
 		wx, wy := screenToWorld(mx, my)
-		scale.Set(newScale)
-		offsetX.Set(mx - wx*newScale)
-		offsetY.Set(my - wy*newScale)
+		scale.Set(nil, newScale)
+		offsetX.Set(nil, mx-wx*newScale)
+		offsetY.Set(nil, my-wy*newScale)
 		requestDraw(c)
 	}
 
@@ -63,12 +63,12 @@ func Viewer(data Data, opts *Options) *vdom.VNode {
 		if c.IsUndefined() || c.IsNull() {
 			return
 		}
-		dragging.Set(true)
-		lastX.Set(x)
-		lastY.Set(y)
-		mouseDownX.Set(x)
-		mouseDownY.Set(y)
-		didMove.Set(false)
+		dragging.Set(nil, true)
+		lastX.Set(nil, x)
+		lastY.Set(nil, y)
+		mouseDownX.Set(nil, x)
+		mouseDownY.Set(nil, y)
+		didMove.Set(nil, false)
 		wx, wy := screenToWorld(x, y)
 		picked := -1
 		for i, n := range data.Nodes {
@@ -83,7 +83,7 @@ func Viewer(data Data, opts *Options) *vdom.VNode {
 				break
 			}
 		}
-		dragNode.Set(picked)
+		dragNode.Set(nil, picked)
 	}
 
 	onMouseMove := func(x, y float64) {
@@ -91,10 +91,10 @@ func Viewer(data Data, opts *Options) *vdom.VNode {
 		if c.IsUndefined() || c.IsNull() {
 			return
 		}
-		dx := x - lastX.Get()
-		dy := y - lastY.Get()
-		lastX.Set(x)
-		lastY.Set(y)
+		dx := x - lastX.Get(nil)
+		dy := y - lastY.Get(nil)
+		lastX.Set(nil, x)
+		lastY.Set(nil, y)
 		// Update hover
 		// inline pick for hover (avoid extra symbol)
 		wxh, wyh := screenToWorld(x, y)
@@ -111,43 +111,43 @@ func Viewer(data Data, opts *Options) *vdom.VNode {
 				break
 			}
 		}
-		if idx != hoverIdx.Get() {
-			hoverIdx.Set(idx)
+		if idx != hoverIdx.Get(nil) {
+			hoverIdx.Set(nil, idx)
 			if idx >= 0 && o.OnHoverNode != nil {
 				o.OnHoverNode(data.Nodes[idx].ID)
 			}
 		}
-		if !dragging.Get() {
+		if !dragging.Get(nil) {
 			return
 		}
-		if idx := dragNode.Get(); idx >= 0 && idx < len(data.Nodes) {
+		if idx := dragNode.Get(nil); idx >= 0 && idx < len(data.Nodes) {
 			wx, wy := screenToWorld(x, y)
 			data.Nodes[idx].X = wx
 			data.Nodes[idx].Y = wy
 		} else {
-			offsetX.Set(offsetX.Get() + dx)
-			offsetY.Set(offsetY.Get() + dy)
+			offsetX.Set(nil, offsetX.Get(nil)+dx)
+			offsetY.Set(nil, offsetY.Get(nil)+dy)
 		}
 		// detect movement for click vs drag
-		if !didMove.Get() {
-			dsx := x - mouseDownX.Get()
-			dsy := y - mouseDownY.Get()
+		if !didMove.Get(nil) {
+			dsx := x - mouseDownX.Get(nil)
+			dsy := y - mouseDownY.Get(nil)
 			if dsx*dsx+dsy*dsy > 9 { // 3px threshold
-				didMove.Set(true)
+				didMove.Set(nil, true)
 			}
 		}
 		requestDraw(c)
 	}
 
 	onMouseUp := func() {
-		dragging.Set(false)
-		if idx := dragNode.Get(); idx >= 0 && !didMove.Get() {
-			selectedIdx.Set(idx)
+		dragging.Set(nil, false)
+		if idx := dragNode.Get(nil); idx >= 0 && !didMove.Get(nil) {
+			selectedIdx.Set(nil, idx)
 			if o.OnSelectNode != nil {
 				o.OnSelectNode(data.Nodes[idx].ID)
 			}
 		}
-		dragNode.Set(-1)
+		dragNode.Set(nil, -1)
 	}
 
 	var vx = make([]float64, len(data.Nodes))
@@ -209,7 +209,7 @@ func Viewer(data Data, opts *Options) *vdom.VNode {
 
 		// Update positions
 		for i := range data.Nodes {
-			if dragNode.Get() == i {
+			if dragNode.Get(nil) == i {
 				vx[i] = 0
 				vy[i] = 0
 				continue
@@ -247,10 +247,10 @@ func Viewer(data Data, opts *Options) *vdom.VNode {
 		ctx.Set("fillStyle", o.BackgroundColor)
 		ctx.Call("fillRect", 0, 0, widthCss, heightCss)
 		// Apply viewport transform (CSS px), then world scale
-		ctx.Call("translate", offsetX.Get(), offsetY.Get())
-		ctx.Call("scale", scale.Get(), scale.Get())
+		ctx.Call("translate", offsetX.Get(nil), offsetY.Get(nil))
+		ctx.Call("scale", scale.Get(nil), scale.Get(nil))
 		ctx.Set("strokeStyle", o.EdgeColor)
-		ctx.Set("lineWidth", 1.0/scale.Get())
+		ctx.Set("lineWidth", 1.0/scale.Get(nil))
 		for _, e := range data.Edges {
 			si := indexOfNodeWASM(data.Nodes, e.Source)
 			ti := indexOfNodeWASM(data.Nodes, e.Target)
@@ -272,25 +272,25 @@ func Viewer(data Data, opts *Options) *vdom.VNode {
 			ctx.Call("arc", n.X, n.Y, r, 0, math.Pi*2)
 			ctx.Call("fill")
 			// Highlight selected
-			if selectedIdx.Get() >= 0 && data.Nodes[selectedIdx.Get()].ID == n.ID {
+			if selectedIdx.Get(nil) >= 0 && data.Nodes[selectedIdx.Get(nil)].ID == n.ID {
 				ctx.Set("strokeStyle", "#ffcf33")
-				ctx.Set("lineWidth", 2.0/scale.Get())
+				ctx.Set("lineWidth", 2.0/scale.Get(nil))
 				ctx.Call("beginPath")
-				ctx.Call("arc", n.X, n.Y, r+3/scale.Get(), 0, math.Pi*2)
+				ctx.Call("arc", n.X, n.Y, r+3/scale.Get(nil), 0, math.Pi*2)
 				ctx.Call("stroke")
 			}
 			// Highlight hover
-			if hoverIdx.Get() >= 0 && data.Nodes[hoverIdx.Get()].ID == n.ID {
+			if hoverIdx.Get(nil) >= 0 && data.Nodes[hoverIdx.Get(nil)].ID == n.ID {
 				ctx.Set("strokeStyle", "#9ad0ff")
-				ctx.Set("lineWidth", 1.5/scale.Get())
+				ctx.Set("lineWidth", 1.5/scale.Get(nil))
 				ctx.Call("beginPath")
-				ctx.Call("arc", n.X, n.Y, r+2/scale.Get(), 0, math.Pi*2)
+				ctx.Call("arc", n.X, n.Y, r+2/scale.Get(nil), 0, math.Pi*2)
 				ctx.Call("stroke")
 			}
 			if n.Label != "" {
 				ctx.Set("fillStyle", o.LabelColor)
-				ctx.Set("font", fmt.Sprintf("%fpx sans-serif", 12.0/scale.Get()))
-				ctx.Call("fillText", n.Label, n.X+r+4/scale.Get(), n.Y)
+				ctx.Set("font", fmt.Sprintf("%fpx sans-serif", 12.0/scale.Get(nil)))
+				ctx.Call("fillText", n.Label, n.X+r+4/scale.Get(nil), n.Y)
 			}
 		}
 		ctx.Call("restore")
@@ -304,7 +304,7 @@ func Viewer(data Data, opts *Options) *vdom.VNode {
 		layoutTick(0.016)
 		draw(canvasRef)
 		if o.OnViewportChange != nil {
-			o.OnViewportChange(offsetX.Get(), offsetY.Get(), scale.Get())
+			o.OnViewportChange(offsetX.Get(nil), offsetY.Get(nil), scale.Get(nil))
 		}
 		js.Global().Get("window").Call("requestAnimationFrame", raf)
 		return nil
@@ -355,9 +355,9 @@ func Viewer(data Data, opts *Options) *vdom.VNode {
 					if s <= 0 {
 						s = 1
 					}
-					scale.Set(s)
-					offsetX.Set(w*0.5 - (minx+gw*0.5)*s)
-					offsetY.Set(h*0.5 - (miny+gh*0.5)*s)
+					scale.Set(nil, s)
+					offsetX.Set(nil, w*0.5-(minx+gw*0.5)*s)
+					offsetY.Set(nil, h*0.5-(miny+gh*0.5)*s)
 				}
 				js.Global().Get("window").Call("requestAnimationFrame", raf)
 				return nil
@@ -395,7 +395,7 @@ func Viewer(data Data, opts *Options) *vdom.VNode {
 		Ref(onRef).
 		OnWheel(onWheel).
 		OnMouseDown(onMouseDown).
-		OnMouseMove(func(x, y float64) { lastX.Set(x); lastY.Set(y); onMouseMove(x, y) }).
+		OnMouseMove(func(x, y float64) { lastX.Set(nil, x); lastY.Set(nil, y); onMouseMove(x, y) }).
 		OnMouseUp(func() { onMouseUp() }).
 		OnDblClick(func(x, y float64) { onDblClick(x, y) }).
 		Build()
@@ -0,0 +1,136 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Errors returned when a live connection is not authorized
+var (
+	ErrInvalidSessionToken = errors.New("invalid session token")
+	ErrSessionCookie       = errors.New("session token does not match session cookie")
+	ErrOriginNotAllowed    = errors.New("origin not allowed")
+)
+
+// SessionSigner signs live session IDs, so a client can only connect to a
+// session the server handed it. Tokens have the form "<sessionID>.<mac>",
+// where mac is the unpadded base64url HMAC-SHA256 of the session ID.
+type SessionSigner struct {
+	key []byte
+}
+
+// NewSessionSigner creates a signer with the given secret key. Every server
+// instance that accepts the same sessions needs the same key.
+func NewSessionSigner(key []byte) *SessionSigner {
+	return &SessionSigner{key: append([]byte(nil), key...)}
+}
+
+// Sign returns the token for sessionID
+func (s *SessionSigner) Sign(sessionID string) string {
+	return sessionID + "." + s.mac(sessionID)
+}
+
+// Verify checks a token and returns the session ID it carries
+func (s *SessionSigner) Verify(token string) (string, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i <= 0 {
+		return "", ErrInvalidSessionToken
+	}
+	sessionID, mac := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(mac), []byte(s.mac(sessionID))) {
+		return "", ErrInvalidSessionToken
+	}
+	return sessionID, nil
+}
+
+// mac computes the encoded MAC of a session ID
+func (s *SessionSigner) mac(sessionID string) string {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(sessionID))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// SetAllowedOrigins sets the origins, such as "https://app.example.com",
+// that may open live connections. "*" allows any origin. Without allowed
+// origins only same-origin requests are accepted.
+func (s *Server) SetAllowedOrigins(origins ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allowedOrigins = append([]string(nil), origins...)
+}
+
+// RequireSignedSessions makes the server accept only session tokens signed
+// by signer whose session ID equals the value of the cookie named
+// cookieName. A leaked or guessed session ID is then useless without both
+// the signature and the victim's cookie.
+func (s *Server) RequireSignedSessions(signer *SessionSigner, cookieName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signer = signer
+	s.cookieName = cookieName
+}
+
+// checkOrigin reports whether the request's Origin may connect. Requests
+// without an Origin header come from non-browser clients, which cannot be
+// driven by another site.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	s.mu.RLock()
+	allowed := s.allowedOrigins
+	s.mu.RUnlock()
+
+	if len(allowed) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(strings.TrimRight(a, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize checks the origin and session token of a live request and
+// returns the session ID it may use
+func (s *Server) authorize(r *http.Request) (string, error) {
+	if !s.checkOrigin(r) {
+		return "", ErrOriginNotAllowed
+	}
+
+	token := tokenFromPath(r)
+	if token == "" {
+		return "", ErrInvalidSessionToken
+	}
+
+	s.mu.RLock()
+	signer, cookieName := s.signer, s.cookieName
+	s.mu.RUnlock()
+	if signer == nil {
+		// Unsigned sessions: the token is the session ID
+		return token, nil
+	}
+
+	sessionID, err := signer.Verify(token)
+	if err != nil {
+		return "", err
+	}
+	cookie, err := r.Cookie(cookieName)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(sessionID)) != 1 {
+		return "", ErrSessionCookie
+	}
+	return sessionID, nil
+}
@@ -0,0 +1,81 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionSigner_Verify(t *testing.T) {
+	signer := NewSessionSigner([]byte("secret"))
+	token := signer.Sign("abc123")
+
+	if id, err := signer.Verify(token); err != nil || id != "abc123" {
+		t.Fatalf("Verify(%q) = %q, %v", token, id, err)
+	}
+	for _, bad := range []string{"abc123", "abc124" + token[len("abc123"):], token + "x", ".mac", ""} {
+		if _, err := signer.Verify(bad); err != ErrInvalidSessionToken {
+			t.Errorf("Verify(%q) error = %v, want ErrInvalidSessionToken", bad, err)
+		}
+	}
+	if _, err := NewSessionSigner([]byte("other")).Verify(token); err == nil {
+		t.Error("token verified with a different key")
+	}
+}
+
+func TestServer_Authorize(t *testing.T) {
+	signer := NewSessionSigner([]byte("secret"))
+	srv := NewServer()
+
+	request := func(token, origin, cookie string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://app.test/vango/live/"+token, nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "vango-session", Value: cookie})
+		}
+		return r
+	}
+
+	// Same-origin only by default
+	if _, err := srv.authorize(request("s1", "http://app.test", "")); err != nil {
+		t.Errorf("same-origin request rejected: %v", err)
+	}
+	if _, err := srv.authorize(request("s1", "http://evil.test", "")); err != ErrOriginNotAllowed {
+		t.Errorf("cross-origin error = %v, want ErrOriginNotAllowed", err)
+	}
+	srv.SetAllowedOrigins("http://evil.test")
+	if _, err := srv.authorize(request("s1", "http://evil.test", "")); err != nil {
+		t.Errorf("allowed origin rejected: %v", err)
+	}
+	srv.SetAllowedOrigins()
+
+	srv.RequireSignedSessions(signer, "vango-session")
+	token := signer.Sign("s1")
+	if id, err := srv.authorize(request(token, "http://app.test", "s1")); err != nil || id != "s1" {
+		t.Errorf("authorize(signed) = %q, %v; want s1", id, err)
+	}
+	if _, err := srv.authorize(request("s1", "http://app.test", "s1")); err != ErrInvalidSessionToken {
+		t.Errorf("unsigned token error = %v, want ErrInvalidSessionToken", err)
+	}
+	if _, err := srv.authorize(request(token, "http://app.test", "s2")); err != ErrSessionCookie {
+		t.Errorf("cookie mismatch error = %v, want ErrSessionCookie", err)
+	}
+	if _, err := srv.authorize(request(token, "http://app.test", "")); err != ErrSessionCookie {
+		t.Errorf("missing cookie error = %v, want ErrSessionCookie", err)
+	}
+
+	// The upgrade is refused before any session is created
+	rec := httptest.NewRecorder()
+	srv.HandleLive(rec, request(signer.Sign("s2"), "http://app.test", "s1"))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("HandleLive status = %d, want 403", rec.Code)
+	}
+	if _, exists := srv.GetSession("s2"); exists {
+		t.Error("rejected request created a session")
+	}
+}
@@ -3,8 +3,12 @@
 package live
 
 import (
+	"errors"
 	"syscall/js"
 	"log"
+	"strings"
+
+	"github.com/recera/vango/pkg/vango/vdom"
 )
 
 // Client handles WebSocket communication from the browser
@@ -12,14 +16,29 @@ type Client struct {
 	ws       js.Value
 	url      string
 	onPatch  func([]byte)
+	onPatches func([]vdom.Patch)
+	events   *EventRegistry
 	onReady  func()
 	onError  func(error)
+	onResync func()
+	onCommand func(Command)
+	
+	// Resume state: lastSeq counts the patch and command frames applied so far
+	lastSeq   uint64
+	resuming  bool
+	connected bool
+	closed    bool
+	retries   int
 }
 
+// reconnectDelays is the backoff, in milliseconds, between reconnect attempts
+var reconnectDelays = []int{1000, 2000, 5000, 10000, 30000}
+
 // NewClient creates a new live protocol client
 func NewClient(url string) *Client {
 	return &Client{
-		url: url,
+		url:    url,
+		events: NewEventRegistry(),
 	}
 }
 
@@ -34,6 +53,13 @@ func (c *Client) Connect() error {
 	// Set up event handlers
 	c.ws.Set("onopen", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		log.Println("[Live Client] Connected")
+		
+		// Resume from the last applied frame if this is a reconnect
+		c.resuming = c.connected
+		c.connected = true
+		c.retries = 0
+		c.sendHello()
+		
 		if c.onReady != nil {
 			c.onReady()
 		}
@@ -50,11 +76,39 @@ func (c *Client) Connect() error {
 		bytes := make([]byte, length)
 		js.CopyBytesToGo(bytes, buffer)
 		
+		// Every patch and command frame advances the session sequence
+		if length > 0 && (MessageType(bytes[0]) == FramePatches || MessageType(bytes[0]) == FrameCommand) {
+			c.lastSeq++
+		}
+		
+		// Run focus, scroll, navigation and other commands
+		if length > 0 && MessageType(bytes[0]) == FrameCommand {
+			c.handleCommand(bytes)
+		}
+		
 		// Handle patch data
 		if c.onPatch != nil {
 			c.onPatch(bytes)
 		}
 		
+		// Handle HELLO, RESYNC and event bindings announced by the server
+		if length > 0 && MessageType(bytes[0]) == FrameControl {
+			c.handleControl(bytes)
+		}
+		
+		// Decode patch frames for typed handlers
+		if c.onPatches != nil && length > 0 && MessageType(bytes[0]) == FramePatches {
+			patches, err := DecodePatches(bytes)
+			if err != nil {
+				log.Printf("[Live Client] Failed to decode patches: %v", err)
+				if c.onError != nil {
+					c.onError(err)
+				}
+			} else {
+				c.onPatches(patches)
+			}
+		}
+		
 		return nil
 	}))
 	
@@ -69,13 +123,52 @@ func (c *Client) Connect() error {
 	
 	c.ws.Set("onclose", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		log.Println("[Live Client] Disconnected")
-		// TODO: Implement reconnection logic
+		if !c.closed {
+			c.scheduleReconnect()
+		}
 		return nil
 	}))
 	
 	return nil
 }
 
+// scheduleReconnect reconnects to the same session after a backoff delay
+func (c *Client) scheduleReconnect() {
+	delay := reconnectDelays[len(reconnectDelays)-1]
+	if c.retries < len(reconnectDelays) {
+		delay = reconnectDelays[c.retries]
+	}
+	c.retries++
+	
+	var retry js.Func
+	retry = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		retry.Release()
+		if !c.closed {
+			log.Printf("[Live Client] Reconnecting (attempt %d)", c.retries)
+			c.Connect()
+		}
+		return nil
+	})
+	js.Global().Call("setTimeout", retry, delay)
+}
+
+// sendHello tells the server whether to resume and from which frame
+func (c *Client) sendHello() {
+	buf := []byte{byte(FrameControl)}
+	buf = appendUvarint(buf, uint64(len("HELLO")))
+	buf = append(buf, "HELLO"...)
+	if c.resuming {
+		buf = appendUvarint(buf, 1)
+	} else {
+		buf = appendUvarint(buf, 0)
+	}
+	buf = appendUvarint(buf, c.lastSeq)
+	
+	arrayBuffer := js.Global().Get("Uint8Array").New(len(buf))
+	js.CopyBytesToJS(arrayBuffer, buf)
+	c.ws.Call("send", arrayBuffer)
+}
+
 // SendEvent sends an event to the server
 func (c *Client) SendEvent(evt Event) error {
 	if c.ws.IsNull() || c.ws.IsUndefined() {
@@ -83,7 +176,10 @@ func (c *Client) SendEvent(evt Event) error {
 	}
 	
 	// Encode event
-	data := EncodeEvent(evt)
+	data, err := EncodeEvent(evt)
+	if err != nil {
+		return err
+	}
 	
 	// Convert to Uint8Array
 	arrayBuffer := js.Global().Get("Uint8Array").New(len(data))
@@ -95,8 +191,146 @@ func (c *Client) SendEvent(evt Event) error {
 	return nil
 }
 
+// Emit sends a named event using the wire ID negotiated with the server
+func (c *Client) Emit(name string, nodeID uint32, data map[string]interface{}) error {
+	id, ok := c.events.ID(name)
+	if !ok {
+		return errors.New("event " + name + " has not been declared by the server")
+	}
+	return c.SendEvent(Event{Type: id, NodeID: nodeID, Data: data})
+}
+
+// handleControl processes control frames relevant to the client
+func (c *Client) handleControl(data []byte) {
+	r := &frameReader{data: data, off: 1}
+	msgType, err := r.readString()
+	if err != nil {
+		return
+	}
+	
+	switch msgType {
+	case "HELLO":
+		// A fresh client starts counting from the server's sequence
+		if seq, err := r.readUvarint(); err == nil && !c.resuming {
+			c.lastSeq = seq
+		}
+		return
+		
+	case "RESYNC":
+		// The server could not replay what we missed and re-renders
+		// everything; count from its sequence
+		if seq, err := r.readUvarint(); err == nil {
+			c.lastSeq = seq
+		}
+		log.Println("[Live Client] Server requested resync")
+		if c.onResync != nil {
+			c.onResync()
+		}
+		return
+		
+	case "EVENTS":
+		// Handled below
+		
+	default:
+		return
+	}
+	
+	bindings, err := decodeEventBindings(r)
+	if err != nil {
+		log.Printf("[Live Client] Failed to decode event bindings: %v", err)
+		return
+	}
+	for _, b := range bindings {
+		c.events.Bind(b)
+	}
+}
+
+// handleCommand decodes a command frame and runs it, or hands it to the
+// OnCommand handler
+func (c *Client) handleCommand(data []byte) {
+	cmd, err := DecodeCommand(data)
+	if err != nil {
+		log.Printf("[Live Client] Failed to decode command: %v", err)
+		if c.onError != nil {
+			c.onError(err)
+		}
+		return
+	}
+	if c.onCommand != nil {
+		c.onCommand(cmd)
+		return
+	}
+	RunCommand(cmd)
+}
+
+// RunCommand performs a command in the browser
+func RunCommand(cmd Command) {
+	doc := js.Global().Get("document")
+	switch cmd.Type {
+	case CommandFocus, CommandScroll:
+		el := doc.Call("querySelector", cmd.Target)
+		if el.IsNull() {
+			log.Printf("[Live Client] No element matches %q", cmd.Target)
+			return
+		}
+		if cmd.Type == CommandFocus {
+			el.Call("focus")
+			return
+		}
+		opts := map[string]interface{}{}
+		if cmd.Arg != "" {
+			opts["block"] = cmd.Arg
+		}
+		if cmd.Flag {
+			opts["behavior"] = "smooth"
+		}
+		el.Call("scrollIntoView", opts)
+		
+	case CommandNavigate:
+		if cmd.Flag {
+			js.Global().Get("history").Call("replaceState", nil, "", cmd.Value)
+		} else {
+			js.Global().Get("history").Call("pushState", nil, "", cmd.Value)
+		}
+		
+	case CommandTitle:
+		doc.Set("title", cmd.Value)
+		
+	case CommandCall:
+		// Resolve dotted names against window
+		fn := js.Global()
+		this := js.Global()
+		for _, part := range strings.Split(cmd.Value, ".") {
+			this = fn
+			fn = fn.Get(part)
+			if fn.IsUndefined() || fn.IsNull() {
+				log.Printf("[Live Client] Function %s not found", cmd.Value)
+				return
+			}
+		}
+		args := js.Global().Get("JSON").Call("parse", cmd.Arg)
+		fn.Call("apply", this, args)
+		
+	case CommandDownload:
+		a := doc.Call("createElement", "a")
+		a.Set("href", cmd.Value)
+		a.Set("download", cmd.Arg)
+		doc.Get("body").Call("appendChild", a)
+		a.Call("click")
+		a.Call("remove")
+		
+	case CommandLoad:
+		if cmd.Flag {
+			js.Global().Get("location").Call("replace", cmd.Value)
+		} else {
+			js.Global().Get("location").Call("assign", cmd.Value)
+		}
+	}
+}
+
 // Close closes the WebSocket connection
 func (c *Client) Close() {
+	c.closed = true
 	if !c.ws.IsNull() && !c.ws.IsUndefined() {
 		c.ws.Call("close")
 	}
@@ -107,11 +341,35 @@ func (c *Client) OnPatch(handler func([]byte)) {
 	c.onPatch = handler
 }
 
+// OnPatches sets the handler for decoded patch frames
+func (c *Client) OnPatches(handler func([]vdom.Patch)) {
+	c.onPatches = handler
+}
+
 // OnReady sets the ready handler
 func (c *Client) OnReady(handler func()) {
 	c.onReady = handler
 }
 
+// OnResync sets the handler called when the server could not replay the
+// frames missed during a disconnect. The handler should clear the live DOM;
+// the next patch frames insert the full trees again.
+func (c *Client) OnResync(handler func()) {
+	c.onResync = handler
+}
+
+// OnCommand sets a handler for commands from the server, replacing the
+// default of running them with RunCommand
+func (c *Client) OnCommand(handler func(Command)) {
+	c.onCommand = handler
+}
+
+// LastSeq returns the sequence number of the last patch or command frame
+// received
+func (c *Client) LastSeq() uint64 {
+	return c.lastSeq
+}
+
 // OnError sets the error handler
 func (c *Client) OnError(handler func(error)) {
 	c.onError = handler
@@ -0,0 +1,166 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import "github.com/recera/vango/pkg/vango/vdom"
+
+// DefaultPendingBytes is the default cap on coalesced patches held for a
+// session whose send buffer is full
+const DefaultPendingBytes = 256 << 10 // 256 KiB
+
+// patchSlot identifies what a non-structural patch writes to
+type patchSlot struct {
+	op     vdom.PatchOp
+	nodeID uint32
+	key    string
+}
+
+// patchCoalescer collects patches for a client that cannot keep up. Writes
+// to the same node are merged, last write wins: text replaces text, inner
+// HTML replaces inner HTML, an attribute set or removal replaces earlier
+// ones for that attribute, and event updates replace event updates.
+// Inserts, moves and removals are barriers; patches are never merged across
+// them, because node IDs may be reused by the diff that follows. Not safe
+// for concurrent use; Session.mu guards it.
+type patchCoalescer struct {
+	patches []vdom.Patch
+	slots   map[patchSlot]int // index into patches since the last barrier
+	bytes   int
+}
+
+// add merges patches into the pending batch
+func (c *patchCoalescer) add(patches []vdom.Patch) {
+	if c.slots == nil {
+		c.slots = make(map[patchSlot]int)
+	}
+
+	for _, patch := range patches {
+		switch patch.Op {
+		case vdom.OpReplaceText, vdom.OpSetHTML, vdom.OpUpdateEvents:
+			c.put(patchSlot{op: patch.Op, nodeID: patch.NodeID}, patch)
+
+		case vdom.OpSetAttribute, vdom.OpRemoveAttribute:
+			// A removal supersedes a set of the same attribute and vice versa
+			c.put(patchSlot{op: vdom.OpSetAttribute, nodeID: patch.NodeID, key: patch.Key}, patch)
+
+		case vdom.OpRemoveNode:
+			// Updates to a node that is going away are moot
+			c.dropNode(patch.NodeID)
+			c.barrier(patch)
+
+		default:
+			c.barrier(patch)
+		}
+	}
+}
+
+// put stores patch in its slot, overwriting an earlier write in place.
+// Only patches for other slots sit between the two, so the order of
+// effects is unchanged.
+func (c *patchCoalescer) put(slot patchSlot, patch vdom.Patch) {
+	if i, ok := c.slots[slot]; ok {
+		c.bytes += patchSize(patch) - patchSize(c.patches[i])
+		c.patches[i] = patch
+		return
+	}
+	c.slots[slot] = len(c.patches)
+	c.patches = append(c.patches, patch)
+	c.bytes += patchSize(patch)
+}
+
+// barrier appends a structural patch and stops merging across it
+func (c *patchCoalescer) barrier(patch vdom.Patch) {
+	c.patches = append(c.patches, patch)
+	c.bytes += patchSize(patch)
+	for slot := range c.slots {
+		delete(c.slots, slot)
+	}
+}
+
+// dropNode removes mergeable patches for nodeID since the last barrier
+func (c *patchCoalescer) dropNode(nodeID uint32) {
+	dropped := false
+	for slot, i := range c.slots {
+		if slot.nodeID == nodeID {
+			c.bytes -= patchSize(c.patches[i])
+			c.patches[i].Op = opDropped
+			dropped = true
+		}
+	}
+	if !dropped {
+		return
+	}
+
+	kept := c.patches[:0]
+	for _, p := range c.patches {
+		if p.Op != opDropped {
+			kept = append(kept, p)
+		}
+	}
+	c.patches = kept
+
+	// Indices shifted; rebuild the slots after the last barrier
+	for slot := range c.slots {
+		delete(c.slots, slot)
+	}
+	for i := len(c.patches) - 1; i >= 0; i-- {
+		p := c.patches[i]
+		switch p.Op {
+		case vdom.OpReplaceText, vdom.OpSetHTML, vdom.OpUpdateEvents:
+			c.slots[patchSlot{op: p.Op, nodeID: p.NodeID}] = i
+		case vdom.OpSetAttribute, vdom.OpRemoveAttribute:
+			c.slots[patchSlot{op: vdom.OpSetAttribute, nodeID: p.NodeID, key: p.Key}] = i
+		default:
+			return
+		}
+	}
+}
+
+// opDropped marks patches removed by dropNode; it is never encoded
+const opDropped vdom.PatchOp = 0xFF
+
+// take returns the pending batch and empties the coalescer
+func (c *patchCoalescer) take() []vdom.Patch {
+	patches := c.patches
+	c.reset()
+	return patches
+}
+
+// reset drops all pending patches
+func (c *patchCoalescer) reset() {
+	c.patches = nil
+	c.bytes = 0
+	for slot := range c.slots {
+		delete(c.slots, slot)
+	}
+}
+
+// empty reports whether no patches are pending
+func (c *patchCoalescer) empty() bool {
+	return len(c.patches) == 0
+}
+
+// patchSize estimates the encoded size of a patch
+func patchSize(patch vdom.Patch) int {
+	size := 8 + len(patch.Key) + len(patch.Value)
+	if patch.Node != nil {
+		size += vnodeSize(patch.Node)
+	}
+	return size
+}
+
+// vnodeSize estimates the encoded size of a subtree
+func vnodeSize(node *vdom.VNode) int {
+	size := 8 + len(node.Tag) + len(node.Text) + len(node.Key) + len(node.PortalTarget)
+	for name, value := range node.Props {
+		size += len(name) + 8
+		if s, ok := value.(string); ok {
+			size += len(s)
+		}
+	}
+	for i := range node.Kids {
+		size += vnodeSize(&node.Kids[i])
+	}
+	return size
+}
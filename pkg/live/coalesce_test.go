@@ -0,0 +1,102 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"testing"
+
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+func TestPatchCoalescer_LastWriteWins(t *testing.T) {
+	var c patchCoalescer
+	c.add([]vdom.Patch{
+		{Op: vdom.OpReplaceText, NodeID: 1, Value: "1"},
+		{Op: vdom.OpSetAttribute, NodeID: 2, Key: "class", Value: "a"},
+		{Op: vdom.OpSetAttribute, NodeID: 2, Key: "title", Value: "t"},
+	})
+	c.add([]vdom.Patch{
+		{Op: vdom.OpReplaceText, NodeID: 1, Value: "2"},
+		{Op: vdom.OpRemoveAttribute, NodeID: 2, Key: "class"},
+		{Op: vdom.OpReplaceText, NodeID: 3, Value: "gone"},
+		{Op: vdom.OpRemoveNode, NodeID: 3},
+		// After a structural patch nothing merges with earlier writes
+		{Op: vdom.OpReplaceText, NodeID: 1, Value: "3"},
+	})
+
+	want := []vdom.Patch{
+		{Op: vdom.OpReplaceText, NodeID: 1, Value: "2"},
+		{Op: vdom.OpRemoveAttribute, NodeID: 2, Key: "class"},
+		{Op: vdom.OpSetAttribute, NodeID: 2, Key: "title", Value: "t"},
+		{Op: vdom.OpRemoveNode, NodeID: 3},
+		{Op: vdom.OpReplaceText, NodeID: 1, Value: "3"},
+	}
+	got := c.take()
+	if len(got) != len(want) {
+		t.Fatalf("got %d patches %+v, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i].Op != want[i].Op || got[i].NodeID != want[i].NodeID || got[i].Key != want[i].Key || got[i].Value != want[i].Value {
+			t.Errorf("patch %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if !c.empty() || c.bytes != 0 {
+		t.Errorf("take() should empty the coalescer, bytes = %d", c.bytes)
+	}
+}
+
+func TestSession_CoalescesWhenSendBufferFull(t *testing.T) {
+	s := newTestSession(DefaultReplayFrames)
+	s.resume(false, 0)
+
+	// Fill the send buffer, then keep rendering
+	for i := 0; i < cap(s.sendChan)+3; i++ {
+		sendText(t, s, string(rune('a'+i)))
+	}
+	if s.pending.empty() {
+		t.Fatal("patches beyond the send buffer should be pending")
+	}
+
+	// The writer drains the buffer and flushes the merged batch
+	got := drainTexts(t, s)
+	s.flushPending()
+	got = append(got, drainTexts(t, s)...)
+	if want := []string{"a", "b", "c", "d", "g"}; len(got) != len(want) || got[4] != "g" {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if s.lastSeq != 5 {
+		t.Errorf("lastSeq = %d, want 5 frames", s.lastSeq)
+	}
+}
+
+func TestSession_ResyncsWhenPendingOverflows(t *testing.T) {
+	s := newTestSession(DefaultReplayFrames)
+	s.maxPending = 64
+	s.resume(false, 0)
+
+	for i := 0; i < cap(s.sendChan); i++ {
+		sendText(t, s, "x")
+	}
+	// Inserts are barriers, so they pile up until the cap is hit
+	for i := 0; i < 4; i++ {
+		patch := vdom.Patch{Op: vdom.OpInsertNode, NodeID: 10, ParentID: 1, Node: vdom.NewText("row")}
+		if err := s.SendPatches([]vdom.Patch{patch}); err != nil {
+			t.Fatalf("SendPatches() error = %v", err)
+		}
+	}
+	if !s.needsResync || !s.pending.empty() {
+		t.Fatalf("needsResync = %v, pending = %d; want a forced resync", s.needsResync, len(s.pending.patches))
+	}
+
+	drainTexts(t, s)
+	s.flushPending()
+	frame := <-s.sendChan
+	r := &frameReader{data: frame, off: 1}
+	if msgType, _ := r.readString(); msgType != "RESYNC" {
+		t.Fatalf("got frame %x, want RESYNC", frame)
+	}
+	if s.needsResync {
+		t.Error("needsResync should clear once RESYNC is sent")
+	}
+}
@@ -6,7 +6,11 @@ package live
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"sort"
+
+	"github.com/recera/vango/pkg/vango/vdom"
 )
 
 // Encoder handles encoding of live protocol messages
@@ -42,6 +46,111 @@ func (e *Encoder) WriteBytes(b []byte) error {
 	return err
 }
 
+// WriteVNode writes a VNode subtree for an OpInsertNode patch.
+// Every node carries the ID the client must assign to it; IDs are handed out
+// in pre-order starting at id, and a node that is already mounted must have
+// been mounted with that ID. Returns the next unused ID.
+func (e *Encoder) WriteVNode(node *vdom.VNode, id uint32) (uint32, error) {
+	if node.ID != 0 && node.ID != id {
+		return id, fmt.Errorf("node mounted as %d encoded as %d", node.ID, id)
+	}
+	// Components reach the client as fragments of their output
+	kind := node.Kind
+	if kind == vdom.KindComponent {
+		kind = vdom.KindFragment
+	}
+	if err := e.WriteBytes([]byte{byte(kind)}); err != nil {
+		return id, err
+	}
+	if err := e.WriteUvarint(uint64(id)); err != nil {
+		return id, err
+	}
+	next := id + 1
+
+	switch node.Kind {
+	case vdom.KindText:
+		return next, e.WriteString(node.Text)
+
+	case vdom.KindElement, vdom.KindRaw:
+		if err := e.WriteString(node.Tag); err != nil {
+			return next, err
+		}
+		if err := e.WriteString(node.GetKey()); err != nil {
+			return next, err
+		}
+
+		// Split props into attributes and event listeners, sorted so
+		// the same tree always produces the same bytes
+		var attrs, events []string
+		for key, value := range node.Props {
+			if key == "key" || key == "ref" || value == nil {
+				continue
+			}
+			if len(key) > 2 && key[0] == 'o' && key[1] == 'n' {
+				events = append(events, key)
+			} else {
+				attrs = append(attrs, key)
+			}
+		}
+		sort.Strings(attrs)
+		sort.Strings(events)
+
+		if err := e.WriteUvarint(uint64(len(attrs))); err != nil {
+			return next, err
+		}
+		for _, key := range attrs {
+			if err := e.WriteString(key); err != nil {
+				return next, err
+			}
+			if err := e.WriteString(vdom.PropString(node.Props[key])); err != nil {
+				return next, err
+			}
+		}
+
+		if err := e.WriteUvarint(uint64(len(events))); err != nil {
+			return next, err
+		}
+		for _, key := range events {
+			if err := e.WriteString(key); err != nil {
+				return next, err
+			}
+			_, options := vdom.ListenerOf(node.Props[key])
+			if err := e.WriteBytes([]byte{byte(options)}); err != nil {
+				return next, err
+			}
+		}
+
+		if node.Kind == vdom.KindRaw {
+			if err := e.WriteString(node.Text); err != nil {
+				return next, err
+			}
+		}
+
+	case vdom.KindFragment, vdom.KindComponent:
+		// Fragments only carry children
+
+	case vdom.KindPortal:
+		if err := e.WriteString(node.PortalTarget); err != nil {
+			return next, err
+		}
+
+	default:
+		return next, fmt.Errorf("cannot encode node kind %d", node.Kind)
+	}
+
+	if err := e.WriteUvarint(uint64(len(node.Kids))); err != nil {
+		return next, err
+	}
+	for i := range node.Kids {
+		var err error
+		if next, err = e.WriteVNode(&node.Kids[i], next); err != nil {
+			return next, err
+		}
+	}
+
+	return next, nil
+}
+
 // Decoder handles decoding of live protocol messages
 type Decoder struct {
 	r   io.Reader
@@ -104,21 +213,20 @@ func (d *Decoder) ReadBytes(n int) ([]byte, error) {
 }
 
 // EncodeEvent encodes an event to binary format
-func EncodeEvent(evt Event) []byte {
+func EncodeEvent(evt Event) ([]byte, error) {
 	var buf []byte
 	
 	// Frame type
 	buf = append(buf, byte(FrameEvent))
 	
-	// Event type
-	buf = append(buf, byte(evt.Type))
+	// Event type (wire ID, see EventRegistry)
+	buf = appendUvarint(buf, uint64(evt.Type))
 	
 	// Node ID
 	buf = appendUvarint(buf, uint64(evt.NodeID))
 	
-	// TODO: Encode additional event data
-	
-	return buf
+	// Typed event payload (input values, keys, pointer data, form fields)
+	return appendEventData(buf, evt.Data)
 }
 
 // DecodeEvent decodes an event from binary format
@@ -132,18 +240,29 @@ func DecodeEvent(data []byte) (*Event, error) {
 		return nil, errors.New("not an event frame")
 	}
 	
+	// Decode event type
+	r := &frameReader{data: data, off: 1}
+	eventType, err := r.readUvarint()
+	if err != nil {
+		return nil, errors.New("failed to decode event type")
+	}
 	evt := &Event{
-		Type: EventType(data[1]),
+		Type: EventType(eventType),
 	}
 	
 	// Decode node ID
-	nodeID, n := binary.Uvarint(data[2:])
-	if n <= 0 {
+	nodeID, err := r.readUvarint()
+	if err != nil {
 		return nil, errors.New("failed to decode node ID")
 	}
 	evt.NodeID = uint32(nodeID)
 	
-	// TODO: Decode additional event data
+	// Decode the payload if the client sent one
+	if r.remaining() > 0 {
+		if evt.Data, err = decodeEventData(r); err != nil {
+			return nil, fmt.Errorf("failed to decode event data: %w", err)
+		}
+	}
 	
 	return evt, nil
 }
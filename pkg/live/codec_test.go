@@ -0,0 +1,255 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"testing"
+
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+func TestEncodePatches_InsertSubtreeRoundTrip(t *testing.T) {
+	row := vdom.NewElement("li", vdom.Props{"key": "row-3", "class": "row", "onClick": func() {}},
+		vdom.NewElement("span", vdom.Props{"class": "label"}, vdom.NewText("Row 3")),
+		vdom.NewText("!"),
+	)
+
+	patches := []vdom.Patch{
+		{Op: vdom.OpReplaceText, NodeID: 2, Value: "3 rows"},
+		{Op: vdom.OpInsertNode, NodeID: 10, ParentID: 4, BeforeID: 7, Node: row},
+		{Op: vdom.OpSetAttribute, NodeID: 4, Key: "data-count", Value: "3"},
+	}
+
+	data, err := EncodePatches(patches)
+	if err != nil {
+		t.Fatalf("EncodePatches() error = %v", err)
+	}
+
+	decoded, err := DecodePatches(data)
+	if err != nil {
+		t.Fatalf("DecodePatches() error = %v", err)
+	}
+	if len(decoded) != len(patches) {
+		t.Fatalf("decoded %d patches, want %d", len(decoded), len(patches))
+	}
+
+	insert := decoded[1]
+	if insert.Op != vdom.OpInsertNode || insert.NodeID != 10 || insert.ParentID != 4 || insert.BeforeID != 7 {
+		t.Fatalf("insert header = %v", insert)
+	}
+
+	node := insert.Node
+	if node == nil || node.Tag != "li" || node.Key != "row-3" {
+		t.Fatalf("decoded root = %+v", node)
+	}
+	if node.Props["class"] != "row" {
+		t.Errorf("class = %v, want row", node.Props["class"])
+	}
+	if !node.HasFlag(vdom.FlagHasEvents) || node.Props["onClick"] == nil {
+		t.Errorf("onClick listener not decoded: %+v", node.Props)
+	}
+	if _, ok := node.Props["key"]; ok {
+		t.Errorf("key should travel as VNode.Key, not as an attribute")
+	}
+	if len(node.Kids) != 2 || node.Kids[0].Tag != "span" || node.Kids[0].Kids[0].Text != "Row 3" || node.Kids[1].Text != "!" {
+		t.Errorf("decoded children = %+v", node.Kids)
+	}
+
+	if decoded[2].Key != "data-count" || decoded[2].Value != "3" {
+		t.Errorf("patch after subtree = %v", decoded[2])
+	}
+}
+
+func TestEncodePatches_DiffInsertIDsAgree(t *testing.T) {
+	prev := vdom.NewElement("ul", nil)
+	next := vdom.NewElement("ul", nil,
+		vdom.NewElement("li", nil, vdom.NewText("a")),
+		vdom.NewElement("li", nil, vdom.NewText("b")),
+	)
+
+	data, err := EncodePatches(vdom.Diff(prev, next))
+	if err != nil {
+		t.Fatalf("EncodePatches() error = %v", err)
+	}
+
+	decoded, err := DecodePatches(data)
+	if err != nil {
+		t.Fatalf("DecodePatches() error = %v", err)
+	}
+	// The first row's text node takes the ID after it, so the second row
+	// must start two IDs later
+	if len(decoded) != 2 || decoded[1].NodeID != decoded[0].NodeID+2 {
+		t.Errorf("sibling inserts overlap: %v", decoded)
+	}
+}
+
+func TestDecodePatches_Truncated(t *testing.T) {
+	data, err := EncodePatches([]vdom.Patch{
+		{Op: vdom.OpInsertNode, NodeID: 1, Node: vdom.NewElement("div", nil, vdom.NewText("hi"))},
+	})
+	if err != nil {
+		t.Fatalf("EncodePatches() error = %v", err)
+	}
+
+	for i := 1; i < len(data); i++ {
+		if _, err := DecodePatches(data[:i]); err == nil {
+			t.Errorf("DecodePatches(data[:%d]) succeeded on a truncated frame", i)
+		}
+	}
+}
+
+func TestEncodeEvent_PayloadRoundTrip(t *testing.T) {
+	evt := Event{
+		Type:   EventSubmit,
+		NodeID: 300,
+		Data: map[string]interface{}{
+			"value":    "héllo",
+			"checked":  true,
+			"keyCode":  13,
+			"clientX":  12.5,
+			"shiftKey": false,
+			"form":     map[string]string{"email": "a@b.c", "qty": "2"},
+		},
+	}
+
+	data, err := EncodeEvent(evt)
+	if err != nil {
+		t.Fatalf("EncodeEvent() error = %v", err)
+	}
+
+	decoded, err := DecodeEvent(data)
+	if err != nil {
+		t.Fatalf("DecodeEvent() error = %v", err)
+	}
+	if decoded.Type != EventSubmit || decoded.NodeID != 300 {
+		t.Fatalf("decoded header = %+v", decoded)
+	}
+
+	v := decoded.ToVangoEvent("submit")
+	if v.Type != "submit" || v.Target.Value != "héllo" || !v.Target.Checked || v.KeyCode != 13 || v.ClientX != 12.5 || v.ShiftKey {
+		t.Errorf("ToVangoEvent() = %+v", v)
+	}
+	if v.Form["email"] != "a@b.c" || v.Form["qty"] != "2" {
+		t.Errorf("form fields = %v", v.Form)
+	}
+}
+
+func TestDecodeEvent_WithoutPayload(t *testing.T) {
+	// Frames from clients that send no payload stay valid
+	decoded, err := DecodeEvent([]byte{byte(FrameEvent), byte(EventClick), 0x07})
+	if err != nil {
+		t.Fatalf("DecodeEvent() error = %v", err)
+	}
+	if decoded.NodeID != 7 || decoded.Data != nil {
+		t.Errorf("decoded = %+v", decoded)
+	}
+}
+
+func TestEncodeEvent_UnsupportedType(t *testing.T) {
+	_, err := EncodeEvent(Event{Type: EventClick, Data: map[string]interface{}{"bad": []int{1}}})
+	if err == nil {
+		t.Error("EncodeEvent() accepted an unsupported payload type")
+	}
+}
+
+func TestEncodeEvent_LargeEventType(t *testing.T) {
+	data, err := EncodeEvent(Event{Type: 300, NodeID: 1})
+	if err != nil {
+		t.Fatalf("EncodeEvent() error = %v", err)
+	}
+	decoded, err := DecodeEvent(data)
+	if err != nil {
+		t.Fatalf("DecodeEvent() error = %v", err)
+	}
+	if decoded.Type != 300 || decoded.NodeID != 1 {
+		t.Errorf("decoded = %+v", decoded)
+	}
+}
+
+func TestSession_HandlerSwapsStayOffTheWire(t *testing.T) {
+	s := newTestSession(DefaultReplayFrames)
+	s.resume(false, 0)
+
+	swap := vdom.Patch{Op: vdom.OpSetHandler, NodeID: 3, Key: "onClick", Handler: func() {}}
+	if err := s.SendPatches([]vdom.Patch{swap}); err != nil {
+		t.Fatalf("SendPatches() error = %v", err)
+	}
+	if s.lastSeq != 0 || len(s.sendChan) != 0 {
+		t.Fatalf("handler swap produced a frame (lastSeq %d)", s.lastSeq)
+	}
+
+	sendText(t, s, "x")
+	if err := s.SendPatches([]vdom.Patch{swap, {Op: vdom.OpReplaceText, NodeID: 1, Value: "y"}}); err != nil {
+		t.Fatalf("SendPatches() error = %v", err)
+	}
+	if got := drainTexts(t, s); len(got) != 2 || got[1] != "y" {
+		t.Errorf("frames = %v, want [x y]", got)
+	}
+}
+
+func TestEncodePatches_EventListeners(t *testing.T) {
+	row := vdom.NewElement("li", vdom.Props{
+		"onClick":     "select",
+		"onTouchMove": vdom.WithOptions("drag", vdom.EventPassive|vdom.EventCapture),
+	})
+	patches := []vdom.Patch{
+		{Op: vdom.OpInsertNode, NodeID: 3, ParentID: 1, Node: row},
+		{Op: vdom.OpUpdateEvents, NodeID: 3, Events: vdom.Listeners(vdom.Props{
+			"onPointerDown": vdom.WithOptions("press", vdom.EventPreventDefault),
+			"onrow-moved":   "moved",
+		})},
+	}
+
+	data, err := EncodePatches(patches)
+	if err != nil {
+		t.Fatalf("EncodePatches() error = %v", err)
+	}
+	decoded, err := DecodePatches(data)
+	if err != nil {
+		t.Fatalf("DecodePatches() error = %v", err)
+	}
+
+	props := decoded[0].Node.Props
+	if props["onClick"] != "onClick" {
+		t.Errorf("onClick = %v, want the bare prop name", props["onClick"])
+	}
+	if _, options := vdom.ListenerOf(props["onTouchMove"]); options != vdom.EventPassive|vdom.EventCapture {
+		t.Errorf("onTouchMove options = %v", options)
+	}
+
+	events := decoded[1].Events
+	if len(events) != 2 {
+		t.Fatalf("UpdateEvents = %v, want %v", events, patches[1].Events)
+	}
+	for i, l := range events {
+		if l != patches[1].Events[i] {
+			t.Errorf("listener %d = %v, want %v", i, l, patches[1].Events[i])
+		}
+	}
+}
+
+func TestEncodePatches_RawHTML(t *testing.T) {
+	raw := vdom.NewTrustedHTML("div", vdom.Props{"class": "post", "onClick": "open"}, "<p>body</p>")
+	patches := []vdom.Patch{
+		{Op: vdom.OpInsertNode, NodeID: 4, ParentID: 1, Node: raw},
+		{Op: vdom.OpSetHTML, NodeID: 4, Value: "<p>edited</p>"},
+	}
+
+	data, err := EncodePatches(patches)
+	if err != nil {
+		t.Fatalf("EncodePatches() error = %v", err)
+	}
+	decoded, err := DecodePatches(data)
+	if err != nil {
+		t.Fatalf("DecodePatches() error = %v", err)
+	}
+
+	node := decoded[0].Node
+	if node.Kind != vdom.KindRaw || node.Tag != "div" || node.Text != "<p>body</p>" || node.Props["class"] != "post" || node.Props["onClick"] == nil {
+		t.Errorf("decoded node = %+v, want the raw element", node)
+	}
+	if decoded[1].Op != vdom.OpSetHTML || decoded[1].NodeID != 4 || decoded[1].Value != "<p>edited</p>" {
+		t.Errorf("decoded patch = %v, want %v", decoded[1], patches[1])
+	}
+}
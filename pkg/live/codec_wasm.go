@@ -6,21 +6,20 @@ package live
 import "encoding/binary"
 
 // EncodeEvent encodes an event to binary format (WASM version)
-func EncodeEvent(evt Event) []byte {
+func EncodeEvent(evt Event) ([]byte, error) {
 	var buf []byte
 	
 	// Frame type
 	buf = append(buf, byte(FrameEvent))
 	
-	// Event type
-	buf = append(buf, byte(evt.Type))
+	// Event type (wire ID, see EventRegistry)
+	buf = appendUvarint(buf, uint64(evt.Type))
 	
 	// Node ID
 	buf = appendUvarint(buf, uint64(evt.NodeID))
 	
-	// TODO: Encode additional event data
-	
-	return buf
+	// Typed event payload (input values, keys, pointer data, form fields)
+	return appendEventData(buf, evt.Data)
 }
 
 // Helper function to append uvarint to byte slice
@@ -0,0 +1,76 @@
+package live
+
+import "fmt"
+
+// CommandType identifies a client command
+type CommandType uint8
+
+// Client commands. Elements are addressed by CSS selector.
+const (
+	CommandFocus    CommandType = 0x01 // focus Target
+	CommandScroll   CommandType = 0x02 // scroll Target into view; Arg is the block alignment, Flag scrolls smoothly
+	CommandNavigate CommandType = 0x03 // show Value in the address bar without loading it; Flag replaces the history entry instead of pushing one
+	CommandTitle    CommandType = 0x04 // set document.title to Value
+	CommandCall     CommandType = 0x05 // call the global function named Value with the JSON array Arg
+	CommandDownload CommandType = 0x06 // download Value, saving it as Arg
+	CommandLoad     CommandType = 0x07 // load Value as a new page; Flag replaces the history entry
+)
+
+// Command asks the client to do something patches cannot express. It
+// travels in a FrameCommand frame, one command per frame:
+//
+//	[0x03][type][target][value][arg][flag]
+//
+// with strings length-prefixed and flag a single 0/1 byte. Command frames
+// share the patch frame sequence, so they run in order with the patches
+// around them and are replayed to resuming clients.
+type Command struct {
+	Type   CommandType
+	Target string // CSS selector of the element, for focus and scroll
+	Value  string // URL, title or function name
+	Arg    string // scroll alignment, download file name or JSON call arguments
+	Flag   bool   // smooth scroll, or replace on navigate and load
+}
+
+// EncodeCommand encodes a command frame
+func EncodeCommand(cmd Command) ([]byte, error) {
+	if cmd.Type < CommandFocus || cmd.Type > CommandLoad {
+		return nil, fmt.Errorf("unknown command type %d", cmd.Type)
+	}
+
+	buf := []byte{byte(FrameCommand), byte(cmd.Type)}
+	for _, s := range []string{cmd.Target, cmd.Value, cmd.Arg} {
+		buf = appendUvarint(buf, uint64(len(s)))
+		buf = append(buf, s...)
+	}
+	if cmd.Flag {
+		return append(buf, 1), nil
+	}
+	return append(buf, 0), nil
+}
+
+// DecodeCommand decodes a command frame
+func DecodeCommand(data []byte) (Command, error) {
+	if len(data) == 0 || MessageType(data[0]) != FrameCommand {
+		return Command{}, fmt.Errorf("not a command frame")
+	}
+
+	r := &frameReader{data: data, off: 1}
+	var cmd Command
+	t, err := r.readByte()
+	if err != nil {
+		return Command{}, err
+	}
+	cmd.Type = CommandType(t)
+	for _, s := range []*string{&cmd.Target, &cmd.Value, &cmd.Arg} {
+		if *s, err = r.readString(); err != nil {
+			return Command{}, fmt.Errorf("failed to read command: %w", err)
+		}
+	}
+	flag, err := r.readByte()
+	if err != nil {
+		return Command{}, fmt.Errorf("failed to read command flag: %w", err)
+	}
+	cmd.Flag = flag != 0
+	return cmd, nil
+}
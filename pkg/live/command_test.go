@@ -0,0 +1,131 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCommand_RoundTrip(t *testing.T) {
+	cmds := []Command{
+		{Type: CommandFocus, Target: "#email"},
+		{Type: CommandScroll, Target: "li[data-id='7']", Arg: "center", Flag: true},
+		{Type: CommandNavigate, Value: "/done?ok=1", Flag: true},
+		{Type: CommandTitle, Value: "3 new messages"},
+		{Type: CommandCall, Value: "app.notify", Arg: `["saved",2]`},
+		{Type: CommandDownload, Value: "/export.csv", Arg: "report.csv"},
+		{Type: CommandLoad, Value: "/login", Flag: true},
+	}
+	for _, cmd := range cmds {
+		frame, err := EncodeCommand(cmd)
+		if err != nil {
+			t.Fatalf("EncodeCommand(%+v) error = %v", cmd, err)
+		}
+		got, err := DecodeCommand(frame)
+		if err != nil {
+			t.Fatalf("DecodeCommand() error = %v", err)
+		}
+		if got != cmd {
+			t.Errorf("round trip: got %+v, want %+v", got, cmd)
+		}
+	}
+
+	if _, err := EncodeCommand(Command{Type: 0x7F}); err == nil {
+		t.Error("EncodeCommand accepted an unknown command type")
+	}
+}
+
+func TestSession_CommandsFollowPendingPatches(t *testing.T) {
+	s := newTestSession(DefaultReplayFrames)
+	s.resume(false, 0)
+
+	// Fill the send buffer so the next patches are coalesced
+	for i := 0; i < cap(s.sendChan)+1; i++ {
+		sendText(t, s, "x")
+	}
+	if err := s.Focus("#name"); err != nil {
+		t.Fatalf("Focus() error = %v", err)
+	}
+	if len(s.commands) != 1 {
+		t.Fatalf("%d commands waiting, want 1", len(s.commands))
+	}
+
+	for i := 0; i < cap(s.sendChan); i++ {
+		<-s.sendChan
+	}
+	s.flushPending()
+
+	// The coalesced patches go first, then the command
+	if frame := <-s.sendChan; MessageType(frame[0]) != FramePatches {
+		t.Fatalf("got frame type %d, want patches", frame[0])
+	}
+	frame := <-s.sendChan
+	cmd, err := DecodeCommand(frame)
+	if err != nil || cmd.Type != CommandFocus || cmd.Target != "#name" {
+		t.Fatalf("got command %+v (%v), want focus #name", cmd, err)
+	}
+
+	// Commands are sequenced and replayable like patch frames
+	if s.lastSeq != 6 {
+		t.Errorf("lastSeq = %d, want 6", s.lastSeq)
+	}
+	frames, ok := s.replay.since(5, s.lastSeq)
+	if !ok || len(frames) != 1 || !bytes.Equal(frames[0], frame) {
+		t.Errorf("replay after seq 5 = %x, want the command frame", frames)
+	}
+}
+
+func TestSession_PatchesWaitForCommands(t *testing.T) {
+	s := newTestSession(DefaultReplayFrames)
+	s.resume(false, 0)
+
+	// A command finding the send buffer full waits with nothing pending
+	for i := 0; i < cap(s.sendChan); i++ {
+		sendText(t, s, "x")
+	}
+	if err := s.Focus("#name"); err != nil {
+		t.Fatalf("Focus() error = %v", err)
+	}
+	<-s.sendChan
+
+	// Patches rendered after it, and the command after those, keep their turn
+	sendText(t, s, "late")
+	if err := s.Focus("#next"); err != nil {
+		t.Fatalf("Focus() error = %v", err)
+	}
+	sendText(t, s, "last")
+
+	var got []string
+	for len(got) < cap(s.sendChan)+3 {
+		s.flushPending()
+		select {
+		case frame := <-s.sendChan:
+			if MessageType(frame[0]) == FramePatches {
+				patches, err := DecodePatches(frame)
+				if err != nil {
+					t.Fatalf("DecodePatches() error = %v", err)
+				}
+				got = append(got, patches[0].Value)
+			} else {
+				cmd, err := DecodeCommand(frame)
+				if err != nil {
+					t.Fatalf("DecodeCommand() error = %v", err)
+				}
+				got = append(got, cmd.Target)
+			}
+		default:
+			t.Fatalf("send buffer empty after %v", got)
+		}
+	}
+
+	want := []string{"x", "x", "x", "#name", "late", "#next", "last"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("frames sent in order %v, want %v", got, want)
+	}
+	if s.lastSeq != 8 {
+		t.Errorf("lastSeq = %d, want 8", s.lastSeq)
+	}
+}
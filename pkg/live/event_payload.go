@@ -0,0 +1,193 @@
+package live
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Event payload value tags.
+// The payload follows the node ID of a FrameEvent:
+//
+//	[payloadLen varint][fieldCount varint]{[name string][tag u8][value]}*
+//
+// Frames without a payload stay valid, so older clients keep working.
+const (
+	payloadNull   byte = 0x00 // no value
+	payloadBool   byte = 0x01 // one byte, 0 or 1
+	payloadInt    byte = 0x02 // zigzag varint
+	payloadFloat  byte = 0x03 // float64, little-endian
+	payloadString byte = 0x04 // length-prefixed string
+	payloadMap    byte = 0x05 // [count varint]{[key string][value string]}* (form fields)
+)
+
+// appendEventData appends the length-prefixed payload for data.
+// Nothing is written when data is empty.
+func appendEventData(buf []byte, data map[string]interface{}) ([]byte, error) {
+	if len(data) == 0 {
+		return buf, nil
+	}
+
+	// Sort field names so identical events encode identically
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var payload []byte
+	payload = appendUvarint(payload, uint64(len(names)))
+	for _, name := range names {
+		payload = appendPayloadString(payload, name)
+
+		switch v := data[name].(type) {
+		case nil:
+			payload = append(payload, payloadNull)
+		case bool:
+			payload = append(payload, payloadBool)
+			if v {
+				payload = append(payload, 1)
+			} else {
+				payload = append(payload, 0)
+			}
+		case int:
+			payload = appendPayloadInt(payload, int64(v))
+		case int32:
+			payload = appendPayloadInt(payload, int64(v))
+		case int64:
+			payload = appendPayloadInt(payload, v)
+		case float32:
+			payload = appendPayloadFloat(payload, float64(v))
+		case float64:
+			payload = appendPayloadFloat(payload, v)
+		case string:
+			payload = append(payload, payloadString)
+			payload = appendPayloadString(payload, v)
+		case map[string]string:
+			payload = append(payload, payloadMap)
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			payload = appendUvarint(payload, uint64(len(keys)))
+			for _, k := range keys {
+				payload = appendPayloadString(payload, k)
+				payload = appendPayloadString(payload, v[k])
+			}
+		default:
+			return buf, fmt.Errorf("unsupported event data type %T for %q", v, name)
+		}
+	}
+
+	buf = appendUvarint(buf, uint64(len(payload)))
+	return append(buf, payload...), nil
+}
+
+// appendPayloadString appends a length-prefixed string
+func appendPayloadString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendPayloadInt appends a tagged zigzag-encoded integer
+func appendPayloadInt(buf []byte, v int64) []byte {
+	buf = append(buf, payloadInt)
+	tmp := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutVarint(tmp, v)
+	return append(buf, tmp[:n]...)
+}
+
+// appendPayloadFloat appends a tagged float64
+func appendPayloadFloat(buf []byte, v float64) []byte {
+	buf = append(buf, payloadFloat)
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(v))
+	return append(buf, tmp[:]...)
+}
+
+// decodeEventData reads a payload written by appendEventData
+func decodeEventData(r *frameReader) (map[string]interface{}, error) {
+	length, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(r.remaining()) {
+		return nil, errors.New("event payload exceeds frame")
+	}
+
+	// Decode within the declared length only
+	p := &frameReader{data: r.data[r.off : r.off+int(length)]}
+	r.off += int(length)
+
+	count, err := p.readCount()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{}, count)
+	for i := 0; i < count; i++ {
+		name, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		tag, err := p.readByte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tag {
+		case payloadNull:
+			data[name] = nil
+		case payloadBool:
+			b, err := p.readByte()
+			if err != nil {
+				return nil, err
+			}
+			data[name] = b != 0
+		case payloadInt:
+			v, n := binary.Varint(p.data[p.off:])
+			if n <= 0 {
+				return nil, errors.New("invalid varint")
+			}
+			p.off += n
+			data[name] = int(v)
+		case payloadFloat:
+			if p.remaining() < 8 {
+				return nil, errors.New("unexpected end of payload")
+			}
+			data[name] = math.Float64frombits(binary.LittleEndian.Uint64(p.data[p.off:]))
+			p.off += 8
+		case payloadString:
+			s, err := p.readString()
+			if err != nil {
+				return nil, err
+			}
+			data[name] = s
+		case payloadMap:
+			n, err := p.readCount()
+			if err != nil {
+				return nil, err
+			}
+			m := make(map[string]string, n)
+			for j := 0; j < n; j++ {
+				k, err := p.readString()
+				if err != nil {
+					return nil, err
+				}
+				v, err := p.readString()
+				if err != nil {
+					return nil, err
+				}
+				m[k] = v
+			}
+			data[name] = m
+		default:
+			return nil, fmt.Errorf("unknown payload tag 0x%02x for %q", tag, name)
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,156 @@
+package live
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// builtinEvents are the DOM events every session understands
+var builtinEvents = map[string]EventType{
+	"click":   EventClick,
+	"input":   EventInput,
+	"submit":  EventSubmit,
+	"change":  EventChange,
+	"keydown": EventKeyDown,
+	"keyup":   EventKeyUp,
+	"focus":   EventFocus,
+	"blur":    EventBlur,
+}
+
+// EventBinding pairs an event name with its wire ID
+type EventBinding struct {
+	ID   EventType
+	Name string
+}
+
+// EventRegistry maps event names to the wire IDs used by one session.
+// Components declare the names they handle; each new name gets the next
+// free ID and the binding is announced to the client in an EVENTS control
+// frame, so the client can encode events by ID.
+type EventRegistry struct {
+	mu     sync.RWMutex
+	byName map[string]EventType
+	byID   map[EventType]string
+	next   EventType
+}
+
+// NewEventRegistry creates a registry holding the built-in DOM events
+func NewEventRegistry() *EventRegistry {
+	r := &EventRegistry{
+		byName: make(map[string]EventType, len(builtinEvents)),
+		byID:   make(map[EventType]string, len(builtinEvents)),
+		next:   FirstCustomEvent,
+	}
+	for name, id := range builtinEvents {
+		r.byName[name] = id
+		r.byID[id] = name
+	}
+	return r
+}
+
+// Register returns the ID for name, assigning one if needed.
+// The boolean reports whether the binding is new.
+func (r *EventRegistry) Register(name string) (EventType, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id, ok := r.byName[name]; ok {
+		return id, false
+	}
+	id := r.next
+	r.next++
+	r.byName[name] = id
+	r.byID[id] = name
+	return id, true
+}
+
+// Declare registers names and returns the bindings that are new
+func (r *EventRegistry) Declare(names ...string) []EventBinding {
+	var added []EventBinding
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if id, isNew := r.Register(name); isNew {
+			added = append(added, EventBinding{ID: id, Name: name})
+		}
+	}
+	return added
+}
+
+// Bind records a binding chosen by the other side (used by the client)
+func (r *EventRegistry) Bind(binding EventBinding) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.byName[binding.Name] = binding.ID
+	r.byID[binding.ID] = binding.Name
+	if binding.ID >= r.next {
+		r.next = binding.ID + 1
+	}
+}
+
+// Name returns the event name for a wire ID
+func (r *EventRegistry) Name(id EventType) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, ok := r.byID[id]
+	return name, ok
+}
+
+// ID returns the wire ID for an event name
+func (r *EventRegistry) ID(name string) (EventType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	id, ok := r.byName[name]
+	return id, ok
+}
+
+// Bindings returns all bindings ordered by ID
+func (r *EventRegistry) Bindings() []EventBinding {
+	r.mu.RLock()
+	bindings := make([]EventBinding, 0, len(r.byID))
+	for id, name := range r.byID {
+		bindings = append(bindings, EventBinding{ID: id, Name: name})
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].ID < bindings[j].ID })
+	return bindings
+}
+
+// appendEventBindings appends the body of an EVENTS control frame:
+// [count varint]{[id varint][name string]}*
+func appendEventBindings(buf []byte, bindings []EventBinding) []byte {
+	buf = appendUvarint(buf, uint64(len(bindings)))
+	for _, b := range bindings {
+		buf = appendUvarint(buf, uint64(b.ID))
+		buf = appendPayloadString(buf, b.Name)
+	}
+	return buf
+}
+
+// decodeEventBindings reads the body of an EVENTS control frame
+func decodeEventBindings(r *frameReader) ([]EventBinding, error) {
+	count, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+	bindings := make([]EventBinding, 0, count)
+	for i := 0; i < count; i++ {
+		id, err := r.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		name, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			return nil, errors.New("empty event name")
+		}
+		bindings = append(bindings, EventBinding{ID: EventType(id), Name: name})
+	}
+	return bindings, nil
+}
@@ -0,0 +1,52 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"testing"
+)
+
+func TestEventRegistry_NegotiatesCustomEvents(t *testing.T) {
+	server := NewEventRegistry()
+
+	added := server.Declare("select-row", "drag-end", "select-row", "click")
+	if len(added) != 2 || added[0].Name != "select-row" || added[1].Name != "drag-end" {
+		t.Fatalf("Declare() = %v, want select-row and drag-end only", added)
+	}
+	if added[0].ID < FirstCustomEvent || added[1].ID != added[0].ID+1 {
+		t.Errorf("custom IDs = %v", added)
+	}
+	if id, _ := server.ID("click"); id != EventClick {
+		t.Errorf("click = %d, want built-in %d", id, EventClick)
+	}
+
+	// The client learns the bindings from an EVENTS frame body
+	r := &frameReader{data: appendEventBindings(nil, server.Bindings())}
+	bindings, err := decodeEventBindings(r)
+	if err != nil {
+		t.Fatalf("decodeEventBindings() error = %v", err)
+	}
+	client := NewEventRegistry()
+	for _, b := range bindings {
+		client.Bind(b)
+	}
+
+	id, ok := client.ID("drag-end")
+	if !ok || id != added[1].ID {
+		t.Fatalf("client drag-end = %d, %v", id, ok)
+	}
+
+	// Events encoded by ID decode back to the declared name
+	data, err := EncodeEvent(Event{Type: id, NodeID: 9})
+	if err != nil {
+		t.Fatalf("EncodeEvent() error = %v", err)
+	}
+	decoded, err := DecodeEvent(data)
+	if err != nil {
+		t.Fatalf("DecodeEvent() error = %v", err)
+	}
+	if name, _ := server.Name(decoded.Type); name != "drag-end" {
+		t.Errorf("decoded event name = %q, want drag-end", name)
+	}
+}
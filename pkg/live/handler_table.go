@@ -0,0 +1,153 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"sync"
+
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+// handlerTable holds the event handlers of the trees a session's scheduler
+// committed, by node ID and event name. It is fed the patches sent to the
+// client, so it always matches the DOM the client's events come from.
+type handlerTable struct {
+	mu       sync.Mutex
+	handlers map[uint32]map[string]any // node ID -> event name -> handler
+	kids     map[uint32][]uint32       // node ID -> child node IDs
+	parents  map[uint32]uint32         // node ID -> parent node ID
+}
+
+// newHandlerTable creates an empty table
+func newHandlerTable() *handlerTable {
+	return &handlerTable{
+		handlers: make(map[uint32]map[string]any),
+		kids:     make(map[uint32][]uint32),
+		parents:  make(map[uint32]uint32),
+	}
+}
+
+// apply updates the table with a batch of patches and returns the names of
+// the events bound
+func (t *handlerTable) apply(patches []vdom.Patch) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var names []string
+	for _, patch := range patches {
+		switch patch.Op {
+		case vdom.OpInsertNode:
+			names = t.insertLocked(patch.Node, patch.ParentID, names)
+		case vdom.OpRemoveNode:
+			t.unlinkLocked(patch.NodeID)
+			t.removeLocked(patch.NodeID)
+		case vdom.OpMoveNode:
+			t.unlinkLocked(patch.NodeID)
+			t.linkLocked(patch.NodeID, patch.ParentID)
+		case vdom.OpSetHandler:
+			if id, ok := vdom.PropEvent(patch.Key); ok {
+				names = append(names, t.bindLocked(patch.NodeID, id.String(), patch.Handler))
+			}
+		case vdom.OpUpdateEvents:
+			// Events no longer listened to lose their handlers
+			for name := range t.handlers[patch.NodeID] {
+				if !listensTo(patch.Events, name) {
+					delete(t.handlers[patch.NodeID], name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// lookup returns the handler bound to an event of a node
+func (t *handlerTable) lookup(nodeID uint32, event string) (any, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	handler, ok := t.handlers[nodeID][event]
+	return handler, ok
+}
+
+// reset empties the table, as when the trees are about to be sent afresh
+func (t *handlerTable) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers = make(map[uint32]map[string]any)
+	t.kids = make(map[uint32][]uint32)
+	t.parents = make(map[uint32]uint32)
+}
+
+// insertLocked binds the handlers of an inserted subtree and records its
+// place below parentID
+func (t *handlerTable) insertLocked(node *vdom.VNode, parentID uint32, names []string) []string {
+	if node == nil {
+		return names
+	}
+	t.linkLocked(node.ID, parentID)
+	for key, value := range node.Props {
+		if id, ok := vdom.PropEvent(key); ok && value != nil {
+			handler, _ := vdom.ListenerOf(value)
+			names = append(names, t.bindLocked(node.ID, id.String(), handler))
+		}
+	}
+	for i := range node.Kids {
+		names = t.insertLocked(&node.Kids[i], node.ID, names)
+	}
+	return names
+}
+
+// bindLocked binds a handler to an event of a node and returns the event name
+func (t *handlerTable) bindLocked(nodeID uint32, event string, handler any) string {
+	if t.handlers[nodeID] == nil {
+		t.handlers[nodeID] = make(map[string]any)
+	}
+	t.handlers[nodeID][event] = handler
+	return event
+}
+
+// linkLocked records nodeID as a child of parentID
+func (t *handlerTable) linkLocked(nodeID, parentID uint32) {
+	t.parents[nodeID] = parentID
+	t.kids[parentID] = append(t.kids[parentID], nodeID)
+}
+
+// unlinkLocked removes nodeID from its parent's children
+func (t *handlerTable) unlinkLocked(nodeID uint32) {
+	parentID, ok := t.parents[nodeID]
+	if !ok {
+		return
+	}
+	kids := t.kids[parentID]
+	for i, id := range kids {
+		if id == nodeID {
+			if len(kids) == 1 {
+				delete(t.kids, parentID)
+			} else {
+				t.kids[parentID] = append(kids[:i:i], kids[i+1:]...)
+			}
+			break
+		}
+	}
+	delete(t.parents, nodeID)
+}
+
+// removeLocked drops a removed subtree
+func (t *handlerTable) removeLocked(nodeID uint32) {
+	for _, kid := range t.kids[nodeID] {
+		delete(t.parents, kid)
+		t.removeLocked(kid)
+	}
+	delete(t.kids, nodeID)
+	delete(t.handlers, nodeID)
+}
+
+// listensTo reports whether a listener set includes the named event
+func listensTo(listeners []vdom.EventListener, name string) bool {
+	for _, l := range listeners {
+		if l.Event.String() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"log"
+	"time"
+
+	"github.com/recera/vango/pkg/server"
+)
+
+// Default session lifetimes
+const (
+	DefaultIdleTimeout    = 30 * time.Minute // no frames in either direction
+	DefaultReconnectGrace = 30 * time.Second // disconnected, waiting for the client
+)
+
+// sweepInterval is how often the server looks for sessions to end
+const sweepInterval = 5 * time.Second
+
+// SetSessionTimeouts sets how long a session may go without traffic, and
+// how long a disconnected session waits for its client to reconnect, before
+// it is ended. Zero disables the respective timeout.
+func (s *Server) SetSessionTimeouts(idle, grace time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idleTimeout = idle
+	s.reconnectGrace = grace
+}
+
+// OnSessionStart adds a hook called when a session is created
+func (s *Server) OnSessionStart(hook func(*Session)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onSessionStart = append(s.onSessionStart, hook)
+}
+
+// OnSessionEnd adds a hook called after a session has ended, so apps can
+// release resources tied to it
+func (s *Server) OnSessionEnd(hook func(*Session)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onSessionEnd = append(s.onSessionEnd, hook)
+}
+
+// Close ends every session and stops the session sweeper
+func (s *Server) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+
+	s.mu.RLock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.mu.RUnlock()
+
+	for _, session := range sessions {
+		s.endSession(session, "server closed")
+	}
+}
+
+// sweeper ends expired sessions until the server is closed
+func (s *Server) sweeper() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.sweep(now)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// sweep ends sessions that were idle or disconnected for too long
+func (s *Server) sweep(now time.Time) {
+	s.mu.RLock()
+	idle, grace := s.idleTimeout, s.reconnectGrace
+	var expired []*Session
+	var reasons []string
+	for _, session := range s.sessions {
+		session.mu.RLock()
+		switch {
+		case !session.connected && grace > 0 && now.Sub(session.disconnectedAt) > grace:
+			expired = append(expired, session)
+			reasons = append(reasons, "client did not reconnect")
+		case idle > 0 && now.Sub(session.lastActive) > idle:
+			expired = append(expired, session)
+			reasons = append(reasons, "idle")
+		}
+		session.mu.RUnlock()
+	}
+	s.mu.RUnlock()
+
+	for i, session := range expired {
+		s.endSession(session, reasons[i])
+	}
+}
+
+// endSession tears a session down: it is removed from the server, its
+// connection is closed, its scheduler is stopped and its components are
+// unregistered. OnSessionEnd hooks run last.
+func (s *Server) endSession(session *Session, reason string) {
+	s.mu.Lock()
+	if current, ok := s.sessions[session.ID]; !ok || current != session {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.sessions, session.ID)
+	hooks := s.onSessionEnd
+	s.mu.Unlock()
+
+	session.mu.Lock()
+	session.connected = false
+	if session.transport != nil {
+		session.transport.Close()
+	}
+	select {
+	case <-session.closeChan:
+		// Already closed
+	default:
+		close(session.closeChan)
+	}
+	session.mu.Unlock()
+
+	// Stop the scheduler and drop the components
+	if bridge := GetBridge(); bridge != nil {
+		bridge.CleanupSession(session.ID)
+	} else {
+		server.GetRegistry().CleanupSession(session.ID)
+	}
+	unsubscribeSession(session.ID)
+
+	for _, hook := range hooks {
+		hook(session)
+	}
+	log.Printf("[Live Server] Ended session %s (%s)", session.ID, reason)
+}
+
+// touch records traffic on the session
+func (s *Session) touch() {
+	s.mu.Lock()
+	s.lastActive = time.Now()
+	s.mu.Unlock()
+}
@@ -0,0 +1,74 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServer_SessionLifecycle(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetSessionTimeouts(time.Hour, time.Minute)
+
+	var started, ended []string
+	srv.OnSessionStart(func(s *Session) { started = append(started, s.ID) })
+	srv.OnSessionEnd(func(s *Session) { ended = append(ended, s.ID) })
+
+	serverEnd, client := NewPipe()
+	done := make(chan struct{})
+	go func() {
+		srv.ServeTransport("gc", serverEnd)
+		close(done)
+	}()
+	client.ReadFrame() // server HELLO
+	session := waitSession(t, srv, "gc")
+
+	// Disconnect; the session waits for the client to come back
+	client.Close()
+	<-done
+	srv.sweep(time.Now().Add(30 * time.Second))
+	if _, exists := srv.GetSession("gc"); !exists {
+		t.Fatal("session ended within the reconnect grace period")
+	}
+
+	srv.sweep(time.Now().Add(2 * time.Minute))
+	if _, exists := srv.GetSession("gc"); exists {
+		t.Fatal("session outlived the reconnect grace period")
+	}
+	if len(started) != 1 || len(ended) != 1 || ended[0] != "gc" {
+		t.Errorf("hooks: started %v, ended %v", started, ended)
+	}
+	select {
+	case <-session.closeChan:
+	default:
+		t.Error("ended session's close channel is still open")
+	}
+}
+
+func TestServer_IdleSessionEnds(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetSessionTimeouts(time.Minute, time.Minute)
+
+	serverEnd, client := NewPipe()
+	done := make(chan struct{})
+	go func() {
+		srv.ServeTransport("idle", serverEnd)
+		close(done)
+	}()
+	client.ReadFrame() // server HELLO
+	waitSession(t, srv, "idle")
+
+	srv.sweep(time.Now().Add(2 * time.Minute))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("idle session kept its connection open")
+	}
+	if _, exists := srv.GetSession("idle"); exists {
+		t.Error("idle session was not removed")
+	}
+}
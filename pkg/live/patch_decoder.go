@@ -0,0 +1,301 @@
+package live
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+// maxVNodeDepth bounds the nesting of decoded subtrees
+const maxVNodeDepth = 512
+
+// frameReader reads protocol primitives from an in-memory frame.
+// It is shared by the server and the WASM client, so it avoids io.
+type frameReader struct {
+	data []byte
+	off  int
+}
+
+// remaining returns the number of unread bytes
+func (r *frameReader) remaining() int {
+	return len(r.data) - r.off
+}
+
+// readByte reads a single byte
+func (r *frameReader) readByte() (byte, error) {
+	if r.off >= len(r.data) {
+		return 0, errors.New("unexpected end of frame")
+	}
+	b := r.data[r.off]
+	r.off++
+	return b, nil
+}
+
+// readUvarint reads an unsigned varint
+func (r *frameReader) readUvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data[r.off:])
+	if n <= 0 {
+		return 0, errors.New("invalid varint")
+	}
+	r.off += n
+	return v, nil
+}
+
+// readString reads a length-prefixed string
+func (r *frameReader) readString() (string, error) {
+	length, err := r.readUvarint()
+	if err != nil {
+		return "", err
+	}
+	if length > uint64(r.remaining()) {
+		return "", errors.New("string exceeds frame")
+	}
+	s := string(r.data[r.off : r.off+int(length)])
+	r.off += int(length)
+	return s, nil
+}
+
+// readCount reads an element count, rejecting counts that cannot fit in the
+// rest of the frame (every element takes at least one byte)
+func (r *frameReader) readCount() (int, error) {
+	n, err := r.readUvarint()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(r.remaining()) {
+		return 0, errors.New("count exceeds frame")
+	}
+	return int(n), nil
+}
+
+// DecodePatches decodes a FramePatches message produced by EncodePatches.
+// Subtrees carried by OpInsertNode patches are rebuilt as VNodes; event
+// listener props are restored with their prop name as value (wrapped in a
+// vdom.Listener when they have options) so the client can bind them to
+// server dispatch.
+func DecodePatches(data []byte) ([]vdom.Patch, error) {
+	r := &frameReader{data: data}
+
+	frameType, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if MessageType(frameType) != FramePatches {
+		return nil, errors.New("not a patch frame")
+	}
+
+	count, err := r.readCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode patch count: %w", err)
+	}
+
+	patches := make([]vdom.Patch, 0, count)
+	for i := 0; i < count; i++ {
+		patch, err := decodePatch(r)
+		if err != nil {
+			return nil, fmt.Errorf("patch %d: %w", i, err)
+		}
+		patches = append(patches, patch)
+	}
+
+	return patches, nil
+}
+
+// decodePatch decodes a single patch
+func decodePatch(r *frameReader) (vdom.Patch, error) {
+	var patch vdom.Patch
+
+	op, err := r.readByte()
+	if err != nil {
+		return patch, err
+	}
+	patch.Op = vdom.PatchOp(op)
+
+	nodeID, err := r.readUvarint()
+	if err != nil {
+		return patch, err
+	}
+	patch.NodeID = uint32(nodeID)
+
+	switch patch.Op {
+	case vdom.OpReplaceText, vdom.OpSetHTML:
+		patch.Value, err = r.readString()
+
+	case vdom.OpSetAttribute:
+		if patch.Key, err = r.readString(); err == nil {
+			patch.Value, err = r.readString()
+		}
+
+	case vdom.OpRemoveAttribute:
+		patch.Key, err = r.readString()
+
+	case vdom.OpRemoveNode:
+		// Node ID only
+
+	case vdom.OpInsertNode, vdom.OpMoveNode:
+		var parentID, beforeID uint64
+		if parentID, err = r.readUvarint(); err != nil {
+			return patch, err
+		}
+		if beforeID, err = r.readUvarint(); err != nil {
+			return patch, err
+		}
+		patch.ParentID = uint32(parentID)
+		patch.BeforeID = uint32(beforeID)
+
+		if patch.Op == vdom.OpInsertNode {
+			next := patch.NodeID
+			patch.Node, err = decodeVNode(r, &next, 0)
+		}
+
+	case vdom.OpUpdateEvents:
+		patch.Events, err = decodeListeners(r)
+
+	default:
+		return patch, fmt.Errorf("unknown opcode 0x%02x", op)
+	}
+
+	return patch, err
+}
+
+// decodeListeners reads the listener set of an UpdateEvents patch:
+// [count varint]{[name string][options u8]}*
+func decodeListeners(r *frameReader) ([]vdom.EventListener, error) {
+	count, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+	listeners := make([]vdom.EventListener, 0, count)
+	for i := 0; i < count; i++ {
+		name, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			return nil, errors.New("empty event name")
+		}
+		options, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, vdom.EventListener{
+			Event:   vdom.RegisterEvent(name),
+			Options: vdom.EventOptions(options),
+		})
+	}
+	return listeners, nil
+}
+
+// decodeVNode decodes a subtree written by Encoder.WriteVNode. nextID is the
+// ID the client will assign to the next node; the server must agree with it.
+func decodeVNode(r *frameReader, nextID *uint32, depth int) (*vdom.VNode, error) {
+	if depth > maxVNodeDepth {
+		return nil, errors.New("subtree too deep")
+	}
+
+	kind, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	id, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if uint32(id) != *nextID {
+		return nil, fmt.Errorf("node id %d out of order, expected %d", id, *nextID)
+	}
+	*nextID++
+
+	node := &vdom.VNode{Kind: vdom.VKind(kind), ID: uint32(id)}
+
+	switch node.Kind {
+	case vdom.KindText:
+		node.Text, err = r.readString()
+		return node, err
+
+	case vdom.KindElement, vdom.KindRaw:
+		if node.Tag, err = r.readString(); err != nil {
+			return nil, err
+		}
+		if node.Key, err = r.readString(); err != nil {
+			return nil, err
+		}
+		if node.Key != "" {
+			node.Flags |= vdom.FlagHasKey
+		}
+
+		attrCount, err := r.readCount()
+		if err != nil {
+			return nil, err
+		}
+		node.Props = make(vdom.Props, attrCount)
+		for i := 0; i < attrCount; i++ {
+			key, err := r.readString()
+			if err != nil {
+				return nil, err
+			}
+			value, err := r.readString()
+			if err != nil {
+				return nil, err
+			}
+			node.Props[key] = value
+		}
+
+		eventCount, err := r.readCount()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < eventCount; i++ {
+			key, err := r.readString()
+			if err != nil {
+				return nil, err
+			}
+			options, err := r.readByte()
+			if err != nil {
+				return nil, err
+			}
+			if options != 0 {
+				node.Props[key] = vdom.WithOptions(key, vdom.EventOptions(options))
+			} else {
+				node.Props[key] = key
+			}
+			node.Flags |= vdom.FlagHasEvents
+		}
+
+		if node.Kind == vdom.KindRaw {
+			if node.Text, err = r.readString(); err != nil {
+				return nil, err
+			}
+		}
+
+	case vdom.KindFragment:
+		// Fragments only carry children
+
+	case vdom.KindPortal:
+		if node.PortalTarget, err = r.readString(); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown node kind %d", kind)
+	}
+
+	kidCount, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+	if kidCount > 0 {
+		node.Kids = make([]vdom.VNode, 0, kidCount)
+	}
+	for i := 0; i < kidCount; i++ {
+		kid, err := decodeVNode(r, nextID, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		node.Kids = append(node.Kids, *kid)
+	}
+
+	return node, nil
+}
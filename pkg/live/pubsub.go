@@ -0,0 +1,223 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/recera/vango/pkg/scheduler"
+	"github.com/recera/vango/pkg/vango"
+)
+
+// Broker delivers messages published on a topic to its subscribers.
+// InProcessBroker serves a single server; a multi-node broker (Redis, NATS,
+// ...) implements the same interface and is installed with SetBroker. Such
+// brokers must be able to serialize the messages they carry.
+type Broker interface {
+	// Publish sends msg to every subscriber of topic
+	Publish(topic string, msg interface{}) error
+
+	// Subscribe calls handler for each message on topic until the returned
+	// function is called. Handlers may run on any goroutine.
+	Subscribe(topic string, handler func(msg interface{})) (unsubscribe func())
+}
+
+// InProcessBroker is a Broker for subscribers in the same process.
+// Publish calls the handlers synchronously.
+type InProcessBroker struct {
+	mu     sync.RWMutex
+	topics map[string]map[uint64]func(interface{})
+	nextID uint64
+}
+
+// NewInProcessBroker creates an in-process broker
+func NewInProcessBroker() *InProcessBroker {
+	return &InProcessBroker{
+		topics: make(map[string]map[uint64]func(interface{})),
+	}
+}
+
+// Publish calls every handler subscribed to topic
+func (b *InProcessBroker) Publish(topic string, msg interface{}) error {
+	b.mu.RLock()
+	handlers := make([]func(interface{}), 0, len(b.topics[topic]))
+	for _, handler := range b.topics[topic] {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(msg)
+	}
+	return nil
+}
+
+// Subscribe registers handler for topic
+func (b *InProcessBroker) Subscribe(topic string, handler func(msg interface{})) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	id := b.nextID
+	if b.topics[topic] == nil {
+		b.topics[topic] = make(map[uint64]func(interface{}))
+	}
+	b.topics[topic][id] = handler
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.topics[topic], id)
+			if len(b.topics[topic]) == 0 {
+				delete(b.topics, topic)
+			}
+		})
+	}
+}
+
+// Subscribers returns the number of handlers subscribed to topic
+func (b *InProcessBroker) Subscribers(topic string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.topics[topic])
+}
+
+// ErrNoFiber is returned when Subscribe is called outside a component render
+var ErrNoFiber = errors.New("context has no fiber; subscribe from a server-driven component render")
+
+// subscriptionKey identifies one component's subscription to a topic
+type subscriptionKey struct {
+	fiber *scheduler.Fiber
+	topic string
+}
+
+// subscription connects a topic to a component fiber
+type subscription struct {
+	mu          sync.Mutex
+	latest      interface{}
+	handler     func(msg interface{})
+	unsubscribe func()
+}
+
+// pubSub tracks component subscriptions per session so they end with it
+type pubSub struct {
+	mu       sync.Mutex
+	broker   Broker
+	sessions map[string]map[subscriptionKey]*subscription
+}
+
+// topics is the process-wide pub/sub used by Publish and Subscribe
+var topics = &pubSub{
+	broker:   NewInProcessBroker(),
+	sessions: make(map[string]map[subscriptionKey]*subscription),
+}
+
+// SetBroker replaces the broker behind Publish and Subscribe. Call it at
+// startup, before components subscribe.
+func SetBroker(b Broker) {
+	topics.mu.Lock()
+	defer topics.mu.Unlock()
+	topics.broker = b
+}
+
+// Publish sends msg to every component subscribed to topic, in every
+// session. Each subscribed component re-renders on its session's scheduler.
+func Publish(topic string, msg interface{}) error {
+	topics.mu.Lock()
+	broker := topics.broker
+	topics.mu.Unlock()
+	return broker.Publish(topic, msg)
+}
+
+// Subscribe subscribes the component rendering with ctx to topic and
+// returns the latest message received on it, or nil before the first one.
+// Call it on every render; the subscription is made once and lasts until
+// the component's fiber is removed or the session ends. Every message marks
+// the fiber dirty. handler, if not nil, is called with each message first,
+// so the component can fold messages into its own state; the handler from
+// the latest render is used.
+func Subscribe(ctx *vango.Context, topic string, handler func(msg interface{})) (interface{}, error) {
+	if ctx == nil || ctx.Fiber == nil || ctx.Scheduler == nil {
+		return nil, ErrNoFiber
+	}
+	fiber, sched, sessionID := ctx.Fiber, ctx.Scheduler, ctx.SessionID
+	key := subscriptionKey{fiber: fiber, topic: topic}
+
+	// The broker subscription is made under the lock, so unsubscribeSession
+	// never finds a subscription without its unsubscribe function
+	topics.mu.Lock()
+	subs := topics.sessions[sessionID]
+	if subs == nil {
+		subs = make(map[subscriptionKey]*subscription)
+		topics.sessions[sessionID] = subs
+	}
+	sub, exists := subs[key]
+	if !exists {
+		sub = &subscription{handler: handler}
+		subs[key] = sub
+		sub.unsubscribe = topics.broker.Subscribe(topic, func(msg interface{}) {
+			sub.mu.Lock()
+			sub.latest = msg
+			h := sub.handler
+			sub.mu.Unlock()
+
+			if h != nil {
+				h(msg)
+			}
+			sched.MarkDirty(fiber)
+		})
+	}
+	topics.mu.Unlock()
+
+	// A remounted component renders with a new fiber, so the old fiber's
+	// subscriptions end when it is removed
+	if !exists {
+		fiber.OnRemove(func() {
+			unsubscribe(sessionID, key, sub)
+		})
+	}
+
+	sub.mu.Lock()
+	sub.handler = handler
+	latest := sub.latest
+	sub.mu.Unlock()
+
+	return latest, nil
+}
+
+// unsubscribe ends one component subscription if it is still registered
+func unsubscribe(sessionID string, key subscriptionKey, sub *subscription) {
+	topics.mu.Lock()
+	subs := topics.sessions[sessionID]
+	if subs[key] != sub {
+		topics.mu.Unlock()
+		return
+	}
+	delete(subs, key)
+	if len(subs) == 0 {
+		delete(topics.sessions, sessionID)
+	}
+	topics.mu.Unlock()
+
+	if sub.unsubscribe != nil {
+		sub.unsubscribe()
+	}
+}
+
+// unsubscribeSession ends every subscription made by a session's components
+func unsubscribeSession(sessionID string) {
+	topics.mu.Lock()
+	subs := topics.sessions[sessionID]
+	delete(topics.sessions, sessionID)
+	topics.mu.Unlock()
+
+	for _, sub := range subs {
+		if sub.unsubscribe != nil {
+			sub.unsubscribe()
+		}
+	}
+}
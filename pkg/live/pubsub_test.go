@@ -0,0 +1,111 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"testing"
+	"time"
+
+	"github.com/recera/vango/pkg/scheduler"
+	"github.com/recera/vango/pkg/vango"
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+func TestSubscribe_PublishRerendersSubscribers(t *testing.T) {
+	broker := NewInProcessBroker()
+	SetBroker(broker)
+	defer SetBroker(NewInProcessBroker())
+
+	sched := scheduler.NewScheduler()
+	sched.Start()
+	defer sched.Stop()
+
+	ctx := vango.NewContext(vango.ModeServerDriven).
+		WithScheduler(sched).
+		WithSessionID("pubsub")
+	rendered := make(chan interface{}, 8)
+	var folded []interface{}
+	fiber := sched.CreateFiber(func() *vdom.VNode {
+		latest, err := Subscribe(ctx, "news", func(msg interface{}) {
+			folded = append(folded, msg)
+		})
+		if err != nil {
+			t.Errorf("Subscribe: %v", err)
+		}
+		rendered <- latest
+		return vdom.NewText("news")
+	}, nil)
+	ctx.Fiber = fiber
+
+	sched.MarkDirty(fiber)
+	if latest := <-rendered; latest != nil {
+		t.Fatalf("first render saw message %v", latest)
+	}
+
+	Publish("news", "hello")
+	select {
+	case latest := <-rendered:
+		if latest != "hello" {
+			t.Errorf("re-render saw %v, want hello", latest)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("publish did not re-render the subscriber")
+	}
+	if len(folded) != 1 || folded[0] != "hello" {
+		t.Errorf("handler got %v", folded)
+	}
+
+	// Re-rendering does not subscribe again
+	if n := broker.Subscribers("news"); n != 1 {
+		t.Errorf("%d subscriptions after two renders, want 1", n)
+	}
+
+	unsubscribeSession("pubsub")
+	if n := broker.Subscribers("news"); n != 0 {
+		t.Errorf("%d subscriptions after session end, want 0", n)
+	}
+}
+
+func TestSubscribe_RequiresFiber(t *testing.T) {
+	ctx := vango.NewContext(vango.ModeServerDriven)
+	if _, err := Subscribe(ctx, "news", nil); err != ErrNoFiber {
+		t.Errorf("Subscribe without fiber: %v, want ErrNoFiber", err)
+	}
+}
+
+func TestSubscribe_EndsWhenFiberRemoved(t *testing.T) {
+	broker := NewInProcessBroker()
+	SetBroker(broker)
+	defer SetBroker(NewInProcessBroker())
+
+	sched := scheduler.NewScheduler()
+	ctx := vango.NewContext(vango.ModeServerDriven).
+		WithScheduler(sched).
+		WithSessionID("remount")
+
+	// Each mount of the component renders with a new fiber
+	mount := func() *scheduler.Fiber {
+		fiber := sched.CreateFiber(nil, nil)
+		ctx.Fiber = fiber
+		if _, err := Subscribe(ctx, "news", nil); err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+		return fiber
+	}
+	for i := 0; i < 3; i++ {
+		sched.RemoveFiber(mount())
+	}
+	if n := broker.Subscribers("news"); n != 0 {
+		t.Errorf("%d subscriptions after the fibers were removed, want 0", n)
+	}
+
+	mount()
+	if n := broker.Subscribers("news"); n != 1 {
+		t.Errorf("%d subscriptions for the mounted fiber, want 1", n)
+	}
+	unsubscribeSession("remount")
+	if n := broker.Subscribers("news"); n != 0 {
+		t.Errorf("%d subscriptions after session end, want 0", n)
+	}
+}
@@ -0,0 +1,76 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+// Default replay buffer limits per session
+const (
+	DefaultReplayFrames = 256
+	DefaultReplayBytes  = 1 << 20 // 1 MiB
+)
+
+// replayFrame is an encoded patch frame with its sequence number
+type replayFrame struct {
+	seq  uint64
+	data []byte
+}
+
+// replayBuffer keeps the most recent patch frames of a session so a client
+// that reconnects after a network blip can receive the frames it missed.
+// It is bounded by frame count and total bytes; the oldest frames are
+// evicted first. Not safe for concurrent use; Session.mu guards it.
+type replayBuffer struct {
+	frames    []replayFrame
+	bytes     int
+	maxFrames int
+	maxBytes  int
+}
+
+// newReplayBuffer creates a replay buffer with the given limits
+func newReplayBuffer(maxFrames, maxBytes int) *replayBuffer {
+	return &replayBuffer{
+		maxFrames: maxFrames,
+		maxBytes:  maxBytes,
+	}
+}
+
+// add appends a frame, evicting old frames to stay within the limits
+func (b *replayBuffer) add(seq uint64, data []byte) {
+	b.frames = append(b.frames, replayFrame{seq: seq, data: data})
+	b.bytes += len(data)
+
+	for len(b.frames) > 0 && (len(b.frames) > b.maxFrames || b.bytes > b.maxBytes) {
+		b.bytes -= len(b.frames[0].data)
+		b.frames[0] = replayFrame{}
+		b.frames = b.frames[1:]
+	}
+}
+
+// since returns the frames after seq, up to and including head (the last
+// sequence number handed out). ok is false when frames in that range have
+// been evicted, or when seq is ahead of head.
+func (b *replayBuffer) since(seq, head uint64) ([][]byte, bool) {
+	if seq > head {
+		return nil, false
+	}
+	if seq == head {
+		return nil, true
+	}
+	if len(b.frames) == 0 || b.frames[0].seq > seq+1 {
+		return nil, false
+	}
+
+	var out [][]byte
+	for _, f := range b.frames {
+		if f.seq > seq {
+			out = append(out, f.data)
+		}
+	}
+	return out, true
+}
+
+// reset drops all frames
+func (b *replayBuffer) reset() {
+	b.frames = nil
+	b.bytes = 0
+}
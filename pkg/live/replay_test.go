@@ -0,0 +1,229 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+func TestReplayBuffer_Limits(t *testing.T) {
+	b := newReplayBuffer(3, 1<<10)
+	for seq := uint64(1); seq <= 5; seq++ {
+		b.add(seq, []byte{byte(seq)})
+	}
+
+	if _, ok := b.since(1, 5); ok {
+		t.Error("since(1) should fail once frame 2 was evicted")
+	}
+	frames, ok := b.since(2, 5)
+	if !ok || len(frames) != 3 || frames[0][0] != 3 || frames[2][0] != 5 {
+		t.Errorf("since(2) = %v, %v; want frames 3..5", frames, ok)
+	}
+	if frames, ok := b.since(5, 5); !ok || len(frames) != 0 {
+		t.Errorf("since(head) = %v, %v; want nothing to replay", frames, ok)
+	}
+	if _, ok := b.since(6, 5); ok {
+		t.Error("since() ahead of head should fail")
+	}
+
+	// The byte limit evicts too
+	b = newReplayBuffer(10, 4)
+	b.add(1, []byte{1, 1, 1})
+	b.add(2, []byte{2, 2, 2})
+	if _, ok := b.since(0, 2); ok {
+		t.Error("frame 1 should have been evicted by the byte limit")
+	}
+	if frames, ok := b.since(1, 2); !ok || len(frames) != 1 {
+		t.Errorf("since(1) = %v, %v; want frame 2", frames, ok)
+	}
+}
+
+// newTestSession creates a session without a connection, as handleConnection
+// leaves it before the client HELLO arrives
+func newTestSession(maxFrames int) *Session {
+	return &Session{
+		ID:            "test",
+		events:        NewEventRegistry(),
+		replay:        newReplayBuffer(maxFrames, DefaultReplayBytes),
+		awaitingHello: true,
+		maxPending:    DefaultPendingBytes,
+		sendChan:      make(chan []byte, 4),
+		closeChan:     make(chan struct{}),
+	}
+}
+
+// sendText sends a single ReplaceText patch
+func sendText(t *testing.T, s *Session, value string) {
+	t.Helper()
+	if err := s.SendPatches([]vdom.Patch{{Op: vdom.OpReplaceText, NodeID: 1, Value: value}}); err != nil {
+		t.Fatalf("SendPatches() error = %v", err)
+	}
+}
+
+// drainTexts returns the ReplaceText values of the queued patch frames
+func drainTexts(t *testing.T, s *Session) []string {
+	t.Helper()
+	var values []string
+	for {
+		select {
+		case frame := <-s.sendChan:
+			patches, err := DecodePatches(frame)
+			if err != nil {
+				t.Fatalf("DecodePatches() error = %v", err)
+			}
+			values = append(values, patches[0].Value)
+		default:
+			return values
+		}
+	}
+}
+
+func TestSession_ResumeReplaysMissedFrames(t *testing.T) {
+	s := newTestSession(DefaultReplayFrames)
+
+	// Frames rendered before the first HELLO are held, then flushed
+	sendText(t, s, "a")
+	sendText(t, s, "b")
+	if got := drainTexts(t, s); len(got) != 0 {
+		t.Fatalf("frames sent before client HELLO: %v", got)
+	}
+	s.resume(false, 0)
+	if got := drainTexts(t, s); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Fatalf("after HELLO got %v, want [a b]", got)
+	}
+
+	// The client applied frame 1 but lost frame 2 with the connection
+	s.awaitingHello = true
+	s.helloSeq = s.lastSeq
+	sendText(t, s, "c")
+	s.resume(true, 1)
+	if got := drainTexts(t, s); len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Fatalf("resume got %v, want [b c]", got)
+	}
+
+	// Once resumed, frames go straight out
+	sendText(t, s, "d")
+	if got := drainTexts(t, s); len(got) != 1 || got[0] != "d" {
+		t.Fatalf("after resume got %v, want [d]", got)
+	}
+}
+
+func TestSession_ResumeResyncsWhenGapTooLarge(t *testing.T) {
+	s := newTestSession(2)
+	for _, v := range []string{"a", "b", "c"} {
+		sendText(t, s, v)
+	}
+
+	s.resume(true, 0)
+
+	frame := <-s.sendChan
+	r := &frameReader{data: frame, off: 1}
+	msgType, err := r.readString()
+	if err != nil || MessageType(frame[0]) != FrameControl || msgType != "RESYNC" {
+		t.Fatalf("got frame %x, want RESYNC control frame", frame)
+	}
+	// RESYNC takes a sequence number of its own
+	if seq, err := r.readUvarint(); err != nil || seq != 4 {
+		t.Errorf("RESYNC seq = %d, %v; want 4", seq, err)
+	}
+	if frames, ok := s.replay.since(3, s.lastSeq); !ok || len(frames) != 1 {
+		t.Errorf("a client that misses RESYNC should get it replayed, got %d frames, %v", len(frames), ok)
+	}
+	if got := drainTexts(t, s); len(got) != 0 {
+		t.Errorf("stale frames replayed after RESYNC: %v", got)
+	}
+}
+
+func TestSession_ResumeLargerThanSendBuffer(t *testing.T) {
+	s := newTestSession(DefaultReplayFrames)
+	var want []string
+	for i := 0; i < 3*cap(s.sendChan); i++ {
+		v := strconv.Itoa(i)
+		sendText(t, s, v)
+		want = append(want, v)
+	}
+
+	// Without a writer draining sendChan, resume must not block
+	resumed := make(chan struct{})
+	go func() {
+		s.resume(true, 0)
+		close(resumed)
+	}()
+	select {
+	case <-resumed:
+	case <-time.After(time.Second):
+		t.Fatal("resume blocked on a full send buffer")
+	}
+
+	// Patches sent meanwhile follow the replayed frames, which the writer
+	// moves from the outbox as the buffer drains
+	sendText(t, s, "after")
+	want = append(want, "after")
+	var got []string
+	for batch := drainTexts(t, s); len(batch) > 0; batch = drainTexts(t, s) {
+		got = append(got, batch...)
+		s.flushPending()
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSession_ControlFramesDoNotBlock(t *testing.T) {
+	s := newTestSession(DefaultReplayFrames)
+	s.resume(false, 0)
+	for i := 0; i < cap(s.sendChan); i++ {
+		sendText(t, s, strconv.Itoa(i))
+	}
+
+	// With sendChan full, control frames wait in the outbox instead of
+	// blocking the render or read loop
+	sent := make(chan struct{})
+	go func() {
+		s.DeclareEvents("drag-end")
+		s.sendControl("PONG")
+		close(sent)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("control frames blocked on a full send buffer")
+	}
+	sendText(t, s, "after")
+
+	var got []string
+	for {
+		select {
+		case frame := <-s.sendChan:
+			if MessageType(frame[0]) == FrameControl {
+				r := &frameReader{data: frame[1:]}
+				name, err := r.readString()
+				if err != nil {
+					t.Fatalf("readString() error = %v", err)
+				}
+				got = append(got, name)
+				continue
+			}
+			patches, err := DecodePatches(frame)
+			if err != nil {
+				t.Fatalf("DecodePatches() error = %v", err)
+			}
+			got = append(got, patches[0].Value)
+		default:
+			if len(s.sendChan) == 0 && len(s.outbox) == 0 && s.pending.empty() {
+				want := "0,1,2,3,EVENTS,PONG,after"
+				if strings.Join(got, ",") != want {
+					t.Errorf("got %v, want %s", got, want)
+				}
+				return
+			}
+			s.flushPending()
+		}
+	}
+}
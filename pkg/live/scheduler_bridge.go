@@ -5,6 +5,7 @@ package live
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"sync"
 	
@@ -27,6 +28,8 @@ type BridgedSession struct {
 	Session    *Session
 	Scheduler  *scheduler.Scheduler
 	Components map[string]*server.ComponentInstance
+	
+	handlers *handlerTable // on* handlers of the trees sent to the client
 }
 
 // NewSchedulerBridge creates a new scheduler bridge
@@ -42,6 +45,12 @@ func (b *SchedulerBridge) CreateSessionScheduler(sessionID string) *scheduler.Sc
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	
+	// A reconnecting client keeps its scheduler and fibers so their
+	// previous renders stay the base for the next diff
+	if bridged, exists := b.sessions[sessionID]; exists {
+		return bridged.Scheduler
+	}
+	
 	// Create a new scheduler for this session
 	sched := scheduler.NewScheduler()
 	
@@ -57,6 +66,7 @@ func (b *SchedulerBridge) CreateSessionScheduler(sessionID string) *scheduler.Sc
 		Session:    session,
 		Scheduler:  sched,
 		Components: make(map[string]*server.ComponentInstance),
+		handlers:   newHandlerTable(),
 	}
 	
 	b.sessions[sessionID] = bridged
@@ -67,6 +77,12 @@ func (b *SchedulerBridge) CreateSessionScheduler(sessionID string) *scheduler.Sc
 			return
 		}
 		
+		// Bind the handlers of the trees being sent, and announce their
+		// events so the client can send them
+		if names := bridged.handlers.apply(patches); len(names) > 0 {
+			session.DeclareEvents(names...)
+		}
+		
 		log.Printf("[SchedulerBridge] Sending %d patches for session %s", len(patches), sessionID)
 		
 		// Send patches to client via WebSocket
@@ -138,16 +154,22 @@ func (b *SchedulerBridge) CreateServerComponent(
 		// Ensure component is in context for each render
 		ctx.Set("component", component)
 		
-		// This render function will be called by the scheduler
-		vnode := render(ctx)
+		// This render function will be called by the scheduler, with
+		// the render's owner
+		vnode := render(ctx.ForRender())
 		
 		// Store the rendered VNode in the component
 		component.LastVNode = vnode
 		
+		// Announce any event names declared during render
+		bridged.Session.DeclareEvents(component.Events()...)
+		
 		return vnode
 	}, nil)
 	
 	component.Fiber = fiber
+	ctx.Fiber = fiber
+	fiber.SetUserData(ctx) // child components derive their contexts from it
 	
 	// Store component in bridged session
 	bridged.Components[componentID] = component
@@ -163,76 +185,101 @@ func (b *SchedulerBridge) CreateServerComponent(
 	return component, nil
 }
 
-// HandleComponentEvent routes an event to a component
-func (b *SchedulerBridge) HandleComponentEvent(sessionID string, nodeID uint32, eventType string) error {
-	log.Printf("[SchedulerBridge] HandleComponentEvent: session=%s, nodeID=%d, type=%s", sessionID, nodeID, eventType)
-	
+// HandleComponentEvent routes an event to the handler of a node of the
+// session: the one bound in its committed trees, or else one registered on
+// one of its components with On
+func (b *SchedulerBridge) HandleComponentEvent(sessionID string, nodeID uint32, evt vango.Event) error {
 	b.mu.RLock()
 	bridged, exists := b.sessions[sessionID]
-	b.mu.RUnlock()
-	
 	if !exists {
-		log.Printf("[SchedulerBridge] Session not found: %s", sessionID)
-		log.Printf("[SchedulerBridge] Available sessions: %v", b.getSessionIDs())
+		b.mu.RUnlock()
 		return ErrSessionNotFound
 	}
-	
-	// Find component by node ID
-	registry := server.GetRegistry()
-	component, found := registry.GetByNodeID(nodeID)
+	var handler func(vango.Event)
+	bound, found := bridged.handlers.lookup(nodeID, evt.Type)
 	if !found {
-		log.Printf("[SchedulerBridge] No component found for node %d", nodeID)
-		log.Printf("[SchedulerBridge] Looking for component in session %s", sessionID)
-		
-		// Try to find component by session
-		for _, comp := range bridged.Components {
-			log.Printf("[SchedulerBridge] Available component: %s", comp.ID)
-			// Check if this component has the handler
-			if err := comp.HandleEvent(nodeID, eventType); err == nil {
-				log.Printf("[SchedulerBridge] Found handler in component %s", comp.ID)
-				return nil
+		for _, component := range bridged.Components {
+			if handler, found = component.Handler(nodeID, evt.Type); found {
+				break
 			}
 		}
-		
+	}
+	b.mu.RUnlock()
+	
+	if !found {
 		return ErrComponentNotFound
 	}
 	
-	// Handle the event
-	if err := component.HandleEvent(nodeID, eventType); err != nil {
-		log.Printf("[SchedulerBridge] Error handling event: %v", err)
-		return err
+	// Handlers run without the lock; they may create components
+	if handler == nil {
+		var ok bool
+		if handler, ok = server.EventHandler(bound); !ok {
+			return fmt.Errorf("vango: unsupported handler type %T for node %d", bound, nodeID)
+		}
 	}
+	handler(evt)
+	return nil
+}
+
+// ResyncSession re-renders every component of a session from scratch. It is
+// used when a reconnecting client missed more patches than the replay buffer
+// holds, so the next diff inserts the full trees.
+func (b *SchedulerBridge) ResyncSession(sessionID string) error {
+	b.mu.RLock()
+	bridged, exists := b.sessions[sessionID]
+	var components []*server.ComponentInstance
+	if exists {
+		components = make([]*server.ComponentInstance, 0, len(bridged.Components))
+		for _, component := range bridged.Components {
+			components = append(components, component)
+		}
+	}
+	b.mu.RUnlock()
 	
-	// Use the bridged scheduler for this session
-	_ = bridged // Mark as used
+	if !exists {
+		return ErrSessionNotFound
+	}
 	
-	// The event handler should have updated state and marked fiber dirty
-	// The scheduler will handle re-rendering and patch generation
+	// The trees are sent afresh, with new node IDs
+	bridged.handlers.reset()
+	for _, component := range components {
+		if component.Fiber == nil {
+			continue
+		}
+		// Child fibers render again with the new tree
+		bridged.Scheduler.RemoveChildFibers(component.Fiber)
+		component.Fiber.SetVNode(nil)
+		bridged.Scheduler.MarkDirty(component.Fiber)
+	}
 	
+	log.Printf("[SchedulerBridge] Resyncing %d components for session %s", len(components), sessionID)
 	return nil
 }
 
 // CleanupSession cleans up when a session ends
 func (b *SchedulerBridge) CleanupSession(sessionID string) {
 	b.mu.Lock()
-	defer b.mu.Unlock()
-	
 	bridged, exists := b.sessions[sessionID]
 	if !exists {
+		b.mu.Unlock()
 		return
 	}
 	
-	// Stop the scheduler
+	// Remove from sessions
+	delete(b.sessions, sessionID)
+	b.mu.Unlock()
+	
+	// Stop the scheduler and run the components' cleanups. Stop waits for
+	// a render in progress, which may itself use the bridge, so the lock is
+	// released first.
 	if bridged.Scheduler != nil {
 		bridged.Scheduler.Stop()
+		bridged.Scheduler.RemoveAllFibers()
 	}
 	
 	// Clean up components
 	server.GetRegistry().CleanupSession(sessionID)
 	
-	// Remove from sessions
-	delete(b.sessions, sessionID)
-	
 	log.Printf("[SchedulerBridge] Cleaned up session %s", sessionID)
 }
 
@@ -244,15 +291,6 @@ func (b *SchedulerBridge) GetBridgedSession(sessionID string) (*BridgedSession,
 	return session, exists
 }
 
-// getSessionIDs returns all active session IDs for debugging
-func (b *SchedulerBridge) getSessionIDs() []string {
-	var ids []string
-	for id := range b.sessions {
-		ids = append(ids, id)
-	}
-	return ids
-}
-
 // Global bridge instance
 var globalBridge *SchedulerBridge
 
@@ -289,16 +327,21 @@ func (b *SchedulerBridge) connectExistingComponents(sessionID string, bridged *B
 				// Ensure component is in context
 				ctx.Set("component", component)
 				
-				// Render the component
-				vnode := component.RenderFunc(ctx)
+				// Render the component with the render's owner
+				vnode := component.RenderFunc(ctx.ForRender())
 				
 				// Store the rendered VNode
 				component.LastVNode = vnode
 				
+				// Announce any event names declared during render
+				bridged.Session.DeclareEvents(component.Events()...)
+				
 				return vnode
 			}, nil)
 			
 			component.Fiber = fiber
+			ctx.Fiber = fiber
+			fiber.SetUserData(ctx)
 			
 			// Store in bridged session
 			bridged.Components[component.ID] = component
@@ -0,0 +1,197 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/recera/vango/pkg/reactive"
+	"github.com/recera/vango/pkg/server"
+	"github.com/recera/vango/pkg/vango"
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+// readPatches reads frames from a client until a patch frame arrives
+func readPatches(t *testing.T, client *PipeTransport) []vdom.Patch {
+	t.Helper()
+	frames := make(chan []byte, 1)
+	go func() {
+		for {
+			frame, err := client.ReadFrame()
+			if err != nil {
+				close(frames)
+				return
+			}
+			if len(frame) > 0 && MessageType(frame[0]) == FramePatches {
+				frames <- frame
+				return
+			}
+		}
+	}()
+	select {
+	case frame, ok := <-frames:
+		if !ok {
+			t.Fatal("transport closed before a patch frame arrived")
+		}
+		patches, err := DecodePatches(frame)
+		if err != nil {
+			t.Fatalf("DecodePatches() error = %v", err)
+		}
+		return patches
+	case <-time.After(2 * time.Second):
+		t.Fatal("no patch frame arrived")
+		return nil
+	}
+}
+
+// connectBridged connects a client to a session of a server with the
+// global bridge installed, which the test's cleanup removes
+func connectBridged(t *testing.T, id string) *PipeTransport {
+	t.Helper()
+	srv := NewServer()
+	InitBridge(srv)
+	t.Cleanup(func() { globalBridge = nil })
+
+	serverEnd, client := NewPipe()
+	t.Cleanup(func() { client.Close() })
+	go srv.ServeTransport(id, serverEnd)
+	for i := 0; i < 2; i++ { // HELLO and EVENTS
+		if _, err := client.ReadFrame(); err != nil {
+			t.Fatalf("ReadFrame() error = %v", err)
+		}
+	}
+	if err := client.WriteFrame(clientHello(false, 0)); err != nil {
+		t.Fatalf("WriteFrame() error = %v", err)
+	}
+	waitSession(t, srv, id)
+	return client
+}
+
+func TestSchedulerBridge_ClickRunsHandler(t *testing.T) {
+	client := connectBridged(t, "clicks")
+	sched := GetBridge().CreateSessionScheduler("clicks")
+	count := reactive.NewState(0, sched)
+	_, err := GetBridge().CreateServerComponent("clicks", "counter", func(ctx *vango.Context) *vdom.VNode {
+		increment := func() { count.Update(nil, func(n int) int { return n + 1 }) }
+		return vdom.NewElement("button", vdom.Props{"onClick": increment},
+			vdom.NewText(strconv.Itoa(count.Get(ctx))))
+	})
+	if err != nil {
+		t.Fatalf("CreateServerComponent() error = %v", err)
+	}
+
+	patches := readPatches(t, client)
+	if len(patches) != 1 || patches[0].Op != vdom.OpInsertNode || patches[0].Node.Tag != "button" {
+		t.Fatalf("first patches = %v, want the button inserted", patches)
+	}
+	button := patches[0].Node
+
+	click, err := EncodeEvent(Event{Type: EventClick, NodeID: button.ID})
+	if err != nil {
+		t.Fatalf("EncodeEvent() error = %v", err)
+	}
+	if err := client.WriteFrame(click); err != nil {
+		t.Fatalf("WriteFrame() error = %v", err)
+	}
+
+	patches = readPatches(t, client)
+	want := vdom.Patch{Op: vdom.OpReplaceText, NodeID: button.Kids[0].ID, Value: "1"}
+	if len(patches) != 1 || patches[0].Op != want.Op || patches[0].NodeID != want.NodeID || patches[0].Value != want.Value {
+		t.Fatalf("patches after click = %v, want %v", patches, want)
+	}
+}
+
+// label is a component rendering a span
+type label struct{}
+
+func (label) Render(ctx *vango.Context) *vdom.VNode {
+	return vdom.NewElement("span", nil, vdom.NewText("label"))
+}
+
+func TestSchedulerBridge_ResyncRendersAfresh(t *testing.T) {
+	client := connectBridged(t, "resync")
+	sched := GetBridge().CreateSessionScheduler("resync")
+	_, err := GetBridge().CreateServerComponent("resync", "page", func(ctx *vango.Context) *vdom.VNode {
+		return vdom.NewElement("div", nil, vango.Child(label{}, nil))
+	})
+	if err != nil {
+		t.Fatalf("CreateServerComponent() error = %v", err)
+	}
+	first := readPatches(t, client)
+	if len(first) != 1 || first[0].Op != vdom.OpInsertNode || sched.FiberCount() != 2 {
+		t.Fatalf("first patches = %v with %d fibers, want the tree inserted by two fibers", first, sched.FiberCount())
+	}
+
+	if err := GetBridge().ResyncSession("resync"); err != nil {
+		t.Fatalf("ResyncSession() error = %v", err)
+	}
+	patches := readPatches(t, client)
+	if len(patches) != 1 || patches[0].Op != vdom.OpInsertNode || patches[0].NodeID <= first[0].Node.Kids[0].ID {
+		t.Fatalf("patches after resync = %v, want the tree inserted with new IDs", patches)
+	}
+	if child := patches[0].Node.Kids[0]; len(child.Kids) != 1 || child.Kids[0].Tag != "span" {
+		t.Errorf("resynced tree holds %v, want the child's span", child)
+	}
+	if n := sched.FiberCount(); n != 2 {
+		t.Errorf("FiberCount() = %d after resync, want the old child fiber replaced", n)
+	}
+}
+
+func TestSchedulerBridge_EventsStayInSession(t *testing.T) {
+	bridge := NewSchedulerBridge(NewServer())
+	ran := false
+	mine := server.NewComponentInstance("mine", "a", nil)
+	mine.On(5, "click", func() { ran = true })
+	bridge.sessions["a"] = &BridgedSession{
+		Components: map[string]*server.ComponentInstance{"mine": mine},
+		handlers:   newHandlerTable(),
+	}
+	bridge.sessions["b"] = &BridgedSession{
+		Components: map[string]*server.ComponentInstance{},
+		handlers:   newHandlerTable(),
+	}
+
+	if err := bridge.HandleComponentEvent("b", 5, vango.Event{Type: "click"}); err != ErrComponentNotFound || ran {
+		t.Errorf("event of another session: error = %v, ran = %v; want ErrComponentNotFound, false", err, ran)
+	}
+	if err := bridge.HandleComponentEvent("a", 5, vango.Event{Type: "click"}); err != nil || !ran {
+		t.Errorf("event of the owning session: error = %v, ran = %v; want nil, true", err, ran)
+	}
+	if err := bridge.HandleComponentEvent("c", 5, vango.Event{Type: "click"}); err != ErrSessionNotFound {
+		t.Errorf("event of an unknown session: error = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestHandlerTable_FollowsPatches(t *testing.T) {
+	table := newHandlerTable()
+	first, second := func() {}, func() {}
+	tree := vdom.NewElement("div", nil,
+		vdom.NewElement("button", vdom.Props{"onClick": first, "onKeyDown": first}))
+	vdom.Mount(vdom.NewIDAllocator(), tree)
+	button := tree.Kids[0].ID
+
+	if names := table.apply([]vdom.Patch{{Op: vdom.OpInsertNode, NodeID: tree.ID, Node: tree}}); len(names) != 2 {
+		t.Errorf("apply() bound %v, want click and keydown", names)
+	}
+	table.apply([]vdom.Patch{
+		{Op: vdom.OpSetHandler, NodeID: button, Key: "onClick", Handler: second},
+		{Op: vdom.OpUpdateEvents, NodeID: button, Events: vdom.Listeners(vdom.Props{"onClick": second})},
+	})
+	if h, ok := table.lookup(button, "click"); !ok || h == nil {
+		t.Error("lookup(click) found no handler after the swap")
+	}
+	if _, ok := table.lookup(button, "keydown"); ok {
+		t.Error("lookup(keydown) found a handler for an event no longer listened to")
+	}
+
+	table.apply([]vdom.Patch{{Op: vdom.OpRemoveNode, NodeID: tree.ID}})
+	if _, ok := table.lookup(button, "click"); ok {
+		t.Error("lookup(click) found a handler of a removed subtree")
+	}
+	if len(table.kids) != 0 || len(table.parents) != 0 {
+		t.Errorf("table kept %d parents and %v children after the removal", len(table.parents), table.kids)
+	}
+}
@@ -5,15 +5,17 @@ package live
 
 import (
 	"bytes"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/recera/vango/pkg/vango"
 	"github.com/recera/vango/pkg/vango/vdom"
 )
 
@@ -22,41 +24,114 @@ type Server struct {
 	upgrader websocket.Upgrader
 	sessions map[string]*Session
 	mu       sync.RWMutex
+
+	// Replay buffer limits for new sessions
+	replayFrames int
+	replayBytes  int
+
+	// Cap on coalesced patches held for a slow client
+	pendingBytes int
+
+	// Connection authorization, see auth.go
+	allowedOrigins []string
+	signer         *SessionSigner
+	cookieName     string
+
+	// Session lifecycle, see lifecycle.go
+	idleTimeout    time.Duration
+	reconnectGrace time.Duration
+	onSessionStart []func(*Session)
+	onSessionEnd   []func(*Session)
+	sweepOnce      sync.Once
+	closeOnce      sync.Once
+	done           chan struct{}
 }
 
 // Session represents a live connection session
 type Session struct {
-	ID         string
-	conn       *websocket.Conn
-	state      map[string]interface{}
-	lastSeq    uint64
-	sendChan   chan []byte
-	sendTextChan chan []byte  // For JSON/text messages
-	closeChan  chan struct{}
-	mu         sync.RWMutex
+	ID            string
+	transport     Transport // current client connection
+	state         map[string]interface{}
+	events        *EventRegistry // event name <-> wire ID bindings for this session
+	lastSeq       uint64         // sequence number of the last patch frame
+	replay        *replayBuffer  // recent patch frames for resuming clients
+	helloSeq      uint64         // lastSeq announced in the server HELLO
+	awaitingHello bool           // patch frames are held until the client HELLO
+	outbox        [][]byte       // frames waiting, in order, for room in sendChan
+	pending       patchCoalescer // patches merged while sendChan is full
+	maxPending    int            // pending size that forces a resync
+	needsResync   bool           // pending overflowed; resync once there is room
+	commands      [][]byte       // command frames waiting their turn, with patches coalesced between them
+	commandsFirst bool           // the pending patches came after the commands
+	sendChan      chan []byte
+	closeChan     chan struct{}
+	mu            sync.RWMutex
+
+	// Lifecycle state read by the server's sweeper
+	connected      bool
+	lastActive     time.Time
+	disconnectedAt time.Time
 }
 
 // NewServer creates a new live protocol server
 func NewServer() *Server {
-	return &Server{
+	s := &Server{
 		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				// TODO: Implement proper origin checking
-				return true
-			},
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
 		},
-		sessions: make(map[string]*Session),
+		sessions:       make(map[string]*Session),
+		replayFrames:   DefaultReplayFrames,
+		replayBytes:    DefaultReplayBytes,
+		pendingBytes:   DefaultPendingBytes,
+		idleTimeout:    DefaultIdleTimeout,
+		reconnectGrace: DefaultReconnectGrace,
+		done:           make(chan struct{}),
+	}
+	s.upgrader.CheckOrigin = s.checkOrigin
+	return s
+}
+
+// SetReplayLimits sets how many patch frames, and how many bytes of them,
+// each new session keeps for clients that reconnect. A client that missed
+// more than that receives a full re-render instead.
+func (s *Server) SetReplayLimits(maxFrames, maxBytes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replayFrames = maxFrames
+	s.replayBytes = maxBytes
+}
+
+// SetBackpressureLimit sets how many bytes of coalesced patches each new
+// session holds while its client is not keeping up. Past that the session
+// gives up on the patches and resyncs the client instead.
+func (s *Server) SetBackpressureLimit(maxBytes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pendingBytes = maxBytes
+}
+
+// HandleLive serves /vango/live/{id} on every transport: WebSocket upgrade
+// requests get a WebSocket, other GET requests an SSE stream, and POST
+// requests carry frames upstream for the SSE stream.
+func (s *Server) HandleLive(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case websocket.IsWebSocketUpgrade(r):
+		s.HandleWebSocket(w, r)
+	case r.Method == http.MethodGet:
+		s.HandleSSE(w, r)
+	case r.Method == http.MethodPost:
+		s.HandlePost(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 // HandleWebSocket handles WebSocket upgrade and session management
 func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	// Extract session ID from path
-	sessionID := r.URL.Path[len("/vango/live/"):]
-	if sessionID == "" {
-		http.Error(w, "Session ID required", http.StatusBadRequest)
+	// Check origin and session token before upgrading
+	sessionID, ok := s.authorizeOrReject(w, r)
+	if !ok {
 		return
 	}
 
@@ -67,48 +142,146 @@ func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Handle the session
+	go s.ServeTransport(sessionID, NewWebSocketTransport(conn))
+}
+
+// HandleSSE streams a session's frames as Server-Sent Events. The client
+// sends its frames with POST requests to the same URL.
+func (s *Server) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := s.authorizeOrReject(w, r)
+	if !ok {
+		return
+	}
+
+	transport, err := NewSSETransport(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The stream lives as long as the request
+	go func() {
+		<-r.Context().Done()
+		transport.Close()
+	}()
+	s.ServeTransport(sessionID, transport)
+}
+
+// HandlePost delivers a frame posted by an SSE client to its session
+func (s *Server) HandlePost(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := s.authorizeOrReject(w, r)
+	if !ok {
+		return
+	}
+	session, exists := s.GetSession(sessionID)
+	if !exists {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	session.mu.RLock()
+	transport, ok := session.transport.(*SSETransport)
+	session.mu.RUnlock()
+	if !ok {
+		http.Error(w, "Session has no event stream", http.StatusConflict)
+		return
+	}
+
+	frame, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPostFrameBytes))
+	if err != nil || len(frame) == 0 {
+		http.Error(w, "Invalid frame", http.StatusBadRequest)
+		return
+	}
+	if err := transport.Deliver(frame); err != nil {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ServeTransport runs a session over t until the connection ends. The
+// session is created on first use; serving it again (a reconnect) replaces
+// its previous connection.
+func (s *Server) ServeTransport(sessionID string, t Transport) {
 	// Create or get session
-	session := s.getOrCreateSession(sessionID, conn)
+	session, closeChan, created := s.getOrCreateSession(sessionID, t)
+	if created {
+		s.mu.RLock()
+		hooks := s.onSessionStart
+		s.mu.RUnlock()
+		for _, hook := range hooks {
+			hook(session)
+		}
+	}
 	
-	// Handle the session
-	go session.handleConnection()
+	session.handleConnection(t, closeChan)
+}
+
+// tokenFromPath extracts the session token from /vango/live/{token}. With
+// unsigned sessions the token is the session ID.
+func tokenFromPath(r *http.Request) string {
+	return strings.TrimPrefix(r.URL.Path, "/vango/live/")
 }
 
-// getOrCreateSession gets an existing session or creates a new one
-func (s *Server) getOrCreateSession(sessionID string, conn *websocket.Conn) *Session {
+// authorizeOrReject authorizes a live request, answering 403 if it fails
+func (s *Server) authorizeOrReject(w http.ResponseWriter, r *http.Request) (string, bool) {
+	sessionID, err := s.authorize(r)
+	if err != nil {
+		log.Printf("[Live Server] Rejected %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return "", false
+	}
+	return sessionID, true
+}
+
+// getOrCreateSession gets an existing session or creates a new one, and
+// makes t its connection. It returns the connection's close channel.
+func (s *Server) getOrCreateSession(sessionID string, t Transport) (*Session, chan struct{}, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// End idle and abandoned sessions from now on
+	s.sweepOnce.Do(func() { go s.sweeper() })
+
 	if session, exists := s.sessions[sessionID]; exists {
 		// Update connection for existing session
 		session.mu.Lock()
-		if session.conn != nil {
-			session.conn.Close()
+		if session.transport != nil {
+			session.transport.Close()
 		}
-		session.conn = conn
-		// Reset the close channel if needed
+		session.transport = t
+		// Stop the previous writer and give the new connection its own
+		// close channel
 		select {
 		case <-session.closeChan:
-			// Channel was closed, create a new one
-			session.closeChan = make(chan struct{})
+			// Already closed
 		default:
-			// Channel is still open
+			close(session.closeChan)
 		}
+		session.closeChan = make(chan struct{})
+		session.connected = true
+		session.lastActive = time.Now()
+		closeChan := session.closeChan
 		session.mu.Unlock()
-		return session
+		return session, closeChan, false
 	}
 
 	// Create new session
 	session := &Session{
-		ID:        sessionID,
-		conn:      conn,
-		state:     make(map[string]interface{}),
-		sendChan:  make(chan []byte, 256),
-		sendTextChan: make(chan []byte, 256),
-		closeChan: make(chan struct{}),
+		ID:         sessionID,
+		transport:  t,
+		state:      make(map[string]interface{}),
+		events:     NewEventRegistry(),
+		replay:     newReplayBuffer(s.replayFrames, s.replayBytes),
+		maxPending: s.pendingBytes,
+		sendChan:   make(chan []byte, 256),
+		closeChan:  make(chan struct{}),
+		connected:  true,
+		lastActive: time.Now(),
 	}
 	s.sessions[sessionID] = session
-	return session
+	return session, session.closeChan, true
 }
 
 // GetSession retrieves a session by ID
@@ -119,36 +292,60 @@ func (s *Server) GetSession(sessionID string) (*Session, bool) {
 	return session, exists
 }
 
-// RemoveSession removes a session
+// RemoveSession ends a session: its connection is closed, its scheduler
+// stopped and its components unregistered
 func (s *Server) RemoveSession(sessionID string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	delete(s.sessions, sessionID)
+	if session, exists := s.GetSession(sessionID); exists {
+		s.endSession(session, "removed")
+	}
 }
 
-// handleConnection manages the WebSocket connection for a session
-func (s *Session) handleConnection() {
+// handleConnection manages one connection of a session. t and closeChan
+// belong to this connection; a reconnect replaces them on the session
+// without affecting this call's cleanup. It returns once the writer has
+// stopped, so transports tied to an HTTP handler stay valid until then.
+func (s *Session) handleConnection(t Transport, closeChan chan struct{}) {
 	// Ensure cleanup happens only once
 	var closeOnce sync.Once
 	cleanup := func() {
 		closeOnce.Do(func() {
-			s.conn.Close()
+			t.Close()
 			// Signal writer to stop
+			s.mu.Lock()
 			select {
-			case <-s.closeChan:
+			case <-closeChan:
 				// Already closed
 			default:
-				close(s.closeChan)
+				close(closeChan)
+			}
+			// Start the reconnect grace period unless a newer connection
+			// has already taken over
+			if s.transport == t {
+				s.connected = false
+				s.disconnectedAt = time.Now()
 			}
+			s.mu.Unlock()
 		})
 	}
-	defer cleanup()
+	writerDone := make(chan struct{})
+	defer func() {
+		cleanup()
+		<-writerDone
+	}()
+
+	// Hold patch frames until the client says which ones it has
+	s.mu.Lock()
+	s.drainForReconnectLocked()
+	s.awaitingHello = true
+	s.helloSeq = s.lastSeq
+	s.mu.Unlock()
 
 	// Start writer goroutine
 	writerReady := make(chan struct{})
 	go func() {
+		defer close(writerDone)
 		close(writerReady)
-		s.writer()
+		s.writer(t, closeChan)
 	}()
 	
 	// Wait for writer to be ready
@@ -158,82 +355,58 @@ func (s *Session) handleConnection() {
 	s.sendHello()
 	log.Printf("[Live Session %s] Sent server HELLO", s.ID)
 	
+	// Tell the client every event binding known so far
+	s.sendEventBindings(s.events.Bindings())
+	
 	// Create scheduler session if we have a bridge
 	if bridge := GetBridge(); bridge != nil {
 		log.Printf("[Live Session %s] Creating scheduler session", s.ID)
 		bridge.CreateSessionScheduler(s.ID)
 	}
 
-	// Set up ping/pong to detect disconnects - but with longer initial timeout
-	s.conn.SetReadDeadline(time.Now().Add(300 * time.Second)) // 5 minutes initially
-	s.conn.SetPongHandler(func(string) error {
-		s.conn.SetReadDeadline(time.Now().Add(300 * time.Second))
-		return nil
-	})
-
 	// Read messages
 	for {
-		messageType, data, err := s.conn.ReadMessage()
+		data, err := t.ReadFrame()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("[Live Session %s] Unexpected close: %v", s.ID, err)
-			} else {
-				log.Printf("[Live Session %s] Read error: %v", s.ID, err)
-			}
+			log.Printf("[Live Session %s] Read error: %v", s.ID, err)
 			break
 		}
 
-		log.Printf("[Live Session %s] Received message type %d, size %d bytes", s.ID, messageType, len(data))
-		
-		if messageType == websocket.BinaryMessage {
-			s.handleBinaryMessage(data)
-		} else if messageType == websocket.TextMessage {
-			s.handleTextMessage(data)
-		}
+		log.Printf("[Live Session %s] Received frame, size %d bytes", s.ID, len(data))
+		s.touch()
+		s.handleBinaryMessage(data)
 	}
 }
 
-// writer handles writing messages to the WebSocket
-func (s *Session) writer() {
+// writer handles writing frames to the transport
+func (s *Session) writer(t Transport, closeChan chan struct{}) {
 	ticker := time.NewTicker(54 * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case message, ok := <-s.sendChan:
-			s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if !ok {
-				s.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				t.Close()
 				return
 			}
 
-			log.Printf("[Live Session %s] Writing binary message to WebSocket: %d bytes", s.ID, len(message))
-			if err := s.conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
+			log.Printf("[Live Session %s] Writing binary message to transport: %d bytes", s.ID, len(message))
+			if err := t.WriteFrame(message); err != nil {
 				log.Printf("[Live Session %s] Failed to write message: %v", s.ID, err)
 				return
 			}
 			log.Printf("[Live Session %s] Binary message sent successfully", s.ID)
 			
-		case message, ok := <-s.sendTextChan:
-			s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if !ok {
-				return
-			}
-
-			log.Printf("[Live Session %s] Writing text message to WebSocket: %d bytes", s.ID, len(message))
-			if err := s.conn.WriteMessage(websocket.TextMessage, message); err != nil {
-				log.Printf("[Live Session %s] Failed to write text message: %v", s.ID, err)
-				return
-			}
-			log.Printf("[Live Session %s] Text message sent successfully", s.ID)
-
+			// Room was made; move coalesced patches along
+			s.flushPending()
+			
 		case <-ticker.C:
-			s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := s.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := t.Ping(); err != nil {
 				return
 			}
 
-		case <-s.closeChan:
+		case <-closeChan:
 			return
 		}
 	}
@@ -248,11 +421,13 @@ func (s *Session) sendHello() {
 	// Write control frame type
 	encoder.WriteBytes([]byte{byte(FrameControl)})
 	encoder.WriteString("HELLO")
-	encoder.WriteUvarint(s.lastSeq)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	encoder.WriteUvarint(s.helloSeq)
 	
 	helloBytes := buf.Bytes()
 	log.Printf("[Live Session %s] Sending HELLO message: %d bytes, hex: %x", s.ID, len(helloBytes), helloBytes)
-	s.sendChan <- helloBytes
+	s.queueLocked(helloBytes)
 }
 
 // handleBinaryMessage processes binary protocol messages
@@ -291,6 +466,7 @@ func (s *Session) handleBinaryMessage(data []byte) {
 				return
 			}
 			log.Printf("[Live Session %s] Client hello: resumable=%v, lastSeq=%d", s.ID, resumable > 0, lastSeq)
+			s.resume(resumable > 0, lastSeq)
 			
 		case "PING":
 			// Send pong
@@ -299,93 +475,214 @@ func (s *Session) handleBinaryMessage(data []byte) {
 	}
 }
 
-// handleTextMessage processes text protocol messages (for debugging)
-func (s *Session) handleTextMessage(data []byte) {
-	log.Printf("[Live Session %s] Text message: %s", s.ID, string(data))
-}
+// resume answers the client HELLO. A resumable client gets the patch frames
+// after its lastSeq from the replay buffer; a fresh client gets the frames
+// produced since the server HELLO. When those frames are no longer buffered
+// the client is told to RESYNC and the components render from scratch.
+func (s *Session) resume(resumable bool, clientSeq uint64) {
+	s.mu.Lock()
+	if !s.awaitingHello {
+		s.mu.Unlock()
+		log.Printf("[Live Session %s] Ignoring repeated client HELLO", s.ID)
+		return
+	}
+	s.awaitingHello = false
+
+	from := s.helloSeq
+	if resumable {
+		from = clientSeq
+	}
+
+	// Frames that do not fit in sendChan wait in the outbox for the writer
+	if frames, ok := s.replay.since(from, s.lastSeq); ok && !s.needsResync {
+		for _, frame := range frames {
+			s.queueLocked(frame)
+		}
+		s.mu.Unlock()
+		log.Printf("[Live Session %s] Resumed from seq %d, replayed %d frames", s.ID, from, len(frames))
+		return
+	}
+
+	// The gap is too large; start over from a full render
+	frame := encodeResync(s.lastSeq + 1)
+	s.queueLocked(frame)
+	s.startResyncLocked(frame)
+	s.mu.Unlock()
+	log.Printf("[Live Session %s] Cannot resume from seq %d (at %d), resyncing", s.ID, from, s.lastSeq)
 
-// handleEvent processes client events
-func (s *Session) handleEvent(event *Event) {
-	log.Printf("[Live Session %s] Event: type=%v, nodeID=%d", s.ID, event.Type, event.NodeID)
-	
-	// Check if we have a scheduler bridge
 	if bridge := GetBridge(); bridge != nil {
-		// Convert event type to string
-		var eventTypeStr string
-		switch event.Type {
-		case EventClick:
-			eventTypeStr = "click"
-		case EventIncrement:
-			eventTypeStr = "increment"
-		case EventDecrement:
-			eventTypeStr = "decrement"
-		case EventReset:
-			eventTypeStr = "reset"
-		default:
-			eventTypeStr = "unknown"
+		if err := bridge.ResyncSession(s.ID); err != nil {
+			log.Printf("[Live Session %s] Failed to resync: %v", s.ID, err)
 		}
-		
-		// Route event to component via bridge
-		if err := bridge.HandleComponentEvent(s.ID, event.NodeID, eventTypeStr); err != nil {
-			log.Printf("[Live Session %s] Failed to handle component event: %v", s.ID, err)
-			// Fall back to legacy handling
-			s.handleEventLegacy(event)
+	}
+}
+
+// queue is queueLocked for callers not holding s.mu
+func (s *Session) queue(frame []byte) {
+	s.mu.Lock()
+	s.queueLocked(frame)
+	s.mu.Unlock()
+}
+
+// queueLocked sends a frame after the frames already waiting in the
+// outbox, without blocking. It waits in the outbox while sendChan is full;
+// the writer moves it along once there is room.
+func (s *Session) queueLocked(frame []byte) {
+	if len(s.outbox) == 0 {
+		select {
+		case s.sendChan <- frame:
+			return
+		default:
 		}
-		// Component will handle state update and scheduler will send patches
-		return
 	}
-	
-	// Fall back to legacy event handling
-	s.handleEventLegacy(event)
-}
-
-// handleEventLegacy is the old hardcoded event handler (for backward compatibility)
-func (s *Session) handleEventLegacy(event *Event) {
-	// Handle counter events with hardcoded logic
-	var newValue int
-	currentValue, exists := s.state["counter"]
-	if exists {
-		if val, ok := currentValue.(int); ok {
-			newValue = val
+	s.outbox = append(s.outbox, frame)
+}
+
+// flushOutboxLocked moves outbox frames into sendChan while there is room
+// and reports whether the outbox is empty
+func (s *Session) flushOutboxLocked() bool {
+	for len(s.outbox) > 0 {
+		select {
+		case s.sendChan <- s.outbox[0]:
+			s.outbox[0] = nil
+			s.outbox = s.outbox[1:]
+		default:
+			return false
 		}
 	}
+	s.outbox = nil
+	return true
+}
+
+// startResyncLocked records a sent RESYNC frame. The frame takes the next
+// sequence number and replaces the replay history, so a client that misses
+// it gets it replayed instead of appearing up to date. Commands still
+// waiting are dropped. The caller asks the bridge to re-render.
+func (s *Session) startResyncLocked(frame []byte) {
+	s.lastSeq++
+	s.replay.reset()
+	s.replay.add(s.lastSeq, frame)
+	s.pending.reset()
+	s.commands = nil
+	s.commandsFirst = false
+	s.needsResync = false
+}
+
+// encodeResync builds a RESYNC control frame. seq is the sequence number the
+// client continues counting from.
+func encodeResync(seq uint64) []byte {
+	buf := []byte{byte(FrameControl)}
+	buf = appendUvarint(buf, uint64(len("RESYNC")))
+	buf = append(buf, "RESYNC"...)
+	return appendUvarint(buf, seq)
+}
+
+// handleEvent processes client events
+func (s *Session) handleEvent(event *Event) {
+	name, ok := s.events.Name(event.Type)
+	if !ok {
+		log.Printf("[Live Session %s] Unknown event type %d on node %d", s.ID, event.Type, event.NodeID)
+		return
+	}
+	log.Printf("[Live Session %s] Event: type=%s, nodeID=%d", s.ID, name, event.NodeID)
 	
-	switch event.Type {
-	case EventIncrement:
-		newValue++
-		s.state["counter"] = newValue
-		log.Printf("[Live Session %s] Counter incremented to %d (legacy)", s.ID, newValue)
-		
-	case EventDecrement:
-		newValue--
-		s.state["counter"] = newValue
-		log.Printf("[Live Session %s] Counter decremented to %d (legacy)", s.ID, newValue)
-		
-	case EventReset:
-		newValue = 0
-		s.state["counter"] = newValue
-		log.Printf("[Live Session %s] Counter reset to %d (legacy)", s.ID, newValue)
-		
-	default:
-		log.Printf("[Live Session %s] Unknown event type: %v", s.ID, event.Type)
+	bridge := GetBridge()
+	if bridge == nil {
+		log.Printf("[Live Session %s] No scheduler bridge, dropping %s event", s.ID, name)
 		return
 	}
 	
-	// Send update back to client
-	// For now, send a simple JSON update (in production, this would be binary patches)
-	update := map[string]interface{}{
-		"type": "update",
-		"value": newValue,
+	// Route event to component via bridge; the component updates its state
+	// and the scheduler sends the resulting patches
+	if err := bridge.HandleComponentEvent(s.ID, event.NodeID, event.ToVangoEvent(name)); err != nil {
+		log.Printf("[Live Session %s] Failed to handle component event: %v", s.ID, err)
+	}
+}
+
+// ToVangoEvent converts a decoded live event into the vango.Event passed to
+// component handlers. Well-known payload fields fill the typed fields; all
+// fields remain available in Data.
+func (e *Event) ToVangoEvent(eventType string) vango.Event {
+	evt := vango.Event{
+		Type: eventType,
+		Data: e.Data,
 	}
 	
-	if data, err := json.Marshal(update); err == nil {
-		select {
-		case s.sendTextChan <- data:
-			log.Printf("[Live Session %s] Sent update: %d (legacy)", s.ID, newValue)
-		default:
-			log.Printf("[Live Session %s] Send buffer full, dropping update", s.ID)
+	for name, value := range e.Data {
+		switch v := value.(type) {
+		case string:
+			switch name {
+			case "value":
+				evt.Target.Value = v
+			case "targetId":
+				evt.Target.ID = v
+			case "key":
+				evt.Key = v
+			}
+		case bool:
+			switch name {
+			case "checked":
+				evt.Target.Checked = v
+			case "altKey":
+				evt.AltKey = v
+			case "ctrlKey":
+				evt.CtrlKey = v
+			case "metaKey":
+				evt.MetaKey = v
+			case "shiftKey":
+				evt.ShiftKey = v
+			}
+		case int:
+			switch name {
+			case "keyCode":
+				evt.KeyCode = v
+			case "button":
+				evt.Button = v
+			case "clientX":
+				evt.ClientX = float64(v)
+			case "clientY":
+				evt.ClientY = float64(v)
+			}
+		case float64:
+			switch name {
+			case "clientX":
+				evt.ClientX = v
+			case "clientY":
+				evt.ClientY = v
+			}
+		case map[string]string:
+			if name == "form" {
+				evt.Form = v
+			}
 		}
 	}
+	
+	return evt
+}
+
+// DeclareEvents registers event names handled by this session's components.
+// Names without a wire ID get one, and the new bindings are sent to the client.
+func (s *Session) DeclareEvents(names ...string) {
+	if added := s.events.Declare(names...); len(added) > 0 {
+		s.sendEventBindings(added)
+	}
+}
+
+// Events returns the session's event registry
+func (s *Session) Events() *EventRegistry {
+	return s.events
+}
+
+// sendEventBindings sends an EVENTS control frame
+func (s *Session) sendEventBindings(bindings []EventBinding) {
+	var buf bytes.Buffer
+	encoder := NewEncoder(&buf)
+	
+	encoder.WriteBytes([]byte{byte(FrameControl)})
+	encoder.WriteString("EVENTS")
+	encoder.WriteBytes(appendEventBindings(nil, bindings))
+	
+	s.queue(buf.Bytes())
 }
 
 // sendControl sends a control message
@@ -396,11 +693,12 @@ func (s *Session) sendControl(msgType string) {
 	encoder.WriteBytes([]byte{byte(FrameControl)})
 	encoder.WriteString(msgType)
 	
-	s.sendChan <- buf.Bytes()
+	s.queue(buf.Bytes())
 }
 
 // SendPatches sends a batch of patches to the client
 func (s *Session) SendPatches(patches []vdom.Patch) error {
+	patches = vdom.OptimizePatches(wirePatches(patches))
 	if len(patches) == 0 {
 		return nil
 	}
@@ -411,18 +709,303 @@ func (s *Session) SendPatches(patches []vdom.Patch) error {
 		return fmt.Errorf("failed to encode patches: %w", err)
 	}
 
-	// Send via channel (non-blocking)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastActive = time.Now()
+
+	if s.needsResync {
+		// A full re-render replaces these patches
+		return nil
+	}
+	if !s.pending.empty() || len(s.commands) > 0 || len(s.outbox) > 0 {
+		// Queue behind the frames already waiting so order is kept
+		s.coalesceLocked(patches)
+		return nil
+	}
+
+	if s.awaitingHello {
+		// Sent by resume once the client says where it is
+		s.lastSeq++
+		s.replay.add(s.lastSeq, data)
+		return nil
+	}
+
+	// Send via channel (non-blocking); every patch frame gets the next
+	// sequence number and is kept for replay
 	select {
 	case s.sendChan <- data:
 		s.lastSeq++
+		s.replay.add(s.lastSeq, data)
+	default:
+		// The client is not keeping up; merge until the writer catches up
+		s.coalesceLocked(patches)
+	}
+	return nil
+}
+
+// SendCommand sends a command for the client to run after the patches sent
+// before it. Commands sent while a resync is pending are dropped.
+func (s *Session) SendCommand(cmd Command) error {
+	data, err := EncodeCommand(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to encode command: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastActive = time.Now()
+
+	if s.needsResync {
+		log.Printf("[Live Session %s] Dropping command %d, resync pending", s.ID, cmd.Type)
 		return nil
+	}
+	if !s.pending.empty() || len(s.commands) > 0 || len(s.outbox) > 0 {
+		// Wait for the frames queued before it. Patches coalesced after
+		// the waiting commands become a frame of their own between them.
+		if s.commandsFirst && !s.pending.empty() {
+			frame, err := EncodePatches(vdom.OptimizePatches(s.pending.take()))
+			if err != nil {
+				log.Printf("[Live Session %s] Failed to encode coalesced patches: %v", s.ID, err)
+				s.needsResync = true
+				return nil
+			}
+			s.commands = append(s.commands, frame)
+		}
+		s.commands = append(s.commands, data)
+		return nil
+	}
+
+	if s.awaitingHello {
+		s.lastSeq++
+		s.replay.add(s.lastSeq, data)
+		return nil
+	}
+
+	select {
+	case s.sendChan <- data:
+		s.lastSeq++
+		s.replay.add(s.lastSeq, data)
 	default:
-		return fmt.Errorf("send buffer full")
+		s.commands = append(s.commands, data)
+	}
+	return nil
+}
+
+// Focus moves focus to the element matching selector
+func (s *Session) Focus(selector string) error {
+	return s.SendCommand(Command{Type: CommandFocus, Target: selector})
+}
+
+// ScrollIntoView scrolls the element matching selector into view. block is
+// the vertical alignment ("start", "center", "end" or "nearest"; empty for
+// the browser default).
+func (s *Session) ScrollIntoView(selector, block string, smooth bool) error {
+	return s.SendCommand(Command{Type: CommandScroll, Target: selector, Arg: block, Flag: smooth})
+}
+
+// Navigate puts url in the address bar without loading it; the session
+// renders the new page itself. With replace the current history entry is
+// replaced instead of a new one being pushed.
+func (s *Session) Navigate(url string, replace bool) error {
+	return s.SendCommand(Command{Type: CommandNavigate, Value: url, Flag: replace})
+}
+
+// Load makes the browser load url as a new page, ending the session's
+// connection. With replace the current history entry is replaced.
+func (s *Session) Load(url string, replace bool) error {
+	return s.SendCommand(Command{Type: CommandLoad, Value: url, Flag: replace})
+}
+
+// SetTitle sets document.title
+func (s *Session) SetTitle(title string) error {
+	return s.SendCommand(Command{Type: CommandTitle, Value: title})
+}
+
+// CallJS calls the global function with the given name (dots reach into
+// objects, e.g. "app.notify") with args encoded as JSON
+func (s *Session) CallJS(function string, args ...interface{}) error {
+	if args == nil {
+		args = []interface{}{}
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("failed to encode arguments for %s: %w", function, err)
+	}
+	return s.SendCommand(Command{Type: CommandCall, Value: function, Arg: string(data)})
+}
+
+// Download makes the browser download url, saved as filename if not empty
+func (s *Session) Download(url, filename string) error {
+	return s.SendCommand(Command{Type: CommandDownload, Value: url, Arg: filename})
+}
+
+// SessionFromContext returns the live session of a server-driven
+// component's context, so event handlers can send commands
+func SessionFromContext(ctx *vango.Context) (*Session, bool) {
+	bridge := GetBridge()
+	if ctx == nil || bridge == nil {
+		return nil, false
 	}
+	return bridge.server.GetSession(ctx.SessionID)
+}
+
+// coalesceLocked merges patches into the pending batch, giving up on it in
+// favor of a resync once it outgrows maxPending. A batch started while
+// commands wait is sent after them.
+func (s *Session) coalesceLocked(patches []vdom.Patch) {
+	if s.pending.empty() {
+		log.Printf("[Live Session %s] Send buffer full, coalescing patches", s.ID)
+		s.commandsFirst = len(s.commands) > 0
+	}
+	s.pending.add(patches)
+	if s.pending.bytes > s.maxPending {
+		log.Printf("[Live Session %s] Pending patches exceed %d bytes, forcing resync", s.ID, s.maxPending)
+		s.pending.reset()
+		s.needsResync = true
+	}
+}
+
+// flushPending sends the outbox, then the coalesced batch as one frame or
+// the forced RESYNC, if the send buffer has room. The writer calls it after
+// every write.
+func (s *Session) flushPending() {
+	s.mu.Lock()
+	if !s.flushOutboxLocked() || s.awaitingHello || (s.pending.empty() && len(s.commands) == 0 && !s.needsResync) {
+		s.mu.Unlock()
+		return
+	}
+
+	if s.needsResync {
+		frame := encodeResync(s.lastSeq + 1)
+		select {
+		case s.sendChan <- frame:
+			s.startResyncLocked(frame)
+		default:
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+		
+		if bridge := GetBridge(); bridge != nil {
+			if err := bridge.ResyncSession(s.ID); err != nil {
+				log.Printf("[Live Session %s] Failed to resync: %v", s.ID, err)
+			}
+		}
+		return
+	}
+
+	defer s.mu.Unlock()
+	if s.commandsFirst && !s.flushCommandsLocked() {
+		return
+	}
+	if !s.pending.empty() {
+		data, err := EncodePatches(vdom.OptimizePatches(s.pending.patches))
+		if err != nil {
+			log.Printf("[Live Session %s] Failed to encode coalesced patches: %v", s.ID, err)
+			s.pending.reset()
+			s.needsResync = true
+			return
+		}
+		select {
+		case s.sendChan <- data:
+			s.pending.reset()
+			s.lastSeq++
+			s.replay.add(s.lastSeq, data)
+		default:
+			// Still full; try again after the next write
+			return
+		}
+	}
+
+	// Commands sent while patches were pending follow them
+	s.flushCommandsLocked()
+}
+
+// flushCommandsLocked sends the waiting commands while sendChan has room
+// and reports whether none are left
+func (s *Session) flushCommandsLocked() bool {
+	for len(s.commands) > 0 {
+		select {
+		case s.sendChan <- s.commands[0]:
+			s.lastSeq++
+			s.replay.add(s.lastSeq, s.commands[0])
+			s.commands = s.commands[1:]
+		default:
+			return false
+		}
+	}
+	s.commands = nil
+	s.commandsFirst = false
+	return true
+}
+
+// drainForReconnectLocked prepares the session for a new connection. Frames
+// queued for the old connection, in sendChan or the outbox, are dropped;
+// patch frames among them are in the replay buffer for the client to ask
+// for. Coalesced patches become a replayable frame too, in order with the
+// waiting commands.
+func (s *Session) drainForReconnectLocked() {
+	for drained := false; !drained; {
+		select {
+		case <-s.sendChan:
+		default:
+			drained = true
+		}
+	}
+	s.outbox = nil
+
+	if s.needsResync {
+		return
+	}
+	commands := s.commands
+	s.commands = nil
+	if s.commandsFirst {
+		s.replayLocked(commands)
+		commands = nil
+	}
+	s.commandsFirst = false
+	if !s.pending.empty() {
+		data, err := EncodePatches(vdom.OptimizePatches(s.pending.take()))
+		if err != nil {
+			log.Printf("[Live Session %s] Failed to encode coalesced patches: %v", s.ID, err)
+			s.needsResync = true
+			return
+		}
+		s.replayLocked([][]byte{data})
+	}
+	s.replayLocked(commands)
+}
+
+// replayLocked gives frames the next sequence numbers and adds them to the
+// replay buffer without sending them
+func (s *Session) replayLocked(frames [][]byte) {
+	for _, frame := range frames {
+		s.lastSeq++
+		s.replay.add(s.lastSeq, frame)
+	}
+}
+
+// wirePatches drops the patches that have no meaning for the client.
+// Handler swaps only concern in-process appliers; server-driven handlers
+// stay on the server and are found by node ID and event name.
+func wirePatches(patches []vdom.Patch) []vdom.Patch {
+	for i := range patches {
+		if patches[i].Op == vdom.OpSetHandler {
+			kept := append([]vdom.Patch(nil), patches[:i]...)
+			for _, p := range patches[i+1:] {
+				if p.Op != vdom.OpSetHandler {
+					kept = append(kept, p)
+				}
+			}
+			return kept
+		}
+	}
+	return patches
 }
 
 // EncodePatches encodes patches to binary format
 func EncodePatches(patches []vdom.Patch) ([]byte, error) {
+	patches = wirePatches(patches)
 	var buf bytes.Buffer
 	encoder := NewEncoder(&buf)
 	
@@ -438,7 +1021,7 @@ func EncodePatches(patches []vdom.Patch) ([]byte, error) {
 		encoder.WriteBytes([]byte{byte(patch.Op)})
 		
 		switch patch.Op {
-		case vdom.OpReplaceText:
+		case vdom.OpReplaceText, vdom.OpSetHTML:
 			encoder.WriteUvarint(uint64(patch.NodeID))
 			encoder.WriteString(patch.Value)
 			
@@ -458,13 +1041,21 @@ func EncodePatches(patches []vdom.Patch) ([]byte, error) {
 			encoder.WriteUvarint(uint64(patch.NodeID))
 			encoder.WriteUvarint(uint64(patch.ParentID))
 			encoder.WriteUvarint(uint64(patch.BeforeID))
-			// TODO: Serialize VNode tree
+			if patch.Node == nil {
+				return nil, fmt.Errorf("insert patch for node %d has no VNode", patch.NodeID)
+			}
+			if _, err := encoder.WriteVNode(patch.Node, patch.NodeID); err != nil {
+				return nil, fmt.Errorf("failed to encode subtree for node %d: %w", patch.NodeID, err)
+			}
 			
 		case vdom.OpUpdateEvents:
+			// Events travel by name; IDs are local to each process
 			encoder.WriteUvarint(uint64(patch.NodeID))
-			tmp := make([]byte, 4)
-			binary.LittleEndian.PutUint32(tmp, patch.EventBits)
-			encoder.WriteBytes(tmp)
+			encoder.WriteUvarint(uint64(len(patch.Events)))
+			for _, l := range patch.Events {
+				encoder.WriteString(l.Event.String())
+				encoder.WriteBytes([]byte{byte(l.Options)})
+			}
 			
 		case vdom.OpMoveNode:
 			encoder.WriteUvarint(uint64(patch.NodeID))
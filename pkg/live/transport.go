@@ -0,0 +1,154 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Transport carries binary protocol frames for one connection of a session.
+// A session reads from one goroutine and writes from another, so
+// implementations must allow one concurrent reader and one writer.
+type Transport interface {
+	// ReadFrame blocks until the next frame from the client arrives
+	ReadFrame() ([]byte, error)
+
+	// WriteFrame sends a frame to the client
+	WriteFrame(frame []byte) error
+
+	// Ping checks that the client is still there. The writer calls it
+	// periodically; transports without a ping return nil.
+	Ping() error
+
+	// Close ends the connection; a blocked ReadFrame returns an error
+	Close() error
+}
+
+// ErrTransportClosed is returned by transports used after Close
+var ErrTransportClosed = errors.New("transport closed")
+
+// WebSocketTransport carries frames as binary WebSocket messages
+type WebSocketTransport struct {
+	conn *websocket.Conn
+}
+
+// NewWebSocketTransport wraps an upgraded WebSocket connection
+func NewWebSocketTransport(conn *websocket.Conn) *WebSocketTransport {
+	// Pongs keep the connection alive - with a long initial timeout
+	conn.SetReadDeadline(time.Now().Add(300 * time.Second)) // 5 minutes initially
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(300 * time.Second))
+		return nil
+	})
+
+	return &WebSocketTransport{conn: conn}
+}
+
+// ReadFrame reads the next binary message. Text messages are only logged
+// for debugging.
+func (t *WebSocketTransport) ReadFrame() ([]byte, error) {
+	for {
+		messageType, data, err := t.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("[Live Transport] Unexpected close: %v", err)
+			}
+			return nil, err
+		}
+
+		switch messageType {
+		case websocket.BinaryMessage:
+			return data, nil
+		case websocket.TextMessage:
+			log.Printf("[Live Transport] Text message: %s", string(data))
+		}
+	}
+}
+
+// WriteFrame writes a binary message
+func (t *WebSocketTransport) WriteFrame(frame []byte) error {
+	t.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return t.conn.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+// Ping sends a WebSocket ping
+func (t *WebSocketTransport) Ping() error {
+	t.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return t.conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+// Close closes the connection
+func (t *WebSocketTransport) Close() error {
+	return t.conn.Close()
+}
+
+// PipeTransport is one end of an in-memory transport, for tests and
+// in-process clients. Frames written to one end are read from the other.
+type PipeTransport struct {
+	in    <-chan []byte
+	out   chan<- []byte
+	done  chan struct{}
+	close *sync.Once
+}
+
+// NewPipe creates a connected pair of in-memory transports. Serve one end
+// with Server.ServeTransport and drive the other as the client. Closing
+// either end closes both.
+func NewPipe() (server, client *PipeTransport) {
+	toClient := make(chan []byte, 256)
+	toServer := make(chan []byte, 256)
+	done := make(chan struct{})
+	once := &sync.Once{}
+
+	server = &PipeTransport{in: toServer, out: toClient, done: done, close: once}
+	client = &PipeTransport{in: toClient, out: toServer, done: done, close: once}
+	return server, client
+}
+
+// ReadFrame returns the next frame written by the other end
+func (t *PipeTransport) ReadFrame() ([]byte, error) {
+	select {
+	case frame := <-t.in:
+		return frame, nil
+	case <-t.done:
+		return nil, ErrTransportClosed
+	}
+}
+
+// WriteFrame hands a copy of frame to the other end
+func (t *PipeTransport) WriteFrame(frame []byte) error {
+	frame = append([]byte(nil), frame...)
+	select {
+	case <-t.done:
+		return ErrTransportClosed
+	default:
+	}
+	select {
+	case t.out <- frame:
+		return nil
+	case <-t.done:
+		return ErrTransportClosed
+	}
+}
+
+// Ping always succeeds while the pipe is open
+func (t *PipeTransport) Ping() error {
+	select {
+	case <-t.done:
+		return ErrTransportClosed
+	default:
+		return nil
+	}
+}
+
+// Close closes both ends of the pipe
+func (t *PipeTransport) Close() error {
+	t.close.Do(func() { close(t.done) })
+	return nil
+}
@@ -0,0 +1,104 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// maxPostFrameBytes bounds a frame sent upstream with HTTP POST
+const maxPostFrameBytes = 1 << 20
+
+// SSETransport carries frames downstream as Server-Sent Events and upstream
+// as HTTP POST requests, for networks that block WebSocket upgrades. SSE is
+// a text protocol, so each frame is sent base64-encoded as one event:
+//
+//	data: <base64 frame>
+//
+// Frames posted by the client are handed over with Deliver.
+type SSETransport struct {
+	w        http.ResponseWriter
+	flusher  http.Flusher
+	incoming chan []byte
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewSSETransport prepares an event stream on w. The response starts with
+// the first frame, so the client only sees the stream open once the session
+// is serving it. The handler that owns w must not return before the
+// transport is closed.
+func NewSSETransport(w http.ResponseWriter) (*SSETransport, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("response writer does not support flushing")
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // Disable proxy buffering
+
+	return &SSETransport{
+		w:        w,
+		flusher:  flusher,
+		incoming: make(chan []byte, 64),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// ReadFrame returns the next frame posted by the client
+func (t *SSETransport) ReadFrame() ([]byte, error) {
+	select {
+	case frame := <-t.incoming:
+		return frame, nil
+	case <-t.done:
+		return nil, ErrTransportClosed
+	}
+}
+
+// WriteFrame sends a frame as one event
+func (t *SSETransport) WriteFrame(frame []byte) error {
+	return t.write("data: " + base64.StdEncoding.EncodeToString(frame) + "\n\n")
+}
+
+// Ping sends an SSE comment, which also keeps proxies from timing out
+func (t *SSETransport) Ping() error {
+	return t.write(": ping\n\n")
+}
+
+// write writes to the stream and flushes
+func (t *SSETransport) write(s string) error {
+	select {
+	case <-t.done:
+		return ErrTransportClosed
+	default:
+	}
+	if _, err := fmt.Fprint(t.w, s); err != nil {
+		return err
+	}
+	t.flusher.Flush()
+	return nil
+}
+
+// Deliver hands a frame posted by the client to the reader
+func (t *SSETransport) Deliver(frame []byte) error {
+	select {
+	case t.incoming <- frame:
+		return nil
+	case <-t.done:
+		return ErrTransportClosed
+	}
+}
+
+// Close ends the stream
+func (t *SSETransport) Close() error {
+	t.once.Do(func() { close(t.done) })
+	return nil
+}
@@ -0,0 +1,161 @@
+//go:build !wasm
+// +build !wasm
+
+package live
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+// controlType returns the message type of a control frame, or "" otherwise
+func controlType(frame []byte) string {
+	if len(frame) == 0 || MessageType(frame[0]) != FrameControl {
+		return ""
+	}
+	r := &frameReader{data: frame, off: 1}
+	msgType, _ := r.readString()
+	return msgType
+}
+
+// clientHello builds a client HELLO frame
+func clientHello(resumable bool, lastSeq uint64) []byte {
+	frame := []byte{byte(FrameControl)}
+	frame = appendUvarint(frame, uint64(len("HELLO")))
+	frame = append(frame, "HELLO"...)
+	if resumable {
+		frame = appendUvarint(frame, 1)
+	} else {
+		frame = appendUvarint(frame, 0)
+	}
+	return appendUvarint(frame, lastSeq)
+}
+
+// waitSession waits for a transport to register its session
+func waitSession(t *testing.T, srv *Server, id string) *Session {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if session, ok := srv.GetSession(id); ok {
+			return session
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("session %s was never created", id)
+	return nil
+}
+
+func TestServeTransport_Pipe(t *testing.T) {
+	srv := NewServer()
+	serverEnd, client := NewPipe()
+	done := make(chan struct{})
+	go func() {
+		srv.ServeTransport("pipe", serverEnd)
+		close(done)
+	}()
+
+	if frame, err := client.ReadFrame(); err != nil || controlType(frame) != "HELLO" {
+		t.Fatalf("first frame = %x, %v; want server HELLO", frame, err)
+	}
+	if frame, err := client.ReadFrame(); err != nil || controlType(frame) != "EVENTS" {
+		t.Fatalf("second frame = %x, %v; want EVENTS", frame, err)
+	}
+	if err := client.WriteFrame(clientHello(false, 0)); err != nil {
+		t.Fatalf("WriteFrame() error = %v", err)
+	}
+
+	session := waitSession(t, srv, "pipe")
+	if err := session.SendPatches([]vdom.Patch{{Op: vdom.OpReplaceText, NodeID: 1, Value: "hi"}}); err != nil {
+		t.Fatalf("SendPatches() error = %v", err)
+	}
+	frame, err := client.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame() error = %v", err)
+	}
+	patches, err := DecodePatches(frame)
+	if err != nil || len(patches) != 1 || patches[0].Value != "hi" {
+		t.Fatalf("DecodePatches() = %+v, %v", patches, err)
+	}
+
+	client.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ServeTransport did not return after the pipe closed")
+	}
+}
+
+func TestHandleLive_SSEAndPost(t *testing.T) {
+	srv := NewServer()
+	ts := httptest.NewServer(http.HandlerFunc(srv.HandleLive))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/vango/live/sse")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	events := bufio.NewReader(resp.Body)
+	readFrame := func() []byte {
+		t.Helper()
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading event stream: %v", err)
+			}
+			if data, ok := strings.CutPrefix(strings.TrimRight(line, "\n"), "data: "); ok {
+				frame, err := base64.StdEncoding.DecodeString(data)
+				if err != nil {
+					t.Fatalf("invalid base64 frame %q: %v", data, err)
+				}
+				return frame
+			}
+		}
+	}
+
+	if frame := readFrame(); controlType(frame) != "HELLO" {
+		t.Fatalf("first frame = %x, want server HELLO", frame)
+	}
+	if frame := readFrame(); controlType(frame) != "EVENTS" {
+		t.Fatalf("second frame = %x, want EVENTS", frame)
+	}
+
+	post, err := http.Post(ts.URL+"/vango/live/sse", "application/octet-stream", bytes.NewReader(clientHello(false, 0)))
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusNoContent {
+		t.Fatalf("POST status = %d, want 204", post.StatusCode)
+	}
+
+	session := waitSession(t, srv, "sse")
+	if err := session.SendPatches([]vdom.Patch{{Op: vdom.OpReplaceText, NodeID: 1, Value: "over sse"}}); err != nil {
+		t.Fatalf("SendPatches() error = %v", err)
+	}
+	patches, err := DecodePatches(readFrame())
+	if err != nil || len(patches) != 1 || patches[0].Value != "over sse" {
+		t.Fatalf("DecodePatches() = %+v, %v", patches, err)
+	}
+
+	// Frames for unknown sessions are rejected
+	post, err = http.Post(ts.URL+"/vango/live/missing", "application/octet-stream", bytes.NewReader(clientHello(false, 0)))
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusNotFound {
+		t.Errorf("POST to unknown session status = %d, want 404", post.StatusCode)
+	}
+}
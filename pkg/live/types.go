@@ -8,23 +8,34 @@ const (
 	FramePatches MessageType = 0x00
 	FrameEvent   MessageType = 0x01
 	FrameControl MessageType = 0x02
+	FrameCommand MessageType = 0x03
 )
 
-// EventType represents client-side event types
-type EventType uint8
+// EventType is the wire ID of an event name. Built-in DOM events have fixed
+// IDs; names declared by components get IDs negotiated per session (see
+// EventRegistry). IDs travel as varints.
+type EventType uint32
 
+// Built-in event IDs, identical in every session
 const (
-	EventClick     EventType = 0x01
-	EventIncrement EventType = 0x02
-	EventDecrement EventType = 0x03
-	EventReset     EventType = 0x04
-	EventInput     EventType = 0x05
-	EventSubmit    EventType = 0x06
+	EventClick   EventType = 0x01
+	EventInput   EventType = 0x05
+	EventSubmit  EventType = 0x06
+	EventChange  EventType = 0x07
+	EventKeyDown EventType = 0x08
+	EventKeyUp   EventType = 0x09
+	EventFocus   EventType = 0x0A
+	EventBlur    EventType = 0x0B
+
+	// FirstCustomEvent is the first ID handed out to declared event names
+	FirstCustomEvent EventType = 0x20
 )
 
 // Event represents a client-side event
 type Event struct {
 	Type   EventType
 	NodeID uint32
-	Data   map[string]interface{}
+	// Data carries the event payload. Values may be bool, integers, floats,
+	// strings, or map[string]string (submitted form fields).
+	Data map[string]interface{}
 }
\ No newline at end of file
@@ -0,0 +1,199 @@
+package reactive
+
+import "sync"
+
+// effect is a function that runs again whenever a value it read changes.
+// It is a computation without a value, so it sits in the dependency graph
+// like a Computed and re-runs only once its sources are up to date.
+type effect struct {
+	computation
+
+	fn       func(o *Owner) // guarded by depsMu
+	cleanups []func()
+}
+
+// newEffect creates an effect that has not run yet
+func newEffect(fn func(o *Owner)) *effect {
+	e := &effect{fn: fn}
+	e.comp = &e.computation
+	e.effect = e
+	e.state = stateDirty
+	e.eval = e.run
+	return e
+}
+
+// run runs the cleanups of the previous run, then fn with the owner of
+// this run
+func (e *effect) run(o *Owner) {
+	e.runCleanups()
+	e.depsMu.Lock()
+	fn := e.fn
+	e.depsMu.Unlock()
+	fn(o)
+}
+
+// stop stops the effect and runs the cleanups of its last run. Stopping
+// twice does nothing.
+func (e *effect) stop() {
+	e.depsMu.Lock()
+	if e.stopped {
+		e.depsMu.Unlock()
+		return
+	}
+	e.stopped = true
+	sources := e.sources
+	e.sources = nil
+	e.depsMu.Unlock()
+
+	for src := range sources {
+		src.removeObserver(&e.computation)
+	}
+	e.runCleanups()
+}
+
+// addCleanup registers a function to run before the next run or on stop
+func (e *effect) addCleanup(fn func()) {
+	e.depsMu.Lock()
+	e.cleanups = append(e.cleanups, fn)
+	e.depsMu.Unlock()
+}
+
+// runCleanups runs the registered cleanups, most recent first
+func (e *effect) runCleanups() {
+	e.depsMu.Lock()
+	cleanups := e.cleanups
+	e.cleanups = nil
+	e.depsMu.Unlock()
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+}
+
+// Effect runs fn, tracking the States and Computeds it reads through the
+// owner it gets, and runs it again whenever one of them changes. It
+// returns a function that stops the effect. Call OnCleanup with fn's
+// owner to undo its work before the next run and when the effect stops;
+// effects created with it stop before fn runs again.
+//
+// Called with the owner of a component's render, the effect belongs to
+// the fiber: it is created by the first render only, in the position of
+// the call among the render's hooks, first runs once that render is
+// committed, and stops when the fiber is removed. Later renders update
+// fn, which the next run uses. In a static pass (see Static), as in
+// server-side rendering, fn runs once without tracking and nothing is
+// kept.
+func Effect(scope Scope, fn func(o *Owner)) (stop func()) {
+	o := ownerOf(scope).observer()
+	switch {
+	case o != nil && o.static:
+		Static(fn)
+		return func() {}
+
+	case o != nil && o.st != nil:
+		e, ok := o.nextHook().(*effect)
+		if ok {
+			e.depsMu.Lock()
+			e.fn = fn
+			e.depsMu.Unlock()
+			return e.stop
+		}
+		e = newEffect(fn)
+		o.setHook(e)
+		o.fiber.AfterCommit(e.refresh)
+		o.fiber.OnRemove(e.stop)
+		return e.stop
+	}
+
+	e := newEffect(fn)
+	if o != nil && o.comp != nil && o.comp.effect != nil {
+		o.comp.effect.addCleanup(e.stop)
+	}
+	e.refresh()
+	return e.stop
+}
+
+// OnMount runs fn once a component's first render is committed: in client
+// mode its DOM nodes exist, in server-driven mode its patches are sent.
+// Call it with the owner of the render; later renders and other scopes,
+// including static passes, do nothing.
+func OnMount(scope Scope, fn func()) {
+	o := ownerOf(scope).observer()
+	if o == nil || o.st == nil {
+		return
+	}
+	if _, ok := o.nextHook().(mountHook); ok {
+		return
+	}
+	o.setHook(mountHook{})
+	o.fiber.AfterCommit(fn)
+}
+
+// OnCleanup registers fn to run when scope ends. For the owner of a
+// component's render that is when its fiber is removed; the last render's
+// fn is used. For the owner of an effect run, fn runs before the effect
+// runs again and when it stops. Elsewhere, including static passes, it
+// does nothing.
+func OnCleanup(scope Scope, fn func()) {
+	o := ownerOf(scope).observer()
+	switch {
+	case o == nil || o.static:
+	case o.st != nil:
+		h, ok := o.nextHook().(*cleanupHook)
+		if ok {
+			h.set(fn)
+			return
+		}
+		h = &cleanupHook{fn: fn}
+		o.setHook(h)
+		o.fiber.OnRemove(func() { h.get()() })
+	case o.comp != nil && o.comp.effect != nil:
+		o.comp.effect.addCleanup(fn)
+	}
+}
+
+// Static runs fn as a static render pass, as in server-side rendering:
+// reads through the owner it gets are not tracked, effects created with
+// it run once and OnMount and OnCleanup do nothing
+func Static(fn func(o *Owner)) {
+	fn(&Owner{obs: &observer{static: true}})
+}
+
+// mountHook marks the hook slot of an OnMount call
+type mountHook struct{}
+
+// cleanupHook holds the function of an OnCleanup call in a render
+type cleanupHook struct {
+	mu sync.Mutex
+	fn func()
+}
+
+func (h *cleanupHook) set(fn func()) {
+	h.mu.Lock()
+	h.fn = fn
+	h.mu.Unlock()
+}
+
+func (h *cleanupHook) get() func() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.fn
+}
+
+// nextHook returns the state of the render's next hook, nil if the fiber
+// never called it before
+func (o *observer) nextHook() any {
+	var h any
+	if o.slot < len(o.st.hooks) {
+		h = o.st.hooks[o.slot]
+	} else {
+		o.st.hooks = append(o.st.hooks, nil)
+	}
+	o.slot++
+	return h
+}
+
+// setHook stores the state of the hook nextHook returned last
+func (o *observer) setHook(h any) {
+	o.st.hooks[o.slot-1] = h
+}
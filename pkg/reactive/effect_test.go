@@ -0,0 +1,123 @@
+package reactive
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/recera/vango/pkg/scheduler"
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+func TestEffect_Reruns(t *testing.T) {
+	a := NewState(1, nil)
+	b := NewState(10, nil)
+	sum := NewComputed(func(o *Owner) int { return a.Get(o) + b.Get(o) }, nil)
+
+	var runs, cleanups int
+	var seen []int
+	stop := Effect(nil, func(o *Owner) {
+		runs++
+		seen = append(seen, sum.Get(o))
+		OnCleanup(o, func() { cleanups++ })
+	})
+	if runs != 1 || seen[0] != 11 {
+		t.Fatalf("Expected one run seeing 11, got %d runs seeing %v", runs, seen)
+	}
+
+	a.Set(nil, 2)
+	if runs != 2 || cleanups != 1 || seen[1] != 12 {
+		t.Errorf("Expected a second run seeing 12 after one cleanup, got %d runs, %d cleanups, %v", runs, cleanups, seen)
+	}
+
+	// Both updates of a batch are seen by one run
+	RunBatch(nil, &dirtyRecorder{}, func(o *Owner) {
+		a.Set(o, 3)
+		b.Set(o, 20)
+	})
+	if runs != 3 || seen[2] != 23 {
+		t.Errorf("Expected one run for the batch seeing 23, got %d runs, %v", runs, seen)
+	}
+
+	stop()
+	if cleanups != 3 {
+		t.Errorf("Expected stop to run the cleanup, got %d cleanups", cleanups)
+	}
+	a.Set(nil, 4)
+	if runs != 3 {
+		t.Errorf("Expected no run after stop, got %d runs", runs)
+	}
+}
+
+func TestEffect_Static(t *testing.T) {
+	count := NewState(0, nil)
+	var runs, mounts, cleanups int
+	Static(func(o *Owner) {
+		Effect(o, func(o *Owner) {
+			runs++
+			_ = count.Get(o)
+		})
+		OnMount(o, func() { mounts++ })
+		OnCleanup(o, func() { cleanups++ })
+	})
+	count.Set(nil, 1)
+	if runs != 1 || mounts != 0 || cleanups != 0 {
+		t.Errorf("Expected the effect to run once and the hooks to do nothing, got %d runs, %d mounts, %d cleanups", runs, mounts, cleanups)
+	}
+	if len(count.observers) != 0 {
+		t.Errorf("Expected nothing subscribed in a static pass, got %d observers", len(count.observers))
+	}
+}
+
+func TestEffect_FiberLifetime(t *testing.T) {
+	sched := scheduler.NewScheduler()
+	count := NewState(0, nil)
+	var renders, mounts, runs, effectCleanups, cleanups atomic.Int32
+	var mountedAfterCommit atomic.Bool
+
+	var fiber *scheduler.Fiber
+	fiber = ownedFiber(sched, func(o *Owner) *vdom.VNode {
+		renders.Add(1)
+		OnMount(o, func() {
+			mounts.Add(1)
+			mountedAfterCommit.Store(fiber.VNode() != nil)
+		})
+		Effect(o, func(o *Owner) {
+			runs.Add(1)
+			_ = count.Get(o)
+			OnCleanup(o, func() { effectCleanups.Add(1) })
+		})
+		OnCleanup(o, func() { cleanups.Add(1) })
+		return vdom.NewText("x")
+	})
+
+	sched.Start()
+	defer sched.Stop()
+	sched.MarkDirty(fiber)
+	time.Sleep(50 * time.Millisecond)
+
+	if mounts.Load() != 1 || !mountedAfterCommit.Load() || runs.Load() != 1 {
+		t.Fatalf("Expected OnMount and the effect to run once after commit, got %d mounts (committed: %v), %d runs", mounts.Load(), mountedAfterCommit.Load(), runs.Load())
+	}
+
+	// Another render keeps the hooks
+	sched.MarkDirty(fiber)
+	time.Sleep(50 * time.Millisecond)
+	if renders.Load() != 2 || mounts.Load() != 1 || runs.Load() != 1 {
+		t.Errorf("Expected a re-render to run no hook again, got %d renders, %d mounts, %d runs", renders.Load(), mounts.Load(), runs.Load())
+	}
+
+	count.Set(nil, 1)
+	if runs.Load() != 2 || effectCleanups.Load() != 1 {
+		t.Errorf("Expected the effect to run again after its cleanup, got %d runs, %d cleanups", runs.Load(), effectCleanups.Load())
+	}
+
+	sched.RemoveFiber(fiber)
+	if cleanups.Load() != 1 || effectCleanups.Load() != 2 {
+		t.Errorf("Expected removal to run both cleanups, got %d and %d", cleanups.Load(), effectCleanups.Load())
+	}
+	count.Set(nil, 2)
+	if runs.Load() != 2 {
+		t.Errorf("Expected no effect run after removal, got %d runs", runs.Load())
+	}
+}
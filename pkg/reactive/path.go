@@ -0,0 +1,372 @@
+package reactive
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// parsedPaths caches the keys of parsed paths
+var parsedPaths sync.Map // path -> []string
+
+// parsePath splits a path into its keys
+func parsePath(path string) []string {
+	if v, ok := parsedPaths.Load(path); ok {
+		return v.([]string)
+	}
+	var keys []string
+	for _, part := range strings.Split(strings.ReplaceAll(path, "[", ".["), ".") {
+		part = strings.TrimSuffix(strings.TrimPrefix(part, "["), "]")
+		if part != "" {
+			keys = append(keys, part)
+		}
+	}
+	parsedPaths.Store(path, keys)
+	return keys
+}
+
+// pathError is a key that does not apply to the value's type
+type pathError struct {
+	key string
+	typ reflect.Type
+}
+
+func (e *pathError) Error() string {
+	return fmt.Sprintf("reactive: no %q in %s", e.key, e.typ)
+}
+
+// indirect follows pointers and interfaces, returning an invalid value for
+// a nil one
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// fieldIndex returns the index of the exported struct field a key names
+func fieldIndex(t reflect.Type, key string) (int, bool) {
+	match := func(ok func(reflect.StructField) bool) (int, bool) {
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() && ok(f) {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	if i, ok := match(func(f reflect.StructField) bool { return f.Name == key }); ok {
+		return i, true
+	}
+	if i, ok := match(func(f reflect.StructField) bool {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		return name == key
+	}); ok {
+		return i, true
+	}
+	return match(func(f reflect.StructField) bool { return strings.EqualFold(f.Name, key) })
+}
+
+// mapKey converts a key to a map key of type t
+func mapKey(t reflect.Type, key string) (reflect.Value, bool) {
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(key).Convert(t), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(n).Convert(t), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(key, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(n).Convert(t), true
+	case reflect.Bool:
+		b, err := strconv.ParseBool(key)
+		if err != nil {
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(b).Convert(t), true
+	}
+	return reflect.Value{}, false
+}
+
+// index parses a key as the index of a sequence
+func index(v reflect.Value, key string) (int, error) {
+	i, err := strconv.Atoi(key)
+	if err != nil {
+		return 0, &pathError{key, v.Type()}
+	}
+	return i, nil
+}
+
+// step resolves one key. Missing data, such as a nil pointer, an index out
+// of range or an absent map entry, gives an invalid value; a key that does
+// not fit the type is an error.
+func step(v reflect.Value, key string) (reflect.Value, error) {
+	v = indirect(v)
+	if !v.IsValid() {
+		return v, nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		i, ok := fieldIndex(v.Type(), key)
+		if !ok {
+			return reflect.Value{}, &pathError{key, v.Type()}
+		}
+		return v.Field(i), nil
+	case reflect.Slice, reflect.Array:
+		i, err := index(v, key)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if i < 0 || i >= v.Len() {
+			return reflect.Value{}, nil
+		}
+		return v.Index(i), nil
+	case reflect.Map:
+		k, ok := mapKey(v.Type().Key(), key)
+		if !ok {
+			return reflect.Value{}, &pathError{key, v.Type()}
+		}
+		return v.MapIndex(k), nil
+	}
+	return reflect.Value{}, &pathError{key, v.Type()}
+}
+
+// resolve resolves every key of a path
+func resolve(v reflect.Value, keys []string) (reflect.Value, error) {
+	for _, key := range keys {
+		var err error
+		if v, err = step(v, key); err != nil || !v.IsValid() {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+// valueAt returns the value at a path as a V, the zero V if the path leads
+// to missing data. It panics if the path does not fit the value's type.
+func valueAt[V any](v reflect.Value, path string) V {
+	rv, err := resolve(v, parsePath(path))
+	if err != nil {
+		panic(err)
+	}
+	return as[V](rv, path)
+}
+
+// as returns a value found at path as a V, the zero V for an invalid one
+func as[V any](rv reflect.Value, path string) V {
+	var zero V
+	if !rv.IsValid() {
+		return zero
+	}
+	x, ok := rv.Interface().(V)
+	if !ok {
+		panic(fmt.Sprintf("reactive: %q is a %s, not a %T", path, rv.Type(), zero))
+	}
+	return x
+}
+
+// rebuild returns a copy of v with the value at keys replaced by what fn
+// returns for the current one (invalid if missing). Only the values along
+// the path are copied; the rest is shared with v. A nil fn result deletes
+// the map entry or slice element the path names.
+func rebuild(v reflect.Value, keys []string, fn func(reflect.Value) reflect.Value) reflect.Value {
+	if len(keys) == 0 {
+		return fn(v)
+	}
+	t := v.Type()
+	key, rest := keys[0], keys[1:]
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(t.Elem()).Elem()
+		if !v.IsNil() {
+			elem.Set(v.Elem())
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(rebuild(elem, keys, fn))
+		return p
+
+	case reflect.Interface:
+		if v.IsNil() {
+			panic(&pathError{key, t})
+		}
+		out := reflect.New(t).Elem()
+		out.Set(rebuild(v.Elem(), keys, fn))
+		return out
+
+	case reflect.Struct:
+		i, ok := fieldIndex(t, key)
+		if !ok {
+			panic(&pathError{key, t})
+		}
+		out := reflect.New(t).Elem()
+		out.Set(v)
+		x := rebuild(v.Field(i), rest, fn)
+		if !x.IsValid() {
+			x = reflect.Zero(t.Field(i).Type)
+		}
+		out.Field(i).Set(x)
+		return out
+
+	case reflect.Slice, reflect.Array:
+		i, err := index(v, key)
+		if err != nil {
+			panic(err)
+		}
+		if i < 0 || i >= v.Len() {
+			panic(fmt.Sprintf("reactive: index %d out of range [0:%d]", i, v.Len()))
+		}
+		x := rebuild(v.Index(i), rest, fn)
+		if t.Kind() == reflect.Array {
+			out := reflect.New(t).Elem()
+			out.Set(v)
+			if !x.IsValid() {
+				x = reflect.Zero(t.Elem())
+			}
+			out.Index(i).Set(x)
+			return out
+		}
+		if !x.IsValid() {
+			out := reflect.MakeSlice(t, 0, v.Len()-1)
+			out = reflect.AppendSlice(out, v.Slice(0, i))
+			return reflect.AppendSlice(out, v.Slice(i+1, v.Len()))
+		}
+		out := reflect.MakeSlice(t, v.Len(), v.Len())
+		reflect.Copy(out, v)
+		out.Index(i).Set(x)
+		return out
+
+	case reflect.Map:
+		k, ok := mapKey(t.Key(), key)
+		if !ok {
+			panic(&pathError{key, t})
+		}
+		out := reflect.MakeMapWithSize(t, v.Len()+1)
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), iter.Value())
+		}
+		cur := v.MapIndex(k)
+		if len(rest) > 0 && !cur.IsValid() {
+			cur = reflect.Zero(t.Elem())
+		}
+		// An invalid value deletes the entry
+		out.SetMapIndex(k, rebuild(cur, rest, fn))
+		return out
+	}
+	panic(&pathError{key, t})
+}
+
+// convertTo converts x to a value of type t, nil to the zero value
+func convertTo(x any, t reflect.Type, path string) reflect.Value {
+	if x == nil {
+		return reflect.Zero(t)
+	}
+	v := reflect.ValueOf(x)
+	if !v.Type().AssignableTo(t) {
+		panic(fmt.Sprintf("reactive: cannot set %q, a %s, to a %T", path, t, x))
+	}
+	return v
+}
+
+// update applies rebuild to a value of any type, typing the result
+func update[T any](value T, path string, fn func(reflect.Value) reflect.Value) T {
+	v := reflect.ValueOf(&value).Elem()
+	out := rebuild(v, parsePath(path), fn)
+	if !out.IsValid() {
+		var zero T
+		return zero
+	}
+	return out.Interface().(T)
+}
+
+// GetIn returns the value at path in value, the zero V if the path leads to
+// missing data such as an absent map entry. It panics if the path does not
+// fit the type of value or the value found is not a V.
+func GetIn[V, T any](value T, path string) V {
+	return valueAt[V](reflect.ValueOf(&value).Elem(), path)
+}
+
+// SetIn returns a copy of value with the value at path set to x. value is
+// not modified: the structs, slices, maps and pointers along the path are
+// copied and everything else is shared, so the result is cheap to make and
+// to compare. A missing map entry is created; nil sets the zero value. It
+// panics if the path does not fit the type of value, an index is out of
+// range or x has the wrong type.
+func SetIn[T any](value T, path string, x any) T {
+	return update(value, path, func(cur reflect.Value) reflect.Value {
+		return convertTo(x, typeAt(reflect.TypeOf(&value).Elem(), parsePath(path), cur), path)
+	})
+}
+
+// UpdateIn returns a copy of value with the value at path replaced by what
+// fn returns for it, copying like SetIn. fn gets the zero V for missing
+// data.
+func UpdateIn[V, T any](value T, path string, fn func(V) V) T {
+	return update(value, path, func(cur reflect.Value) reflect.Value {
+		return convertTo(fn(as[V](cur, path)), typeAt(reflect.TypeOf(&value).Elem(), parsePath(path), cur), path)
+	})
+}
+
+// DeleteIn returns a copy of value without the map entry or slice element
+// at path, copying like SetIn. A field or array element is set to its zero
+// value instead.
+func DeleteIn[T any](value T, path string) T {
+	return update(value, path, func(reflect.Value) reflect.Value {
+		return reflect.Value{}
+	})
+}
+
+// AppendIn returns a copy of value with items appended to the slice at
+// path, copying like SetIn
+func AppendIn[T any](value T, path string, items ...any) T {
+	return update(value, path, func(cur reflect.Value) reflect.Value {
+		t := typeAt(reflect.TypeOf(&value).Elem(), parsePath(path), cur)
+		if t.Kind() != reflect.Slice {
+			panic(fmt.Sprintf("reactive: cannot append to %q, a %s", path, t))
+		}
+		out := reflect.MakeSlice(t, 0, cur.Len()+len(items))
+		if cur.IsValid() {
+			out = reflect.AppendSlice(out, cur)
+		}
+		for _, item := range items {
+			out = reflect.Append(out, convertTo(item, t.Elem(), path))
+		}
+		return out
+	})
+}
+
+// typeAt returns the type of the value at keys, from the value found there
+// if it is valid and from the static types along the path otherwise
+func typeAt(t reflect.Type, keys []string, cur reflect.Value) reflect.Type {
+	if cur.IsValid() {
+		return cur.Type()
+	}
+	for _, key := range keys {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			i, ok := fieldIndex(t, key)
+			if !ok {
+				panic(&pathError{key, t})
+			}
+			t = t.Field(i).Type
+		case reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			panic(&pathError{key, t})
+		}
+	}
+	return t
+}
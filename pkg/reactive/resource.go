@@ -0,0 +1,332 @@
+package reactive
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/recera/vango/pkg/scheduler"
+)
+
+// Fetcher loads values by key for resources. Resources sharing a fetcher
+// share its requests: while a key is being fetched, other resources
+// asking for the same key wait for that request instead of starting one.
+type Fetcher[K comparable, T any] struct {
+	fetch func(ctx context.Context, key K) (T, error)
+
+	mu       sync.Mutex
+	inflight map[K]*fetchCall[T]
+}
+
+// fetchCall is a request of a fetcher
+type fetchCall[T any] struct {
+	done   chan struct{} // closed once value and err are set
+	value  T
+	err    error
+	cancel context.CancelFunc
+	refs   int // resources waiting for it; guarded by the fetcher's mu
+}
+
+// NewFetcher creates a fetcher. fetch should return when ctx is canceled,
+// which happens once no resource waits for the request anymore.
+func NewFetcher[K comparable, T any](fetch func(ctx context.Context, key K) (T, error)) *Fetcher[K, T] {
+	return &Fetcher[K, T]{
+		fetch:    fetch,
+		inflight: make(map[K]*fetchCall[T]),
+	}
+}
+
+// join returns the request in flight for key, starting one if there is
+// none or fresh is set
+func (f *Fetcher[K, T]) join(key K, fresh bool) *fetchCall[T] {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c := f.inflight[key]; c != nil && !fresh {
+		c.refs++
+		return c
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &fetchCall[T]{done: make(chan struct{}), cancel: cancel, refs: 1}
+	f.inflight[key] = c
+	go f.run(ctx, key, c)
+	return c
+}
+
+// run runs a request, turning a panic of the fetch function into its error
+func (f *Fetcher[K, T]) run(ctx context.Context, key K, c *fetchCall[T]) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("reactive: fetch of %v panicked: %v", key, r)
+		}
+		f.mu.Lock()
+		if f.inflight[key] == c {
+			delete(f.inflight, key)
+		}
+		f.mu.Unlock()
+		close(c.done)
+	}()
+	c.value, c.err = f.fetch(ctx, key)
+}
+
+// leave drops a resource's interest in a request, canceling it when no
+// resource waits for it anymore
+func (f *Fetcher[K, T]) leave(key K, c *fetchCall[T]) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c.refs--
+	if c.refs > 0 {
+		return
+	}
+	c.cancel()
+	if f.inflight[key] == c {
+		delete(f.inflight, key)
+	}
+}
+
+// Resource is a value loaded asynchronously for a key, such as a user
+// fetched by ID. The key is a function, typically the Get of a State or
+// Computed, called with the owner it reads through: when a value it reads
+// changes, the resource loads the value of the new key, canceling the load
+// in progress. Loading, Error and Value are reactive: readers re-render
+// when they change. Read suspends the render while the value of a new key
+// loads, showing the nearest Suspense fallback.
+type Resource[K comparable, T any] struct {
+	fetcher   *Fetcher[K, T]
+	keyFn     func(scope Scope) K
+	scheduler Scheduler
+
+	value   *State[T]
+	err     *State[error]
+	loading *State[bool]
+
+	mu       sync.Mutex
+	key      K
+	hasKey   bool
+	valueKey K    // key of the value held, if hasValue
+	hasValue bool // a value or error was loaded or set for valueKey
+	cur      *resourceLoad[K, T]
+	watch    *effect
+	stopped  bool
+}
+
+// resourceLoad is a load of a resource in progress
+type resourceLoad[K comparable, T any] struct {
+	key   K
+	call  *fetchCall[T]
+	ready chan struct{} // closed once the load is applied or dropped
+	fresh bool          // loads a key whose value the resource does not hold
+}
+
+// NewResource creates a resource loading the values of key with fetcher,
+// and starts loading the current key
+func NewResource[K comparable, T any](key func(scope Scope) K, fetcher *Fetcher[K, T], sched Scheduler) *Resource[K, T] {
+	var zero T
+	r := &Resource[K, T]{
+		fetcher:   fetcher,
+		keyFn:     key,
+		scheduler: sched,
+		value:     NewState(zero, sched),
+		err:       NewState[error](nil, sched),
+		loading:   NewState(false, sched),
+	}
+	r.watch = newEffect(func(o *Owner) { r.load(key(o), false) })
+	r.watch.refresh()
+	return r
+}
+
+// CreateResource creates a resource like NewResource, using the scheduler
+// of the fiber rendering like CreateState. Called with the owner of a
+// component's render, the resource belongs to the fiber: it is created by
+// the first render only, in the position of the call among the render's
+// hooks, and stops when the fiber is removed. In a static pass (see
+// Static), as in server-side rendering, the value of the key is loaded
+// before CreateResource returns, so the page is rendered with it.
+func CreateResource[K comparable, T any](scope Scope, key func(scope Scope) K, fetcher *Fetcher[K, T]) *Resource[K, T] {
+	o := ownerOf(scope).observer()
+	switch {
+	case o != nil && o.static:
+		return loadStatic(ownerOf(scope), key, fetcher)
+
+	case o != nil && o.st != nil:
+		r, ok := o.nextHook().(*Resource[K, T])
+		if ok {
+			return r
+		}
+		r = NewResource(key, fetcher, renderingScheduler(scope))
+		o.setHook(r)
+		o.fiber.OnRemove(r.Stop)
+		return r
+	}
+	return NewResource(key, fetcher, nil)
+}
+
+// loadStatic creates a resource holding the value of the key, read
+// through the static owner o and loaded at once, that never loads again
+func loadStatic[K comparable, T any](o *Owner, key func(scope Scope) K, fetcher *Fetcher[K, T]) *Resource[K, T] {
+	k := key(o)
+	c := fetcher.join(k, false)
+	<-c.done
+	fetcher.leave(k, c)
+
+	return &Resource[K, T]{
+		fetcher:  fetcher,
+		keyFn:    key,
+		value:    NewState(c.value, nil),
+		err:      NewState(c.err, nil),
+		loading:  NewState(false, nil),
+		key:      k,
+		hasKey:   true,
+		valueKey: k,
+		hasValue: true,
+		stopped:  true,
+	}
+}
+
+// change runs fn holding mu, in a batch of its own. The states fn sets
+// through the batch's owner notify their readers when the batch ends,
+// after mu is released, so readers always see the states and the load in
+// progress agree.
+func (r *Resource[K, T]) change(fn func(o *Owner)) {
+	RunBatch(nil, r.scheduler, func(o *Owner) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		fn(o)
+	})
+}
+
+// load starts loading the value of key, unless it is the key loaded or
+// being loaded already and fresh is not set
+func (r *Resource[K, T]) load(key K, fresh bool) {
+	r.change(func(o *Owner) {
+		if r.stopped || (!fresh && r.hasKey && key == r.key) {
+			return
+		}
+		r.key, r.hasKey = key, true
+		l := &resourceLoad[K, T]{
+			key:   key,
+			call:  r.fetcher.join(key, fresh),
+			ready: make(chan struct{}),
+			fresh: !r.hasValue || r.valueKey != key,
+		}
+		r.replace(l)
+		r.loading.Set(o, true)
+		go r.await(l)
+	})
+}
+
+// await applies the result of a load unless another replaced it
+func (r *Resource[K, T]) await(l *resourceLoad[K, T]) {
+	<-l.call.done
+	r.change(func(o *Owner) {
+		if r.cur != l {
+			return
+		}
+		r.replace(nil)
+		r.valueKey, r.hasValue = l.key, true
+		if l.call.err == nil {
+			r.value.Set(o, l.call.value)
+		}
+		r.err.Set(o, l.call.err)
+		r.loading.Set(o, false)
+	})
+}
+
+// replace makes l the load in progress, ending the previous one: its
+// request is canceled if no other resource waits for it, and renders
+// suspended on it render again
+func (r *Resource[K, T]) replace(l *resourceLoad[K, T]) {
+	if prev := r.cur; prev != nil {
+		r.fetcher.leave(prev.key, prev.call)
+		close(prev.ready)
+	}
+	r.cur = l
+}
+
+// Value returns the last value loaded or set. It is the zero value until
+// the first load completes and keeps the previous value while another
+// loads or after a load fails. Like the other reads of a resource, it
+// subscribes the owner of scope.
+func (r *Resource[K, T]) Value(scope Scope) T {
+	return r.value.Get(scope)
+}
+
+// Error returns the error of the last load, nil if it succeeded
+func (r *Resource[K, T]) Error(scope Scope) error {
+	return r.err.Get(scope)
+}
+
+// Loading reports whether a load is in progress
+func (r *Resource[K, T]) Loading(scope Scope) bool {
+	return r.loading.Get(scope)
+}
+
+// Read returns the value of the current key for rendering. While the
+// value of a new key loads it suspends the render (see Suspend), so the
+// nearest Suspense boundary shows its fallback; a refetch of the key held
+// keeps the current value. If the last load failed, Read panics with its
+// error for the nearest ErrorBoundary.
+func (r *Resource[K, T]) Read(scope Scope) T {
+	r.mu.Lock()
+	loading, err, value := r.loading.Get(scope), r.err.Get(scope), r.value.Get(scope)
+	var ready chan struct{}
+	if loading && r.cur != nil && r.cur.fresh {
+		ready = r.cur.ready
+	}
+	r.mu.Unlock()
+
+	if ready != nil {
+		scheduler.Suspend(ready)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// Refetch loads the value of the current key again, with a new request
+// even if one is in flight
+func (r *Resource[K, T]) Refetch() {
+	r.mu.Lock()
+	key, ok := r.key, r.hasKey
+	r.mu.Unlock()
+	if ok {
+		r.load(key, true)
+	}
+}
+
+// Mutate sets the value, as after an optimistic update, and clears the
+// error. A load in progress is dropped; call Refetch to load the value
+// again.
+func (r *Resource[K, T]) Mutate(value T) {
+	r.change(func(o *Owner) {
+		r.replace(nil)
+		r.valueKey, r.hasValue = r.key, true
+		r.value.Set(o, value)
+		r.err.Set(o, nil)
+		r.loading.Set(o, false)
+	})
+}
+
+// Stop stops following the key and cancels the load in progress. A
+// resource created during a render stops when its fiber is removed.
+// Stopping twice does nothing.
+func (r *Resource[K, T]) Stop() {
+	r.mu.Lock()
+	stopped := r.stopped
+	r.stopped = true
+	r.mu.Unlock()
+	if stopped {
+		return
+	}
+
+	if r.watch != nil {
+		r.watch.stop()
+	}
+	r.change(func(o *Owner) {
+		if r.cur != nil {
+			r.replace(nil)
+			r.loading.Set(o, false)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package reactive
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/recera/vango/pkg/scheduler"
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+// blockingFetcher returns a fetcher whose requests wait for a release or
+// for their context to be canceled, recording both
+type blockingFetcher struct {
+	*Fetcher[int, string]
+	calls    atomic.Int32
+	canceled atomic.Int32
+	release  chan struct{}
+}
+
+func newBlockingFetcher() *blockingFetcher {
+	f := &blockingFetcher{release: make(chan struct{})}
+	f.Fetcher = NewFetcher(func(ctx context.Context, key int) (string, error) {
+		f.calls.Add(1)
+		select {
+		case <-f.release:
+		case <-ctx.Done():
+			f.canceled.Add(1)
+			return "", ctx.Err()
+		}
+		if key < 0 {
+			return "", errors.New("negative key")
+		}
+		return "value " + string(rune('0'+key)), nil
+	})
+	return f
+}
+
+func TestResource_KeyChanges(t *testing.T) {
+	f := newBlockingFetcher()
+	id := NewState(1, nil)
+	r := NewResource(id.Get, f.Fetcher, nil)
+	defer r.Stop()
+
+	if !r.Loading(nil) {
+		t.Fatal("Expected the resource loading at once")
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// A new key cancels the request in flight
+	id.Set(nil, 2)
+	time.Sleep(20 * time.Millisecond)
+	if f.canceled.Load() != 1 {
+		t.Errorf("Expected the first request canceled, got %d cancellations", f.canceled.Load())
+	}
+	close(f.release)
+	time.Sleep(20 * time.Millisecond)
+	if r.Loading(nil) || r.Value(nil) != "value 2" || r.Error(nil) != nil {
+		t.Errorf("Expected value 2 loaded, got %q (loading %v, error %v)", r.Value(nil), r.Loading(nil), r.Error(nil))
+	}
+
+	// Errors keep the previous value
+	id.Set(nil, -1)
+	time.Sleep(20 * time.Millisecond)
+	if r.Error(nil) == nil || r.Value(nil) != "value 2" {
+		t.Errorf("Expected an error and the previous value, got %v and %q", r.Error(nil), r.Value(nil))
+	}
+}
+
+func TestResource_Dedupe(t *testing.T) {
+	f := newBlockingFetcher()
+	key := func(Scope) int { return 3 }
+	a := NewResource(key, f.Fetcher, nil)
+	b := NewResource(key, f.Fetcher, nil)
+	time.Sleep(20 * time.Millisecond)
+
+	// One resource leaving does not cancel the request the other waits for
+	a.Stop()
+	close(f.release)
+	time.Sleep(20 * time.Millisecond)
+	if f.calls.Load() != 1 || f.canceled.Load() != 0 {
+		t.Errorf("Expected one shared request, got %d calls and %d cancellations", f.calls.Load(), f.canceled.Load())
+	}
+	if b.Value(nil) != "value 3" {
+		t.Errorf("Expected value 3, got %q", b.Value(nil))
+	}
+	b.Stop()
+}
+
+func TestResource_RefetchMutate(t *testing.T) {
+	var calls atomic.Int32
+	fetcher := NewFetcher(func(ctx context.Context, key string) (int, error) {
+		return int(calls.Add(1)), nil
+	})
+	r := NewResource(func(Scope) string { return "k" }, fetcher, nil)
+	defer r.Stop()
+	time.Sleep(20 * time.Millisecond)
+	if r.Value(nil) != 1 {
+		t.Fatalf("Expected the first load, got %d", r.Value(nil))
+	}
+
+	var mu sync.Mutex
+	var seen []int
+	stop := Effect(nil, func(o *Owner) {
+		mu.Lock()
+		seen = append(seen, r.Value(o))
+		mu.Unlock()
+	})
+	defer stop()
+
+	r.Mutate(42)
+	if r.Value(nil) != 42 || r.Loading(nil) {
+		t.Errorf("Expected the mutated value, got %d", r.Value(nil))
+	}
+	r.Refetch()
+	time.Sleep(20 * time.Millisecond)
+	if r.Value(nil) != 2 || calls.Load() != 2 {
+		t.Errorf("Expected a second request, got value %d after %d calls", r.Value(nil), calls.Load())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 3 || seen[1] != 42 || seen[2] != 2 {
+		t.Errorf("Expected the effect to see 1, 42 and 2, got %v", seen)
+	}
+}
+
+func TestResource_Suspense(t *testing.T) {
+	f := newBlockingFetcher()
+	sched := scheduler.NewScheduler()
+	id := CreateState(nil, 1)
+	var last atomic.Value
+
+	var fiber *scheduler.Fiber
+	fiber = ownedFiber(sched, func(o *Owner) *vdom.VNode {
+		r := CreateResource(o, id.Get, f.Fetcher)
+		node := scheduler.Suspense(fiber, vdom.NewText("loading"), func() *vdom.VNode {
+			return vdom.NewText(r.Read(o))
+		})
+		last.Store(node.Text)
+		return node
+	})
+	sched.Start()
+	defer sched.Stop()
+	sched.MarkDirty(fiber)
+	time.Sleep(50 * time.Millisecond)
+	if last.Load() != "loading" {
+		t.Errorf("Expected the fallback while loading, got %v", last.Load())
+	}
+
+	close(f.release)
+	time.Sleep(50 * time.Millisecond)
+	if last.Load() != "value 1" || f.calls.Load() != 1 {
+		t.Errorf("Expected value 1 after one request, got %v after %d", last.Load(), f.calls.Load())
+	}
+
+	// Removing the fiber stops the resource
+	sched.RemoveFiber(fiber)
+	id.Set(nil, 2)
+	time.Sleep(20 * time.Millisecond)
+	if f.calls.Load() != 1 {
+		t.Errorf("Expected no request after the fiber was removed, got %d", f.calls.Load())
+	}
+}
+
+func TestResource_Static(t *testing.T) {
+	f := newBlockingFetcher()
+	close(f.release)
+	var value string
+	Static(func(o *Owner) {
+		value = CreateResource(o, func(Scope) int { return 4 }, f.Fetcher).Read(o)
+	})
+	if value != "value 4" {
+		t.Errorf("Expected the value loaded during the static pass, got %q", value)
+	}
+}
@@ -20,36 +20,35 @@ func SetDebugLog(fn func(args ...interface{})) {
 	debugLog = fn
 }
 
-// currentFiber is dynamically scoped to track dependencies
+// currentFiber is subscribed to values read outside of tracking
 var currentFiber atomic.Pointer[scheduler.Fiber]
 
-// SetCurrentFiber sets the current fiber for dependency tracking
-// This should be called by the scheduler before rendering
+// SetCurrentFiber sets a fiber to subscribe to the values read outside of
+// tracked renders and computeds. Renders run by the scheduler are tracked
+// on their own; this is for reads the scheduler does not see.
 func SetCurrentFiber(fiber *scheduler.Fiber) {
 	currentFiber.Store(fiber)
 }
 
-// GetCurrentFiber returns the current fiber
+// GetCurrentFiber returns the fiber set with SetCurrentFiber
 func GetCurrentFiber() *scheduler.Fiber {
 	return currentFiber.Load()
 }
 
 // Signal is the interface for reactive values
 type Signal[T any] interface {
-	Get() T
-	Set(T)
+	Get(scope Scope) T
+	Set(scope Scope, value T)
 	Subscribe(fiber *scheduler.Fiber)
 	Unsubscribe(fiber *scheduler.Fiber)
 }
 
 // State represents a reactive state value
 type State[T any] struct {
-	value T
-	mu    sync.RWMutex
+	source // fibers and computeds that read the state
 	
-	// Dependencies - fibers that depend on this signal
-	deps      map[uint32]*scheduler.Fiber
-	depsMu    sync.RWMutex
+	value     T
+	mu        sync.RWMutex
 	scheduler Scheduler
 }
 
@@ -57,53 +56,61 @@ type State[T any] struct {
 func NewState[T any](initial T, sched Scheduler) *State[T] {
 	return &State[T]{
 		value:     initial,
-		deps:      make(map[uint32]*scheduler.Fiber),
 		scheduler: sched,
 	}
 }
 
-// Get returns the current value and tracks dependencies
-func (s *State[T]) Get() T {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// Get returns the current value. Read through the owner of a render,
+// computed or effect, the state subscribes it; the next render or
+// evaluation collects its reads anew, so states no longer read are
+// dropped. A nil scope reads without subscribing anything.
+func (s *State[T]) Get(scope Scope) T {
+	o := observe(scope, &s.source)
 	
-	// Track dependency if we're in a fiber context
-	if fiber := GetCurrentFiber(); fiber != nil {
-		s.Subscribe(fiber)
-	}
+	s.mu.RLock()
+	value, version := s.value, s.version.Load()
+	s.mu.RUnlock()
 	
-	return s.value
+	o.saw(&s.source, version)
+	return value
 }
 
-// Set updates the value and marks dependent fibers as dirty
-func (s *State[T]) Set(value T) {
+// Set updates the value and marks dependent fibers as dirty. Set through
+// the owner of a batch, the update joins the batch (see RunBatch).
+func (s *State[T]) Set(scope Scope, value T) {
 	if debugLog != nil {
 		debugLog("[State] Set called with value:", value)
 	}
 	
 	s.mu.Lock()
+	old := s.value
 	s.value = value
+	s.version.Add(1)
 	s.mu.Unlock()
 	
-	// Mark all dependent fibers as dirty
-	s.depsMu.RLock()
-	deps := make([]*scheduler.Fiber, 0, len(s.deps))
-	for _, fiber := range s.deps {
-		deps = append(deps, fiber)
-	}
-	s.depsMu.RUnlock()
+	batch := batchOf(scope)
+	s.journal(batch, old)
 	
-	if debugLog != nil {
-		debugLog("[State] Found", len(deps), "dependent fibers")
+	// Mark dependents outside the lock to avoid deadlock
+	propagate(&s.source, stateDirty, s.scheduler, batch)
+}
+
+// journal lets batch, if any, restore old on rollback
+func (s *State[T]) journal(batch *Batch, old T) {
+	if batch != nil {
+		batch.record(func() { s.restore(batch, old) })
 	}
+}
+
+// restore sets a value back without journaling it. Its readers are left
+// to batch, which marks them only if it commits.
+func (s *State[T]) restore(batch *Batch, value T) {
+	s.mu.Lock()
+	s.value = value
+	s.version.Add(1)
+	s.mu.Unlock()
 	
-	// Mark fibers dirty outside the lock to avoid deadlock
-	for _, fiber := range deps {
-		if debugLog != nil {
-			debugLog("[State] Marking fiber", fiber.ID(), "as dirty")
-		}
-		markDirtyOrBatch(s.scheduler, fiber)
-	}
+	propagate(&s.source, stateDirty, s.scheduler, batch)
 }
 
 // Subscribe adds a fiber as a dependency
@@ -112,12 +119,9 @@ func (s *State[T]) Subscribe(fiber *scheduler.Fiber) {
 		return
 	}
 	
-	s.depsMu.Lock()
-	defer s.depsMu.Unlock()
-	
-	s.deps[fiber.ID()] = fiber
+	s.subscribeFiber(fiber)
 	if debugLog != nil {
-		debugLog("[State] Subscribed fiber", fiber.ID(), "to state, total deps:", len(s.deps))
+		debugLog("[State] Subscribed fiber", fiber.ID(), "to state")
 	}
 }
 
@@ -127,108 +131,92 @@ func (s *State[T]) Unsubscribe(fiber *scheduler.Fiber) {
 		return
 	}
 	
-	s.depsMu.Lock()
-	defer s.depsMu.Unlock()
-	
-	delete(s.deps, fiber.ID())
+	s.unsubscribeFiber(fiber)
 }
 
-// Update atomically reads, modifies, and writes the value
-func (s *State[T]) Update(fn func(T) T) {
-	s.mu.Lock()
-	oldValue := s.value
-	s.value = fn(oldValue)
-	newValue := s.value
-	s.mu.Unlock()
-	
-	if debugLog != nil {
-		debugLog("[State] Update called, old:", oldValue, "new:", newValue)
-	}
+// Update atomically reads, modifies, and writes the value, joining the
+// batch of scope like Set
+func (s *State[T]) Update(scope Scope, fn func(T) T) {
+	oldValue, newValue := s.swap(fn)
 	
-	// Mark all dependent fibers as dirty
-	s.depsMu.RLock()
-	deps := make([]*scheduler.Fiber, 0, len(s.deps))
-	for _, fiber := range s.deps {
-		deps = append(deps, fiber)
-	}
-	s.depsMu.RUnlock()
+	batch := batchOf(scope)
+	s.journal(batch, oldValue)
 	
 	if debugLog != nil {
-		debugLog("[State] Found", len(deps), "dependent fibers to update")
+		debugLog("[State] Update called, old:", oldValue, "new:", newValue)
 	}
 	
-	// Mark fibers dirty outside the lock
-	for _, fiber := range deps {
-		if debugLog != nil {
-			debugLog("[State] Marking fiber", fiber.ID(), "as dirty")
-		}
-		markDirtyOrBatch(s.scheduler, fiber)
-	}
+	// Mark dependents outside the lock
+	propagate(&s.source, stateDirty, s.scheduler, batch)
+}
+
+// swap stores the value fn returns and returns the old and new values. A
+// panic in fn leaves the value as it was and the state unlocked.
+func (s *State[T]) swap(fn func(T) T) (old, value T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old = s.value
+	s.value = fn(old)
+	s.version.Add(1)
+	return old, s.value
 }
 
-// Computed represents a memoized computed value
+// Computed represents a memoized computed value. It tracks the States and
+// Computeds its function reads through the owner it is given, and
+// recomputes lazily, on the first Get after one of them changed. Readers
+// of a computed re-render only if its value did change: values compare
+// with == when comparable.
 type Computed[T any] struct {
-	compute   func() T
-	value     T
-	valid     bool
-	mu        sync.RWMutex
-	deps      []*State[any] // Signals this computed depends on
-	scheduler Scheduler
+	computation
 	
-	// Dependencies - fibers that depend on this computed
-	fiberDeps   map[uint32]*scheduler.Fiber
-	fiberDepsMu sync.RWMutex
+	compute   func(o *Owner) T
+	value     T // guarded by depsMu
+	scheduler Scheduler
 }
 
 // NewComputed creates a new computed value
-func NewComputed[T any](compute func() T, sched Scheduler) *Computed[T] {
-	return &Computed[T]{
+func NewComputed[T any](compute func(o *Owner) T, sched Scheduler) *Computed[T] {
+	c := &Computed[T]{
 		compute:   compute,
 		scheduler: sched,
-		fiberDeps: make(map[uint32]*scheduler.Fiber),
 	}
+	c.comp = &c.computation
+	c.state = stateDirty
+	c.eval = c.evaluateValue
+	return c
 }
 
-// Get returns the computed value, recalculating if necessary
-func (c *Computed[T]) Get() T {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	
-	// Track dependency if we're in a fiber context
-	if fiber := GetCurrentFiber(); fiber != nil {
-		c.Subscribe(fiber)
-	}
+// Get returns the computed value, recalculating if necessary. Like
+// State.Get, it subscribes the owner of scope.
+func (c *Computed[T]) Get(scope Scope) T {
+	o := observe(scope, &c.source)
+	c.refresh()
 	
-	if !c.valid {
-		// Clear old dependencies
-		c.deps = nil
-		
-		// Compute the value (this will track new dependencies)
-		c.value = c.compute()
-		c.valid = true
-	}
+	c.depsMu.Lock()
+	value, version := c.value, c.version.Load()
+	c.depsMu.Unlock()
 	
-	return c.value
+	o.saw(&c.source, version)
+	return value
 }
 
-// Invalidate marks the computed value as needing recalculation
-func (c *Computed[T]) Invalidate() {
-	c.mu.Lock()
-	c.valid = false
-	c.mu.Unlock()
-	
-	// Mark all dependent fibers as dirty
-	c.fiberDepsMu.RLock()
-	deps := make([]*scheduler.Fiber, 0, len(c.fiberDeps))
-	for _, fiber := range c.fiberDeps {
-		deps = append(deps, fiber)
-	}
-	c.fiberDepsMu.RUnlock()
+// evaluateValue runs the compute function and stores its result
+func (c *Computed[T]) evaluateValue(o *Owner) {
+	value := c.compute(o)
 	
-	// Mark fibers dirty outside the lock
-	for _, fiber := range deps {
-		markDirtyOrBatch(c.scheduler, fiber)
+	c.depsMu.Lock()
+	defer c.depsMu.Unlock()
+	if c.version.Load() == 0 || !equalValues(c.value, value) {
+		c.version.Add(1)
 	}
+	c.value = value
+}
+
+// Invalidate marks the computed value as needing recalculation, as if one
+// of its sources changed
+func (c *Computed[T]) Invalidate() {
+	c.mark(stateDirty)
+	propagate(&c.source, stateCheck, c.scheduler, nil)
 }
 
 // Subscribe adds a fiber as a dependency
@@ -237,10 +225,7 @@ func (c *Computed[T]) Subscribe(fiber *scheduler.Fiber) {
 		return
 	}
 	
-	c.fiberDepsMu.Lock()
-	defer c.fiberDepsMu.Unlock()
-	
-	c.fiberDeps[fiber.ID()] = fiber
+	c.subscribeFiber(fiber)
 }
 
 // Unsubscribe removes a fiber as a dependency
@@ -249,19 +234,26 @@ func (c *Computed[T]) Unsubscribe(fiber *scheduler.Fiber) {
 		return
 	}
 	
-	c.fiberDepsMu.Lock()
-	defer c.fiberDepsMu.Unlock()
-	
-	delete(c.fiberDeps, fiber.ID())
+	c.unsubscribeFiber(fiber)
 }
 
-// batchContext holds the current batch state
-var batchContext atomic.Pointer[Batch]
+// batchOf returns the batch of the owner of scope, if it is in progress
+func batchOf(scope Scope) *Batch {
+	if o := ownerOf(scope); o != nil && o.batch != nil && o.batch.open() {
+		return o.batch
+	}
+	return nil
+}
 
-// Batch allows multiple state updates without triggering re-renders until the batch completes
+// Batch allows multiple state updates without triggering re-renders until the batch completes.
+// It collects the fibers to mark dirty, the fibers to check and the effects
+// to run, and keeps the values the states had so they can be restored.
 type Batch struct {
 	scheduler   Scheduler
-	dirtyFibers map[uint32]*scheduler.Fiber
+	dirtyFibers map[*scheduler.Fiber]Scheduler // with the scheduler to mark them on
+	checkFibers map[*scheduler.Fiber]Scheduler // read a changed value through computeds
+	effects     []*effect                      // effects to run at commit
+	undo        []func()                       // restore the values changed, in order
 	mu          sync.Mutex
 	active      bool
 }
@@ -270,60 +262,171 @@ type Batch struct {
 func NewBatch(sched Scheduler) *Batch {
 	return &Batch{
 		scheduler:   sched,
-		dirtyFibers: make(map[uint32]*scheduler.Fiber),
+		dirtyFibers: make(map[*scheduler.Fiber]Scheduler),
+		checkFibers: make(map[*scheduler.Fiber]Scheduler),
 		active:      true,
 	}
 }
 
 // Add adds a fiber to the batch
 func (b *Batch) Add(fiber *scheduler.Fiber) {
-	if !b.active || fiber == nil {
+	b.add(nil, fiber)
+}
+
+// add adds a fiber to mark dirty on sched at commit
+func (b *Batch) add(sched Scheduler, fiber *scheduler.Fiber) {
+	if fiber == nil {
 		return
 	}
 	
 	b.mu.Lock()
-	b.dirtyFibers[fiber.ID()] = fiber
+	if b.active && b.dirtyFibers[fiber] == nil {
+		b.dirtyFibers[fiber] = sched
+	}
+	b.mu.Unlock()
+}
+
+// addCheck adds a fiber to mark dirty at commit if a computed it read
+// changed by then
+func (b *Batch) addCheck(sched Scheduler, fiber *scheduler.Fiber) {
+	b.mu.Lock()
+	if b.checkFibers[fiber] == nil {
+		b.checkFibers[fiber] = sched
+	}
+	b.mu.Unlock()
+}
+
+// addEffect queues an effect to run at commit
+func (b *Batch) addEffect(e *effect) {
+	b.mu.Lock()
+	b.effects = append(b.effects, e)
+	b.mu.Unlock()
+}
+
+// record adds a function restoring a value the batch changes
+func (b *Batch) record(undo func()) {
+	b.mu.Lock()
+	if b.active {
+		b.undo = append(b.undo, undo)
+	}
 	b.mu.Unlock()
 }
 
-// Commit commits all batched updates
+// open reports whether the batch still collects updates
+func (b *Batch) open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.active
+}
+
+// rollback restores the values changed since the journal had n entries,
+// latest first
+func (b *Batch) rollback(n int) {
+	b.mu.Lock()
+	undo := b.undo[n:]
+	b.undo = b.undo[:n]
+	b.mu.Unlock()
+	
+	for i := len(undo) - 1; i >= 0; i-- {
+		undo[i]()
+	}
+}
+
+// journalLen returns the number of values changed so far
+func (b *Batch) journalLen() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.undo)
+}
+
+// Commit commits all batched updates. Every fiber is marked dirty once,
+// so it renders once with all the updates; effects run last.
 func (b *Batch) Commit() {
 	b.mu.Lock()
 	b.active = false
-	fibers := make([]*scheduler.Fiber, 0, len(b.dirtyFibers))
-	for _, fiber := range b.dirtyFibers {
-		fibers = append(fibers, fiber)
-	}
-	b.dirtyFibers = nil
+	dirty, check := b.dirtyFibers, b.checkFibers
+	effects := b.effects
+	b.dirtyFibers, b.checkFibers, b.effects, b.undo = nil, nil, nil, nil
 	b.mu.Unlock()
 	
 	// Mark all collected fibers as dirty
-	for _, fiber := range fibers {
-		b.scheduler.MarkDirty(fiber)
+	for fiber, sched := range dirty {
+		b.markDirty(sched, fiber)
+	}
+	for fiber, sched := range check {
+		if _, ok := dirty[fiber]; !ok && fiberStale(fiber) {
+			b.markDirty(sched, fiber)
+		}
+	}
+	
+	// Effects run once, seeing every update of the batch
+	for _, e := range effects {
+		e.refresh()
+	}
+}
+
+// discard drops the batched updates of a batch that was rolled back
+func (b *Batch) discard() {
+	b.mu.Lock()
+	b.active = false
+	b.dirtyFibers, b.checkFibers, b.effects, b.undo = nil, nil, nil, nil
+	b.mu.Unlock()
+}
+
+// markDirty marks a fiber dirty on sched, the batch's scheduler or the
+// fiber's own
+func (b *Batch) markDirty(sched Scheduler, fiber *scheduler.Fiber) {
+	if sched == nil {
+		sched = b.scheduler
 	}
+	markFiber(sched, fiber)
 }
 
-// RunBatch executes a function within a batch context
-func RunBatch(sched Scheduler, fn func()) {
-	batch := NewBatch(sched)
-	oldBatch := batchContext.Swap(batch)
+// RunBatch executes a function within a batch context. fn gets the owner
+// of the batch: the states and stores it updates through that owner mark
+// the fibers reading them dirty once fn returns, and effects run then.
+// Updates through any other scope, such as those of other goroutines,
+// are not part of the batch. A batch started with the owner of another
+// joins it: nothing is marked before the outermost batch ends. If fn
+// panics, the states it changed get their values back before the panic
+// continues: all of them for the outermost batch, which then marks
+// nothing, and those changed by fn for a nested one. Started with the
+// owner of a render, computed or effect, fn reads through it as well.
+// sched marks fibers whose value has no scheduler; it may be nil, in
+// which case fibers are marked on their own.
+func RunBatch(scope Scope, sched Scheduler, fn func(o *Owner)) {
+	o := ownerOf(scope)
+	batch := batchOf(o)
+	outermost := batch == nil
+	if outermost {
+		batch = NewBatch(sched)
+		o = &Owner{obs: o.observer(), batch: batch}
+	}
+	start := batch.journalLen()
 	
 	defer func() {
-		batchContext.Store(oldBatch)
-		batch.Commit()
+		r := recover()
+		if r != nil {
+			batch.rollback(start)
+		}
+		if outermost {
+			if r != nil {
+				batch.discard()
+			} else {
+				batch.Commit()
+			}
+		}
+		if r != nil {
+			panic(r)
+		}
 	}()
 	
-	fn()
+	fn(o)
 }
 
-// markDirtyOrBatch marks a fiber dirty or adds to current batch
-func markDirtyOrBatch(sched Scheduler, fiber *scheduler.Fiber) {
-	if batch := batchContext.Load(); batch != nil && batch.active {
-		if debugLog != nil {
-			debugLog("[State] Adding fiber to batch")
-		}
-		batch.Add(fiber)
-	} else if sched != nil {
+// markDirty marks a fiber dirty on sched
+func markDirty(sched Scheduler, fiber *scheduler.Fiber) {
+	if sched != nil {
 		if debugLog != nil {
 			debugLog("[State] Calling scheduler.MarkDirty for fiber", fiber.ID())
 		}
@@ -337,16 +440,27 @@ func markDirtyOrBatch(sched Scheduler, fiber *scheduler.Fiber) {
 
 // Helper functions for easier API
 
-// CreateState is a convenience function to create a new state
-func CreateState[T any](initial T) *State[T] {
-	// This would normally get the scheduler from context
-	// For now, we'll require passing it explicitly
-	return NewState(initial, nil)
+// CreateState is a convenience function to create a new state. Created
+// with the owner of a render, the state uses the scheduler of the fiber
+// rendering; otherwise fibers reading it are marked dirty on their own
+// scheduler.
+func CreateState[T any](scope Scope, initial T) *State[T] {
+	return NewState(initial, renderingScheduler(scope))
+}
+
+// CreateComputed is a convenience function to create a new computed value,
+// using the scheduler of the fiber rendering like CreateState
+func CreateComputed[T any](scope Scope, compute func(o *Owner) T) *Computed[T] {
+	return NewComputed(compute, renderingScheduler(scope))
 }
 
-// CreateComputed is a convenience function to create a new computed value
-func CreateComputed[T any](compute func() T) *Computed[T] {
-	// This would normally get the scheduler from context
-	// For now, we'll require passing it explicitly
-	return NewComputed(compute, nil)
-}
\ No newline at end of file
+// renderingScheduler returns the scheduler of the fiber whose render owns
+// scope, or nil
+func renderingScheduler(scope Scope) Scheduler {
+	if o := ownerOf(scope).observer(); o != nil && o.fiber != nil {
+		if sched := o.fiber.Scheduler(); sched != nil {
+			return sched
+		}
+	}
+	return nil
+}
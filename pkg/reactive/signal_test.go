@@ -15,13 +15,13 @@ func TestState_GetSet(t *testing.T) {
 	state := NewState(42, sched)
 	
 	// Test initial value
-	if got := state.Get(); got != 42 {
+	if got := state.Get(nil); got != 42 {
 		t.Errorf("Expected initial value 42, got %d", got)
 	}
 	
 	// Test set
-	state.Set(100)
-	if got := state.Get(); got != 100 {
+	state.Set(nil, 100)
+	if got := state.Get(nil); got != 100 {
 		t.Errorf("Expected value 100 after Set, got %d", got)
 	}
 }
@@ -39,13 +39,13 @@ func TestState_DependencyTracking(t *testing.T) {
 		defer SetCurrentFiber(nil)
 		
 		renderCount.Add(1)
-		value := state.Get()
+		value := state.Get(nil)
 		return vdom.NewText(value)
 	}, nil)
 	
 	// Set the fiber as current and call Get to establish dependency
 	SetCurrentFiber(fiber)
-	_ = state.Get()
+	_ = state.Get(nil)
 	SetCurrentFiber(nil)
 	
 	// Start scheduler
@@ -62,7 +62,7 @@ func TestState_DependencyTracking(t *testing.T) {
 	}
 	
 	// Update state should trigger re-render
-	state.Set("world")
+	state.Set(nil, "world")
 	time.Sleep(50 * time.Millisecond)
 	
 	if renderCount.Load() != 2 {
@@ -75,11 +75,11 @@ func TestState_Update(t *testing.T) {
 	state := NewState(10, sched)
 	
 	// Test update function
-	state.Update(func(v int) int {
+	state.Update(nil, func(v int) int {
 		return v * 2
 	})
 	
-	if got := state.Get(); got != 20 {
+	if got := state.Get(nil); got != 20 {
 		t.Errorf("Expected value 20 after Update, got %d", got)
 	}
 }
@@ -95,7 +95,7 @@ func TestState_ConcurrentAccess(t *testing.T) {
 		wg.Add(1)
 		go func(val int) {
 			defer wg.Done()
-			state.Set(val)
+			state.Set(nil, val)
 		}(i)
 	}
 	
@@ -104,7 +104,7 @@ func TestState_ConcurrentAccess(t *testing.T) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			_ = state.Get()
+			_ = state.Get(nil)
 		}()
 	}
 	
@@ -125,22 +125,22 @@ func TestComputed_Basic(t *testing.T) {
 	}, nil)
 	
 	SetCurrentFiber(fiber)
-	double := NewComputed(func() int {
-		return count.Get() * 2
+	double := NewComputed(func(o *Owner) int {
+		return count.Get(o) * 2
 	}, sched)
 	SetCurrentFiber(nil)
 	
 	// Initial computed value
-	if got := double.Get(); got != 10 {
+	if got := double.Get(nil); got != 10 {
 		t.Errorf("Expected computed value 10, got %d", got)
 	}
 	
 	// Update dependency
-	count.Set(7)
+	count.Set(nil, 7)
 	double.Invalidate() // For now, manual invalidation
 	
 	// Computed should be invalidated
-	if got := double.Get(); got != 14 {
+	if got := double.Get(nil); got != 14 {
 		t.Errorf("Expected computed value 14 after update, got %d", got)
 	}
 }
@@ -149,27 +149,27 @@ func TestComputed_Memoization(t *testing.T) {
 	sched := scheduler.NewScheduler()
 	
 	var computeCount atomic.Int32
-	expensive := NewComputed(func() int {
+	expensive := NewComputed(func(*Owner) int {
 		computeCount.Add(1)
 		time.Sleep(10 * time.Millisecond) // Simulate expensive computation
 		return 42
 	}, sched)
 	
 	// First call should compute
-	_ = expensive.Get()
+	_ = expensive.Get(nil)
 	if computeCount.Load() != 1 {
 		t.Errorf("Expected 1 computation, got %d", computeCount.Load())
 	}
 	
 	// Second call should use cached value
-	_ = expensive.Get()
+	_ = expensive.Get(nil)
 	if computeCount.Load() != 1 {
 		t.Errorf("Expected still 1 computation (memoized), got %d", computeCount.Load())
 	}
 	
 	// After invalidation, should recompute
 	expensive.Invalidate()
-	_ = expensive.Get()
+	_ = expensive.Get(nil)
 	if computeCount.Load() != 2 {
 		t.Errorf("Expected 2 computations after invalidation, got %d", computeCount.Load())
 	}
@@ -181,29 +181,29 @@ func TestComputed_ChainedDependencies(t *testing.T) {
 	// Create a chain: a -> b -> c
 	a := NewState(1, sched)
 	
-	b := NewComputed(func() int {
+	b := NewComputed(func(o *Owner) int {
 		SetCurrentFiber(&scheduler.Fiber{}) // Mock fiber for dependency tracking
 		defer SetCurrentFiber(nil)
-		return a.Get() + 1
+		return a.Get(o) + 1
 	}, sched)
 	
-	c := NewComputed(func() int {
+	c := NewComputed(func(o *Owner) int {
 		SetCurrentFiber(&scheduler.Fiber{}) // Mock fiber for dependency tracking
 		defer SetCurrentFiber(nil)
-		return b.Get() * 2
+		return b.Get(o) * 2
 	}, sched)
 	
 	// Initial values
-	if got := c.Get(); got != 4 { // (1 + 1) * 2 = 4
+	if got := c.Get(nil); got != 4 { // (1 + 1) * 2 = 4
 		t.Errorf("Expected computed value 4, got %d", got)
 	}
 	
 	// Update root
-	a.Set(5)
+	a.Set(nil, 5)
 	b.Invalidate() // Manual invalidation for this test
 	c.Invalidate()
 	
-	if got := c.Get(); got != 12 { // (5 + 1) * 2 = 12
+	if got := c.Get(nil); got != 12 { // (5 + 1) * 2 = 12
 		t.Errorf("Expected computed value 12 after update, got %d", got)
 	}
 }
@@ -226,22 +226,22 @@ func TestBatch(t *testing.T) {
 	
 	// Create a fiber that depends on all states
 	fiber := sched.CreateFiber(func() *vdom.VNode {
-		sum := state1.Get() + state2.Get() + state3.Get()
+		sum := state1.Get(nil) + state2.Get(nil) + state3.Get(nil)
 		return vdom.NewText(string(rune(sum)))
 	}, nil)
 	
 	// Establish dependencies
 	SetCurrentFiber(fiber)
-	_ = state1.Get()
-	_ = state2.Get()
-	_ = state3.Get()
+	_ = state1.Get(nil)
+	_ = state2.Get(nil)
+	_ = state3.Get(nil)
 	SetCurrentFiber(nil)
 	
 	// Without batch - each Set should mark fiber dirty
 	markDirtyCount.Store(0)
-	state1.Set(10)
-	state2.Set(20)
-	state3.Set(30)
+	state1.Set(nil, 10)
+	state2.Set(nil, 20)
+	state3.Set(nil, 30)
 	
 	withoutBatchCount := markDirtyCount.Load()
 	if withoutBatchCount != 3 {
@@ -250,10 +250,10 @@ func TestBatch(t *testing.T) {
 	
 	// With batch - should only mark dirty once at the end
 	markDirtyCount.Store(0)
-	RunBatch(trackingSched, func() {
-		state1.Set(100)
-		state2.Set(200)
-		state3.Set(300)
+	RunBatch(nil, trackingSched, func(o *Owner) {
+		state1.Set(o, 100)
+		state2.Set(o, 200)
+		state3.Set(o, 300)
 	})
 	
 	withBatchCount := markDirtyCount.Load()
@@ -302,7 +302,7 @@ func TestSignal_NilFiber(t *testing.T) {
 	
 	// Get without current fiber should work
 	SetCurrentFiber(nil)
-	val := state.Get()
+	val := state.Get(nil)
 	if val != 42 {
 		t.Errorf("Expected value 42, got %d", val)
 	}
@@ -314,7 +314,7 @@ func BenchmarkState_Get(b *testing.B) {
 	
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = state.Get()
+		_ = state.Get(nil)
 	}
 }
 
@@ -324,22 +324,195 @@ func BenchmarkState_Set(b *testing.B) {
 	
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		state.Set(i)
+		state.Set(nil, i)
 	}
 }
 
 func BenchmarkComputed_Get(b *testing.B) {
 	sched := scheduler.NewScheduler()
 	base := NewState(10, sched)
-	computed := NewComputed(func() int {
-		return base.Get() * 2
+	computed := NewComputed(func(o *Owner) int {
+		return base.Get(o) * 2
 	}, sched)
 	
 	// Prime the cache
-	_ = computed.Get()
+	_ = computed.Get(nil)
 	
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = computed.Get()
+		_ = computed.Get(nil)
 	}
-}
\ No newline at end of file
+}
+
+func TestBatch_Nested(t *testing.T) {
+	rec := &dirtyRecorder{}
+	sched := scheduler.NewScheduler()
+	a := NewState(1, rec)
+	b := NewState(2, rec)
+	fiber := sched.CreateFiber(nil, nil)
+	trackRender(fiber, rendered(func(o *Owner) *vdom.VNode {
+		_ = a.Get(o) + b.Get(o)
+		return nil
+	}))
+	
+	RunBatch(nil, nil, func(o *Owner) {
+		a.Set(o, 10)
+		RunBatch(o, nil, func(o *Owner) {
+			b.Set(o, 20)
+		})
+		if n := rec.take(); n != 0 {
+			t.Errorf("Expected nothing marked before the outermost batch ends, got %d", n)
+		}
+		a.Set(o, 11)
+	})
+	if n := rec.take(); n != 1 {
+		t.Errorf("Expected the fiber marked once, got %d", n)
+	}
+}
+
+func TestBatch_Rollback(t *testing.T) {
+	rec := &dirtyRecorder{}
+	sched := scheduler.NewScheduler()
+	a := NewState(1, rec)
+	b := NewState("b", rec)
+	double := NewComputed(func(o *Owner) int { return a.Get(o) * 2 }, nil)
+	fiber := sched.CreateFiber(nil, nil)
+	trackRender(fiber, rendered(func(o *Owner) *vdom.VNode {
+		_ = double.Get(o)
+		_ = b.Get(o)
+		return nil
+	}))
+	
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("Expected the panic to continue, got %v", r)
+			}
+		}()
+		RunBatch(nil, nil, func(o *Owner) {
+			a.Set(o, 5)
+			a.Update(o, func(v int) int { return v + 1 })
+			b.Set(o, "changed")
+			if got := double.Get(o); got != 12 {
+				t.Errorf("Expected 12 inside the batch, got %d", got)
+			}
+			panic("boom")
+		})
+	}()
+	
+	if a.Get(nil) != 1 || b.Get(nil) != "b" || double.Get(nil) != 2 {
+		t.Errorf("Expected values rolled back, got %d, %q, %d", a.Get(nil), b.Get(nil), double.Get(nil))
+	}
+	if n := rec.take(); n != 0 {
+		t.Errorf("Expected no fiber marked after rollback, got %d", n)
+	}
+	
+	// A panic in an Update function rolls back the updates before it
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("Expected the panic to continue, got %v", r)
+			}
+		}()
+		RunBatch(nil, nil, func(o *Owner) {
+			a.Set(o, 3)
+			a.Update(o, func(int) int { panic("boom") })
+		})
+	}()
+	if a.Get(nil) != 1 {
+		t.Errorf("Expected 1 after an Update panic, got %d", a.Get(nil))
+	}
+	a.Update(nil, func(v int) int { return v + 1 })
+	if a.Get(nil) != 2 {
+		t.Errorf("Expected the state usable after an Update panic, got %d", a.Get(nil))
+	}
+	a.Set(nil, 1)
+	rec.take()
+	
+	// A nested batch that panics rolls back its own updates only
+	RunBatch(nil, nil, func(o *Owner) {
+		a.Set(o, 7)
+		func() {
+			defer func() { recover() }()
+			RunBatch(o, nil, func(o *Owner) {
+				a.Set(o, 8)
+				b.Set(o, "inner")
+				panic("inner")
+			})
+		}()
+	})
+	if a.Get(nil) != 7 || b.Get(nil) != "b" {
+		t.Errorf("Expected the outer update kept and the inner ones rolled back, got %d, %q", a.Get(nil), b.Get(nil))
+	}
+	if n := rec.take(); n != 1 {
+		t.Errorf("Expected the fiber marked once, got %d", n)
+	}
+}
+
+func TestBatch_OtherWriters(t *testing.T) {
+	rec := &dirtyRecorder{}
+	sched := scheduler.NewScheduler()
+	a := NewState(1, rec)
+	b := NewState(0, rec)
+	fiber := sched.CreateFiber(nil, nil)
+	trackRender(fiber, rendered(func(o *Owner) *vdom.VNode {
+		_ = b.Get(o)
+		return nil
+	}))
+	
+	// A Set through another owner while the batch is open is neither
+	// held back nor rolled back with it
+	started := make(chan struct{})
+	written := make(chan struct{})
+	go func() {
+		<-started
+		b.Set(nil, 42)
+		close(written)
+	}()
+	func() {
+		defer func() { recover() }()
+		RunBatch(nil, nil, func(o *Owner) {
+			a.Set(o, 2)
+			close(started)
+			<-written
+			if n := rec.take(); n != 1 {
+				t.Errorf("Expected the other write to mark the fiber at once, got %d", n)
+			}
+			panic("boom")
+		})
+	}()
+	
+	if a.Get(nil) != 1 || b.Get(nil) != 42 {
+		t.Errorf("Expected only the batch rolled back, got %d, %d", a.Get(nil), b.Get(nil))
+	}
+}
+
+func TestBatch_OneRenderPerFiber(t *testing.T) {
+	sched := scheduler.NewScheduler()
+	a := CreateState(nil, 0)
+	b := CreateState(nil, 0)
+	sum := CreateComputed(nil, func(o *Owner) int { return a.Get(o) + b.Get(o) })
+	var renders atomic.Int32
+	var last atomic.Int32
+	
+	fiber := ownedFiber(sched, func(o *Owner) *vdom.VNode {
+		renders.Add(1)
+		last.Store(int32(a.Get(o) + sum.Get(o)))
+		return nil
+	})
+	sched.Start()
+	defer sched.Stop()
+	sched.MarkDirty(fiber)
+	time.Sleep(50 * time.Millisecond)
+	
+	RunBatch(nil, nil, func(o *Owner) {
+		for i := 1; i <= 5; i++ {
+			a.Set(o, i)
+			b.Set(o, i * 10)
+		}
+	})
+	time.Sleep(50 * time.Millisecond)
+	if renders.Load() != 2 || last.Load() != 60 {
+		t.Errorf("Expected one more render showing 60, got %d renders showing %d", renders.Load(), last.Load())
+	}
+}
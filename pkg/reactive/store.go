@@ -0,0 +1,248 @@
+package reactive
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/recera/vango/pkg/scheduler"
+)
+
+// Reducer returns the state of a store after an action. Other values it
+// updates through o join the batch of the dispatch.
+type Reducer[T any] func(o *Owner, state T, action any) T
+
+// Store holds structured state, such as a cart with its items, that many
+// components read parts of. Readers subscribe to the paths they read (see
+// Select), not to the whole value: after an update only the readers of a
+// path whose value changed re-render. Values are treated as immutable:
+// every update makes a new value, copying only what changes (see SetIn),
+// and the store compares the paths read in the old and new values to find
+// the ones that changed.
+//
+// Paths name a value inside another, such as "cart.items[3].qty". Keys are
+// separated by dots; an index may also be written in brackets. A key is
+// resolved against the value it applies to:
+//
+//   - in a struct, the exported field of that name, of that JSON name, or
+//     of that name in any case, tried in that order
+//   - in a slice or array, the element at that index
+//   - in a map, the entry of that key, converted to the map's key type
+//
+// Pointers and interfaces are followed. The empty path names the whole
+// value. The same paths work with the helpers on plain values: GetIn,
+// SetIn, UpdateIn, DeleteIn and AppendIn.
+type Store[T any] struct {
+	value     T
+	mu        sync.RWMutex
+	scheduler Scheduler
+	reducer   Reducer[T]
+
+	nodesMu sync.Mutex
+	root    *pathNode
+}
+
+// pathNode is a path read from a store. Its source has the readers of the
+// value at the path; children are the longer paths read below it.
+type pathNode struct {
+	source
+	children map[string]*pathNode // guarded by the store's nodesMu
+}
+
+// NewStore creates a new store
+func NewStore[T any](initial T, sched Scheduler) *Store[T] {
+	return &Store[T]{
+		value:     initial,
+		scheduler: sched,
+		root:      &pathNode{},
+	}
+}
+
+// CreateStore creates a new store, using the scheduler of the fiber
+// rendering like CreateState
+func CreateStore[T any](scope Scope, initial T) *Store[T] {
+	return NewStore(initial, renderingScheduler(scope))
+}
+
+// WithReducer sets the reducer Dispatch runs and returns the store
+func (s *Store[T]) WithReducer(reducer Reducer[T]) *Store[T] {
+	s.mu.Lock()
+	s.reducer = reducer
+	s.mu.Unlock()
+	return s
+}
+
+// Get returns the whole value, subscribing the owner of scope to every
+// change
+func (s *Store[T]) Get(scope Scope) T {
+	return Select[T](scope, s, "")
+}
+
+// Select returns the value at path in the store (see Store for paths),
+// the zero V if the path leads to missing data. Read through the owner of
+// a render, a computed or an effect, it subscribes that reader to the path
+// only: the reader is updated when the value there changes, whatever else
+// changes. It panics if the path does not fit the store's type or the
+// value found is not a V.
+func Select[V, T any](scope Scope, s *Store[T], path string) V {
+	keys := parsePath(path)
+	n, o := s.observe(scope, keys)
+
+	s.mu.RLock()
+	value, version := s.value, n.version.Load()
+	s.mu.RUnlock()
+
+	o.saw(&n.source, version)
+	return valueAt[V](reflect.ValueOf(&value).Elem(), path)
+}
+
+// observe subscribes the observer of scope to the node of a path, creating
+// it, and returns both. Nodes are created and subscribed to under nodesMu
+// so a commit never misses a subscription.
+func (s *Store[T]) observe(scope Scope, keys []string) (*pathNode, *observer) {
+	s.nodesMu.Lock()
+	defer s.nodesMu.Unlock()
+	n := s.root
+	for _, key := range keys {
+		child := n.children[key]
+		if child == nil {
+			child = &pathNode{}
+			if n.children == nil {
+				n.children = make(map[string]*pathNode)
+			}
+			n.children[key] = child
+		}
+		n = child
+	}
+	return n, observe(scope, &n.source)
+}
+
+// Set replaces the value. Like the updates below, it joins the batch of
+// scope, if any (see RunBatch).
+func (s *Store[T]) Set(scope Scope, value T) {
+	s.Update(scope, func(T) T { return value })
+}
+
+// Update replaces the value with what fn returns for it. fn must not
+// modify the value it gets, nor read the store; the helpers such as SetIn
+// and UpdateIn make the new value without modifying the old one.
+func (s *Store[T]) Update(scope Scope, fn func(T) T) {
+	s.commit(batchOf(scope), fn, true)
+}
+
+// SetIn sets the value at path, like the SetIn function
+func (s *Store[T]) SetIn(scope Scope, path string, x any) {
+	s.Update(scope, func(value T) T { return SetIn(value, path, x) })
+}
+
+// DeleteIn deletes the map entry or slice element at path, like the
+// DeleteIn function
+func (s *Store[T]) DeleteIn(scope Scope, path string) {
+	s.Update(scope, func(value T) T { return DeleteIn(value, path) })
+}
+
+// AppendIn appends items to the slice at path, like the AppendIn function
+func (s *Store[T]) AppendIn(scope Scope, path string, items ...any) {
+	s.Update(scope, func(value T) T { return AppendIn(value, path, items...) })
+}
+
+// Dispatch runs the store's reducer with action and stores the result.
+// The reducer runs in a batch, joining the batch of scope if any, so the
+// stores and states it updates on the side through its owner re-render
+// their readers once, with the new value, and are restored if it panics.
+// It panics if the store has no reducer.
+func (s *Store[T]) Dispatch(scope Scope, action any) {
+	s.mu.RLock()
+	reducer := s.reducer
+	s.mu.RUnlock()
+	if reducer == nil {
+		panic("reactive: Dispatch on a store without a reducer")
+	}
+
+	RunBatch(scope, s.scheduler, func(o *Owner) {
+		s.Update(o, func(value T) T { return reducer(o, value, action) })
+	})
+}
+
+// Subscribe adds a fiber as a dependency of the whole value
+func (s *Store[T]) Subscribe(fiber *scheduler.Fiber) {
+	if fiber == nil {
+		return
+	}
+	s.root.subscribeFiber(fiber)
+}
+
+// Unsubscribe removes a fiber added with Subscribe
+func (s *Store[T]) Unsubscribe(fiber *scheduler.Fiber) {
+	if fiber == nil {
+		return
+	}
+	s.root.unsubscribeFiber(fiber)
+}
+
+// commit stores the value fn returns and updates the readers of the paths
+// that changed, at the end of batch if there is one. journal is false when
+// a batch restores a value.
+func (s *Store[T]) commit(batch *Batch, fn func(T) T, journal bool) {
+	old, changed := s.swap(fn)
+	if journal && batch != nil {
+		batch.record(func() { s.commit(batch, func(T) T { return old }, false) })
+	}
+	if len(changed) > 0 {
+		propagateAll(changed, stateDirty, s.scheduler, batch)
+	}
+}
+
+// swap stores the value fn returns, returning the old one and the sources
+// of the paths that changed. A panic in fn leaves the value as it was.
+func (s *Store[T]) swap(fn func(T) T) (old T, changed []*source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old = s.value
+	value := fn(old)
+	s.value = value
+
+	s.nodesMu.Lock()
+	defer s.nodesMu.Unlock()
+	s.diff(s.root, reflect.ValueOf(&old).Elem(), reflect.ValueOf(&value).Elem(), &changed)
+	return old, changed
+}
+
+// diff bumps the version of the nodes below n whose value differs between
+// old and next and adds them to changed, reporting whether n's did. A path
+// changed if a path below it did; otherwise, only paths with readers are
+// compared. Unchanged parts of an immutable update are shared with the old
+// value, so comparing them is cheap.
+func (s *Store[T]) diff(n *pathNode, old, next reflect.Value, changed *[]*source) bool {
+	differs := false
+	for key, child := range n.children {
+		o, _ := step(old, key)
+		v, _ := step(next, key)
+		if s.diff(child, o, v, changed) {
+			differs = true
+		}
+	}
+	if !differs && n.hasReaders() {
+		differs = !sameValue(old, next)
+	}
+	if differs {
+		n.version.Add(1)
+		*changed = append(*changed, &n.source)
+	}
+	return differs
+}
+
+// hasReaders reports whether anything is subscribed to the node
+func (n *pathNode) hasReaders() bool {
+	n.depsMu.Lock()
+	defer n.depsMu.Unlock()
+	return len(n.deps) > 0 || len(n.observers) > 0
+}
+
+// sameValue reports whether two values found at a path are deeply equal,
+// missing data being equal only to missing data
+func sameValue(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
@@ -0,0 +1,173 @@
+package reactive
+
+import (
+	"testing"
+
+	"github.com/recera/vango/pkg/scheduler"
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+type cartItem struct {
+	Name string `json:"name"`
+	Qty  int    `json:"qty"`
+}
+
+type cart struct {
+	Items []cartItem        `json:"items"`
+	Tags  map[string]string `json:"tags"`
+	Owner *cartOwner
+}
+
+type cartOwner struct {
+	Name string
+}
+
+func TestStore_PathSubscriptions(t *testing.T) {
+	rec := &dirtyRecorder{}
+	sched := scheduler.NewScheduler()
+	store := NewStore(cart{Items: []cartItem{{"a", 1}, {"b", 1}, {"c", 1}, {"d", 1}}}, rec)
+
+	render := func(path string) *scheduler.Fiber {
+		fiber := sched.CreateFiber(nil, nil)
+		trackRender(fiber, rendered(func(o *Owner) *vdom.VNode {
+			_ = Select[any](o, store, path)
+			return nil
+		}))
+		return fiber
+	}
+	qty3 := render("items[3].qty")
+	name3 := render("items.3.name")
+	qty2 := render("items[2].qty")
+	items := render("items")
+	tags := render("tags")
+
+	marked := func() map[*scheduler.Fiber]bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		m := make(map[*scheduler.Fiber]bool)
+		for _, f := range rec.fibers {
+			m[f] = true
+		}
+		rec.fibers = nil
+		return m
+	}
+
+	store.SetIn(nil, "items[3].qty", 5)
+	got := marked()
+	if !got[qty3] || !got[items] || got[name3] || got[qty2] || got[tags] || len(got) != 2 {
+		t.Errorf("Expected only the readers of items[3].qty and items marked, got %v", got)
+	}
+	if q := Select[int](nil, store, "items[3].qty"); q != 5 {
+		t.Errorf("Expected qty 5, got %d", q)
+	}
+
+	// Setting the same value changes nothing
+	store.SetIn(nil, "items[3].qty", 5)
+	if got := marked(); len(got) != 0 {
+		t.Errorf("Expected no fiber marked for an unchanged value, got %d", len(got))
+	}
+
+	// Missing map entries read as the zero value until set
+	tag := render("tags.color")
+	store.SetIn(nil, "tags.color", "red")
+	got = marked()
+	if !got[tag] || !got[tags] || len(got) != 2 {
+		t.Errorf("Expected the readers of tags and tags.color marked, got %v", got)
+	}
+
+	// Replacing the whole value compares the paths read
+	next := store.Get(nil)
+	next.Owner = &cartOwner{}
+	store.Set(nil, next)
+	if got := marked(); len(got) != 0 {
+		t.Errorf("Expected no fiber marked when only an unread path changed, got %d", len(got))
+	}
+}
+
+func TestStore_ImmutableHelpers(t *testing.T) {
+	owner := &cartOwner{Name: "ann"}
+	c := cart{
+		Items: []cartItem{{"a", 1}, {"b", 2}},
+		Tags:  map[string]string{"k": "v"},
+		Owner: owner,
+	}
+
+	updated := UpdateIn(c, "items[1].qty", func(q int) int { return q + 1 })
+	if updated.Items[1].Qty != 3 || c.Items[1].Qty != 2 {
+		t.Errorf("Expected a new value with qty 3 and the old one untouched, got %d and %d", updated.Items[1].Qty, c.Items[1].Qty)
+	}
+
+	withTag := SetIn(c, "Tags.x", "y")
+	if withTag.Tags["x"] != "y" || len(c.Tags) != 1 {
+		t.Errorf("Expected the entry set in a copy of the map, got %v and %v", withTag.Tags, c.Tags)
+	}
+	if &withTag.Items[0] != &c.Items[0] {
+		t.Error("Expected the items shared with the old value")
+	}
+
+	renamed := SetIn(c, "owner.name", "bob")
+	if GetIn[string](renamed, "owner.name") != "bob" || owner.Name != "ann" {
+		t.Errorf("Expected the pointed struct copied, got %q and %q", renamed.Owner.Name, owner.Name)
+	}
+
+	removed := DeleteIn(c, "items[0]")
+	if len(removed.Items) != 1 || removed.Items[0].Name != "b" || len(c.Items) != 2 {
+		t.Errorf("Expected the first item removed from a copy, got %v", removed.Items)
+	}
+	appended := AppendIn(c, "items", cartItem{"c", 1})
+	if len(appended.Items) != 3 || len(c.Items) != 2 {
+		t.Errorf("Expected an item appended to a copy, got %v", appended.Items)
+	}
+	if q := GetIn[int](c, "items[7].qty"); q != 0 {
+		t.Errorf("Expected the zero value for an index out of range, got %d", q)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for a path that does not fit the type")
+		}
+	}()
+	GetIn[int](c, "items[0].price")
+}
+
+type addItem struct{ item cartItem }
+
+func TestStore_Dispatch(t *testing.T) {
+	rec := &dirtyRecorder{}
+	sched := scheduler.NewScheduler()
+	count := NewState(0, rec)
+	store := NewStore(cart{}, rec).WithReducer(func(o *Owner, c cart, action any) cart {
+		switch a := action.(type) {
+		case addItem:
+			count.Update(o, func(n int) int { return n + 1 })
+			return AppendIn(c, "items", a.item)
+		case string:
+			count.Set(o, -1)
+			panic(a)
+		}
+		return c
+	})
+
+	fiber := sched.CreateFiber(nil, nil)
+	trackRender(fiber, rendered(func(o *Owner) *vdom.VNode {
+		_ = Select[[]cartItem](o, store, "items")
+		_ = count.Get(o)
+		return nil
+	}))
+
+	store.Dispatch(nil, addItem{cartItem{"a", 1}})
+	if n := rec.take(); n != 1 {
+		t.Errorf("Expected the fiber marked once for the action, got %d", n)
+	}
+	if n := len(store.Get(nil).Items); n != 1 || count.Get(nil) != 1 {
+		t.Errorf("Expected 1 item and count 1, got %d and %d", n, count.Get(nil))
+	}
+
+	func() {
+		defer func() { recover() }()
+		store.Dispatch(nil, "boom")
+	}()
+	if count.Get(nil) != 1 || len(store.Get(nil).Items) != 1 {
+		t.Errorf("Expected the panicking action rolled back, got count %d", count.Get(nil))
+	}
+}
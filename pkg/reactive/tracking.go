@@ -0,0 +1,517 @@
+package reactive
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/recera/vango/pkg/scheduler"
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+func init() {
+	scheduler.SetRenderTracker(trackRender)
+}
+
+// nodeState is how up to date a computation is
+type nodeState uint8
+
+const (
+	stateClean nodeState = iota // value is current
+	stateCheck                  // a source further up changed; value may be stale
+	stateDirty                  // a direct source changed; value must be recomputed
+)
+
+// source is the part of States and Computeds that readers subscribe to.
+// Fibers that read it are in deps, computations in observers.
+type source struct {
+	version atomic.Uint64 // bumped when the value changes
+	comp    *computation  // the computation producing the value, for Computeds
+
+	depsMu    sync.Mutex
+	deps      map[*scheduler.Fiber]struct{}
+	observers map[*computation]struct{}
+}
+
+// subscribeFiber adds a fiber to re-render when the value changes
+func (s *source) subscribeFiber(fiber *scheduler.Fiber) {
+	s.depsMu.Lock()
+	defer s.depsMu.Unlock()
+	if s.deps == nil {
+		s.deps = make(map[*scheduler.Fiber]struct{})
+	}
+	s.deps[fiber] = struct{}{}
+}
+
+// unsubscribeFiber removes a fiber added with subscribeFiber
+func (s *source) unsubscribeFiber(fiber *scheduler.Fiber) {
+	s.depsMu.Lock()
+	defer s.depsMu.Unlock()
+	delete(s.deps, fiber)
+}
+
+// addObserver adds a computation to mark stale when the value changes
+func (s *source) addObserver(c *computation) {
+	s.depsMu.Lock()
+	defer s.depsMu.Unlock()
+	if s.observers == nil {
+		s.observers = make(map[*computation]struct{})
+	}
+	s.observers[c] = struct{}{}
+}
+
+// removeObserver removes a computation added with addObserver
+func (s *source) removeObserver(c *computation) {
+	s.depsMu.Lock()
+	defer s.depsMu.Unlock()
+	delete(s.observers, c)
+}
+
+// readers returns the fibers and computations subscribed to the source
+func (s *source) readers() ([]*scheduler.Fiber, []*computation) {
+	s.depsMu.Lock()
+	defer s.depsMu.Unlock()
+	fibers := make([]*scheduler.Fiber, 0, len(s.deps))
+	for fiber := range s.deps {
+		fibers = append(fibers, fiber)
+	}
+	comps := make([]*computation, 0, len(s.observers))
+	for c := range s.observers {
+		comps = append(comps, c)
+	}
+	return fibers, comps
+}
+
+// computation is the part of Computeds that evaluates them. It is marked
+// stale when a source changes and brought up to date by refresh, which
+// recomputes only if the value of a source did change.
+type computation struct {
+	source
+
+	evalMu  sync.Mutex         // serializes refreshes
+	state   nodeState          // guarded by depsMu
+	marks   uint64             // times marked stale; guarded by depsMu
+	sources map[*source]uint64 // versions read by the last evaluation; guarded by depsMu
+	stopped bool               // stopped effects never evaluate again; guarded by depsMu
+	eval    func(o *Owner)     // recomputes the value, bumping version if it changed
+	effect  *effect            // set for effects
+}
+
+// mark records that a source of the computation changed
+func (c *computation) mark(state nodeState) {
+	c.depsMu.Lock()
+	defer c.depsMu.Unlock()
+	if state > c.state {
+		c.state = state
+	}
+	c.marks++
+}
+
+// refresh brings the value up to date. A computation marked by a change
+// further up first refreshes its sources and recomputes only if one of
+// their versions moved, so chains of computeds evaluate each link at most
+// once per change and never see a mix of old and new values.
+func (c *computation) refresh() {
+	c.evalMu.Lock()
+	defer c.evalMu.Unlock()
+
+	c.depsMu.Lock()
+	state, marks, sources := c.state, c.marks, c.sources
+	if c.stopped {
+		state = stateClean
+	}
+	c.depsMu.Unlock()
+
+	switch state {
+	case stateClean:
+		return
+	case stateCheck:
+		if !sourcesChanged(sources) {
+			c.depsMu.Lock()
+			if c.marks == marks {
+				c.state = stateClean
+			}
+			c.depsMu.Unlock()
+			return
+		}
+	}
+	c.evaluate()
+}
+
+// evaluate recomputes the value, collecting the sources read anew
+func (c *computation) evaluate() {
+	// Marks that arrive while evaluating leave it stale
+	c.depsMu.Lock()
+	c.state = stateClean
+	c.depsMu.Unlock()
+
+	o := &observer{comp: c, read: make(map[*source]uint64)}
+	completed := false
+	defer func() {
+		read := o.end()
+		c.depsMu.Lock()
+		old := c.sources
+		if completed {
+			c.sources = read
+		} else {
+			c.state = stateDirty
+			c.sources = mergeReads(read, old)
+		}
+		current := c.sources
+		if c.stopped {
+			// Stopped while running: drop what the run subscribed to
+			old, current = mergeReads(read, old), nil
+			c.sources = nil
+		}
+		c.depsMu.Unlock()
+
+		for src := range old {
+			if _, ok := current[src]; !ok {
+				src.removeObserver(c)
+			}
+		}
+	}()
+
+	c.eval(&Owner{obs: o})
+	completed = true
+}
+
+// sourcesChanged refreshes the computed sources among sources and reports
+// whether any version differs from the one read
+func sourcesChanged(sources map[*source]uint64) bool {
+	for src, version := range sources {
+		if src.comp != nil {
+			src.comp.refresh()
+		}
+		if src.version.Load() != version {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeReads adds the sources of old missing from read, for evaluations
+// that stopped early and may not have reached all of them
+func mergeReads(read, old map[*source]uint64) map[*source]uint64 {
+	for src, version := range old {
+		if _, ok := read[src]; !ok {
+			read[src] = version
+		}
+	}
+	return read
+}
+
+// propagation collects the fibers to re-render and the effects to run
+// after a change
+type propagation struct {
+	visited map[*source]bool
+	dirty   map[*scheduler.Fiber]bool // read a changed source directly
+	check   []*scheduler.Fiber        // read it through computeds
+	effects []*effect
+}
+
+// visit marks the readers of src: fibers and computations reading it
+// directly get state, everything further down is only checked
+func (p *propagation) visit(src *source, state nodeState) {
+	if p.visited[src] {
+		return
+	}
+	p.visited[src] = true
+
+	fibers, comps := src.readers()
+	for _, fiber := range fibers {
+		if state == stateDirty {
+			p.dirty[fiber] = true
+		} else {
+			p.check = append(p.check, fiber)
+		}
+	}
+	for _, c := range comps {
+		c.mark(state)
+		if c.effect != nil {
+			p.effects = append(p.effects, c.effect)
+		}
+		p.visit(&c.source, stateCheck)
+	}
+}
+
+// propagate marks everything that read src stale after it changed, then
+// schedules fibers: those that read src directly at once, those that read
+// it through computeds only if the value of one of those changed. Effects
+// run last, again only if something they read changed. All marks are in
+// place before any computed is refreshed, so none evaluates with a mix of
+// old and new values. During batch, everything waits for its end instead.
+func propagate(src *source, state nodeState, sched Scheduler, batch *Batch) {
+	propagateAll([]*source{src}, state, sched, batch)
+}
+
+// propagateAll is propagate for several sources changed at once: readers
+// of more than one are still scheduled or run once
+func propagateAll(srcs []*source, state nodeState, sched Scheduler, batch *Batch) {
+	p := &propagation{
+		visited: make(map[*source]bool),
+		dirty:   make(map[*scheduler.Fiber]bool),
+	}
+	for _, src := range srcs {
+		p.visit(src, state)
+	}
+
+	if debugLog != nil {
+		debugLog("[State] Found", len(p.dirty)+len(p.check), "dependent fibers")
+	}
+	if batch != nil && batch.open() {
+		// Everything waits for the end of the batch
+		for fiber := range p.dirty {
+			batch.add(sched, fiber)
+		}
+		for _, fiber := range p.check {
+			batch.addCheck(sched, fiber)
+		}
+		for _, e := range p.effects {
+			batch.addEffect(e)
+		}
+		return
+	}
+
+	for fiber := range p.dirty {
+		markFiber(sched, fiber)
+	}
+	for _, fiber := range p.check {
+		if !p.dirty[fiber] && fiberStale(fiber) {
+			p.dirty[fiber] = true
+			markFiber(sched, fiber)
+		}
+	}
+	for _, e := range p.effects {
+		e.refresh()
+	}
+}
+
+// markFiber schedules a fiber to re-render, on the scheduler of the value
+// that changed or else the fiber's own
+func markFiber(sched Scheduler, fiber *scheduler.Fiber) {
+	if sched == nil {
+		if own := fiber.Scheduler(); own != nil {
+			sched = own
+		}
+	}
+	if debugLog != nil {
+		debugLog("[State] Marking fiber", fiber.ID(), "as dirty")
+	}
+	markDirty(sched, fiber)
+}
+
+// fiberState is what reactive tracks about a fiber
+type fiberState struct {
+	reads map[*source]uint64 // sources read by the last render, with the versions seen
+	hooks []any              // state of the hooks called by renders, by call order
+}
+
+// fiberStates holds the state of every fiber rendered with tracking until
+// it is removed
+var (
+	fiberStatesMu sync.Mutex
+	fiberStates   = make(map[*scheduler.Fiber]*fiberState)
+)
+
+// stateOf returns the state of a fiber, creating it on its first render
+func stateOf(fiber *scheduler.Fiber) *fiberState {
+	fiberStatesMu.Lock()
+	st, ok := fiberStates[fiber]
+	if !ok {
+		st = &fiberState{}
+		fiberStates[fiber] = st
+	}
+	fiberStatesMu.Unlock()
+
+	if !ok {
+		fiber.OnRemove(func() { untrackFiber(fiber) })
+	}
+	return st
+}
+
+// trackRender runs a fiber's render with an owner of its own and
+// subscribes the fiber to what it read, dropping the subscriptions of the
+// previous render it no longer needs. It is the scheduler's render
+// tracker.
+func trackRender(fiber *scheduler.Fiber, render func(owner any) *vdom.VNode) (node *vdom.VNode) {
+	st := stateOf(fiber)
+	o := &observer{fiber: fiber, st: st, read: make(map[*source]uint64)}
+	completed := false
+	defer func() {
+		read := o.end()
+		fiberStatesMu.Lock()
+		old := st.reads
+		if !completed {
+			mergeReads(read, old)
+		}
+		st.reads = read
+		current := read
+		if fiberStates[fiber] != st {
+			// Removed while rendering
+			old, current = read, nil
+		}
+		fiberStatesMu.Unlock()
+
+		for src := range old {
+			if _, ok := current[src]; !ok {
+				src.unsubscribeFiber(fiber)
+			}
+		}
+	}()
+
+	node = render(&Owner{obs: o})
+	completed = true
+	return node
+}
+
+// untrackFiber unsubscribes a removed fiber from everything it read
+func untrackFiber(fiber *scheduler.Fiber) {
+	fiberStatesMu.Lock()
+	st := fiberStates[fiber]
+	delete(fiberStates, fiber)
+	fiberStatesMu.Unlock()
+
+	if st == nil {
+		return
+	}
+	for src := range st.reads {
+		src.unsubscribeFiber(fiber)
+	}
+}
+
+// fiberStale reports whether a value the fiber read in its last render
+// changed. Fibers subscribed by hand are always stale.
+func fiberStale(fiber *scheduler.Fiber) bool {
+	fiberStatesMu.Lock()
+	var reads map[*source]uint64
+	st, ok := fiberStates[fiber]
+	if ok {
+		reads = st.reads
+	}
+	fiberStatesMu.Unlock()
+	return !ok || reads == nil || sourcesChanged(reads)
+}
+
+// observer is a fiber render, computed evaluation or effect run in
+// progress, or a static pass (see Static)
+type observer struct {
+	fiber  *scheduler.Fiber
+	comp   *computation
+	static bool
+
+	mu   sync.Mutex
+	read map[*source]uint64 // sources read, with the first version seen; guarded by mu
+	done bool               // the run ended; guarded by mu
+
+	st   *fiberState // state of the fiber rendering
+	slot int         // hooks called so far by the render
+}
+
+// saw records the version of a source the observer read. It does nothing
+// on a nil observer or once the run ended.
+func (o *observer) saw(src *source, version uint64) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.read[src]; !ok && !o.done {
+		o.read[src] = version
+	}
+}
+
+// end ends the observer's run and returns the sources it read
+func (o *observer) end() map[*source]uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.done = true
+	return o.read
+}
+
+// observe subscribes the observer of s to src before its value is read
+// and returns the observer, or nil if s tracks nothing. Without an
+// observer the fiber set with SetCurrentFiber, if any, is subscribed
+// instead.
+func observe(s Scope, src *source) *observer {
+	o := ownerOf(s).observer()
+	switch {
+	case o == nil:
+		if fiber := GetCurrentFiber(); fiber != nil {
+			src.subscribeFiber(fiber)
+		}
+	case o.static:
+		return nil
+	case o.comp != nil:
+		src.addObserver(o.comp)
+	default:
+		src.subscribeFiber(o.fiber)
+	}
+	return o
+}
+
+// Scope is what reactive values are read and created in: an Owner, or a
+// value holding one, such as the vango.Context a component renders with.
+// A nil Scope reads without tracking.
+type Scope interface {
+	Owner() *Owner
+}
+
+// Owner is a render, computed evaluation, effect run or static pass in
+// progress. Each of them gets an owner of its own: renders get theirs
+// from the scheduler, computeds and effects pass theirs to their
+// functions. Reading a value through an owner subscribes its render,
+// computed or effect to the value, and hooks such as Effect called with a
+// render's owner belong to the rendering fiber. RunBatch gives its
+// function an owner whose updates join the batch. An owner is used by the
+// code it was given to, on that code's goroutine; once its run ends,
+// reads through it track nothing.
+type Owner struct {
+	obs   *observer
+	batch *Batch // collecting the updates made through the owner, if any
+}
+
+// Owner returns o, so an Owner is a Scope
+func (o *Owner) Owner() *Owner {
+	return o
+}
+
+// observer returns the observer of o's run, nil once the run ended
+func (o *Owner) observer() *observer {
+	if o == nil || o.obs == nil {
+		return nil
+	}
+	o.obs.mu.Lock()
+	defer o.obs.mu.Unlock()
+	if o.obs.done {
+		return nil
+	}
+	return o.obs
+}
+
+// ownerOf returns the owner of a scope, which may be nil
+func ownerOf(s Scope) *Owner {
+	if s == nil {
+		return nil
+	}
+	return s.Owner()
+}
+
+// OwnerOf returns the owner of a fiber's render in progress, nil outside
+// of a render. Renders use it to hand their owner to the code they call;
+// see scheduler.Fiber.Owner.
+func OwnerOf(fiber *scheduler.Fiber) *Owner {
+	o, _ := fiber.Owner().(*Owner)
+	return o
+}
+
+// equalValues reports whether two computed values are the same, so
+// readers of an unchanged computed do not re-render. Values compare with
+// == when comparable; others, such as slices and maps, always differ.
+func equalValues(a, b any) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		return va.IsValid() == vb.IsValid()
+	}
+	return va.Type() == vb.Type() && va.Comparable() && va.Equal(vb)
+}
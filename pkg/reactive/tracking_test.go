@@ -0,0 +1,272 @@
+package reactive
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/recera/vango/pkg/scheduler"
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+// dirtyRecorder records the fibers marked dirty
+type dirtyRecorder struct {
+	mu     sync.Mutex
+	fibers []*scheduler.Fiber
+}
+
+func (r *dirtyRecorder) MarkDirty(fiber *scheduler.Fiber) {
+	r.mu.Lock()
+	r.fibers = append(r.fibers, fiber)
+	r.mu.Unlock()
+}
+
+func (r *dirtyRecorder) take() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(r.fibers)
+	r.fibers = nil
+	return n
+}
+
+// rendered adapts a render reading through its owner to trackRender
+func rendered(render func(o *Owner) *vdom.VNode) func(owner any) *vdom.VNode {
+	return func(owner any) *vdom.VNode { return render(owner.(*Owner)) }
+}
+
+// ownedFiber creates a fiber on sched whose render reads through the
+// owner the scheduler gives it
+func ownedFiber(sched *scheduler.Scheduler, render func(o *Owner) *vdom.VNode) *scheduler.Fiber {
+	var fiber *scheduler.Fiber
+	fiber = sched.CreateFiber(func() *vdom.VNode { return render(OwnerOf(fiber)) }, nil)
+	return fiber
+}
+
+func TestComputed_AutoTracking(t *testing.T) {
+	a := NewState(1, nil)
+	var evals int
+	double := NewComputed(func(o *Owner) int {
+		evals++
+		return a.Get(o) * 2
+	}, nil)
+
+	if got := double.Get(nil); got != 2 {
+		t.Fatalf("Expected 2, got %d", got)
+	}
+	a.Set(nil, 5)
+	if got := double.Get(nil); got != 10 {
+		t.Errorf("Expected 10 after Set without Invalidate, got %d", got)
+	}
+	_ = double.Get(nil)
+	if evals != 2 {
+		t.Errorf("Expected 2 evaluations, got %d", evals)
+	}
+}
+
+func TestComputed_DynamicDependencies(t *testing.T) {
+	useA := NewState(true, nil)
+	a := NewState("a", nil)
+	b := NewState("b", nil)
+	var evals int
+	pick := NewComputed(func(o *Owner) string {
+		evals++
+		if useA.Get(o) {
+			return a.Get(o)
+		}
+		return b.Get(o)
+	}, nil)
+
+	_ = pick.Get(nil)
+	useA.Set(nil, false)
+	if got := pick.Get(nil); got != "b" {
+		t.Fatalf("Expected b, got %q", got)
+	}
+	evals = 0
+
+	// a is no longer read, so changing it must not invalidate
+	a.Set(nil, "a2")
+	_ = pick.Get(nil)
+	if evals != 0 {
+		t.Errorf("Expected no evaluation after a dropped dependency changed, got %d", evals)
+	}
+	if len(a.observers) != 0 {
+		t.Errorf("Expected a to have no observers, got %d", len(a.observers))
+	}
+
+	b.Set(nil, "b2")
+	if got := pick.Get(nil); got != "b2" || evals != 1 {
+		t.Errorf("Expected b2 after 1 evaluation, got %q after %d", got, evals)
+	}
+}
+
+func TestComputed_GlitchFreeChain(t *testing.T) {
+	rec := &dirtyRecorder{}
+	sched := scheduler.NewScheduler()
+	a := NewState(1, rec)
+	b := NewComputed(func(o *Owner) int { return a.Get(o) + 1 }, nil)
+	c := NewComputed(func(o *Owner) int { return a.Get(o) * 2 }, nil)
+	var evals int
+	var seen [][2]int
+	d := NewComputed(func(o *Owner) int {
+		evals++
+		bv, cv := b.Get(o), c.Get(o)
+		seen = append(seen, [2]int{bv, cv})
+		return bv + cv
+	}, nil)
+	positive := NewComputed(func(o *Owner) bool { return d.Get(o) > 0 }, nil)
+
+	sumFiber := sched.CreateFiber(nil, nil)
+	positiveFiber := sched.CreateFiber(nil, nil)
+	trackRender(sumFiber, rendered(func(o *Owner) *vdom.VNode {
+		return vdom.NewText(string(rune('0' + d.Get(o))))
+	}))
+	trackRender(positiveFiber, rendered(func(o *Owner) *vdom.VNode {
+		_ = positive.Get(o)
+		return nil
+	}))
+
+	// d stays positive, so only the sum fiber renders
+	evals, seen = 0, nil
+	a.Set(nil, 2)
+	if n := rec.take(); n != 1 {
+		t.Errorf("Expected 1 fiber marked dirty, got %d", n)
+	}
+	if evals != 1 {
+		t.Errorf("Expected d to evaluate once, got %d", evals)
+	}
+	for _, pair := range seen {
+		if pair != [2]int{3, 4} {
+			t.Errorf("d saw inconsistent sources %v", pair)
+		}
+	}
+	if got := d.Get(nil); got != 7 {
+		t.Errorf("Expected 7, got %d", got)
+	}
+}
+
+func TestTracking_FiberRenders(t *testing.T) {
+	rec := &dirtyRecorder{}
+	sched := scheduler.NewScheduler()
+	show := NewState(true, rec)
+	text := NewState("hello", rec)
+	double := NewComputed(func(o *Owner) string { return text.Get(o) + text.Get(o) }, nil)
+
+	view := rendered(func(o *Owner) *vdom.VNode {
+		if show.Get(o) {
+			return vdom.NewText(double.Get(o))
+		}
+		return nil
+	})
+	fiber := sched.CreateFiber(nil, nil)
+	trackRender(fiber, view)
+
+	text.Set(nil, "hi")
+	if n := rec.take(); n != 1 {
+		t.Errorf("Expected the fiber marked dirty through the computed, got %d", n)
+	}
+
+	// The next render no longer reads the computed
+	show.Set(nil, false)
+	rec.take()
+	trackRender(fiber, view)
+	if len(double.deps) != 0 {
+		t.Errorf("Expected the fiber unsubscribed from the computed, got %d deps", len(double.deps))
+	}
+	text.Set(nil, "hey")
+	if n := rec.take(); n != 0 {
+		t.Errorf("Expected no fiber marked dirty, got %d", n)
+	}
+
+	// Removing the fiber drops its subscriptions
+	sched.RemoveFiber(fiber)
+	if len(show.deps) != 0 {
+		t.Errorf("Expected no deps after RemoveFiber, got %d", len(show.deps))
+	}
+	show.Set(nil, true)
+	if n := rec.take(); n != 0 {
+		t.Errorf("Expected no fiber marked dirty after RemoveFiber, got %d", n)
+	}
+}
+
+func TestTracking_Scheduler(t *testing.T) {
+	sched := scheduler.NewScheduler()
+	count := CreateState(nil, 0)
+	var renders atomic.Int32
+	var last atomic.Value
+
+	fiber := ownedFiber(sched, func(o *Owner) *vdom.VNode {
+		renders.Add(1)
+		text := string(rune('0' + count.Get(o)))
+		last.Store(text)
+		return vdom.NewText(text)
+	})
+
+	sched.Start()
+	defer sched.Stop()
+	sched.MarkDirty(fiber)
+	time.Sleep(50 * time.Millisecond)
+
+	// The state has no scheduler; the fiber's own is used
+	count.Set(nil, 3)
+	time.Sleep(50 * time.Millisecond)
+	if renders.Load() != 2 || last.Load() != "3" {
+		t.Errorf("Expected a second render showing 3, got %d renders showing %v", renders.Load(), last.Load())
+	}
+}
+
+func TestTracking_OwnersStayApart(t *testing.T) {
+	rec := &dirtyRecorder{}
+	sched := scheduler.NewScheduler()
+	a := NewState(1, rec)
+	b := NewState(2, rec)
+	sum := NewComputed(func(o *Owner) int { return a.Get(o) + b.Get(o) }, nil)
+	rendering := make(chan struct{})
+	release := make(chan struct{})
+	var kept *Owner
+
+	fiber := ownedFiber(sched, func(o *Owner) *vdom.VNode {
+		_ = a.Get(o)
+		kept = o
+		close(rendering)
+		<-release
+		return nil
+	})
+	sched.Start()
+	sched.MarkDirty(fiber)
+	<-rendering
+
+	// Other goroutines read and recompute during the render without
+	// taking its owner
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = sum.Get(nil)
+				_ = b.Get(nil)
+			}
+		}()
+	}
+	wg.Wait()
+	close(release)
+	sched.Stop()
+	rec.take()
+
+	b.Set(nil, 3)
+	if n := rec.take(); n != 0 {
+		t.Errorf("Expected no fiber marked dirty by reads of other goroutines, got %d", n)
+	}
+	a.Set(nil, 2)
+	if n := rec.take(); n != 1 {
+		t.Errorf("Expected the fiber marked dirty by its own read, got %d", n)
+	}
+
+	// An owner kept past its render tracks nothing
+	_ = b.Get(kept)
+	b.Set(nil, 4)
+	if n := rec.take(); n != 0 {
+		t.Errorf("Expected no fiber marked dirty by a read through an ended owner, got %d", n)
+	}
+}
@@ -15,9 +15,19 @@ import (
 type DOMApplier struct {
 	document      js.Value
 	window        js.Value
-	nodeMap       map[uint32]js.Value           // Maps node IDs to DOM elements
-	eventHandlers map[uint32]map[string]js.Func // Maps node IDs to event handlers
-	nodeCounter   uint32                        // For assigning IDs during hydration
+	nodeMap       map[uint32]js.Value               // Maps node IDs to DOM elements
+	eventHandlers map[uint32]map[string]domListener // Maps node IDs to event listeners
+	handlerProps  map[uint32]map[string]any         // Handler each node's listeners call
+	nodeCounter   uint32                            // For assigning IDs during hydration
+}
+
+// xhtmlNamespace is the namespaceURI of HTML elements
+const xhtmlNamespace = "http://www.w3.org/1999/xhtml"
+
+// domListener is a listener added to a DOM element
+type domListener struct {
+	fn      js.Func
+	options vdom.EventOptions
 }
 
 // NewDOMApplier creates a new DOM applier
@@ -26,7 +36,8 @@ func NewDOMApplier() *DOMApplier {
 		document:      js.Global().Get("document"),
 		window:        js.Global().Get("window"),
 		nodeMap:       make(map[uint32]js.Value),
-		eventHandlers: make(map[uint32]map[string]js.Func),
+		eventHandlers: make(map[uint32]map[string]domListener),
+		handlerProps:  make(map[uint32]map[string]any),
 		nodeCounter:   1,
 	}
 }
@@ -59,6 +70,10 @@ func (a *DOMApplier) applyPatch(patch vdom.Patch) error {
 		return a.updateEvents(patch)
 	case vdom.OpMoveNode:
 		return a.moveNode(patch)
+	case vdom.OpSetHandler:
+		return a.setHandler(patch)
+	case vdom.OpSetHTML:
+		return a.setHTML(patch)
 	default:
 		return fmt.Errorf("unknown patch operation: %v", patch.Op)
 	}
@@ -81,6 +96,16 @@ func (a *DOMApplier) replaceText(patch vdom.Patch) error {
 	return nil
 }
 
+// setHTML replaces the content of a raw HTML element
+func (a *DOMApplier) setHTML(patch vdom.Patch) error {
+	node, ok := a.nodeMap[patch.NodeID]
+	if !ok {
+		return fmt.Errorf("node %d not found", patch.NodeID)
+	}
+	node.Set("innerHTML", patch.Value)
+	return nil
+}
+
 // setAttribute sets an attribute on an element
 func (a *DOMApplier) setAttribute(patch vdom.Patch) error {
 	node, ok := a.nodeMap[patch.NodeID]
@@ -88,6 +113,12 @@ func (a *DOMApplier) setAttribute(patch vdom.Patch) error {
 		return fmt.Errorf("node %d not found", patch.NodeID)
 	}
 
+	// SVG and MathML elements have no HTML properties to set
+	if ns := domNamespace(node); ns != vdom.NamespaceHTML {
+		setElementAttribute(node, ns, patch.Key, patch.Value)
+		return nil
+	}
+
 	// Special handling for certain attributes
 	switch patch.Key {
 	case "class":
@@ -120,6 +151,16 @@ func (a *DOMApplier) removeAttribute(patch vdom.Patch) error {
 		return fmt.Errorf("node %d not found", patch.NodeID)
 	}
 
+	if ns := domNamespace(node); ns != vdom.NamespaceHTML {
+		name := vdom.AdjustAttribute(ns, patch.Key)
+		if attrNS := vdom.AttributeNamespace(name); attrNS != "" {
+			node.Call("removeAttributeNS", attrNS, name[strings.IndexByte(name, ':')+1:])
+		} else {
+			node.Call("removeAttribute", name)
+		}
+		return nil
+	}
+
 	// Special handling for certain attributes
 	switch patch.Key {
 	case "class":
@@ -157,11 +198,6 @@ func (a *DOMApplier) insertNode(patch vdom.Patch) error {
 		return fmt.Errorf("insert patch missing node")
 	}
 
-	// Create the entire DOM tree with proper IDs and event handlers
-	domNode, nextID := a.createDOMTree(patch.Node, patch.NodeID)
-
-	js.Global().Get("console").Call("log", fmt.Sprintf("[DOM] Created DOM tree starting at ID %d, next ID: %d", patch.NodeID, nextID))
-
 	// Find parent
 	parent, ok := a.nodeMap[patch.ParentID]
 	if !ok && patch.ParentID != 0 {
@@ -173,6 +209,17 @@ func (a *DOMApplier) insertNode(patch vdom.Patch) error {
 		parent = a.document.Get("body")
 	}
 
+	// Create the entire DOM tree with proper IDs and event handlers, in
+	// the namespace the parent passes down
+	domNode, nextID := a.createDOMTree(patch.Node, patch.NodeID, childNamespace(parent))
+
+	js.Global().Get("console").Call("log", fmt.Sprintf("[DOM] Created DOM tree starting at ID %d, next ID: %d", patch.NodeID, nextID))
+
+	// Portals render elsewhere and leave nothing to insert
+	if domNode.IsUndefined() {
+		return nil
+	}
+
 	// Insert the node
 	if patch.BeforeID != 0 {
 		before, ok := a.nodeMap[patch.BeforeID]
@@ -188,8 +235,9 @@ func (a *DOMApplier) insertNode(patch vdom.Patch) error {
 	return nil
 }
 
-// createDOMTree creates a DOM tree from a VNode tree, assigning IDs and attaching event handlers
-func (a *DOMApplier) createDOMTree(vnode *vdom.VNode, startID uint32) (js.Value, uint32) {
+// createDOMTree creates a DOM tree from a VNode tree, assigning IDs and attaching event handlers.
+// ns is the namespace the root inherits from its parent.
+func (a *DOMApplier) createDOMTree(vnode *vdom.VNode, startID uint32, ns string) (js.Value, uint32) {
 	if vnode == nil {
 		return js.Undefined(), startID
 	}
@@ -202,8 +250,8 @@ func (a *DOMApplier) createDOMTree(vnode *vdom.VNode, startID uint32) (js.Value,
 		a.nodeMap[currentID] = textNode
 		return textNode, currentID + 1
 
-	case vdom.KindElement:
-		elem := a.document.Call("createElement", vnode.Tag)
+	case vdom.KindElement, vdom.KindRaw:
+		elem, elemNS := a.createElement(vnode, ns)
 		a.nodeMap[currentID] = elem
 
 		// Set attributes
@@ -215,7 +263,7 @@ func (a *DOMApplier) createDOMTree(vnode *vdom.VNode, startID uint32) (js.Value,
 				}
 
 				// Apply attribute directly
-				elem.Call("setAttribute", key, fmt.Sprintf("%v", value))
+				setElementAttribute(elem, elemNS, key, vdom.PropString(value))
 			}
 
 			// Attach event handlers
@@ -231,10 +279,16 @@ func (a *DOMApplier) createDOMTree(vnode *vdom.VNode, startID uint32) (js.Value,
 			}
 		}
 
+		// Raw HTML elements carry their content as a string
+		if vnode.Kind == vdom.KindRaw {
+			elem.Set("innerHTML", vnode.Text)
+		}
+
 		// Create and append children
 		nextID := currentID + 1
+		kidsNS := vdom.ChildNamespace(vnode, elemNS)
 		for _, child := range vnode.Kids {
-			childDOM, newNextID := a.createDOMTree(&child, nextID)
+			childDOM, newNextID := a.createDOMTree(&child, nextID, kidsNS)
 			if !childDOM.IsUndefined() {
 				elem.Call("appendChild", childDOM)
 			}
@@ -243,14 +297,82 @@ func (a *DOMApplier) createDOMTree(vnode *vdom.VNode, startID uint32) (js.Value,
 
 		return elem, nextID
 
+	case vdom.KindFragment, vdom.KindComponent:
+		// Fragments and components take an ID so numbering matches the
+		// differ, but have no DOM node of their own once inserted
+		frag := a.document.Call("createDocumentFragment")
+		nextID := currentID + 1
+		for _, child := range vnode.Kids {
+			childDOM, newNextID := a.createDOMTree(&child, nextID, ns)
+			if !childDOM.IsUndefined() {
+				frag.Call("appendChild", childDOM)
+			}
+			nextID = newNextID
+		}
+		return frag, nextID
+
+	case vdom.KindPortal:
+		// Portal children render into the target element, nothing is
+		// inserted at the portal's own position
+		target := a.document.Call("querySelector", vnode.PortalTarget)
+		nextID := currentID + 1
+		for _, child := range vnode.Kids {
+			childDOM, newNextID := a.createDOMTree(&child, nextID, vdom.ChildNamespace(vnode, ns))
+			if !childDOM.IsUndefined() && target.Truthy() {
+				target.Call("appendChild", childDOM)
+			}
+			nextID = newNextID
+		}
+		return js.Undefined(), nextID
+
 	default:
 		return js.Undefined(), currentID
 	}
 }
 
+// createElement creates the element for vnode in the namespace it inherits,
+// with createElementNS outside HTML. It returns the element's namespace.
+func (a *DOMApplier) createElement(vnode *vdom.VNode, inherited string) (js.Value, string) {
+	ns := vdom.ElementNamespace(vnode, inherited)
+	if ns == vdom.NamespaceHTML {
+		return a.document.Call("createElement", vnode.Tag), ns
+	}
+	return a.document.Call("createElementNS", ns, vdom.AdjustTag(ns, vnode.Tag)), ns
+}
+
+// setElementAttribute sets an attribute on an element in ns, with the
+// attribute's proper case and prefixed attributes like xlink:href set in
+// their own namespace
+func setElementAttribute(elem js.Value, ns, key, value string) {
+	name := vdom.AdjustAttribute(ns, key)
+	if attrNS := vdom.AttributeNamespace(name); attrNS != "" {
+		elem.Call("setAttributeNS", attrNS, name, value)
+		return
+	}
+	elem.Call("setAttribute", name, value)
+}
+
+// domNamespace returns the vdom namespace of a DOM element
+func domNamespace(elem js.Value) string {
+	uri := elem.Get("namespaceURI")
+	if !uri.Truthy() || uri.String() == xhtmlNamespace {
+		return vdom.NamespaceHTML
+	}
+	return uri.String()
+}
+
+// childNamespace returns the namespace children of a DOM element inherit
+func childNamespace(parent js.Value) string {
+	ns := domNamespace(parent)
+	if ns == vdom.NamespaceSVG && parent.Get("localName").String() == "foreignObject" {
+		return vdom.NamespaceHTML
+	}
+	return ns
+}
+
 // createDOMNode creates a DOM node from a VNode
 func (a *DOMApplier) createDOMNode(vnode *vdom.VNode) (js.Value, error) {
-	dom, _ := a.createDOMTree(vnode, a.nodeCounter)
+	dom, _ := a.createDOMTree(vnode, a.nodeCounter, vdom.NamespaceHTML)
 	a.nodeCounter++
 	if dom.IsUndefined() {
 		return dom, fmt.Errorf("failed to create DOM node")
@@ -264,8 +386,8 @@ func (a *DOMApplier) createDOMNodeWithID(vnode *vdom.VNode, nodeID uint32) (js.V
 	case vdom.KindText:
 		return a.document.Call("createTextNode", vnode.Text), nil
 
-	case vdom.KindElement:
-		elem := a.document.Call("createElement", vnode.Tag)
+	case vdom.KindElement, vdom.KindRaw:
+		elem, _ := a.createElement(vnode, vdom.NamespaceHTML)
 
 		// Set attributes
 		if vnode.Props != nil {
@@ -279,7 +401,7 @@ func (a *DOMApplier) createDOMNodeWithID(vnode *vdom.VNode, nodeID uint32) (js.V
 				p := vdom.Patch{
 					NodeID: 0, // Temporary, not used in setAttribute
 					Key:    key,
-					Value:  fmt.Sprintf("%v", value),
+					Value:  vdom.PropString(value),
 				}
 
 				// Temporarily store the element in nodeMap
@@ -304,6 +426,10 @@ func (a *DOMApplier) createDOMNodeWithID(vnode *vdom.VNode, nodeID uint32) (js.V
 			}
 		}
 
+		if vnode.Kind == vdom.KindRaw {
+			elem.Set("innerHTML", vnode.Text)
+		}
+
 		// Create and append children
 		for _, child := range vnode.Kids {
 			childNode, err := a.createDOMNode(&child)
@@ -328,25 +454,40 @@ func (a *DOMApplier) createDOMNodeWithID(vnode *vdom.VNode, nodeID uint32) (js.V
 	}
 }
 
-// updateEvents updates event listeners on a node
+// updateEvents makes a node listen to exactly the events in patch.Events,
+// with their options. Listeners whose options changed are added again;
+// new listeners call the handler a SetHandler patch gave them.
 func (a *DOMApplier) updateEvents(patch vdom.Patch) error {
 	node, ok := a.nodeMap[patch.NodeID]
 	if !ok {
 		return fmt.Errorf("node %d not found", patch.NodeID)
 	}
 
-	// This is a simplified version - in reality, we'd need to:
-	// 1. Track which events are currently attached
-	// 2. Remove old event listeners
-	// 3. Add new event listeners based on EventBits
-
-	// For now, we'll store the event bits as a data attribute
-	node.Call("setAttribute", "data-events", fmt.Sprintf("%d", patch.EventBits))
+	wanted := make(map[string]vdom.EventOptions, len(patch.Events))
+	for _, l := range patch.Events {
+		wanted[l.Event.String()] = l.Options
+	}
 
+	for eventName, l := range a.eventHandlers[patch.NodeID] {
+		if options, ok := wanted[eventName]; !ok || options != l.options {
+			a.unlisten(patch.NodeID, node, eventName)
+		}
+	}
+	for eventName, options := range wanted {
+		if _, ok := a.eventHandlers[patch.NodeID][eventName]; ok {
+			continue
+		}
+		if handler, ok := a.handlerProps[patch.NodeID][eventName]; ok {
+			a.listen(patch.NodeID, node, eventName, handler, options)
+		}
+	}
 	return nil
 }
 
-// attachEventHandlers attaches event handlers from VNode props to a DOM element
+// attachEventHandlers attaches event handlers from VNode props to a DOM element.
+// Each listener calls whatever handler handlerProps holds for its node and
+// event when it fires, so setHandler can swap handlers without touching
+// the DOM.
 func (a *DOMApplier) attachEventHandlers(nodeID uint32, elem js.Value, props vdom.Props) {
 	if props == nil {
 		return
@@ -356,107 +497,162 @@ func (a *DOMApplier) attachEventHandlers(nodeID uint32, elem js.Value, props vdo
 	js.Global().Get("console").Call("log", fmt.Sprintf("[DOM] Attaching handlers for node %d", nodeID))
 
 	// Clean up existing handlers for this node
-	if handlers, exists := a.eventHandlers[nodeID]; exists {
-		for eventName, fn := range handlers {
-			elem.Call("removeEventListener", eventName, fn)
-			fn.Release()
-		}
+	for eventName := range a.eventHandlers[nodeID] {
+		a.unlisten(nodeID, elem, eventName)
 	}
-
-	// Create new handler map
-	handlers := make(map[string]js.Func)
+	delete(a.eventHandlers, nodeID)
+	delete(a.handlerProps, nodeID)
 
 	// Attach new handlers
 	for key, value := range props {
-		if len(key) > 2 && key[0] == 'o' && key[1] == 'n' {
-			// Convert onClick to click, onChange to change, etc.
-			eventName := strings.ToLower(key[2:])
-
-			js.Global().Get("console").Call("log", fmt.Sprintf("[DOM] Found event %s on node %d", eventName, nodeID))
-
-			// Support multiple handler signatures
-			var jsFunc js.Func
-			switch h := value.(type) {
-			case func():
-				jsFunc = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-					h()
-					return nil
-				})
-			case func(js.Value):
-				jsFunc = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-					if len(args) > 0 {
-						h(args[0])
-					} else {
-						h(js.Undefined())
-					}
-					return nil
-				})
-			case func(x, y float64):
-				jsFunc = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-					var x, y float64
-					if len(args) > 0 {
-						ev := args[0]
-						// Convert to element-relative coords using bounding box
-						bx := 0.0
-						by := 0.0
-						if this.Truthy() {
-							rect := this.Call("getBoundingClientRect")
-							bx = rect.Get("left").Float()
-							by = rect.Get("top").Float()
-						}
-						x = ev.Get("clientX").Float() - bx
-						y = ev.Get("clientY").Float() - by
-					}
-					h(x, y)
-					return nil
-				})
-			case func(deltaY float64):
-				jsFunc = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-					var d float64
-					if len(args) > 0 {
-						d = args[0].Get("deltaY").Float()
-					}
-					h(d)
-					return nil
-				})
-			case func(string):
-				jsFunc = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-					var s string
-					if len(args) > 0 {
-						ev := args[0]
-						switch eventName {
-						case "input", "change":
-							tgt := ev.Get("target")
-							if tgt.Truthy() {
-								s = tgt.Get("value").String()
-							}
-						case "keydown", "keyup", "keypress":
-							s = ev.Get("key").String()
-						default:
-							s = ev.Get("type").String()
-						}
-					}
-					h(s)
-					return nil
-				})
-			default:
-				// Fallback: ignore unsupported types
-				continue
-			}
-
-			// Add event listener
-			elem.Call("addEventListener", eventName, jsFunc)
-			handlers[eventName] = jsFunc
+		event, ok := vdom.PropEvent(key)
+		if !ok {
+			continue
+		}
+		handler, options := vdom.ListenerOf(value)
+		if !supportedHandler(handler) {
+			// Fallback: ignore unsupported types
+			continue
 		}
+
+		eventName := event.String()
+		js.Global().Get("console").Call("log", fmt.Sprintf("[DOM] Found event %s on node %d", eventName, nodeID))
+		a.listen(nodeID, elem, eventName, handler, options)
 	}
 
-	// Store handlers for cleanup
-	if len(handlers) > 0 {
-		a.eventHandlers[nodeID] = handlers
+	if handlers := a.eventHandlers[nodeID]; len(handlers) > 0 {
 		js.Global().Get("console").Call("log", fmt.Sprintf("[DOM] Stored %d handlers for node %d", len(handlers), nodeID))
 	}
 }
 
+// listen adds a listener for eventName that dispatches to the node's
+// current handler, which starts out as handler
+func (a *DOMApplier) listen(nodeID uint32, elem js.Value, eventName string, handler any, options vdom.EventOptions) {
+	if a.handlerProps[nodeID] == nil {
+		a.handlerProps[nodeID] = make(map[string]any)
+	}
+	if a.eventHandlers[nodeID] == nil {
+		a.eventHandlers[nodeID] = make(map[string]domListener)
+	}
+	a.handlerProps[nodeID][eventName] = handler
+
+	jsFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if options&vdom.EventPreventDefault != 0 && len(args) > 0 {
+			args[0].Call("preventDefault")
+		}
+		invokeHandler(a.handlerProps[nodeID][eventName], eventName, this, args)
+		return nil
+	})
+
+	// Add event listener
+	elem.Call("addEventListener", eventName, jsFunc, map[string]interface{}{
+		"capture": options&vdom.EventCapture != 0,
+		"passive": options&vdom.EventPassive != 0,
+		"once":    options&vdom.EventOnce != 0,
+	})
+	a.eventHandlers[nodeID][eventName] = domListener{fn: jsFunc, options: options}
+}
+
+// unlisten removes a node's listener for eventName; its handler is kept
+func (a *DOMApplier) unlisten(nodeID uint32, elem js.Value, eventName string) {
+	l, ok := a.eventHandlers[nodeID][eventName]
+	if !ok {
+		return
+	}
+	elem.Call("removeEventListener", eventName, l.fn, l.options&vdom.EventCapture != 0)
+	l.fn.Release()
+	delete(a.eventHandlers[nodeID], eventName)
+}
+
+// setHandler swaps the handler behind a node's listener. Only the Go-side
+// reference changes; a listener is added if the node had none for the event.
+func (a *DOMApplier) setHandler(patch vdom.Patch) error {
+	if !supportedHandler(patch.Handler) {
+		return nil
+	}
+	event, ok := vdom.PropEvent(patch.Key)
+	if !ok {
+		return nil
+	}
+	eventName := event.String()
+
+	if _, ok := a.eventHandlers[patch.NodeID][eventName]; ok {
+		a.handlerProps[patch.NodeID][eventName] = patch.Handler
+		return nil
+	}
+
+	node, ok := a.nodeMap[patch.NodeID]
+	if !ok {
+		return fmt.Errorf("node %d not found", patch.NodeID)
+	}
+	a.listen(patch.NodeID, node, eventName, patch.Handler, 0)
+	return nil
+}
+
+// supportedHandler reports whether a handler prop has a signature the
+// applier can call
+func supportedHandler(handler any) bool {
+	switch handler.(type) {
+	case func(), func(js.Value), func(x, y float64), func(deltaY float64), func(string):
+		return true
+	}
+	return false
+}
+
+// invokeHandler calls a handler with the arguments its signature asks for
+func invokeHandler(handler any, eventName string, this js.Value, args []js.Value) {
+	// Support multiple handler signatures
+	switch h := handler.(type) {
+	case func():
+		h()
+	case func(js.Value):
+		if len(args) > 0 {
+			h(args[0])
+		} else {
+			h(js.Undefined())
+		}
+	case func(x, y float64):
+		var x, y float64
+		if len(args) > 0 {
+			ev := args[0]
+			// Convert to element-relative coords using bounding box
+			bx := 0.0
+			by := 0.0
+			if this.Truthy() {
+				rect := this.Call("getBoundingClientRect")
+				bx = rect.Get("left").Float()
+				by = rect.Get("top").Float()
+			}
+			x = ev.Get("clientX").Float() - bx
+			y = ev.Get("clientY").Float() - by
+		}
+		h(x, y)
+	case func(deltaY float64):
+		var d float64
+		if len(args) > 0 {
+			d = args[0].Get("deltaY").Float()
+		}
+		h(d)
+	case func(string):
+		var s string
+		if len(args) > 0 {
+			ev := args[0]
+			switch eventName {
+			case "input", "change":
+				tgt := ev.Get("target")
+				if tgt.Truthy() {
+					s = tgt.Get("value").String()
+				}
+			case "keydown", "keyup", "keypress":
+				s = ev.Get("key").String()
+			default:
+				s = ev.Get("type").String()
+			}
+		}
+		h(s)
+	}
+}
+
 // moveNode moves a node to a new position
 func (a *DOMApplier) moveNode(patch vdom.Patch) error {
 	node, ok := a.nodeMap[patch.NodeID]
@@ -565,6 +761,18 @@ func (a *DOMApplier) HydrateFullTree(vnode *vdom.VNode, domNode js.Value, nodeID
 
 		return currentID
 
+	case vdom.KindComponent:
+		// A component stands for the root of its output
+		if len(vnode.Kids) == 0 {
+			return nodeID + 1
+		}
+		return a.HydrateFullTree(&vnode.Kids[0], domNode, nodeID+1)
+
+	case vdom.KindRaw:
+		// The element's content is not part of the VNode tree
+		a.nodeMap[nodeID] = domNode
+		return nodeID + 1
+
 	case vdom.KindText:
 		// Store text node in nodeMap
 		a.nodeMap[nodeID] = domNode
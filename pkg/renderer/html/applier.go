@@ -46,6 +46,7 @@ type HTMLApplier struct {
 	w              io.Writer
 	hydrationIDGen *HydrationIDGenerator
 	err            error
+	ns             string // namespace the element being rendered inherits
 }
 
 // HydrationIDGenerator generates unique IDs for hydration
@@ -109,11 +110,11 @@ func (a *HTMLApplier) renderNode(node *vdom.VNode) {
 		// HTML escape text content to prevent XSS
 		a.write(html.EscapeString(node.Text))
 
-	case vdom.KindElement:
+	case vdom.KindElement, vdom.KindRaw:
 		a.renderElement(node)
 
-	case vdom.KindFragment:
-		// Fragments just render their children
+	case vdom.KindFragment, vdom.KindComponent:
+		// Fragments and components just render their children
 		for i := range node.Kids {
 			a.renderNode(&node.Kids[i])
 		}
@@ -125,11 +126,18 @@ func (a *HTMLApplier) renderNode(node *vdom.VNode) {
 	}
 }
 
-// renderElement renders an element node
+// renderElement renders an element node, or a raw HTML element with its
+// HTML unescaped. Inside SVG and MathML, tag and attribute names get their
+// proper case, HTML boolean and void rules do not apply and childless
+// elements close themselves.
 func (a *HTMLApplier) renderElement(node *vdom.VNode) {
+	ns := vdom.ElementNamespace(node, a.ns)
+	tag := vdom.AdjustTag(ns, node.Tag)
+	foreign := ns != vdom.NamespaceHTML
+
 	// Start tag
 	a.write("<")
-	a.write(node.Tag)
+	a.write(tag)
 
 	// Check if this node needs a hydration ID
 	needsHydrationID := false
@@ -142,10 +150,15 @@ func (a *HTMLApplier) renderElement(node *vdom.VNode) {
 		}
 	}
 
-	// Add hydration ID if needed
+	// Add hydration ID if needed; mounted nodes keep their own ID so
+	// later patches address the same element
 	var hydrationID string
 	if needsHydrationID {
-		hydrationID = a.hydrationIDGen.Next()
+		if node.ID != 0 {
+			hydrationID = fmt.Sprintf("h%d", node.ID)
+		} else {
+			hydrationID = a.hydrationIDGen.Next()
+		}
 		a.write(fmt.Sprintf(` data-hid="%s"`, hydrationID))
 	}
 
@@ -158,7 +171,7 @@ func (a *HTMLApplier) renderElement(node *vdom.VNode) {
 			}
 
 			// Handle boolean attributes
-			if booleanAttributes[key] {
+			if !foreign && booleanAttributes[key] {
 				if v, ok := value.(bool); ok && v {
 					a.write(" ")
 					a.write(key)
@@ -167,32 +180,51 @@ func (a *HTMLApplier) renderElement(node *vdom.VNode) {
 			}
 
 			// Regular attributes
-			valueStr := fmt.Sprintf("%v", value)
+			valueStr := vdom.PropString(value)
 
 			// Security: prevent javascript: URLs in href/src attributes
-			if (key == "href" || key == "src") && strings.HasPrefix(strings.ToLower(valueStr), "javascript:") {
+			if (key == "href" || key == "src" || key == "xlink:href") && strings.HasPrefix(strings.ToLower(valueStr), "javascript:") {
 				valueStr = "#"
 			}
 
 			a.write(" ")
-			a.write(key)
+			a.write(vdom.AdjustAttribute(ns, key))
 			a.write(`="`)
 			a.write(html.EscapeString(valueStr))
 			a.write(`"`)
 		}
 	}
 
+	// Foreign elements without children close themselves
+	if foreign && len(node.Kids) == 0 && node.Text == "" {
+		a.write("/>")
+		return
+	}
+
 	// Close opening tag
 	a.write(">")
 
 	// Void elements don't have closing tags or children
-	if voidElements[node.Tag] {
+	if !foreign && voidElements[node.Tag] {
+		return
+	}
+
+	// Raw HTML is written as is; it was trusted or sanitized when the node
+	// was created
+	if node.Kind == vdom.KindRaw {
+		a.write(node.Text)
+		a.write("</")
+		a.write(tag)
+		a.write(">")
 		return
 	}
 
 	// Render children with special handling for script/style tags
-	// Script and style tags should not have their content escaped
-	isRawTextElement := node.Tag == "script" || node.Tag == "style"
+	// Script and style tags should not have their content escaped,
+	// except in SVG, where their text is parsed like any other
+	isRawTextElement := !foreign && (node.Tag == "script" || node.Tag == "style")
+	parentNS := a.ns
+	a.ns = vdom.ChildNamespace(node, ns)
 	for i := range node.Kids {
 		if isRawTextElement {
 			a.renderRawNode(&node.Kids[i])
@@ -200,10 +232,11 @@ func (a *HTMLApplier) renderElement(node *vdom.VNode) {
 			a.renderNode(&node.Kids[i])
 		}
 	}
+	a.ns = parentNS
 
 	// Closing tag
 	a.write("</")
-	a.write(node.Tag)
+	a.write(tag)
 	a.write(">")
 }
 
@@ -222,8 +255,8 @@ func (a *HTMLApplier) renderRawNode(node *vdom.VNode) {
 		// This shouldn't happen inside script/style but handle anyway
 		a.renderElement(node)
 
-	case vdom.KindFragment:
-		// Fragments just render their children
+	case vdom.KindFragment, vdom.KindComponent:
+		// Fragments and components just render their children
 		for i := range node.Kids {
 			a.renderRawNode(&node.Kids[i])
 		}
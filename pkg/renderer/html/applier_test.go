@@ -316,4 +316,93 @@ func htmlEquals(a, b string) bool {
 	// For this test suite, we'll accept that attributes might be in different orders
 	// A real implementation would parse and compare the DOM trees
 	return true
-}
\ No newline at end of file
+}
+func TestHTMLApplier_ForeignContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     *vdom.VNode
+		expected string
+	}{
+		{
+			name: "svg attribute and tag casing",
+			node: vdom.NewElement("svg", vdom.Props{"viewbox": "0 0 10 10"},
+				vdom.NewElement("lineargradient", vdom.Props{"gradientunits": "userSpaceOnUse"}),
+				vdom.NewElement("use", vdom.Props{"xlink:href": "#icon"}),
+			),
+			expected: `<svg viewBox="0 0 10 10"><linearGradient gradientUnits="userSpaceOnUse"/><use xlink:href="#icon"/></svg>`,
+		},
+		{
+			name: "html void and boolean rules stay in html",
+			node: vdom.NewElement("svg", nil,
+				vdom.NewElement("image", vdom.Props{"hidden": true}),
+				vdom.NewElement("foreignObject", nil,
+					vdom.NewElement("input", vdom.Props{"disabled": true}),
+				),
+			),
+			expected: `<svg><image hidden="true"/><foreignObject><input disabled></foreignObject></svg>`,
+		},
+		{
+			name:     "mathml",
+			node:     vdom.NewElement("math", nil, vdom.NewElement("mi", nil, vdom.NewText("x")), vdom.NewElement("mspace", nil)),
+			expected: `<math><mi>x</mi><mspace/></math>`,
+		},
+		{
+			name:     "svg style text is escaped",
+			node:     vdom.NewElement("svg", nil, vdom.NewElement("style", nil, vdom.NewText("a>b{}"))),
+			expected: `<svg><style>a&gt;b{}</style></svg>`,
+		},
+		{
+			name:     "javascript xlink:href",
+			node:     vdom.NewElement("svg", nil, vdom.NewElement("a", vdom.Props{"xlink:href": "javascript:alert(1)"})),
+			expected: `<svg><a xlink:href="#"/></svg>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := RenderToString(tt.node)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("RenderToString() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHTMLApplier_RawHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     *vdom.VNode
+		expected string
+	}{
+		{
+			name:     "trusted html is not escaped",
+			node:     vdom.NewTrustedHTML("div", vdom.Props{"class": "post"}, `<p>Hi <em>there</em></p><script>track()</script>`),
+			expected: `<div class="post"><p>Hi <em>there</em></p><script>track()</script></div>`,
+		},
+		{
+			name:     "sanitized html",
+			node:     vdom.NewSanitizedHTML("article", nil, `<p onclick="x()">Hi <a href="javascript:alert(1)">link</a><script>alert(1)</script>`),
+			expected: `<article><p>Hi <a>link</a></p></article>`,
+		},
+		{
+			name:     "raw html inside a tree",
+			node:     vdom.NewElement("main", nil, vdom.NewText("<b>"), vdom.NewTrustedHTML("pre", nil, `<span class="kw">func</span>`)),
+			expected: `<main>&lt;b&gt;<pre><span class="kw">func</span></pre></main>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := RenderToString(tt.node)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("RenderToString() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
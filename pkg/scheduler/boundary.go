@@ -0,0 +1,317 @@
+package scheduler
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"sync"
+
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+// Suspended is the panic value of a render waiting on an async resource.
+// Suspense boundaries catch it and show their fallback until Ready is
+// closed.
+type Suspended struct {
+	Ready <-chan struct{}
+}
+
+// Suspend stops the render in progress until ready is closed. Sources of
+// async data call it during render while their value is not available; it
+// returns at once if ready is already closed.
+func Suspend(ready <-chan struct{}) {
+	select {
+	case <-ready:
+		return
+	default:
+	}
+	panic(&Suspended{Ready: ready})
+}
+
+// PanicError is a render panic caught by an error boundary
+type PanicError struct {
+	Value any    // the value passed to panic
+	Stack []byte // stack of the panicking goroutine
+}
+
+// Error returns the panic value as text
+func (e *PanicError) Error() string {
+	return fmt.Sprint(e.Value)
+}
+
+// Unwrap returns the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// ErrorBoundary renders children, or fallback if rendering them panics.
+// With a fiber, the boundary also catches panics of the fibers created
+// below it: the fiber is re-rendered with the fallback in place of the
+// children, and the next render after that tries the children again.
+// Suspensions pass through to the enclosing Suspense boundary. Without a
+// fiber, as in static SSR, only panics of children itself are caught.
+func ErrorBoundary(fiber *Fiber, fallback func(err error) *vdom.VNode, children func() *vdom.VNode) (node *vdom.VNode) {
+	if fiber != nil {
+		b := fiber.enterBoundary(false)
+		defer fiber.exitBoundary()
+		if err := b.takeError(); err != nil {
+			return fallback(err)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(*Suspended); ok {
+				panic(r)
+			}
+			node = fallback(&PanicError{Value: r, Stack: debug.Stack()})
+		}
+	}()
+	return children()
+}
+
+// Suspense renders children, or fallback while they wait on an async
+// resource (see Suspend). With a fiber, the fiber renders again once the
+// resource is ready, and fibers created below the boundary that suspend
+// show its fallback too. Without a fiber, as in static SSR, the fallback
+// is rendered in place of children that suspend. Other panics pass
+// through to the enclosing ErrorBoundary.
+func Suspense(fiber *Fiber, fallback *vdom.VNode, children func() *vdom.VNode) (node *vdom.VNode) {
+	var b *boundary
+	if fiber != nil {
+		b = fiber.enterBoundary(true)
+		defer fiber.exitBoundary()
+		if b.waiting() {
+			return fallback
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			suspended, ok := r.(*Suspended)
+			if !ok {
+				panic(r)
+			}
+			if b != nil {
+				b.wait(suspended.Ready)
+			}
+			node = fallback
+		}
+	}()
+	return children()
+}
+
+// boundary is an error or suspense boundary rendered by a fiber. It keeps
+// its identity across the fiber's renders, so fibers created below it can
+// report to it later.
+type boundary struct {
+	owner    *Fiber
+	parent   *boundary // enclosing boundary, possibly rendered by an ancestor
+	suspense bool
+
+	mu      sync.Mutex
+	err     error                        // descendant error for the next render
+	pending map[<-chan struct{}][]*Fiber // resources waited on, with the fibers to wake
+}
+
+// takeError returns and clears the error a descendant fiber reported
+func (b *boundary) takeError() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := b.err
+	b.err = nil
+	return err
+}
+
+// fail records the error of a descendant fiber
+func (b *boundary) fail(err error) {
+	b.mu.Lock()
+	b.err = err
+	b.mu.Unlock()
+}
+
+// waiting reports whether anything below the boundary is suspended
+func (b *boundary) waiting() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending) > 0
+}
+
+// wait shows the fallback until ready is closed, then renders the owner
+// and the given fibers again. One goroutine waits for each resource, and
+// gives up when the owner is removed.
+func (b *boundary) wait(ready <-chan struct{}, fibers ...*Fiber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending == nil {
+		b.pending = make(map[<-chan struct{}][]*Fiber)
+	}
+	waiters, known := b.pending[ready]
+	b.pending[ready] = append(waiters, fibers...)
+	if known {
+		return
+	}
+
+	removed := b.owner.removedChan()
+	go func() {
+		select {
+		case <-ready:
+		case <-removed:
+			b.mu.Lock()
+			delete(b.pending, ready)
+			b.mu.Unlock()
+			return
+		}
+		b.mu.Lock()
+		woken := b.pending[ready]
+		delete(b.pending, ready)
+		b.mu.Unlock()
+
+		sched := b.owner.sched
+		if sched == nil {
+			return
+		}
+		for _, f := range woken {
+			sched.MarkDirty(f)
+		}
+		sched.MarkDirty(b.owner)
+	}()
+}
+
+// boundaryFrame is a boundary open in the render in progress
+type boundaryFrame struct {
+	b    *boundary
+	key  string
+	kids int // boundaries opened inside it so far
+}
+
+// boundaryState tracks the boundaries a fiber renders. Boundaries are
+// identified by their position among the boundaries of the render, so
+// one that is skipped, such as below a fallback, does not shift the
+// others.
+type boundaryState struct {
+	mu        sync.Mutex
+	rendering bool                 // inside a scheduled render
+	byKey     map[string]*boundary // boundaries of the current render
+	prev      map[string]*boundary // boundaries of the previous render
+	open      []*boundaryFrame
+	rootKids  int
+}
+
+// beginRender starts collecting the boundaries of a scheduled render
+func (f *Fiber) beginRender() {
+	st := &f.bounds
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.rendering = true
+	st.prev, st.byKey = st.byKey, make(map[string]*boundary)
+	st.open = st.open[:0]
+	st.rootKids = 0
+}
+
+// endRender drops the boundaries the render no longer has. A render that
+// panicked keeps the boundaries it did not reach.
+func (f *Fiber) endRender(completed bool) {
+	st := &f.bounds
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !completed {
+		for key, b := range st.prev {
+			if _, ok := st.byKey[key]; !ok {
+				st.byKey[key] = b
+			}
+		}
+	}
+	st.rendering = false
+	st.prev = nil
+	st.open = st.open[:0]
+}
+
+// enterBoundary opens the next boundary of the render
+func (f *Fiber) enterBoundary(suspense bool) *boundary {
+	st := &f.bounds
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	parent := f.boundary
+	var key string
+	if n := len(st.open); n > 0 {
+		top := st.open[n-1]
+		parent = top.b
+		key = top.key + "." + strconv.Itoa(top.kids)
+		top.kids++
+	} else {
+		key = strconv.Itoa(st.rootKids)
+		st.rootKids++
+	}
+
+	var b *boundary
+	if st.rendering {
+		b = st.prev[key]
+	}
+	if b == nil || b.suspense != suspense {
+		b = &boundary{owner: f, suspense: suspense}
+	}
+	b.parent = parent
+	if st.rendering {
+		st.byKey[key] = b
+	}
+	st.open = append(st.open, &boundaryFrame{b: b, key: key})
+	return b
+}
+
+// exitBoundary closes the innermost open boundary
+func (f *Fiber) exitBoundary() {
+	st := &f.bounds
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if n := len(st.open); n > 0 {
+		st.open = st.open[:n-1]
+	}
+}
+
+// currentBoundary returns the innermost boundary fibers created by f now
+// report to
+func (f *Fiber) currentBoundary() *boundary {
+	st := &f.bounds
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if n := len(st.open); n > 0 {
+		return st.open[n-1].b
+	}
+	return f.boundary
+}
+
+// nearestBoundary returns the closest enclosing boundary of a kind
+func (f *Fiber) nearestBoundary(suspense bool) *boundary {
+	for b := f.boundary; b != nil; b = b.parent {
+		if b.suspense == suspense {
+			return b
+		}
+	}
+	return nil
+}
+
+// handleFiberPanic routes a panic that escaped a fiber's render: to the
+// nearest boundary above the fiber if there is one, and to the fiber's
+// error handler otherwise
+func (s *Scheduler) handleFiberPanic(fiber *Fiber, r interface{}) {
+	if suspended, ok := r.(*Suspended); ok {
+		if b := fiber.nearestBoundary(true); b != nil {
+			b.wait(suspended.Ready, fiber)
+			s.MarkDirty(b.owner)
+			return
+		}
+		// Keep the last tree on screen and render again once ready
+		fiber.waits.wait(suspended.Ready)
+		return
+	}
+
+	if b := fiber.nearestBoundary(false); b != nil {
+		b.fail(&PanicError{Value: r, Stack: debug.Stack()})
+		s.MarkDirty(b.owner)
+		return
+	}
+	s.handleFiberError(fiber, r)
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+// ComponentRenderFunc renders the component of a component node for the
+// fiber created for it
+type ComponentRenderFunc func(fiber *Fiber, component any, props vdom.Props) *vdom.VNode
+
+// componentRenderer is set by the vango package
+var componentRenderer ComponentRenderFunc
+
+// SetComponentRenderer sets how component nodes are rendered. The vango
+// package sets it to render vango.Component values.
+func SetComponentRenderer(fn ComponentRenderFunc) {
+	componentRenderer = fn
+}
+
+// componentHost renders the component nodes of a fiber's tree. Every
+// component node gets a child fiber, created below the fiber whose output
+// contains the node, which renders again on its own when marked dirty.
+type componentHost struct {
+	s        *Scheduler
+	fiber    *Fiber   // fiber whose tree is diffed
+	rendered []*Fiber // child fibers rendered by the diff
+}
+
+// RenderComponent renders a component node with its fiber, creating the
+// fiber for a new node
+func (h *componentHost) RenderComponent(node, owner *vdom.VNode) *vdom.VNode {
+	child, _ := node.Instance.(*Fiber)
+	if child == nil {
+		parent := h.fiber
+		if owner != nil {
+			if f, ok := owner.Instance.(*Fiber); ok {
+				parent = f
+			}
+		}
+		child = h.s.CreateFiber(nil, parent)
+		child.render = child.renderComponent
+		node.Instance = child
+	}
+	child.node = node
+	output := h.s.renderChild(child)
+	h.rendered = append(h.rendered, child)
+	return output
+}
+
+// AttachComponent points a child fiber at the node standing for it
+func (h *componentHost) AttachComponent(node *vdom.VNode, parentID uint32) {
+	if child, ok := node.Instance.(*Fiber); ok {
+		child.node = node
+		child.parentID = parentID
+		child.SetVNode(child.output())
+	}
+}
+
+// UnmountComponent removes a child fiber
+func (h *componentHost) UnmountComponent(node *vdom.VNode) {
+	if child, ok := node.Instance.(*Fiber); ok {
+		h.s.RemoveFiber(child)
+	}
+}
+
+// renderComponent is the render function of child fibers
+func (f *Fiber) renderComponent() *vdom.VNode {
+	if componentRenderer == nil || f.node == nil {
+		return nil
+	}
+	return componentRenderer(f, f.node.Component, f.node.Props)
+}
+
+// output returns the output of a child fiber as mounted
+func (f *Fiber) output() *vdom.VNode {
+	if f.node == nil || len(f.node.Kids) == 0 {
+		return nil
+	}
+	return &f.node.Kids[0]
+}
+
+// renderChild renders a child fiber while its parent is diffed. A render
+// that panics is reported like any other and leaves the last output.
+func (s *Scheduler) renderChild(fiber *Fiber) (output *vdom.VNode) {
+	fiber.dirty.Store(false)
+	fiber.beginRender()
+	completed := false
+	defer func() {
+		fiber.endRender(completed)
+		if r := recover(); r != nil {
+			s.handleFiberPanic(fiber, r)
+			output = fiber.VNode()
+		}
+	}()
+
+	output = fiber.renderTracked()
+	completed = true
+	return output
+}
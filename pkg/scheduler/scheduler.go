@@ -3,6 +3,7 @@ package scheduler
 import (
 	"fmt"
 	"runtime/debug"
+	"sort"
 	"sync"
 	"sync/atomic"
 
@@ -20,7 +21,16 @@ type ErrorHandler func(fiber *Fiber, err interface{}) bool
 type Fiber struct {
 	id     uint32
 	parent *Fiber
-	vnode  *vdom.VNode // last rendered tree
+	sched  *Scheduler
+	
+	// Last rendered tree; VNode may read it from any goroutine
+	vnodeMu sync.RWMutex
+	vnode   *vdom.VNode
+	
+	// Child fibers render a component node of their parent's tree: node
+	// holds their output, below the DOM node parentID
+	node     *vdom.VNode
+	parentID uint32
 	
 	// Component render function
 	render RenderFunc
@@ -32,8 +42,21 @@ type Fiber struct {
 	// Error handling
 	onError ErrorHandler
 	
+	// Boundaries: the one the fiber reports to and the ones it renders
+	boundary *boundary
+	bounds   boundaryState
+	waits    boundary // resources its renders wait on outside any Suspense
+	owner    any // of the render in progress; see Owner
+	
 	// User data
 	userData interface{}
+	
+	// Functions to run after the next commit and when the fiber is removed
+	removeMu    sync.Mutex
+	afterCommit []func()
+	onRemove    []func()
+	removed     bool
+	gone        chan struct{} // closed on removal; see removedChan
 }
 
 // debugLog is set by platform-specific code
@@ -52,6 +75,8 @@ type Scheduler struct {
 	dirtyQueue []*Fiber
 	globalWake chan *Fiber
 	running    atomic.Bool
+	done       chan struct{} // closed when the loop goroutine exits
+	ids        *vdom.IDAllocator // node IDs shared by every fiber's tree
 	
 	// Callbacks
 	applyPatches func(patches []vdom.Patch)
@@ -65,6 +90,7 @@ func NewScheduler() *Scheduler {
 		nextID:     1,
 		dirtyQueue: make([]*Fiber, 0, 1024),
 		globalWake: make(chan *Fiber, 1024), // buffered for performance
+		ids:        vdom.NewIDAllocator(),
 	}
 }
 
@@ -89,9 +115,17 @@ func (s *Scheduler) CreateFiber(render RenderFunc, parent *Fiber) *Fiber {
 	fiber := &Fiber{
 		id:     id,
 		parent: parent,
+		sched:  s,
 		render: render,
 		ch:     make(chan struct{}, 1), // buffered to avoid blocking
 	}
+	fiber.waits.owner = fiber
+	
+	// Fibers created below a boundary report their panics and suspensions
+	// to it
+	if parent != nil {
+		fiber.boundary = parent.currentBoundary()
+	}
 	
 	// Use default error handler if none specified
 	if s.defaultError != nil {
@@ -102,16 +136,65 @@ func (s *Scheduler) CreateFiber(render RenderFunc, parent *Fiber) *Fiber {
 	return fiber
 }
 
-// RemoveFiber removes a fiber from the scheduler
+// RemoveFiber removes a fiber from the scheduler and runs the functions
+// registered with OnRemove
 func (s *Scheduler) RemoveFiber(fiber *Fiber) {
 	if fiber == nil {
 		return
 	}
 	
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	
 	delete(s.fibers, fiber.id)
+	s.mu.Unlock()
+	
+	fiber.runOnRemove()
+}
+
+// RemoveAllFibers removes every fiber, as when the session the scheduler
+// serves ends. Fibers are removed newest first, so child fibers go before
+// their parents.
+func (s *Scheduler) RemoveAllFibers() {
+	s.mu.Lock()
+	fibers := make([]*Fiber, 0, len(s.fibers))
+	for _, fiber := range s.fibers {
+		fibers = append(fibers, fiber)
+	}
+	s.fibers = make(map[uint32]*Fiber)
+	s.mu.Unlock()
+	
+	sort.Slice(fibers, func(i, j int) bool { return fibers[i].id > fibers[j].id })
+	for _, fiber := range fibers {
+		fiber.runOnRemove()
+	}
+}
+
+// RemoveChildFibers removes the fibers created below fiber, newest first,
+// as when its tree is about to be rendered from scratch
+func (s *Scheduler) RemoveChildFibers(fiber *Fiber) {
+	s.mu.Lock()
+	var children []*Fiber
+	for id, f := range s.fibers {
+		if f.below(fiber) {
+			children = append(children, f)
+			delete(s.fibers, id)
+		}
+	}
+	s.mu.Unlock()
+	
+	sort.Slice(children, func(i, j int) bool { return children[i].id > children[j].id })
+	for _, child := range children {
+		child.runOnRemove()
+	}
+}
+
+// below reports whether f was created below ancestor
+func (f *Fiber) below(ancestor *Fiber) bool {
+	for p := f.parent; p != nil; p = p.parent {
+		if p == ancestor {
+			return true
+		}
+	}
+	return false
 }
 
 // MarkDirty marks a fiber as needing re-render
@@ -159,11 +242,14 @@ func (s *Scheduler) MarkDirty(fiber *Fiber) {
 
 // Start begins the scheduler loop
 func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.running.CompareAndSwap(false, true) {
 		if debugLog != nil {
 			debugLog("[Scheduler] Starting scheduler loop")
 		}
-		go s.loop()
+		s.done = make(chan struct{})
+		go s.loop(s.done)
 	} else {
 		if debugLog != nil {
 			debugLog("[Scheduler] Scheduler already running")
@@ -171,9 +257,30 @@ func (s *Scheduler) Start() {
 	}
 }
 
-// Stop stops the scheduler
+// Stop stops the scheduler and waits for a render in progress to finish,
+// so no fiber renders once it returns. It must not be called from a render.
 func (s *Scheduler) Stop() {
-	s.running.Store(false)
+	s.mu.Lock()
+	if !s.running.CompareAndSwap(true, false) {
+		s.mu.Unlock()
+		return
+	}
+	done := s.done
+	s.mu.Unlock()
+	
+	// Wake the loop if it is waiting for work so it sees the flag and exits
+	select {
+	case s.globalWake <- nil:
+	default:
+	}
+	<-done
+}
+
+// IDs returns the node ID allocator shared by the fibers' trees. Trees
+// rendered outside the scheduler, such as server-rendered HTML to be
+// hydrated, should be mounted from it.
+func (s *Scheduler) IDs() *vdom.IDAllocator {
+	return s.ids
 }
 
 // IsRunning returns whether the scheduler is running
@@ -181,8 +288,9 @@ func (s *Scheduler) IsRunning() bool {
 	return s.running.Load()
 }
 
-// loop is the main scheduler event loop
-func (s *Scheduler) loop() {
+// loop is the main scheduler event loop. It closes done when it exits.
+func (s *Scheduler) loop(done chan struct{}) {
+	defer close(done)
 	if debugLog != nil {
 		debugLog("[Scheduler] Loop started")
 	}
@@ -258,11 +366,19 @@ func (s *Scheduler) processFiber(fiber *Fiber) {
 		return
 	}
 	
+	// Child fibers whose component was unmounted have no DOM to patch
+	if fiber.node != nil && s.GetFiber(fiber.id) != fiber {
+		return
+	}
+	
 	// Wrap render in panic recovery
 	func() {
+		fiber.beginRender()
+		completed := false
 		defer func() {
+			fiber.endRender(completed)
 			if r := recover(); r != nil {
-				s.handleFiberError(fiber, r)
+				s.handleFiberPanic(fiber, r)
 			}
 		}()
 		
@@ -271,10 +387,19 @@ func (s *Scheduler) processFiber(fiber *Fiber) {
 		}
 		
 		// Render the component
-		next := fiber.render()
+		next := fiber.renderTracked()
 		
-		// Diff against previous render
-		patches := vdom.Diff(fiber.vnode, next)
+		// Diff against previous render. A child fiber's output replaces
+		// the one in its component node.
+		host := &componentHost{s: s, fiber: fiber}
+		var patches []vdom.Patch
+		if fiber.node != nil {
+			patches = vdom.DiffComponent(s.ids, host, fiber.node, next, fiber.parentID)
+			next = fiber.output()
+		} else {
+			patches = vdom.DiffWithHost(s.ids, host, fiber.vnode, next)
+		}
+		patches = vdom.OptimizePatches(patches)
 		
 		if debugLog != nil {
 			debugLog("[Scheduler] Diff produced", len(patches), "patches for fiber", fiber.ID())
@@ -289,7 +414,15 @@ func (s *Scheduler) processFiber(fiber *Fiber) {
 		}
 		
 		// Update the fiber's vnode
-		fiber.vnode = next
+		fiber.SetVNode(next)
+		completed = true
+		
+		// Child fibers rendered by the diff commit with their parent,
+		// before it
+		for _, child := range host.rendered {
+			child.commit()
+		}
+		fiber.commit()
 	}()
 }
 
@@ -339,6 +472,11 @@ func (f *Fiber) ID() uint32 {
 	return f.id
 }
 
+// Scheduler returns the scheduler that created the fiber
+func (f *Fiber) Scheduler() *Scheduler {
+	return f.sched
+}
+
 // Parent returns the fiber's parent
 func (f *Fiber) Parent() *Fiber {
 	return f.parent
@@ -346,12 +484,16 @@ func (f *Fiber) Parent() *Fiber {
 
 // VNode returns the fiber's last rendered VNode
 func (f *Fiber) VNode() *vdom.VNode {
+	f.vnodeMu.RLock()
+	defer f.vnodeMu.RUnlock()
 	return f.vnode
 }
 
 // SetVNode sets the current VNode for the fiber (used during hydration)
 func (f *Fiber) SetVNode(vnode *vdom.VNode) {
+	f.vnodeMu.Lock()
 	f.vnode = vnode
+	f.vnodeMu.Unlock()
 }
 
 // SetErrorHandler sets a custom error handler for this fiber
@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -120,11 +121,12 @@ func TestScheduler_ErrorHandling(t *testing.T) {
 	sched := NewScheduler()
 	
 	var errorHandled atomic.Bool
-	var shouldContinue = true
+	var shouldContinue atomic.Bool
+	shouldContinue.Store(true)
 	
 	sched.SetDefaultErrorHandler(func(f *Fiber, err interface{}) bool {
 		errorHandled.Store(true)
-		return shouldContinue
+		return shouldContinue.Load()
 	})
 	
 	// Create fiber that panics
@@ -151,7 +153,7 @@ func TestScheduler_ErrorHandling(t *testing.T) {
 	}
 	
 	// Test with error handler returning false
-	shouldContinue = false
+	shouldContinue.Store(false)
 	errorHandled.Store(false)
 	
 	fiber2 := sched.CreateFiber(panicRender, nil)
@@ -230,6 +232,74 @@ func TestScheduler_RemoveFiber(t *testing.T) {
 	}
 }
 
+func TestFiber_OnRemove(t *testing.T) {
+	sched := NewScheduler()
+	fiber := sched.CreateFiber(func() *vdom.VNode { return nil }, nil)
+	
+	var order []int
+	fiber.OnRemove(func() { order = append(order, 1) })
+	fiber.OnRemove(func() { order = append(order, 2) })
+	
+	sched.RemoveFiber(fiber)
+	sched.RemoveFiber(fiber)
+	if len(order) != 2 || order[0] != 2 || order[1] != 1 {
+		t.Errorf("Expected OnRemove functions to run once in reverse order, got %v", order)
+	}
+	
+	// Registered after removal: runs at once
+	fiber.OnRemove(func() { order = append(order, 3) })
+	if len(order) != 3 {
+		t.Errorf("Expected OnRemove on a removed fiber to run at once, got %v", order)
+	}
+}
+
+func TestFiber_AfterCommit(t *testing.T) {
+	sched := NewScheduler()
+	var events []string
+	sched.SetPatchApplier(func(patches []vdom.Patch) {
+		events = append(events, "apply")
+	})
+	
+	var fiber *Fiber
+	fiber = sched.CreateFiber(func() *vdom.VNode {
+		fiber.AfterCommit(func() { events = append(events, "commit") })
+		events = append(events, "render")
+		return vdom.NewText("x")
+	}, nil)
+	fiber.dirty.Store(true)
+	sched.processFiber(fiber)
+	
+	if len(events) != 3 || events[0] != "render" || events[1] != "apply" || events[2] != "commit" {
+		t.Errorf("Expected render, apply, commit, got %v", events)
+	}
+	
+	// RemoveAllFibers removes children before their parents
+	child := sched.CreateFiber(nil, fiber)
+	var removed []*Fiber
+	fiber.OnRemove(func() { removed = append(removed, fiber) })
+	child.OnRemove(func() { removed = append(removed, child) })
+	sched.RemoveAllFibers()
+	if sched.FiberCount() != 0 || len(removed) != 2 || removed[0] != child {
+		t.Errorf("Expected the child removed first and no fibers left, got %d fibers left", sched.FiberCount())
+	}
+	
+	// RemoveChildFibers removes the fibers below one, not the fiber itself
+	parent := sched.CreateFiber(nil, nil)
+	child = sched.CreateFiber(nil, parent)
+	grandchild := sched.CreateFiber(nil, child)
+	other := sched.CreateFiber(nil, nil)
+	removed = nil
+	child.OnRemove(func() { removed = append(removed, child) })
+	grandchild.OnRemove(func() { removed = append(removed, grandchild) })
+	sched.RemoveChildFibers(parent)
+	if sched.GetFiber(parent.ID()) != parent || sched.GetFiber(other.ID()) != other || sched.FiberCount() != 2 {
+		t.Errorf("Expected the parent and unrelated fibers kept, got %d fibers", sched.FiberCount())
+	}
+	if len(removed) != 2 || removed[0] != grandchild {
+		t.Errorf("Expected the grandchild removed before the child, got %d removed", len(removed))
+	}
+}
+
 func TestScheduler_StopStart(t *testing.T) {
 	sched := NewScheduler()
 	
@@ -266,6 +336,44 @@ func TestScheduler_StopStart(t *testing.T) {
 	}
 }
 
+func TestScheduler_StopWaitsForRender(t *testing.T) {
+	sched := NewScheduler()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var finished atomic.Bool
+	fiber := sched.CreateFiber(func() *vdom.VNode {
+		close(started)
+		<-release
+		finished.Store(true)
+		return nil
+	}, nil)
+	
+	sched.Start()
+	sched.MarkDirty(fiber)
+	<-started
+	
+	stopped := make(chan struct{})
+	go func() {
+		sched.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while a render was in progress")
+	case <-time.After(20 * time.Millisecond):
+	}
+	
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not return after the render finished")
+	}
+	if !finished.Load() {
+		t.Error("Stop returned before the render finished")
+	}
+}
+
 func TestFiber_UserData(t *testing.T) {
 	sched := NewScheduler()
 	fiber := sched.CreateFiber(func() *vdom.VNode { return nil }, nil)
@@ -314,4 +422,246 @@ func BenchmarkScheduler_MarkDirty(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		sched.MarkDirty(fiber)
 	}
-}
\ No newline at end of file
+}
+func TestBoundary_CatchesRenderPanics(t *testing.T) {
+	failed := ErrorBoundary(nil, func(err error) *vdom.VNode {
+		return vdom.NewText("error: " + err.Error())
+	}, func() *vdom.VNode {
+		panic("boom")
+	})
+	if failed.Text != "error: boom" {
+		t.Errorf("ErrorBoundary() = %q, want the fallback", failed.Text)
+	}
+
+	// Suspensions pass through error boundaries to the nearest Suspense
+	ready := make(chan struct{})
+	loading := Suspense(nil, vdom.NewText("loading"), func() *vdom.VNode {
+		return ErrorBoundary(nil, func(error) *vdom.VNode {
+			return vdom.NewText("error")
+		}, func() *vdom.VNode {
+			Suspend(ready)
+			return vdom.NewText("done")
+		})
+	})
+	if loading.Text != "loading" {
+		t.Errorf("Suspense() = %q, want the fallback", loading.Text)
+	}
+
+	close(ready)
+	done := Suspense(nil, vdom.NewText("loading"), func() *vdom.VNode {
+		Suspend(ready)
+		return vdom.NewText("done")
+	})
+	if done.Text != "done" {
+		t.Errorf("Suspense() = %q once ready, want the children", done.Text)
+	}
+}
+
+func TestBoundary_SuspenseRendersAgainWhenReady(t *testing.T) {
+	sched := NewScheduler()
+	ready := make(chan struct{})
+
+	var fiber *Fiber
+	fiber = sched.CreateFiber(func() *vdom.VNode {
+		return Suspense(fiber, vdom.NewText("loading"), func() *vdom.VNode {
+			Suspend(ready)
+			return vdom.NewText("done")
+		})
+	}, nil)
+
+	sched.Start()
+	defer sched.Stop()
+	sched.MarkDirty(fiber)
+	time.Sleep(50 * time.Millisecond)
+	if got := fiber.VNode().Text; got != "loading" {
+		t.Fatalf("rendered %q, want the fallback", got)
+	}
+
+	close(ready)
+	time.Sleep(50 * time.Millisecond)
+	if got := fiber.VNode().Text; got != "done" {
+		t.Errorf("rendered %q once ready, want the children", got)
+	}
+}
+
+func TestBoundary_DescendantFibers(t *testing.T) {
+	sched := NewScheduler()
+	var handled atomic.Int32
+	sched.SetDefaultErrorHandler(func(*Fiber, interface{}) bool {
+		handled.Add(1)
+		return true
+	})
+
+	var fail atomic.Bool
+	ready := make(chan struct{})
+	// The children are created by the render loop and read by the test
+	var parent *Fiber
+	var failing, waiting atomic.Pointer[Fiber]
+	parent = sched.CreateFiber(func() *vdom.VNode {
+		return vdom.NewElement("div", nil,
+			ErrorBoundary(parent, func(err error) *vdom.VNode {
+				return vdom.NewText("error: " + err.Error())
+			}, func() *vdom.VNode {
+				if failing.Load() == nil {
+					failing.Store(sched.CreateFiber(func() *vdom.VNode {
+						if fail.Load() {
+							panic("child failed")
+						}
+						return vdom.NewText("child")
+					}, parent))
+				}
+				return vdom.NewText("ok")
+			}),
+			Suspense(parent, vdom.NewText("loading"), func() *vdom.VNode {
+				if waiting.Load() == nil {
+					waiting.Store(sched.CreateFiber(func() *vdom.VNode {
+						Suspend(ready)
+						return vdom.NewText("child")
+					}, parent))
+				}
+				return vdom.NewText("loaded")
+			}),
+		)
+	}, nil)
+
+	sched.Start()
+	defer sched.Stop()
+	sched.MarkDirty(parent)
+	time.Sleep(50 * time.Millisecond)
+
+	// A panicking child swaps the error boundary's children for its fallback
+	fail.Store(true)
+	sched.MarkDirty(failing.Load())
+	time.Sleep(50 * time.Millisecond)
+	if got := parent.VNode().Kids[0].Text; got != "error: child failed" {
+		t.Errorf("error boundary rendered %q, want the fallback", got)
+	}
+	if handled.Load() != 0 {
+		t.Errorf("error handler ran for a panic the boundary caught")
+	}
+
+	// A suspended child shows the Suspense fallback until it is ready
+	sched.MarkDirty(waiting.Load())
+	time.Sleep(50 * time.Millisecond)
+	kids := parent.VNode().Kids
+	if kids[0].Text != "ok" || kids[1].Text != "loading" {
+		t.Errorf("rendered %q, %q; want the children retried and the Suspense fallback", kids[0].Text, kids[1].Text)
+	}
+
+	close(ready)
+	time.Sleep(50 * time.Millisecond)
+	if got := parent.VNode().Kids[1].Text; got != "loaded" {
+		t.Errorf("Suspense rendered %q once ready, want the children", got)
+	}
+	if got := waiting.Load().VNode(); got == nil || got.Text != "child" {
+		t.Errorf("suspended child was not rendered again")
+	}
+}
+
+func TestBoundary_WaitsEndWithFiber(t *testing.T) {
+	sched := NewScheduler()
+	never := make(chan struct{})
+
+	// One fiber suspends outside any boundary, the other inside one
+	var inside *Fiber
+	outside := sched.CreateFiber(func() *vdom.VNode {
+		Suspend(never)
+		return nil
+	}, nil)
+	inside = sched.CreateFiber(func() *vdom.VNode {
+		return Suspense(inside, vdom.NewText("loading"), func() *vdom.VNode {
+			Suspend(never)
+			return nil
+		})
+	}, nil)
+
+	before := runtime.NumGoroutine()
+	sched.Start()
+	defer sched.Stop()
+	for i := 0; i < 5; i++ {
+		sched.MarkDirty(outside)
+		sched.MarkDirty(inside)
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The loop and one waiter per fiber
+	if n := runtime.NumGoroutine() - before; n != 3 {
+		t.Errorf("%d goroutines while suspended, want the loop and one waiter per fiber", n)
+	}
+
+	sched.RemoveAllFibers()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine()-before > 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine() - before; n != 1 {
+		t.Errorf("%d goroutines after the fibers were removed, want only the loop", n)
+	}
+}
+
+func TestComponent_ChildFibers(t *testing.T) {
+	// Components are strings rendered into their label and the state kept
+	// in their fiber's user data
+	renders := map[any]int{}
+	prevRenderer := componentRenderer
+	SetComponentRenderer(func(fiber *Fiber, component any, props vdom.Props) *vdom.VNode {
+		renders[component]++
+		state, _ := fiber.GetUserData().(string)
+		return vdom.NewElement("p", nil, vdom.NewText(props["label"].(string)+state))
+	})
+	defer SetComponentRenderer(prevRenderer)
+
+	sched := NewScheduler()
+	var patches []vdom.Patch
+	sched.SetPatchApplier(func(p []vdom.Patch) { patches = p })
+
+	parentRenders := 0
+	labels := []string{"a", "b"}
+	parent := sched.CreateFiber(func() *vdom.VNode {
+		parentRenders++
+		node := vdom.NewElement("div", nil)
+		for _, label := range labels {
+			node.Kids = append(node.Kids, *vdom.NewComponent(label, vdom.Props{"label": label}))
+		}
+		return node
+	}, nil)
+	render := func(f *Fiber) {
+		patches = nil
+		sched.MarkDirty(f)
+		sched.processFiber(f)
+	}
+
+	render(parent)
+	if sched.FiberCount() != 3 || renders["a"] != 1 || renders["b"] != 1 {
+		t.Fatalf("mount: %d fibers, renders %v; want a fiber per component", sched.FiberCount(), renders)
+	}
+	child, _ := parent.VNode().Kids[0].Instance.(*Fiber)
+	if child == nil || child.Parent() != parent {
+		t.Fatalf("component node has no child fiber of the parent")
+	}
+
+	// A child renders on its own, patching only its output
+	child.SetUserData("!")
+	render(child)
+	if parentRenders != 1 || renders["a"] != 2 || renders["b"] != 1 {
+		t.Errorf("child render re-rendered others: parent %d, renders %v", parentRenders, renders)
+	}
+	if len(patches) != 1 || patches[0].Op != vdom.OpReplaceText || patches[0].Value != "a!" {
+		t.Errorf("child render patches = %v, want its text replaced", patches)
+	}
+
+	// The parent rendering with unchanged props keeps the children's output
+	render(parent)
+	if len(patches) != 0 || renders["a"] != 2 || renders["b"] != 1 {
+		t.Errorf("parent render: patches %v, renders %v; want children reused", patches, renders)
+	}
+	if got := parent.VNode().Kids[0].Kids[0].Kids[0].Text; got != "a!" {
+		t.Errorf("parent tree holds %q, want the child's latest output", got)
+	}
+
+	// Unmounted components lose their fibers
+	labels = labels[:1]
+	render(parent)
+	if sched.FiberCount() != 2 || len(patches) != 1 || patches[0].Op != vdom.OpRemoveNode {
+		t.Errorf("unmount: %d fibers, patches %v", sched.FiberCount(), patches)
+	}
+}
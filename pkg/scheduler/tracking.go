@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+// RenderTracker runs a fiber's render while recording what it reads. It
+// calls render with the owner the render's reactive code runs in, which
+// the fiber holds for the render (see Fiber.Owner).
+type RenderTracker func(fiber *Fiber, render func(owner any) *vdom.VNode) *vdom.VNode
+
+// renderTracker is set by the reactive package
+var renderTracker RenderTracker
+
+// SetRenderTracker sets the function every scheduled render runs through.
+// The reactive package sets it to subscribe fibers to the reactive values
+// they read.
+func SetRenderTracker(fn RenderTracker) {
+	renderTracker = fn
+}
+
+// renderTracked calls the fiber's render function through the tracker
+func (f *Fiber) renderTracked() *vdom.VNode {
+	if renderTracker != nil {
+		return renderTracker(f, f.renderOwned)
+	}
+	return f.render()
+}
+
+// renderOwned calls the fiber's render function with owner held for it
+func (f *Fiber) renderOwned(owner any) *vdom.VNode {
+	prev := f.owner
+	f.owner = owner
+	defer func() { f.owner = prev }()
+	return f.render()
+}
+
+// Owner returns the owner the render tracker gave the fiber's render in
+// progress, nil outside of a render. Only code called by the render may
+// use it: other goroutines must never take the owner of a render.
+func (f *Fiber) Owner() any {
+	if f == nil {
+		return nil
+	}
+	return f.owner
+}
+
+// OnRemove registers fn to run when the fiber is removed from its
+// scheduler. Functions run once, most recently registered first. A fiber
+// that is already removed runs fn at once.
+func (f *Fiber) OnRemove(fn func()) {
+	f.removeMu.Lock()
+	if f.removed {
+		f.removeMu.Unlock()
+		fn()
+		return
+	}
+	f.onRemove = append(f.onRemove, fn)
+	f.removeMu.Unlock()
+}
+
+// runOnRemove runs the functions registered with OnRemove
+func (f *Fiber) runOnRemove() {
+	f.removeMu.Lock()
+	fns := f.onRemove
+	f.onRemove = nil
+	if f.gone != nil && !f.removed {
+		close(f.gone)
+	}
+	f.removed = true
+	f.removeMu.Unlock()
+
+	for i := len(fns) - 1; i >= 0; i-- {
+		fns[i]()
+	}
+}
+
+// removedChan returns a channel closed once the fiber is removed
+func (f *Fiber) removedChan() <-chan struct{} {
+	f.removeMu.Lock()
+	defer f.removeMu.Unlock()
+	if f.gone == nil {
+		f.gone = make(chan struct{})
+		if f.removed {
+			close(f.gone)
+		}
+	}
+	return f.gone
+}
+
+// AfterCommit registers fn to run once the fiber's render in progress, or
+// its next one if none is, has been diffed and its patches applied. A
+// render that panics leaves fn for the next one that completes.
+func (f *Fiber) AfterCommit(fn func()) {
+	f.removeMu.Lock()
+	f.afterCommit = append(f.afterCommit, fn)
+	f.removeMu.Unlock()
+}
+
+// commit runs the functions registered with AfterCommit
+func (f *Fiber) commit() {
+	f.removeMu.Lock()
+	fns := f.afterCommit
+	f.afterCommit = nil
+	f.removeMu.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
@@ -24,7 +24,10 @@ type ComponentInstance struct {
 	mu    sync.RWMutex
 	
 	// Event handlers registered by the component
-	handlers map[uint32]func() // nodeID -> handler
+	handlers map[uint32]map[string]func(vango.Event) // nodeID -> event name -> handler ("" matches any event)
+	
+	// Event names the component handles, in declaration order
+	events []string
 	
 	// Last rendered VNode tree
 	LastVNode *vdom.VNode
@@ -37,7 +40,7 @@ func NewComponentInstance(id, sessionID string, render func(ctx *vango.Context)
 		SessionID:  sessionID,
 		RenderFunc: render,
 		state:      make(map[string]interface{}),
-		handlers:   make(map[uint32]func()),
+		handlers:   make(map[uint32]map[string]func(vango.Event)),
 	}
 }
 
@@ -63,29 +66,104 @@ func (c *ComponentInstance) GetState(key string) (interface{}, bool) {
 	return val, ok
 }
 
-// RegisterHandler registers an event handler for a node
-func (c *ComponentInstance) RegisterHandler(nodeID uint32, handler func()) {
+// RegisterHandler registers a handler for any event on a node.
+// Supported handler signatures are func(), func(vango.Event) and
+// func(string), which receives the target value; others panic.
+func (c *ComponentInstance) RegisterHandler(nodeID uint32, handler interface{}) {
+	c.On(nodeID, "", handler)
+}
+
+// On registers a handler for a named event on a node and declares the
+// event name, e.g. On(id, "select-row", fn). The live session assigns the
+// name a wire ID and announces it to the client. It panics if handler is
+// not one of the signatures RegisterHandler supports.
+func (c *ComponentInstance) On(nodeID uint32, event string, handler interface{}) {
+	fn, ok := EventHandler(handler)
+	if !ok {
+		panic(fmt.Sprintf("vango: unsupported handler type %T for node %d", handler, nodeID))
+	}
+	
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.handlers[nodeID] = handler
+	if c.handlers[nodeID] == nil {
+		c.handlers[nodeID] = make(map[string]func(vango.Event))
+	}
+	c.handlers[nodeID][event] = fn
+	c.declareLocked(event)
 	
 	// Also register the mapping in the global registry
 	// so that events can find this component by node ID
 	GetRegistry().MapNodeToComponent(nodeID, c)
 }
 
-// HandleEvent processes an event for this component
-func (c *ComponentInstance) HandleEvent(nodeID uint32, eventType string) error {
+// EventHandler adapts a handler to func(vango.Event). The boolean is false
+// if handler is not one of the signatures RegisterHandler supports.
+func EventHandler(handler interface{}) (func(vango.Event), bool) {
+	switch h := handler.(type) {
+	case func(vango.Event):
+		return h, true
+	case func():
+		return func(vango.Event) { h() }, true
+	case func(string):
+		return func(e vango.Event) { h(e.Target.Value) }, true
+	default:
+		return nil, false
+	}
+}
+
+// DeclareEvents declares event names the component handles without
+// registering handlers yet
+func (c *ComponentInstance) DeclareEvents(names ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range names {
+		c.declareLocked(name)
+	}
+}
+
+// declareLocked records an event name; c.mu must be held
+func (c *ComponentInstance) declareLocked(name string) {
+	if name == "" {
+		return
+	}
+	for _, existing := range c.events {
+		if existing == name {
+			return
+		}
+	}
+	c.events = append(c.events, name)
+}
+
+// Events returns the event names the component has declared
+func (c *ComponentInstance) Events() []string {
 	c.mu.RLock()
-	handler, ok := c.handlers[nodeID]
-	c.mu.RUnlock()
-	
+	defer c.mu.RUnlock()
+	return append([]string(nil), c.events...)
+}
+
+// Handler returns the handler registered for an event on a node. A handler
+// registered for the event takes precedence over one registered for any
+// event.
+func (c *ComponentInstance) Handler(nodeID uint32, event string) (func(vango.Event), bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	handler, ok := c.handlers[nodeID][event]
+	if !ok {
+		handler, ok = c.handlers[nodeID][""]
+	}
+	return handler, ok
+}
+
+// HandleEvent processes an event for this component with the handler
+// Handler returns for it
+func (c *ComponentInstance) HandleEvent(nodeID uint32, evt vango.Event) error {
+	handler, ok := c.Handler(nodeID, evt.Type)
 	if !ok {
-		return fmt.Errorf("no handler for node %d", nodeID)
+		return fmt.Errorf("no %s handler for node %d", evt.Type, nodeID)
 	}
 	
 	// Execute the handler
-	handler()
+	handler(evt)
 	
 	return nil
 }
@@ -101,6 +179,7 @@ func (c *ComponentInstance) Render() ([]vdom.Patch, error) {
 	
 	// Render the component
 	newVNode := c.RenderFunc(ctx)
+	vango.RenderComponents(ctx, newVNode)
 	
 	// Diff against previous render
 	var patches []vdom.Patch
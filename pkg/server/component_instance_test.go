@@ -0,0 +1,32 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/recera/vango/pkg/vango"
+)
+
+func TestComponentInstance_On(t *testing.T) {
+	c := NewComponentInstance("c1", "s1", nil)
+	defer GetRegistry().CleanupSession("s1")
+
+	var got string
+	c.On(1, "", func(value string) { got = value })
+	evt := vango.Event{Type: "input"}
+	evt.Target.Value = "hi"
+	if err := c.HandleEvent(1, evt); err != nil {
+		t.Fatalf("HandleEvent() error = %v", err)
+	}
+	if got != "hi" {
+		t.Errorf("handler got %q, want %q", got, "hi")
+	}
+
+	// Unsupported handler types fail at registration, not when the event
+	// arrives
+	defer func() {
+		if recover() == nil {
+			t.Error("On() with an unsupported handler did not panic")
+		}
+	}()
+	c.On(2, "", func(n int) {})
+}
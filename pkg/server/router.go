@@ -7,6 +7,7 @@ import (
 	"sync"
 	
 	"github.com/recera/vango/pkg/renderer/html"
+	"github.com/recera/vango/pkg/vango"
 	"github.com/recera/vango/pkg/vango/vdom"
 )
 
@@ -203,6 +204,7 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 	
 	// Render VNode to HTML and send response
+	vango.RenderComponents(nil, vnode)
 	htmlContent, err := html.RenderToString(vnode)
 	if err != nil {
 		r.handleError(ctx, fmt.Errorf("failed to render VNode: %w", err))
@@ -329,6 +331,7 @@ func (r *Router) handleError(ctx Ctx, err error) {
 	if r.errorPage != nil {
 		if vnode, err := r.errorPage(ctx); err == nil && vnode != nil {
 			// Render error page VNode
+			vango.RenderComponents(nil, vnode)
 			if htmlContent, renderErr := html.RenderToString(vnode); renderErr == nil {
 				ctx.SetHeader("Content-Type", "text/html; charset=utf-8")
 				ctx.(*ctxImpl).w.WriteHeader(http.StatusInternalServerError)
@@ -1,8 +1,6 @@
 package server
 
 import (
-	"fmt"
-	
 	"github.com/recera/vango/pkg/vango/vdom"
 )
 
@@ -23,7 +21,6 @@ func InjectServerDrivenClient(doc *vdom.VNode, sessionID string) *vdom.VNode {
 	}
 	
 	// Use the embedded minimal client script
-	// In production, this would be loaded from internal/assets/server-driven-client.js
 	scriptContent := []byte(getMinimalClientScript())
 	
 	// Create script element
@@ -59,121 +56,691 @@ func InjectServerDrivenClient(doc *vdom.VNode, sessionID string) *vdom.VNode {
 	return doc
 }
 
-// getMinimalClientScript returns a minimal fallback client script
+// getMinimalClientScript returns the client script for server-driven pages
 func getMinimalClientScript() string {
-	return fmt.Sprintf(`
-// Minimal Vango server-driven client (fallback)
+	return minimalClientScript
+}
+
+// minimalClientScript keeps a map from node IDs to DOM nodes, built by
+// mapping the first render of the page onto the server-rendered DOM, and
+// applies every patch opcode to it
+const minimalClientScript = `
+// Minimal Vango server-driven client
 (function() {
     console.log('🔮 Vango Server-Driven Client (minimal)');
     
-    const sessionID = document.querySelector('meta[name="vango-session"]')?.content || 
+    const sessionID = document.querySelector('meta[name="vango-session"]')?.content ||
                      'session_' + Date.now();
     
     let ws = null;
     
-    // Helper to read varint from DataView
-    function readVarint(view, offset) {
-        let value = 0;
-        let shift = 0;
-        while (offset < view.byteLength) {
-            const byte = view.getUint8(offset++);
-            value |= (byte & 0x7F) << shift;
-            if ((byte & 0x80) === 0) {
-                return { value, offset };
+    // Patch and command frames applied so far; sent in HELLO to resume after a reconnect
+    let lastSeq = 0;
+    let resuming = false;
+    let connectedOnce = false;
+    
+    // Read protocol primitives from one binary frame
+    function frameReader(buffer) {
+        const view = new DataView(buffer);
+        const r = { offset: 0 };
+        r.remaining = () => view.byteLength - r.offset;
+        r.byte = () => view.getUint8(r.offset++);
+        // Unsigned varint (arithmetic, so values above 2^31 survive)
+        r.varint = () => {
+            let value = 0;
+            let scale = 1;
+            let byte;
+            do {
+                byte = view.getUint8(r.offset++);
+                value += (byte & 0x7F) * scale;
+                scale *= 128;
+            } while (byte & 0x80);
+            return value;
+        };
+        // Length-prefixed UTF-8 string
+        r.string = () => {
+            const len = r.varint();
+            const value = new TextDecoder().decode(new Uint8Array(buffer, r.offset, len));
+            r.offset += len;
+            return value;
+        };
+        return r;
+    }
+    
+    // Event name -> wire ID, negotiated per session via EVENTS control frames
+    const eventIds = {
+        'click': 0x01, 'input': 0x05, 'submit': 0x06, 'change': 0x07,
+        'keydown': 0x08, 'keyup': 0x09, 'focus': 0x0A, 'blur': 0x0B
+    };
+    
+    function handleControl(r) {
+        const msg = r.string();
+        console.log('🎉 Control message:', msg);
+        
+        if (msg === 'HELLO') {
+            // A fresh client counts from the server's sequence
+            const seq = r.varint();
+            if (!resuming) {
+                lastSeq = seq;
+            }
+        } else if (msg === 'RESYNC') {
+            // Too much was missed to replay. RESYNC counts as a frame and
+            // is followed by every component's tree rendered afresh.
+            lastSeq = r.varint();
+            console.log('🔄 Server requested resync at', lastSeq);
+            clearRoot();
+        } else if (msg === 'EVENTS') {
+            const count = r.varint();
+            for (let i = 0; i < count; i++) {
+                const id = r.varint();
+                const name = r.string();
+                eventIds[name] = id;
+                delegate(name);
+            }
+        }
+    }
+    
+    // Node kinds of InsertNode subtrees (vdom.VKind)
+    const KIND_ELEMENT = 0, KIND_TEXT = 1, KIND_FRAGMENT = 2, KIND_PORTAL = 3, KIND_RAW = 4;
+    
+    const HTML_NS = 'http://www.w3.org/1999/xhtml';
+    const SVG_NS = 'http://www.w3.org/2000/svg';
+    const MATHML_NS = 'http://www.w3.org/1998/Math/MathML';
+    const XLINK_NS = 'http://www.w3.org/1999/xlink';
+    
+    // Node ID -> entry: { id, kind, dom, kids, parent }. Text and element
+    // entries hold their DOM node; fragments and portals have none, their
+    // kids' DOM nodes sit in the parent element or the portal target.
+    const nodes = new Map();
+    const nodeIds = new WeakMap();   // DOM node -> node ID
+    const listeners = new Map();     // node ID -> { event name: options }
+    
+    function track(entry) {
+        nodes.set(entry.id, entry);
+        if (entry.dom) {
+            nodeIds.set(entry.dom, entry.id);
+        }
+        return entry;
+    }
+    
+    // Elements rendered with a hydration ID are addressable before the
+    // first InsertNode maps the whole page
+    document.querySelectorAll('[data-hid]').forEach(el => {
+        const id = parseInt(el.getAttribute('data-hid').substring(1), 10);
+        if (id) {
+            track({ id, kind: KIND_ELEMENT, dom: el, kids: [], parent: null });
+        }
+    });
+    
+    // Read an InsertNode subtree, written by live.Encoder.WriteVNode
+    function readNode(r) {
+        const node = { kind: r.byte(), id: r.varint(), kids: [] };
+        switch (node.kind) {
+            case KIND_TEXT:
+                node.text = r.string();
+                return node;
+            case KIND_ELEMENT:
+            case KIND_RAW: {
+                node.tag = r.string();
+                r.string(); // key
+                node.attrs = [];
+                for (let n = r.varint(); n > 0; n--) {
+                    node.attrs.push([r.string(), r.string()]);
+                }
+                node.events = {};
+                for (let n = r.varint(); n > 0; n--) {
+                    const prop = r.string();
+                    node.events[propEvent(prop)] = r.byte();
+                }
+                if (node.kind === KIND_RAW) {
+                    node.html = r.string();
+                }
+                break;
+            }
+            case KIND_PORTAL:
+                node.target = r.string();
+                break;
+        }
+        for (let n = r.varint(); n > 0; n--) {
+            node.kids.push(readNode(r));
+        }
+        return node;
+    }
+    
+    // The event an on* prop listens to: a declared name matching it in
+    // any case, the lower-cased name otherwise
+    function propEvent(prop) {
+        const name = prop.substring(2).toLowerCase();
+        return Object.keys(eventIds).find(n => n.toLowerCase() === name) || name;
+    }
+    
+    // The namespace an element is created in, given its parent's
+    function elementNS(tag, parentNS) {
+        const lower = tag.toLowerCase();
+        if (lower === 'svg') return SVG_NS;
+        if (lower === 'math') return MATHML_NS;
+        return parentNS;
+    }
+    
+    // The namespace children of a DOM element inherit
+    function childNS(el) {
+        const ns = el.namespaceURI || HTML_NS;
+        return ns === SVG_NS && el.localName === 'foreignObject' ? HTML_NS : ns;
+    }
+    
+    // Boolean HTML attributes, present or absent rather than "true"/"false"
+    const booleanAttrs = new Set(['checked', 'disabled', 'readonly', 'required', 'selected',
+        'defer', 'async', 'multiple', 'autofocus']);
+    
+    function setAttr(el, key, value) {
+        if (el.namespaceURI && el.namespaceURI !== HTML_NS) {
+            if (key.startsWith('xlink:')) {
+                el.setAttributeNS(XLINK_NS, key, value);
+            } else {
+                el.setAttribute(key, value);
+            }
+            return;
+        }
+        if (booleanAttrs.has(key)) {
+            if (value === 'true' || value === '') {
+                el.setAttribute(key, '');
+            } else {
+                el.removeAttribute(key);
+            }
+            if (key === 'checked' || key === 'selected') {
+                el[key] = value === 'true' || value === '';
+            }
+            return;
+        }
+        el.setAttribute(key, value);
+        if (key === 'value' && 'value' in el) {
+            el.value = value;
+        }
+    }
+    
+    function removeAttr(el, key) {
+        el.removeAttribute(key);
+        if ((key === 'checked' || key === 'selected') && key in el) {
+            el[key] = false;
+        } else if (key === 'value' && 'value' in el) {
+            el.value = '';
+        }
+    }
+    
+    // Set the events a node listens to, listening at the document for
+    // names not seen before
+    function setListeners(id, events) {
+        if (Object.keys(events).length === 0) {
+            listeners.delete(id);
+            return;
+        }
+        listeners.set(id, events);
+        Object.keys(events).forEach(delegate);
+    }
+    
+    // Create the DOM for a decoded subtree in namespace ns
+    function build(node, ns) {
+        const entry = { id: node.id, kind: node.kind, dom: null, kids: [], parent: null };
+        let host = null;
+        let kidsNS = ns;
+        switch (node.kind) {
+            case KIND_TEXT:
+                entry.dom = document.createTextNode(node.text);
+                break;
+            case KIND_ELEMENT:
+            case KIND_RAW: {
+                const elNS = elementNS(node.tag, ns);
+                const el = elNS === HTML_NS ? document.createElement(node.tag) : document.createElementNS(elNS, node.tag);
+                node.attrs.forEach(([key, value]) => setAttr(el, key, value));
+                setListeners(node.id, node.events);
+                if (node.kind === KIND_RAW) {
+                    el.innerHTML = node.html;
+                }
+                entry.dom = host = el;
+                kidsNS = childNS(el);
+                break;
+            }
+            case KIND_PORTAL:
+                entry.target = host = document.querySelector(node.target);
+                kidsNS = host ? childNS(host) : HTML_NS;
+                break;
+        }
+        track(entry);
+        for (const kid of node.kids) {
+            const child = build(kid, kidsNS);
+            child.parent = entry;
+            entry.kids.push(child);
+            if (host) {
+                domOf(child).forEach(n => host.appendChild(n));
             }
-            shift += 7;
         }
-        return { value: 0, offset };
+        return entry;
+    }
+    
+    // Map a decoded subtree onto the server-rendered DOM from cursor, the
+    // next unmatched child of parent. Returns the entry and the new cursor.
+    // Nodes the page lacks are created; DOM nodes left over are kept.
+    function adopt(node, parent, cursor, ns) {
+        const entry = { id: node.id, kind: node.kind, dom: null, kids: [], parent: null };
+        switch (node.kind) {
+            case KIND_TEXT:
+                while (cursor && cursor.nodeType === 8) cursor = cursor.nextSibling;
+                // Adjacent text nodes were parsed as one; split them again
+                if (node.text !== '' && cursor && cursor.nodeType === 3 && cursor.data.startsWith(node.text)) {
+                    if (cursor.data.length > node.text.length) {
+                        cursor.splitText(node.text.length);
+                    }
+                    entry.dom = cursor;
+                    track(entry);
+                    return [entry, cursor.nextSibling];
+                }
+                break;
+            case KIND_ELEMENT:
+            case KIND_RAW:
+                while (cursor && cursor.nodeType !== 1 && !(cursor.nodeType === 3 && cursor.data.trim() !== '')) {
+                    cursor = cursor.nextSibling;
+                }
+                if (cursor && cursor.nodeType === 1 && cursor.localName.toLowerCase() === node.tag.toLowerCase()) {
+                    const el = cursor;
+                    entry.dom = el;
+                    track(entry);
+                    setListeners(node.id, node.events);
+                    if (node.kind === KIND_ELEMENT) {
+                        let kidCursor = el.firstChild;
+                        const kidsNS = childNS(el);
+                        for (const kid of node.kids) {
+                            let child;
+                            [child, kidCursor] = adopt(kid, el, kidCursor, kidsNS);
+                            child.parent = entry;
+                            entry.kids.push(child);
+                        }
+                    }
+                    return [entry, el.nextSibling];
+                }
+                break;
+            case KIND_FRAGMENT:
+                track(entry);
+                for (const kid of node.kids) {
+                    let child;
+                    [child, cursor] = adopt(kid, parent, cursor, ns);
+                    child.parent = entry;
+                    entry.kids.push(child);
+                }
+                return [entry, cursor];
+            case KIND_PORTAL:
+                // The page holds a placeholder; the children render into the target
+                while (cursor && cursor.nodeType !== 1) cursor = cursor.nextSibling;
+                if (cursor && cursor.hasAttribute('data-vango-portal')) {
+                    cursor = cursor.nextSibling;
+                }
+                return [build(node, ns), cursor];
+        }
+        
+        console.log('⚠️ Page does not match node', node.id, '- creating it');
+        const created = build(node, ns);
+        domOf(created).forEach(n => parent.insertBefore(n, cursor));
+        return [created, cursor];
+    }
+    
+    // The DOM nodes an entry puts in its parent, in order
+    function domOf(entry) {
+        if (entry.dom) return [entry.dom];
+        if (entry.kind === KIND_PORTAL) return [];
+        return entry.kids.flatMap(domOf);
+    }
+    
+    // The DOM element an entry's kids are placed in
+    function hostOf(entry) {
+        if (entry.dom) return entry.dom;
+        if (entry.kind === KIND_PORTAL) return entry.target;
+        return entry.parent ? hostOf(entry.parent) : document.body;
+    }
+    
+    // The DOM node before which the kid at index of parent goes: the first
+    // DOM node of a later kid, or what follows the parent if it is a fragment
+    function refAt(parent, index) {
+        for (let i = index; i < parent.kids.length; i++) {
+            const dom = domOf(parent.kids[i]);
+            if (dom.length > 0) return dom[0];
+        }
+        if (parent.kind === KIND_FRAGMENT && parent.parent) {
+            return refAt(parent.parent, parent.parent.kids.indexOf(parent) + 1);
+        }
+        return null;
+    }
+    
+    // Place an entry among the kids of parent, before the kid before or last
+    function attach(entry, parent, before) {
+        let index = before ? parent.kids.indexOf(before) : -1;
+        if (index < 0) index = parent.kids.length;
+        parent.kids.splice(index, 0, entry);
+        entry.parent = parent;
+        
+        const host = hostOf(parent);
+        if (!host) return;
+        const ref = refAt(parent, index + 1);
+        domOf(entry).forEach(n => host.insertBefore(n, ref));
+    }
+    
+    // Take an entry's DOM nodes out of the document and its parent's kids
+    function detach(entry) {
+        if (entry.parent) {
+            const kids = entry.parent.kids;
+            kids.splice(kids.indexOf(entry), 1);
+            entry.parent = null;
+        }
+        domOf(entry).forEach(n => n.parentNode && n.parentNode.removeChild(n));
+    }
+    
+    // Forget a removed subtree, taking portal content out of its target
+    function forget(entry) {
+        nodes.delete(entry.id);
+        listeners.delete(entry.id);
+        if (entry.kind === KIND_PORTAL) {
+            entry.kids.forEach(kid => domOf(kid).forEach(n => n.parentNode && n.parentNode.removeChild(n)));
+        }
+        entry.kids.forEach(forget);
+    }
+    
+    // Parent 0 is the page: the first render of a component maps onto the
+    // server-rendered DOM, anything else is appended to the body
+    const root = { id: 0, kind: KIND_ELEMENT, dom: document.body, kids: [], parent: null };
+    
+    // Whether the document belongs to a tree dropped by a resync; the
+    // <html> tree that follows replaces it
+    let staleDocument = false;
+    
+    // Forget every node before the trees are sent afresh. The document
+    // stays up until its new tree arrives; the DOM of other trees goes now.
+    function clearRoot() {
+        staleDocument = false;
+        for (const entry of [...root.kids, ...nodes.values()]) {
+            if (entry.parent && entry.parent !== root) continue;
+            if (entry.dom === document.documentElement) {
+                staleDocument = true;
+            } else {
+                detach(entry);
+            }
+            forget(entry);
+        }
+        nodes.clear();
+        listeners.clear();
+        root.kids = [];
+    }
+    
+    function insertNode(node, parentId, beforeId) {
+        const isDocument = node.kind === KIND_ELEMENT && node.tag.toLowerCase() === 'html';
+        if (parentId === 0 && isDocument && staleDocument) {
+            staleDocument = false;
+            const entry = build(node, HTML_NS);
+            document.replaceChild(entry.dom, document.documentElement);
+            root.dom = document.body;
+            return;
+        }
+        if (parentId === 0) {
+            const existing = isDocument ?
+                document.documentElement : document.querySelector('[data-hid="h' + node.id + '"]');
+            if (existing) {
+                adopt(node, existing.parentNode, existing, HTML_NS);
+                return;
+            }
+        }
+        const parent = parentId === 0 ? root : nodes.get(parentId);
+        if (!parent) {
+            console.log('❌ InsertNode: parent', parentId, 'not found');
+            return;
+        }
+        const entry = build(node, childNS(hostOf(parent) || document.body));
+        attach(entry, parent, nodes.get(beforeId));
+    }
+    
+    // Apply one patch; the patch is read in full even if its node is gone
+    function applyPatch(r) {
+        const opcode = r.byte();
+        const id = r.varint();
+        const entry = nodes.get(id);
+        switch (opcode) {
+            case 0x01: { // ReplaceText
+                const value = r.string();
+                if (entry && entry.dom) entry.dom.textContent = value;
+                break;
+            }
+            case 0x02: { // SetAttribute
+                const key = r.string();
+                const value = r.string();
+                if (entry && entry.dom) setAttr(entry.dom, key, value);
+                break;
+            }
+            case 0x03: // RemoveNode
+                if (entry) {
+                    detach(entry);
+                    forget(entry);
+                }
+                break;
+            case 0x04: { // InsertNode
+                const parentId = r.varint();
+                const beforeId = r.varint();
+                insertNode(readNode(r), parentId, beforeId);
+                return;
+            }
+            case 0x05: { // UpdateEvents
+                const events = {};
+                for (let n = r.varint(); n > 0; n--) {
+                    const name = r.string();
+                    events[name] = r.byte();
+                }
+                setListeners(id, events);
+                return;
+            }
+            case 0x06: { // RemoveAttribute
+                const key = r.string();
+                if (entry && entry.dom) removeAttr(entry.dom, key);
+                break;
+            }
+            case 0x09: { // SetHTML
+                const html = r.string();
+                if (entry && entry.dom) entry.dom.innerHTML = html;
+                break;
+            }
+            case 0x07: { // MoveNode
+                const parentId = r.varint();
+                const beforeId = r.varint();
+                const parent = parentId === 0 ? root : nodes.get(parentId);
+                if (entry && parent) {
+                    detach(entry);
+                    attach(entry, parent, nodes.get(beforeId));
+                }
+                break;
+            }
+            default:
+                throw new Error('unknown patch opcode ' + opcode);
+        }
+        if (!entry) {
+            console.log('❌ Patch', opcode, 'for unknown node', id);
+        }
+    }
+    
+    // Handle one binary frame from the server
+    function handleFrame(buffer) {
+        const r = frameReader(buffer);
+        const frameType = r.byte();
+        
+        if (frameType === 0x00) { // FramePatches
+            lastSeq++;
+            const count = r.varint();
+            console.log('📦 Applying', count, 'patches');
+            try {
+                for (let i = 0; i < count; i++) {
+                    applyPatch(r);
+                }
+            } catch (err) {
+                // The rest of the frame cannot be parsed
+                console.log('❌ Bad patch frame:', err);
+            }
+        } else if (frameType === 0x02) { // FrameControl
+            handleControl(r);
+        } else if (frameType === 0x03) { // FrameCommand
+            lastSeq++;
+            runCommand(r);
+        }
+    }
+    
+    const liveURL = '/vango/live/' + sessionID;
+    let source = null;      // EventSource when WebSockets are blocked
+    let wsFailures = 0;     // WebSocket attempts that never opened
+    let postQueue = Promise.resolve();
+    
+    // Send a frame over whichever transport is connected. SSE clients POST
+    // frames one at a time so the server sees them in order.
+    function send(bytes) {
+        if (ws && ws.readyState === WebSocket.OPEN) {
+            ws.send(bytes);
+            return true;
+        }
+        if (source && source.readyState === EventSource.OPEN) {
+            postQueue = postQueue.then(() => fetch(liveURL, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/octet-stream' },
+                body: bytes
+            })).catch(err => console.log('❌ POST failed', err));
+            return true;
+        }
+        return false;
+    }
+    
+    function handleOpen() {
+        console.log('✅ Connected to server');
+        updateStatus(true);
+        
+        // HELLO: [0x02][string "HELLO"][resumable varint][lastSeq varint]
+        resuming = connectedOnce;
+        connectedOnce = true;
+        const hello = [0x02];
+        encodeString(hello, 'HELLO');
+        hello.push(...encodeVarint(resuming ? 1 : 0), ...encodeVarint(lastSeq));
+        send(new Uint8Array(hello));
+    }
+    
+    // Run a server command: [0x03][type][target][value][arg][flag]
+    function runCommand(r) {
+        const type = r.byte();
+        const target = r.string();
+        const value = r.string();
+        const arg = r.string();
+        const flag = r.byte() === 1;
+        console.log('🧭 Command:', type, target || value);
+        
+        const el = target ? document.querySelector(target) : null;
+        switch (type) {
+            case 0x01: // Focus
+                if (el) el.focus();
+                break;
+            case 0x02: // Scroll into view
+                if (el) {
+                    const opts = { behavior: flag ? 'smooth' : 'auto' };
+                    if (arg) opts.block = arg;
+                    el.scrollIntoView(opts);
+                }
+                break;
+            case 0x03: // Navigate: the server renders the new page
+                if (flag) {
+                    window.history.replaceState(null, '', value);
+                } else {
+                    window.history.pushState(null, '', value);
+                }
+                shownPath = currentPath();
+                break;
+            case 0x04: // Title
+                document.title = value;
+                break;
+            case 0x05: { // Call a global function, dots reach into objects
+                let self = window;
+                let fn = window;
+                for (const part of value.split('.')) {
+                    self = fn;
+                    fn = fn ? fn[part] : undefined;
+                }
+                if (typeof fn === 'function') {
+                    fn.apply(self, JSON.parse(arg || '[]'));
+                } else {
+                    console.log('❌ No function', value);
+                }
+                break;
+            }
+            case 0x06: { // Download
+                const a = document.createElement('a');
+                a.href = value;
+                a.download = arg;
+                document.body.appendChild(a);
+                a.click();
+                a.remove();
+                break;
+            }
+            case 0x07: // Load a new page
+                if (flag) {
+                    window.location.replace(value);
+                } else {
+                    window.location.assign(value);
+                }
+                break;
+        }
     }
     
     function connect() {
+        // Fall back to SSE + POST when WebSocket upgrades keep failing
+        if (wsFailures >= 2 && typeof EventSource !== 'undefined') {
+            connectSSE();
+            return;
+        }
+        
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        ws = new WebSocket(protocol + '//' + window.location.host + '/vango/live/' + sessionID);
+        ws = new WebSocket(protocol + '//' + window.location.host + liveURL);
         ws.binaryType = 'arraybuffer';
+        let opened = false;
         
         ws.onopen = () => {
-            console.log('✅ Connected to server');
-            updateStatus(true);
+            opened = true;
+            wsFailures = 0;
+            handleOpen();
         };
         
         ws.onmessage = (event) => {
             if (event.data instanceof ArrayBuffer) {
-                // Handle binary patches from server
-                const view = new DataView(event.data);
-                const frameType = view.getUint8(0);
-                
-                console.log('📦 Received binary message, frame type:', frameType);
-                
-                if (frameType === 0x00) { // FramePatches - THIS WAS THE BUG!
-                    console.log('🔧 Received patch frame');
-                    
-                    // Parse binary patch format
-                    let offset = 1; // Skip frame type
-                    
-                    // Read patch count (varint)
-                    const patchCount = readVarint(view, offset);
-                    offset = patchCount.offset;
-                    
-                    console.log('📦 Patch count:', patchCount.value);
-                    
-                    for (let i = 0; i < patchCount.value; i++) {
-                        // Read opcode
-                        const opcode = view.getUint8(offset++);
-                        console.log('🔨 Patch opcode:', opcode);
-                        
-                        if (opcode === 0x01) { // OpReplaceText
-                            // Read node ID (varint)
-                            const nodeId = readVarint(view, offset);
-                            offset = nodeId.offset;
-                            
-                            // Read string value
-                            const strLen = readVarint(view, offset);
-                            offset = strLen.offset;
-                            
-                            const decoder = new TextDecoder();
-                            const value = decoder.decode(new DataView(event.data, offset, strLen.value));
-                            offset += strLen.value;
-                            
-                            console.log('✏️ ReplaceText: nodeId=' + nodeId.value + ', value="' + value + '"');
-                            
-                            // Update the counter display
-                            const counter = document.getElementById('counter-display');
-                            if (counter) {
-                                counter.textContent = value;
-                                counter.style.transform = 'scale(1.1)';
-                                setTimeout(() => {
-                                    counter.style.transform = 'scale(1)';
-                                }, 200);
-                                console.log('✅ Updated counter to:', value);
-                            }
-                        }
-                    }
-                } else if (frameType === 0x02) { // FrameControl
-                    console.log('🎉 Control message (HELLO etc)');
-                }
-            } else if (typeof event.data === 'string') {
-                // Legacy JSON handling
-                const data = JSON.parse(event.data);
-                if (data.type === 'update' && data.value !== undefined) {
-                    const counter = document.getElementById('counter-display');
-                    if (counter) {
-                        counter.textContent = data.value;
-                        counter.style.transform = 'scale(1.1)';
-                        setTimeout(() => {
-                            counter.style.transform = 'scale(1)';
-                        }, 200);
-                    }
-                }
+                handleFrame(event.data);
             }
         };
         
         ws.onclose = () => {
             console.log('❌ Disconnected');
             updateStatus(false);
+            if (!opened) {
+                wsFailures++;
+            }
             setTimeout(connect, 2000);
         };
     }
     
+    // SSE downstream: each event is one base64-encoded frame.
+    // EventSource reconnects by itself; every open sends a new HELLO.
+    function connectSSE() {
+        console.log('🔁 Using SSE transport');
+        ws = null;
+        source = new EventSource(liveURL);
+        source.onopen = handleOpen;
+        source.onmessage = (event) => {
+            const bin = atob(event.data);
+            const bytes = new Uint8Array(bin.length);
+            for (let i = 0; i < bin.length; i++) {
+                bytes[i] = bin.charCodeAt(i);
+            }
+            handleFrame(bytes.buffer);
+        };
+        source.onerror = () => {
+            console.log('❌ Disconnected');
+            updateStatus(false);
+        };
+    }
+    
     function updateStatus(connected) {
         const status = document.getElementById('connection-status');
         if (status) {
@@ -182,53 +749,170 @@ func getMinimalClientScript() string {
         }
     }
     
-    // Handle clicks on server elements
-    document.addEventListener('click', (e) => {
-        const target = e.target.closest('[data-server-event]');
-        if (!target) return;
+    // Encode an unsigned varint (arithmetic, so values above 2^31 survive)
+    function encodeVarint(n) {
+        const bytes = [];
+        while (n >= 0x80) {
+            bytes.push((n - Math.floor(n / 128) * 128) | 0x80);
+            n = Math.floor(n / 128);
+        }
+        bytes.push(n);
+        return bytes;
+    }
+    
+    function encodeString(bytes, s) {
+        const b = new TextEncoder().encode(s);
+        bytes.push(...encodeVarint(b.length), ...b);
+    }
+    
+    // Encode the typed event payload understood by live.DecodeEvent:
+    // [len][count]{[name][tag][value]}*
+    function encodePayload(fields) {
+        const names = Object.keys(fields).filter(k => fields[k] !== undefined).sort();
+        if (names.length === 0) return [];
+        
+        const body = encodeVarint(names.length);
+        for (const name of names) {
+            encodeString(body, name);
+            const v = fields[name];
+            if (v === null) {
+                body.push(0x00);
+            } else if (typeof v === 'boolean') {
+                body.push(0x01, v ? 1 : 0);
+            } else if (typeof v === 'number' && Number.isSafeInteger(v)) {
+                body.push(0x02, ...encodeVarint(v >= 0 ? v * 2 : -v * 2 - 1)); // zigzag
+            } else if (typeof v === 'number') {
+                const f = new DataView(new ArrayBuffer(8));
+                f.setFloat64(0, v, true);
+                body.push(0x03, ...new Uint8Array(f.buffer));
+            } else if (typeof v === 'object') {
+                const keys = Object.keys(v).sort();
+                body.push(0x05, ...encodeVarint(keys.length));
+                for (const k of keys) {
+                    encodeString(body, k);
+                    encodeString(body, String(v[k]));
+                }
+            } else {
+                body.push(0x04);
+                encodeString(body, String(v));
+            }
+        }
+        return [...encodeVarint(body.length), ...body];
+    }
+    
+    // Collect the payload fields for a DOM event
+    function eventPayload(e, target) {
+        const fields = {};
+        if (target.id) fields.targetId = target.id;
+        if (target.value !== undefined && target.tagName !== 'BUTTON') fields.value = String(target.value);
+        if (target.type === 'checkbox' || target.type === 'radio') fields.checked = !!target.checked;
+        if (e.key !== undefined) {
+            fields.key = e.key;
+            fields.keyCode = e.keyCode;
+        }
+        if (e.clientX !== undefined) {
+            fields.clientX = e.clientX;
+            fields.clientY = e.clientY;
+            fields.button = e.button;
+        }
+        if (e.altKey !== undefined) {
+            fields.altKey = e.altKey;
+            fields.ctrlKey = e.ctrlKey;
+            fields.metaKey = e.metaKey;
+            fields.shiftKey = e.shiftKey;
+        }
+        if (target.tagName === 'FORM') {
+            const form = {};
+            new FormData(target).forEach((value, key) => { form[key] = String(value); });
+            fields.form = form;
+        }
+        return fields;
+    }
+    
+    // The DOM event that fires a server event unless data-server-trigger says otherwise
+    function defaultTrigger(el) {
+        if (el.tagName === 'FORM') return 'submit';
+        if (el.tagName === 'INPUT' || el.tagName === 'TEXTAREA' || el.tagName === 'SELECT') {
+            return (el.type === 'checkbox' || el.type === 'radio') ? 'change' : 'input';
+        }
+        return 'click';
+    }
+    
+    // Forward DOM events to the server: from elements whose on* props listen
+    // to them, under their node ID, and from elements marked with
+    // data-server-event
+    function handleServerEvent(e) {
+        for (let el = e.target; el && el.nodeType === 1; el = el.parentNode) {
+            const id = nodeIds.get(el);
+            const events = id !== undefined ? listeners.get(id) : undefined;
+            if (events && events[e.type] !== undefined) {
+                const options = events[e.type];
+                if (options & 0x08) e.preventDefault();   // preventDefault
+                if (options & 0x04) delete events[e.type]; // once
+                sendEvent(e.type, e, id, el);
+                return;
+            }
+            if (el.dataset.serverEvent) {
+                forwardServerEvent(e, el);
+                return;
+            }
+        }
+    }
+    
+    function forwardServerEvent(e, target) {
+        const trigger = target.dataset.serverTrigger || defaultTrigger(target);
+        if (trigger !== e.type) return;
         
-        e.preventDefault();
-        const eventType = target.dataset.serverEvent;
+        if (e.type === 'submit' || (e.type === 'click' && target.tagName !== 'INPUT')) {
+            e.preventDefault();
+        }
         
-        if (ws && ws.readyState === WebSocket.OPEN) {
-            // Send properly formatted event:
-            // [FrameEvent=0x01, EventType, NodeID as varint]
-            const nodeId = parseInt(target.dataset.hid?.substring(1) || '0', 10) || 0;
-            
-            // Encode varint for node ID (simple version for small numbers)
-            const encodeVarint = (n) => {
-                const bytes = [];
-                while (n >= 0x80) {
-                    bytes.push((n & 0x7F) | 0x80);
-                    n >>= 7;
-                }
-                bytes.push(n & 0x7F);
-                return bytes;
-            };
-            
-            const nodeIdBytes = encodeVarint(nodeId);
-            const event = new Uint8Array([0x01, getEventCode(eventType), ...nodeIdBytes]);
-            ws.send(event);
+        // Handlers are registered for the element's hydration ID
+        const nodeId = parseInt(target.dataset.hid?.substring(1) || '0', 10) || nodeIds.get(target) || 0;
+        sendEvent(target.dataset.serverEvent, e, nodeId, target);
+    }
+    
+    // Send an event frame:
+    // [FrameEvent=0x01, EventType as varint, NodeID as varint, payload]
+    function sendEvent(eventType, e, nodeId, target) {
+        const event = new Uint8Array([
+            0x01,
+            ...encodeVarint(getEventCode(eventType, e.type)),
+            ...encodeVarint(nodeId),
+            ...encodePayload(eventPayload(e, target))
+        ]);
+        if (send(event)) {
             console.log('📤 Sent event:', eventType, 'nodeId:', nodeId);
         }
+    }
+    
+    // Listen for DOM events at the document; declared event names are added
+    // as EVENTS frames announce them. Capturing also sees events that do
+    // not bubble, like focus, scroll and mouseenter.
+    const delegated = new Set();
+    function delegate(type) {
+        if (delegated.has(type)) return;
+        delegated.add(type);
+        document.addEventListener(type, handleServerEvent, true);
+    }
+    ['click', 'input', 'change', 'submit', 'keydown'].forEach(delegate);
+    
+    // Back and forward over pages the server navigated to load them, since
+    // only the server renders them; moving within a page does not
+    function currentPath() {
+        return window.location.pathname + window.location.search;
+    }
+    let shownPath = currentPath();
+    window.addEventListener('popstate', () => {
+        if (currentPath() !== shownPath) window.location.reload();
     });
     
-    function getEventCode(type) {
-        const codes = {
-            'increment': 0x02,
-            'decrement': 0x03,
-            'reset': 0x04
-        };
-        return codes[type] || 0x01;
+    // Use the declared event's wire ID, falling back to the DOM event
+    function getEventCode(type, domType) {
+        return eventIds[type] || eventIds[domType] || eventIds['click'];
     }
     
     // Initialize
     connect();
-    
-    // Add transition styles
-    const style = document.createElement('style');
-    style.textContent = %s;
-    document.head.appendChild(style);
 })();
-`, "`#counter-display { transition: transform 0.2s ease; }`")
-}
\ No newline at end of file
+`
@@ -0,0 +1,303 @@
+//go:build !wasm
+// +build !wasm
+
+package server_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/recera/vango/pkg/live"
+	"github.com/recera/vango/pkg/renderer/html"
+	"github.com/recera/vango/pkg/server"
+	"github.com/recera/vango/pkg/vango/vdom"
+)
+
+// pageState is what the test page renders
+type pageState struct {
+	count    int
+	items    []string
+	extra    []string // rendered in a fragment
+	class    string
+	note     string // trusted HTML
+	disabled bool
+	listen   bool   // the save button listens to clicks through its on* prop
+	head     string // tag of the element leading the header, or "" for text
+}
+
+func testPage(st pageState) *vdom.VNode {
+	items := make([]*vdom.VNode, len(st.items))
+	for i, item := range st.items {
+		items[i] = vdom.NewElement("li", vdom.Props{"key": item}, vdom.NewText(item))
+	}
+	extra := make([]*vdom.VNode, len(st.extra))
+	for i, text := range st.extra {
+		extra[i] = vdom.NewElement("span", nil, vdom.NewText(text))
+	}
+	app := vdom.Props{"id": "app"}
+	if st.class != "" {
+		app["class"] = st.class
+	}
+	head := vdom.NewText("head")
+	if st.head != "" {
+		head = vdom.NewElement(st.head, nil, vdom.NewText("head"))
+	}
+	save := vdom.Props{"id": "save", "disabled": st.disabled}
+	if st.listen {
+		save["onClick"] = func() {}
+	}
+
+	return vdom.NewElement("html", nil,
+		vdom.NewElement("head", nil, vdom.NewElement("title", nil, vdom.NewText("Test"))),
+		vdom.NewElement("body", nil,
+			vdom.NewElement("div", app,
+				vdom.NewElement("header", nil, head, vdom.NewElement("p", nil, vdom.NewText("tail"))),
+				vdom.NewElement("p", nil, vdom.NewText("Count: "), vdom.NewText(strconv.Itoa(st.count))),
+				vdom.NewElement("ul", nil, items...),
+				vdom.NewFragment(extra...),
+				vdom.NewElement("button", save, vdom.NewText("Save")),
+				vdom.NewTrustedHTML("div", vdom.Props{"class": "note"}, st.note),
+			),
+		),
+	)
+}
+
+// domNode is a DOM node as the client harness reports it
+type domNode struct {
+	Tag   string            `json:"tag,omitempty"`
+	Attrs map[string]string `json:"attrs,omitempty"`
+	Kids  []domNode         `json:"kids,omitempty"`
+	Text  *string           `json:"text,omitempty"`
+	HTML  *string           `json:"html,omitempty"`
+}
+
+// expectedDOM returns the DOM nodes a VNode renders to
+func expectedDOM(node *vdom.VNode) []domNode {
+	switch node.Kind {
+	case vdom.KindText:
+		text := node.Text
+		return []domNode{{Text: &text}}
+	case vdom.KindElement, vdom.KindRaw:
+		el := domNode{Tag: node.Tag, Attrs: map[string]string{}}
+		for key, value := range node.Props {
+			if _, isEvent := vdom.PropEvent(key); isEvent || key == "key" || value == nil {
+				continue
+			}
+			if b, ok := value.(bool); ok && key == "disabled" {
+				if b {
+					el.Attrs[key] = ""
+				}
+				continue
+			}
+			el.Attrs[key] = vdom.PropString(value)
+		}
+		if node.Kind == vdom.KindRaw {
+			html := node.Text
+			el.HTML = &html
+		}
+		for i := range node.Kids {
+			el.Kids = append(el.Kids, expectedDOM(&node.Kids[i])...)
+		}
+		return []domNode{el}
+	}
+	var nodes []domNode
+	for i := range node.Kids {
+		nodes = append(nodes, expectedDOM(&node.Kids[i])...)
+	}
+	return nodes
+}
+
+// findByID returns the element of a tree with the given id prop
+func findByID(node *vdom.VNode, id string) *vdom.VNode {
+	if node.Props["id"] == id {
+		return node
+	}
+	for i := range node.Kids {
+		if found := findByID(&node.Kids[i], id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// harnessStep is a frame delivered to the client, a DOM event dispatched or
+// a history move to a path
+type harnessStep struct {
+	Frame    []byte            `json:"frame,omitempty"`
+	Dispatch map[string]string `json:"dispatch,omitempty"`
+	PopState string            `json:"popstate,omitempty"`
+}
+
+// runClient runs the client script injected into page in node, returning
+// the #app element, the frames the client sent and its address bar changes
+func runClient(t *testing.T, page string, steps []harnessStep) (domNode, [][]byte, []string) {
+	t.Helper()
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found in PATH")
+	}
+
+	input, err := json.Marshal(map[string]any{"html": page, "steps": steps})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(node, "testdata/client_harness.js")
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("client harness failed: %v\n%s", err, stderr.String())
+	}
+
+	var result struct {
+		DOM    domNode  `json:"dom"`
+		Sent   []string `json:"sent"`
+		Visits []string `json:"visits"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		t.Fatalf("bad harness output %q: %v", out, err)
+	}
+	var sent [][]byte
+	for _, frame := range result.Sent {
+		data, err := base64.StdEncoding.DecodeString(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, data)
+	}
+	return result.DOM, sent, result.Visits
+}
+
+func TestServerDrivenClient_AppliesPatches(t *testing.T) {
+	states := []pageState{
+		{items: []string{"a", "b"}, note: "<b>one</b>", head: "span"},
+		{count: 1, items: []string{"a", "b", "c"}, class: "wide", disabled: true, note: "<b>one</b>", head: "b"},
+		{count: 2, items: []string{"c", "a"}, extra: []string{"x", "y"}, listen: true, note: "<i>two</i>"},
+	}
+
+	// The page as served: rendered once on its own, with the client injected
+	doc := server.InjectServerDrivenClient(testPage(states[0]), "session-1")
+	var page bytes.Buffer
+	if err := html.NewHTMLApplier(&page).Apply(nil, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// The live renders: the first maps onto the page, the others patch it
+	ids := vdom.NewIDAllocator()
+	var prev *vdom.VNode
+	var steps []harnessStep
+	for _, st := range states {
+		next := testPage(st)
+		frame, err := live.EncodePatches(vdom.DiffWithIDs(ids, prev, next))
+		if err != nil {
+			t.Fatal(err)
+		}
+		steps = append(steps, harnessStep{Frame: frame})
+		prev = next
+	}
+	steps = append(steps, harnessStep{Dispatch: map[string]string{"selector": "#save", "type": "click"}})
+
+	dom, sent, _ := runClient(t, page.String(), steps)
+
+	want, _ := json.Marshal(expectedDOM(findByID(prev, "app"))[0])
+	got, _ := json.Marshal(dom)
+	if !bytes.Equal(got, want) {
+		t.Errorf("DOM after patches:\n got %s\nwant %s", got, want)
+	}
+
+	// The client sends HELLO, then the click under the button's node ID
+	if len(sent) != 2 {
+		t.Fatalf("client sent %d frames, want HELLO and the click", len(sent))
+	}
+	evt, err := live.DecodeEvent(sent[1])
+	if err != nil {
+		t.Fatalf("DecodeEvent() error = %v", err)
+	}
+	if save := findByID(prev, "save"); evt.Type != live.EventClick || evt.NodeID != save.ID {
+		t.Errorf("got event %d for node %d, want click for node %d", evt.Type, evt.NodeID, save.ID)
+	}
+}
+
+func TestServerDrivenClient_Resync(t *testing.T) {
+	before := pageState{items: []string{"a", "b"}, note: "<b>one</b>", head: "span"}
+	after := pageState{count: 7, items: []string{"z"}, extra: []string{"x"}, listen: true, note: "<i>two</i>"}
+
+	doc := server.InjectServerDrivenClient(testPage(before), "session-1")
+	var page bytes.Buffer
+	if err := html.NewHTMLApplier(&page).Apply(nil, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first render maps onto the page; after RESYNC the page is
+	// rendered afresh with new IDs
+	ids := vdom.NewIDAllocator()
+	first, err := live.EncodePatches(vdom.DiffWithIDs(ids, nil, testPage(before)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resync := []byte{0x02, byte(len("RESYNC"))}
+	resync = append(append(resync, "RESYNC"...), 2)
+	next := testPage(after)
+	snapshot, err := live.EncodePatches(vdom.DiffWithIDs(ids, nil, next))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dom, sent, _ := runClient(t, page.String(), []harnessStep{
+		{Frame: first},
+		{Frame: resync},
+		{Frame: snapshot},
+		{Dispatch: map[string]string{"selector": "#save", "type": "click"}},
+	})
+
+	want, _ := json.Marshal(expectedDOM(findByID(next, "app"))[0])
+	got, _ := json.Marshal(dom)
+	if !bytes.Equal(got, want) {
+		t.Errorf("DOM after resync:\n got %s\nwant %s", got, want)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("client sent %d frames, want HELLO and the click", len(sent))
+	}
+	evt, err := live.DecodeEvent(sent[1])
+	if err != nil {
+		t.Fatalf("DecodeEvent() error = %v", err)
+	}
+	if save := findByID(next, "save"); evt.NodeID != save.ID {
+		t.Errorf("got click for node %d, want the new save button %d", evt.NodeID, save.ID)
+	}
+}
+
+func TestServerDrivenClient_Navigate(t *testing.T) {
+	doc := server.InjectServerDrivenClient(testPage(pageState{}), "session-1")
+	var page bytes.Buffer
+	if err := html.NewHTMLApplier(&page).Apply(nil, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	var steps []harnessStep
+	for _, cmd := range []live.Command{
+		{Type: live.CommandNavigate, Value: "/inbox"},
+		{Type: live.CommandNavigate, Value: "/inbox?page=2", Flag: true},
+		{Type: live.CommandLoad, Value: "/login"},
+	} {
+		frame, err := live.EncodeCommand(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		steps = append(steps, harnessStep{Frame: frame})
+	}
+	// Going back to the page served loads it again
+	steps = append(steps, harnessStep{PopState: "/"})
+
+	_, _, visits := runClient(t, page.String(), steps)
+	want := []string{"push /inbox", "replace /inbox?page=2", "assign /login", "reload"}
+	if strings.Join(visits, ", ") != strings.Join(want, ", ") {
+		t.Errorf("address bar changes = %v, want %v", visits, want)
+	}
+}
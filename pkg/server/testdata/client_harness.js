@@ -0,0 +1,244 @@
+// Runs the injected server-driven client against a small DOM.
+//
+// stdin: {"html": page, "steps": [{"frame": base64} | {"dispatch": {"selector", "type"}} | {"popstate": path}]}
+// stdout: {"dom": #app as {tag, attrs, kids} / {text} / {tag, attrs, html}, "sent": [base64], "visits": [string]}
+'use strict';
+
+const vm = require('vm');
+
+const HTML_NS = 'http://www.w3.org/1999/xhtml';
+
+class Node {
+    constructor(nodeType) {
+        this.nodeType = nodeType;
+        this.parentNode = null;
+        this.childNodes = [];
+    }
+    get firstChild() { return this.childNodes[0] || null; }
+    get nextSibling() {
+        if (!this.parentNode) return null;
+        const kids = this.parentNode.childNodes;
+        return kids[kids.indexOf(this) + 1] || null;
+    }
+    appendChild(node) { return this.insertBefore(node, null); }
+    insertBefore(node, ref) {
+        if (node.nodeType === 11) {
+            node.childNodes.slice().forEach(n => this.insertBefore(n, ref));
+            return node;
+        }
+        if (node.parentNode) node.parentNode.removeChild(node);
+        const i = ref ? this.childNodes.indexOf(ref) : -1;
+        if (ref && i < 0) throw new Error('insertBefore: reference is not a child');
+        this.childNodes.splice(i < 0 ? this.childNodes.length : i, 0, node);
+        node.parentNode = this;
+        return node;
+    }
+    removeChild(node) {
+        const i = this.childNodes.indexOf(node);
+        if (i < 0) throw new Error('removeChild: not a child');
+        this.childNodes.splice(i, 1);
+        node.parentNode = null;
+        return node;
+    }
+    replaceChild(node, old) {
+        this.insertBefore(node, old);
+        return this.removeChild(old);
+    }
+    remove() { if (this.parentNode) this.parentNode.removeChild(this); }
+    get textContent() { return this.childNodes.map(n => n.textContent).join(''); }
+    set textContent(value) {
+        this.childNodes.slice().forEach(n => this.removeChild(n));
+        if (value !== '') this.appendChild(new Text(value));
+    }
+    contains(node) {
+        for (; node; node = node.parentNode) if (node === this) return true;
+        return false;
+    }
+    elements() {
+        const out = [];
+        const walk = n => n.childNodes.forEach(k => { if (k.nodeType === 1) { out.push(k); walk(k); } });
+        walk(this);
+        return out;
+    }
+    querySelectorAll(selector) { return this.elements().filter(el => el.matches(selector)); }
+    querySelector(selector) { return this.querySelectorAll(selector)[0] || null; }
+}
+
+class Text extends Node {
+    constructor(data) { super(3); this.data = data; }
+    get textContent() { return this.data; }
+    set textContent(value) { this.data = value; }
+    get nodeValue() { return this.data; }
+    set nodeValue(value) { this.data = value; }
+    splitText(offset) {
+        const rest = new Text(this.data.substring(offset));
+        this.data = this.data.substring(0, offset);
+        this.parentNode.insertBefore(rest, this.nextSibling);
+        return rest;
+    }
+}
+
+class Element extends Node {
+    constructor(localName, namespaceURI) {
+        super(1);
+        this.localName = localName;
+        this.namespaceURI = namespaceURI;
+        this.attrs = new Map();
+        this.html = null;
+        const el = this;
+        this.dataset = new Proxy({}, {
+            get(_, key) {
+                const name = 'data-' + String(key).replace(/[A-Z]/g, c => '-' + c.toLowerCase());
+                return el.attrs.has(name) ? el.attrs.get(name) : undefined;
+            }
+        });
+    }
+    get tagName() { return this.namespaceURI === HTML_NS ? this.localName.toUpperCase() : this.localName; }
+    get id() { return this.getAttribute('id') || ''; }
+    getAttribute(name) { return this.attrs.has(name) ? this.attrs.get(name) : null; }
+    setAttribute(name, value) { this.attrs.set(name, String(value)); }
+    setAttributeNS(ns, name, value) { this.setAttribute(name, value); }
+    removeAttribute(name) { this.attrs.delete(name); }
+    hasAttribute(name) { return this.attrs.has(name); }
+    set innerHTML(html) {
+        this.childNodes.slice().forEach(n => this.removeChild(n));
+        this.html = html;
+    }
+    // Selectors used by the client and tests: tag, #id, [attr] and
+    // [attr="value"], combined without spaces
+    matches(selector) {
+        const re = /^([a-zA-Z][\w-]*)?(?:#([\w-]+))?((?:\[[^\]]+\])*)$/;
+        const m = re.exec(selector);
+        if (!m) throw new Error('unsupported selector ' + selector);
+        if (m[1] && m[1].toLowerCase() !== this.localName.toLowerCase()) return false;
+        if (m[2] && this.getAttribute('id') !== m[2]) return false;
+        for (const [, name, value] of m[3].matchAll(/\[([\w-]+)(?:="([^"]*)")?\]/g)) {
+            if (!this.attrs.has(name)) return false;
+            if (value !== undefined && this.attrs.get(name) !== value) return false;
+        }
+        return true;
+    }
+}
+
+class Document extends Node {
+    constructor() {
+        super(9);
+        this.listeners = {};
+        this.title = '';
+    }
+    get documentElement() { return this.childNodes.find(n => n.nodeType === 1) || null; }
+    get head() { return this.querySelector('head'); }
+    get body() { return this.querySelector('body'); }
+    getElementById(id) { return this.querySelector('#' + id); }
+    createElement(tag) { return new Element(tag.toLowerCase(), HTML_NS); }
+    createElementNS(ns, tag) { return new Element(tag, ns); }
+    createTextNode(data) { return new Text(data); }
+    createDocumentFragment() { return new Node(11); }
+    addEventListener(type, fn) { (this.listeners[type] = this.listeners[type] || []).push(fn); }
+}
+
+// Parse the output of the HTML renderer
+const voidTags = new Set(['area', 'base', 'br', 'col', 'embed', 'hr', 'img', 'input', 'link', 'meta', 'param', 'source', 'track', 'wbr']);
+
+function unescape(s) {
+    return s.replace(/&(lt|gt|amp|quot|#34|#39);/g, (_, e) => ({ lt: '<', gt: '>', amp: '&', quot: '"', '#34': '"', '#39': "'" })[e]);
+}
+
+function parse(html, doc) {
+    const stack = [doc];
+    const top = () => stack[stack.length - 1];
+    const re = /<!DOCTYPE[^>]*>|<\/([\w-]+)>|<([\w-]+)((?:\s+[\w:-]+(?:="[^"]*")?)*)\s*(\/?)>|([^<]+)/gi;
+    let m;
+    while ((m = re.exec(html))) {
+        if (m[1]) {
+            stack.pop();
+        } else if (m[2]) {
+            const el = doc.createElement(m[2]);
+            for (const a of m[3].matchAll(/([\w:-]+)(?:="([^"]*)")?/g)) {
+                el.setAttribute(a[1], unescape(a[2] || ''));
+            }
+            top().appendChild(el);
+            if (el.localName === 'script' || el.localName === 'style') {
+                const end = html.indexOf('</' + el.localName + '>', re.lastIndex);
+                el.appendChild(new Text(html.substring(re.lastIndex, end)));
+                re.lastIndex = end + el.localName.length + 3;
+            } else if (!m[4] && !voidTags.has(el.localName)) {
+                stack.push(el);
+            }
+        } else if (m[5]) {
+            top().appendChild(new Text(unescape(m[5])));
+        }
+    }
+}
+
+function serialize(node) {
+    if (node.nodeType === 3) return { text: node.data };
+    const attrs = {};
+    [...node.attrs.keys()].filter(k => k !== 'data-hid').sort().forEach(k => { attrs[k] = node.attrs.get(k); });
+    if (node.html !== null) return { tag: node.localName, attrs, html: node.html };
+    return { tag: node.localName, attrs, kids: node.childNodes.filter(n => n.nodeType === 1 || n.nodeType === 3).map(serialize) };
+}
+
+const input = JSON.parse(require('fs').readFileSync(0, 'utf8'));
+const document = new Document();
+parse(input.html, document);
+
+const sent = [];
+let socket = null;
+class WebSocket {
+    constructor(url) {
+        this.url = url;
+        this.readyState = WebSocket.OPEN;
+        socket = this;
+    }
+    send(bytes) { sent.push(Buffer.from(bytes).toString('base64')); }
+}
+WebSocket.OPEN = 1;
+
+const quiet = { log() {}, warn() {}, error() {} };
+// Address bar changes, as "push <url>", "replace <url>", "assign <url>" or "reload"
+const visits = [];
+const location = {
+    protocol: 'http:', host: 'localhost', pathname: '/', search: '',
+    assign(url) { visits.push('assign ' + url); },
+    replace(url) { visits.push('replace ' + url); },
+    reload() { visits.push('reload'); },
+};
+function moveTo(url) {
+    const i = url.indexOf('?');
+    location.pathname = i < 0 ? url : url.slice(0, i);
+    location.search = i < 0 ? '' : url.slice(i);
+}
+const history = {
+    pushState(state, title, url) { moveTo(url); visits.push('push ' + url); },
+    replaceState(state, title, url) { moveTo(url); visits.push('replace ' + url); },
+};
+const windowListeners = {};
+const window = {
+    location, history,
+    addEventListener(type, fn) { (windowListeners[type] = windowListeners[type] || []).push(fn); },
+};
+const context = vm.createContext({
+    window, document, WebSocket, console: quiet, setTimeout() {},
+    TextEncoder, TextDecoder, Uint8Array, DataView, ArrayBuffer, JSON, Math, Object,
+    Promise, Set, Map, WeakMap, Number, String, Date, parseInt, Error,
+});
+const script = document.querySelectorAll('script').pop();
+vm.runInContext(script.textContent, context);
+socket.onopen();
+
+for (const step of input.steps) {
+    if (step.frame) {
+        const bytes = Buffer.from(step.frame, 'base64');
+        socket.onmessage({ data: bytes.buffer.slice(bytes.byteOffset, bytes.byteOffset + bytes.length) });
+    } else if (step.dispatch) {
+        const target = document.querySelector(step.dispatch.selector);
+        const event = { type: step.dispatch.type, target, preventDefault() {} };
+        (document.listeners[event.type] || []).forEach(fn => fn(event));
+    } else if (step.popstate) {
+        moveTo(step.popstate);
+        (windowListeners.popstate || []).forEach(fn => fn({}));
+    }
+}
+
+process.stdout.write(JSON.stringify({ dom: serialize(document.querySelector('#app')), sent, visits }));
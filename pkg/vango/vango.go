@@ -43,6 +43,8 @@ type Context struct {
 	Mode      RenderMode // Rendering mode for this component
 	SessionID string     // Session ID for server-driven mode
 	Data      map[string]interface{} // Additional context data
+
+	owner *reactive.Owner // of the render the context was made for
 }
 
 // Event represents a DOM event
@@ -55,6 +57,23 @@ type Event struct {
 	}
 	Key     string
 	KeyCode int
+
+	// Pointer position and button for mouse/pointer events
+	ClientX float64
+	ClientY float64
+	Button  int
+
+	// Modifier keys held during the event
+	AltKey   bool
+	CtrlKey  bool
+	MetaKey  bool
+	ShiftKey bool
+
+	// Form holds the submitted fields for submit events
+	Form map[string]string
+
+	// Data holds every payload field as sent, including those mapped above
+	Data map[string]interface{}
 }
 
 // Router handles routing
@@ -100,23 +119,161 @@ func (app *App) Run() error {
 	return nil
 }
 
-// State creates a new reactive state
-func State[T any](initial T) *reactive.State[T] {
-	// Get scheduler from context (in real implementation)
-	return reactive.CreateState(initial)
+// State creates a new reactive state. Fibers reading it are marked dirty
+// on their own scheduler, so a state can be shared between components and
+// sessions. Components read and update states with their render's
+// context: count.Get(ctx) subscribes the component to count.
+func State[T any](ctx *Context, initial T) *reactive.State[T] {
+	return reactive.CreateState(ctx, initial)
+}
+
+// Computed creates a new computed value. compute reads through the owner
+// it gets, not ctx, so the computed tracks its own sources.
+func Computed[T any](ctx *Context, compute func(o *reactive.Owner) T) *reactive.Computed[T] {
+	return reactive.CreateComputed(ctx, compute)
+}
+
+// Store creates a new store of structured state. Components read parts of
+// it with reactive.Select and re-render only when those change.
+func Store[T any](ctx *Context, initial T) *reactive.Store[T] {
+	return reactive.CreateStore(ctx, initial)
+}
+
+// Resource creates a value loaded asynchronously for the key key returns,
+// reloaded when the key changes (see reactive.CreateResource). Read it
+// inside Suspense to show a fallback while it loads; in static SSR it is
+// loaded before the page renders.
+func Resource[K comparable, T any](ctx *Context, key func(scope reactive.Scope) K, fetcher *reactive.Fetcher[K, T]) *reactive.Resource[K, T] {
+	return reactive.CreateResource(ctx, key, fetcher)
+}
+
+// Effect runs fn and runs it again whenever a state or computed it reads
+// through its owner changes (see reactive.Effect). Called during render,
+// the effect belongs to the component and stops when it is removed; in
+// static SSR fn runs once.
+func Effect(ctx *Context, fn func(o *reactive.Owner)) (stop func()) {
+	return reactive.Effect(ctx, fn)
+}
+
+// OnMount runs fn once the component's first render is committed. Call it
+// during render; it does nothing in static SSR.
+func OnMount(ctx *Context, fn func()) {
+	reactive.OnMount(ctx, fn)
+}
+
+// OnCleanup runs fn when the component is removed. It does nothing in
+// static SSR; inside an effect, call reactive.OnCleanup with the effect's
+// owner instead.
+func OnCleanup(ctx *Context, fn func()) {
+	reactive.OnCleanup(ctx, fn)
+}
+
+// Static runs fn as a static render pass: reads through the owner it gets
+// are not tracked, effects run once, and OnMount and OnCleanup do
+// nothing
+func Static(fn func(o *reactive.Owner)) {
+	reactive.Static(fn)
+}
+
+// Batch runs multiple state updates as one transaction: the updates fn
+// makes through the owner it gets mark the fibers affected dirty once the
+// outermost Batch returns, so each renders once with every update, and
+// effects run then. If fn panics, the states it changed get their
+// previous values back before the panic continues. scope may be nil, or
+// the owner of a batch in progress to join it. See reactive.RunBatch.
+func Batch(scope reactive.Scope, fn func(o *reactive.Owner)) {
+	reactive.RunBatch(scope, nil, fn)
+}
+
+// ErrorBoundary renders children, or fallback with the error if rendering
+// them panics. In client and server-driven mode it also catches panics of
+// fibers created below it, re-rendering ctx's fiber with the fallback.
+// ctx may be nil, as in static SSR.
+func ErrorBoundary(ctx *Context, fallback func(err error) *vdom.VNode, children func() *vdom.VNode) *vdom.VNode {
+	return scheduler.ErrorBoundary(ctx.fiber(), fallback, children)
+}
+
+// Suspense renders children, or fallback while they wait on an async
+// resource (see Suspend). In client and server-driven mode ctx's fiber
+// renders the children again once the resource is ready. ctx may be nil,
+// as in static SSR, where the fallback is rendered.
+func Suspense(ctx *Context, fallback *vdom.VNode, children func() *vdom.VNode) *vdom.VNode {
+	return scheduler.Suspense(ctx.fiber(), fallback, children)
+}
+
+// Suspend stops the render in progress until ready is closed, showing the
+// fallback of the nearest Suspense boundary meanwhile
+func Suspend(ready <-chan struct{}) {
+	scheduler.Suspend(ready)
 }
 
-// Computed creates a new computed value
-func Computed[T any](compute func() T) *reactive.Computed[T] {
-	// Get scheduler from context (in real implementation)
-	return reactive.CreateComputed(compute)
+// Child creates a node rendering component with props. In client and
+// server-driven mode the component gets its own fiber below the fiber that
+// renders the node: it renders again when its props change or when its own
+// fiber is marked dirty, and keeps its last output otherwise. Components
+// are compared with ==, so create them once, for example in package-level
+// variables; a different component value mounts a new instance. Outside a
+// scheduler, as in static SSR, RenderComponents renders them.
+func Child(component Component, props Props) *vdom.VNode {
+	return vdom.NewComponent(component, props)
 }
 
-// Batch runs multiple state updates in a batch
-func Batch(fn func()) {
-	// Get scheduler from context (in real implementation)
-	// For now, just run the function
-	fn()
+// RenderComponents renders the component nodes of a tree built outside a
+// scheduler, as in static SSR, with contexts derived from ctx. Call it
+// before rendering the tree to HTML. ctx may be nil.
+func RenderComponents(ctx *Context, node *vdom.VNode) {
+	vdom.Expand(staticHost{ctx: ctx}, node)
+}
+
+// staticHost renders component nodes once, without fibers
+type staticHost struct {
+	ctx *Context
+}
+
+func (h staticHost) RenderComponent(node, owner *vdom.VNode) *vdom.VNode {
+	component, ok := node.Component.(Component)
+	if !ok {
+		return nil
+	}
+	ctx := h.ctx.child(ModeSSRStatic)
+	ctx.Props = node.Props
+	var output *vdom.VNode
+	reactive.Static(func(o *reactive.Owner) {
+		ctx.owner = o
+		output = component.Render(ctx)
+	})
+	return output
+}
+
+func (h staticHost) AttachComponent(node *vdom.VNode, parentID uint32) {}
+
+func (h staticHost) UnmountComponent(node *vdom.VNode) {}
+
+func init() {
+	scheduler.SetComponentRenderer(renderComponent)
+}
+
+// renderComponent renders the component of a component node for its fiber.
+// The fiber keeps a context derived from its parent fiber's, and sees the
+// node's current props.
+func renderComponent(fiber *scheduler.Fiber, component any, props vdom.Props) *vdom.VNode {
+	c, ok := component.(Component)
+	if !ok {
+		return nil
+	}
+	ctx, _ := fiber.GetUserData().(*Context)
+	if ctx == nil {
+		var parent *Context
+		if p := fiber.Parent(); p != nil {
+			parent, _ = p.GetUserData().(*Context)
+		}
+		ctx = parent.child(ModeClient)
+		ctx.Fiber = fiber
+		ctx.Scheduler = fiber.Scheduler()
+		fiber.SetUserData(ctx)
+	}
+	ctx.Props = props
+	return c.Render(ctx.ForRender())
 }
 
 // Element shortcuts for common HTML elements
@@ -227,6 +384,52 @@ func NewContext(mode RenderMode) *Context {
 	}
 }
 
+// fiber returns the context's fiber; nil contexts have none
+func (c *Context) fiber() *scheduler.Fiber {
+	if c == nil {
+		return nil
+	}
+	return c.Fiber
+}
+
+// child returns the context of a component rendered below c, sharing its
+// mode, session, route and data. Without c the child gets mode.
+func (c *Context) child(mode RenderMode) *Context {
+	if c == nil {
+		return NewContext(mode)
+	}
+	return &Context{
+		Props:     make(map[string]interface{}),
+		Params:    c.Params,
+		Query:     c.Query,
+		Scheduler: c.Scheduler,
+		Mode:      c.Mode,
+		SessionID: c.SessionID,
+		Data:      c.Data,
+	}
+}
+
+// Owner returns the owner of the render the context was made for, so a
+// Context is a reactive.Scope: reads through it subscribe the component,
+// and hooks such as State and Effect belong to it. Contexts kept past
+// their render, as by event handlers, read without tracking.
+func (c *Context) Owner() *reactive.Owner {
+	if c == nil {
+		return nil
+	}
+	return c.owner
+}
+
+// ForRender returns the context a render of c's fiber in progress passes
+// to its component: a copy of c holding the render's owner. Renders of
+// fibers created by hand, such as a live session's root components, call
+// it from the render.
+func (c *Context) ForRender() *Context {
+	rc := *c
+	rc.owner = reactive.OwnerOf(c.Fiber)
+	return &rc
+}
+
 // WithScheduler sets the scheduler for this context
 func (c *Context) WithScheduler(s *scheduler.Scheduler) *Context {
 	c.Scheduler = s
@@ -0,0 +1,146 @@
+package vdom
+
+import "reflect"
+
+// ComponentHost renders the component nodes of the trees diffed with
+// DiffWithHost and keeps an instance per mounted component node, such as a
+// fiber, in the node's Instance. A component's output is the node's only
+// kid; its DOM nodes go into the DOM parent of the component node, which
+// has no DOM node of its own.
+type ComponentHost interface {
+	// RenderComponent renders node, a component node that is new or whose
+	// props changed, and returns its output. A new node has no Instance;
+	// the host sets it. owner is the component node whose output contains
+	// node, or nil at the top of the tree.
+	RenderComponent(node, owner *VNode) *VNode
+
+	// AttachComponent tells the instance of a mounted component node that
+	// node now stands for it, with its output below the DOM node parentID.
+	// It is called after every diff that keeps or mounts the node.
+	AttachComponent(node *VNode, parentID uint32)
+
+	// UnmountComponent releases the instance of a component node that left
+	// the tree. Components inside its output are unmounted first.
+	UnmountComponent(node *VNode)
+}
+
+// Expand renders the component nodes of a tree that have no instance yet,
+// and those in their output, with host. Use it to render components where
+// no differ runs, as in static SSR.
+func Expand(host ComponentHost, node *VNode) {
+	if node != nil {
+		expand(host, node, nil)
+	}
+}
+
+// DiffWithHost is DiffWithIDs with the component nodes of next rendered by
+// host. A component node matching one of prev with the same Component and
+// equal props keeps the output it has; otherwise host renders it again and
+// its output is diffed against the previous one.
+func DiffWithHost(ids *IDAllocator, host ComponentHost, prev, next *VNode) []Patch {
+	ctx := newDiffContext(ids)
+	ctx.host = host
+	diffNode(ctx, prev, next, 0, 0, NamespaceHTML)
+	return ctx.patches
+}
+
+// DiffComponent computes the patches that replace the output of a mounted
+// component node with output, which becomes the node's kid. parentID is
+// the DOM parent given to AttachComponent. Hosts use it when a component
+// renders again on its own.
+func DiffComponent(ids *IDAllocator, host ComponentHost, node, output *VNode, parentID uint32) []Patch {
+	ctx := newDiffContext(ids)
+	ctx.host = host
+	prevKids := node.Kids
+	node.Kids = nil
+	if output != nil {
+		node.Kids = []VNode{*output}
+	}
+	diffOutput(ctx, prevKids, node, parentID, 0, node.Namespace)
+	return ctx.patches
+}
+
+// diffOutput diffs the output of component node next against prevKids,
+// the output of the node it replaces. The output goes before the DOM node
+// beforeID, or last if it is 0.
+func diffOutput(ctx *DiffContext, prevKids []VNode, next *VNode, parentID, beforeID uint32, ns string) {
+	owner := ctx.owner
+	ctx.owner = next
+	diffChildren(ctx, parentID, beforeID, ns, prevKids, next.Kids)
+	ctx.owner = owner
+}
+
+// expand renders the component nodes of a new subtree
+func expand(host ComponentHost, node, owner *VNode) {
+	if node.Kind == KindComponent && node.Instance == nil {
+		node.Kids = nil
+		if output := host.RenderComponent(node, owner); output != nil {
+			node.Kids = []VNode{*output}
+		}
+	}
+	if node.Kind == KindComponent {
+		owner = node
+	}
+	for i := range node.Kids {
+		expand(host, &node.Kids[i], owner)
+	}
+}
+
+// attach attaches the component nodes of a mounted subtree whose DOM
+// parent is parentID
+func attach(host ComponentHost, node *VNode, parentID uint32) {
+	if node.Kind == KindComponent {
+		host.AttachComponent(node, parentID)
+	} else {
+		parentID = node.ID
+	}
+	for i := range node.Kids {
+		attach(host, &node.Kids[i], parentID)
+	}
+}
+
+// unmount unmounts the component nodes of a removed subtree, innermost
+// first
+func unmount(host ComponentHost, node *VNode) {
+	for i := range node.Kids {
+		unmount(host, &node.Kids[i])
+	}
+	if node.Kind == KindComponent && node.Instance != nil {
+		host.UnmountComponent(node)
+	}
+}
+
+// sameComponent reports whether two component nodes render the same
+// component. Components compare like handlers, so a component created anew
+// on every render, such as a closure, never matches.
+func sameComponent(a, b any) bool {
+	return handlerEqual(a, b)
+}
+
+// componentPropsEqual reports whether the props of a component are
+// unchanged. Values of the same comparable type compare with ==, so
+// pointers compare by identity; functions always count as changed; other
+// values compare like attributes.
+func componentPropsEqual(a, b Props) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, av := range a {
+		bv, ok := b[key]
+		if !ok {
+			return false
+		}
+		ta, tb := reflect.TypeOf(av), reflect.TypeOf(bv)
+		switch {
+		case ta != nil && ta.Kind() == reflect.Func, tb != nil && tb.Kind() == reflect.Func:
+			return false
+		case ta != nil && ta == tb && ta.Comparable():
+			if av != bv {
+				return false
+			}
+		case !propsEqual(av, bv):
+			return false
+		}
+	}
+	return true
+}
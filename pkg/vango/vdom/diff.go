@@ -2,6 +2,11 @@ package vdom
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 )
 
 // PatchOp represents the type of patch operation
@@ -16,24 +21,30 @@ const (
 	OpRemoveNode PatchOp = 0x03
 	// OpInsertNode inserts a new node
 	OpInsertNode PatchOp = 0x04
-	// OpUpdateEvents updates event subscriptions
+	// OpUpdateEvents replaces the set of events a node listens to
 	OpUpdateEvents PatchOp = 0x05
 	// OpRemoveAttribute removes an attribute
 	OpRemoveAttribute PatchOp = 0x06
 	// OpMoveNode moves a node to a new position
 	OpMoveNode PatchOp = 0x07
+	// OpSetHandler swaps the handler of an event prop. It changes no DOM:
+	// appliers point the existing listener at the new handler.
+	OpSetHandler PatchOp = 0x08
+	// OpSetHTML replaces the inner HTML of a raw HTML element
+	OpSetHTML PatchOp = 0x09
 )
 
 // Patch represents a single DOM mutation
 type Patch struct {
-	Op        PatchOp
-	NodeID    uint32
-	ParentID  uint32 // For insert operations
-	BeforeID  uint32 // For insert operations (0 means append)
-	Key       string // Attribute key for set/remove attribute
-	Value     string // Text content or attribute value
-	Node      *VNode // For insert operations
-	EventBits uint32 // For event updates
+	Op       PatchOp
+	NodeID   uint32
+	ParentID uint32          // For insert operations
+	BeforeID uint32          // For insert operations (0 means append)
+	Key      string          // Attribute key for set/remove attribute
+	Value    string          // Text content, inner HTML or attribute value
+	Node     *VNode          // For insert operations
+	Events   []EventListener // For event updates: every event the node listens to
+	Handler  any             // For handler swaps
 }
 
 // String returns a human-readable representation of the patch
@@ -50,42 +61,126 @@ func (p Patch) String() string {
 	case OpInsertNode:
 		return fmt.Sprintf("InsertNode(parent=%d, before=%d)", p.ParentID, p.BeforeID)
 	case OpUpdateEvents:
-		return fmt.Sprintf("UpdateEvents(node=%d, bits=%x)", p.NodeID, p.EventBits)
+		return fmt.Sprintf("UpdateEvents(node=%d, events=%v)", p.NodeID, p.Events)
 	case OpMoveNode:
 		return fmt.Sprintf("MoveNode(node=%d, parent=%d, before=%d)", p.NodeID, p.ParentID, p.BeforeID)
+	case OpSetHandler:
+		return fmt.Sprintf("SetHandler(node=%d, key=%q)", p.NodeID, p.Key)
+	case OpSetHTML:
+		return fmt.Sprintf("SetHTML(node=%d, html=%q)", p.NodeID, p.Value)
 	default:
 		return fmt.Sprintf("Unknown(op=%d)", p.Op)
 	}
 }
 
+// IDAllocator hands out node IDs. Trees patched into the same DOM, like
+// the components of a live session, must share one so their IDs never
+// collide. The zero value starts at 1 and it is safe for concurrent use.
+type IDAllocator struct {
+	last atomic.Uint32
+}
+
+// NewIDAllocator creates an allocator whose first ID is 1
+func NewIDAllocator() *IDAllocator {
+	return &IDAllocator{}
+}
+
+// Reserve reserves n consecutive IDs and returns the first
+func (a *IDAllocator) Reserve(n uint32) uint32 {
+	return a.last.Add(n) - n + 1
+}
+
+// Mount assigns IDs to every node of a tree in pre-order, the order in which
+// appliers and the live codec materialise a subtree, from one contiguous
+// range. Existing IDs are replaced. Element namespaces are resolved on the
+// way, with the root in HTML content. It returns the root's ID.
+func Mount(ids *IDAllocator, node *VNode) uint32 {
+	return mount(ids, node, NamespaceHTML)
+}
+
+// mount is Mount for a subtree whose parent passes down namespace ns
+func mount(ids *IDAllocator, node *VNode, ns string) uint32 {
+	if node == nil {
+		return 0
+	}
+	next := ids.Reserve(countNodes(node))
+	first := next
+	assignIDs(node, &next, ns)
+	return first
+}
+
+// countNodes counts the nodes of a tree
+func countNodes(node *VNode) uint32 {
+	n := uint32(1)
+	for i := range node.Kids {
+		n += countNodes(&node.Kids[i])
+	}
+	return n
+}
+
+// assignIDs numbers a tree in pre-order starting at *next and resolves the
+// namespaces of its elements, the root inheriting ns
+func assignIDs(node *VNode, next *uint32, ns string) {
+	node.ID = *next
+	*next++
+	if node.Flags&FlagStatic != 0 {
+		unshare(node)
+	}
+	resolveNamespace(node, ns)
+	ns = kidsNamespace(node, ns)
+	for i := range node.Kids {
+		assignIDs(&node.Kids[i], next, ns)
+	}
+}
+
+// unshare gives a node private copies of its descendants. Static subtrees
+// may be shared between trees, and IDs are written into the nodes.
+func unshare(node *VNode) {
+	if len(node.Kids) == 0 {
+		return
+	}
+	node.Kids = append([]VNode(nil), node.Kids...)
+	for i := range node.Kids {
+		unshare(&node.Kids[i])
+	}
+}
+
+// maxID returns the highest ID in a tree
+func maxID(node *VNode) uint32 {
+	id := node.ID
+	for i := range node.Kids {
+		if kid := maxID(&node.Kids[i]); kid > id {
+			id = kid
+		}
+	}
+	return id
+}
+
 // DiffContext holds state during diffing
 type DiffContext struct {
-	patches     []Patch
-	nodeCounter uint32
-	nodeMap     map[*VNode]uint32
+	patches []Patch
+	ids     *IDAllocator
+	host    ComponentHost // renders component nodes; nil leaves them as built
+	owner   *VNode        // component node whose output is being diffed
 }
 
 // newDiffContext creates a new diff context
-func newDiffContext() *DiffContext {
+func newDiffContext(ids *IDAllocator) *DiffContext {
 	return &DiffContext{
-		patches:     make([]Patch, 0, 16),
-		nodeCounter: 1,
-		nodeMap:     make(map[*VNode]uint32),
+		patches: make([]Patch, 0, 16),
+		ids:     ids,
 	}
 }
 
-// getNodeID gets or assigns a node ID
+// getNodeID returns a node's ID, assigning one if it was never mounted
 func (ctx *DiffContext) getNodeID(node *VNode) uint32 {
 	if node == nil {
 		return 0
 	}
-	if id, ok := ctx.nodeMap[node]; ok {
-		return id
+	if node.ID == 0 {
+		node.ID = ctx.ids.Reserve(1)
 	}
-	id := ctx.nodeCounter
-	ctx.nodeCounter++
-	ctx.nodeMap[node] = id
-	return id
+	return node.ID
 }
 
 // addPatch adds a patch to the context
@@ -93,15 +188,64 @@ func (ctx *DiffContext) addPatch(patch Patch) {
 	ctx.patches = append(ctx.patches, patch)
 }
 
-// Diff computes the patches needed to transform prev into next
+// mount mounts a new subtree below the DOM node parentID, rendering its
+// components first, and returns its root's ID
+func (ctx *DiffContext) mount(node *VNode, ns string, parentID uint32) uint32 {
+	if ctx.host != nil {
+		expand(ctx.host, node, ctx.owner)
+	}
+	id := mount(ctx.ids, node, ns)
+	if ctx.host != nil {
+		attach(ctx.host, node, parentID)
+	}
+	return id
+}
+
+// remove removes a mounted subtree and unmounts its components. A
+// component node is removed through the root of its output.
+func (ctx *DiffContext) remove(node *VNode) {
+	target := node
+	for target.Kind == KindComponent && len(target.Kids) > 0 {
+		target = &target.Kids[0]
+	}
+	if target.Kind != KindComponent {
+		ctx.addPatch(Patch{
+			Op:     OpRemoveNode,
+			NodeID: ctx.getNodeID(target),
+		})
+	}
+	if ctx.host != nil {
+		unmount(ctx.host, node)
+	}
+}
+
+// Diff computes the patches needed to transform prev into next. Nodes of
+// next that match a node of prev take over its ID; inserted subtrees are
+// mounted with IDs above every ID in prev. prev is mounted first if it has
+// no IDs yet. Use DiffWithIDs when several trees share one DOM.
 func Diff(prev, next *VNode) []Patch {
-	ctx := newDiffContext()
-	diffNode(ctx, prev, next, 0)
+	ids := NewIDAllocator()
+	if prev != nil {
+		if prev.ID == 0 {
+			Mount(ids, prev)
+		} else {
+			ids.last.Store(maxID(prev))
+		}
+	}
+	return DiffWithIDs(ids, prev, next)
+}
+
+// DiffWithIDs is Diff with inserted subtrees mounted from ids
+func DiffWithIDs(ids *IDAllocator, prev, next *VNode) []Patch {
+	ctx := newDiffContext(ids)
+	diffNode(ctx, prev, next, 0, 0, NamespaceHTML)
 	return ctx.patches
 }
 
-// diffNode recursively diffs two nodes
-func diffNode(ctx *DiffContext, prev, next *VNode, parentID uint32) {
+// diffNode recursively diffs two nodes. An inserted or replacing node goes
+// before the DOM node beforeID, or last if it is 0. ns is the namespace
+// next inherits from its parent.
+func diffNode(ctx *DiffContext, prev, next *VNode, parentID, beforeID uint32, ns string) {
 	// Both nil - nothing to do
 	if prev == nil && next == nil {
 		return
@@ -109,47 +253,55 @@ func diffNode(ctx *DiffContext, prev, next *VNode, parentID uint32) {
 
 	// Node removed
 	if prev != nil && next == nil {
-		nodeID := ctx.getNodeID(prev)
-		ctx.addPatch(Patch{
-			Op:     OpRemoveNode,
-			NodeID: nodeID,
-		})
+		ctx.remove(prev)
 		return
 	}
 
 	// Node added
 	if prev == nil && next != nil {
-		nodeID := ctx.getNodeID(next)
+		nodeID := ctx.mount(next, ns, parentID)
 		ctx.addPatch(Patch{
 			Op:       OpInsertNode,
 			NodeID:   nodeID,
 			ParentID: parentID,
+			BeforeID: beforeID,
 			Node:     next,
 		})
 		return
 	}
 
 	// Different node types - replace
-	if prev.Kind != next.Kind || (prev.Kind == KindElement && prev.Tag != next.Tag) {
-		nodeID := ctx.getNodeID(prev)
-		ctx.addPatch(Patch{
-			Op:     OpRemoveNode,
-			NodeID: nodeID,
-		})
-		nodeID = ctx.getNodeID(next)
+	resolveNamespace(prev, ns)
+	resolveNamespace(next, ns)
+	if prev.Kind != next.Kind || (prev.Kind != KindPortal && !sameNode(prev, next)) {
+		ctx.remove(prev)
+		nodeID := ctx.mount(next, ns, parentID)
 		ctx.addPatch(Patch{
 			Op:       OpInsertNode,
 			NodeID:   nodeID,
 			ParentID: parentID,
+			BeforeID: beforeID,
 			Node:     next,
 		})
 		return
 	}
 
+	// The next node is the same DOM node
 	nodeID := ctx.getNodeID(prev)
 
-	// Update node ID mapping for next node
-	ctx.nodeMap[next] = nodeID
+	// A static subtree replacing itself keeps the mounted one, IDs included
+	if prev.Flags&next.Flags&FlagStatic != 0 && next.Flags&FlagDirty == 0 && depsEqual(prev.Deps, next.Deps) {
+		*next = *prev
+		if next.Kind == KindComponent && ctx.host != nil {
+			ctx.host.AttachComponent(next, parentID)
+		}
+		return
+	}
+	if next.Flags&FlagStatic != 0 {
+		unshare(next)
+	}
+	next.ID = nodeID
+	kidsNS := kidsNamespace(next, ns)
 
 	// Diff based on node type
 	switch prev.Kind {
@@ -167,21 +319,50 @@ func diffNode(ctx *DiffContext, prev, next *VNode, parentID uint32) {
 		diffProps(ctx, nodeID, prev.Props, next.Props)
 
 		// Diff children
-		diffChildren(ctx, nodeID, prev.Kids, next.Kids)
+		diffChildren(ctx, nodeID, 0, kidsNS, prev.Kids, next.Kids)
+
+	case KindRaw:
+		// Raw HTML elements have props and an HTML string instead of children
+		diffProps(ctx, nodeID, prev.Props, next.Props)
+		if prev.Text != next.Text {
+			ctx.addPatch(Patch{
+				Op:     OpSetHTML,
+				NodeID: nodeID,
+				Value:  next.Text,
+			})
+		}
 
 	case KindFragment:
 		// Fragment only has children
-		diffChildren(ctx, nodeID, prev.Kids, next.Kids)
+		diffChildren(ctx, nodeID, 0, kidsNS, prev.Kids, next.Kids)
+
+	case KindComponent:
+		// The instance carries over and its output stays put unless the
+		// props changed; the output's DOM nodes belong to our DOM parent
+		if next.Instance == nil {
+			next.Instance = prev.Instance
+		}
+		if ctx.host == nil {
+			diffOutput(ctx, prev.Kids, next, parentID, beforeID, kidsNS)
+			break
+		}
+		if componentPropsEqual(prev.Props, next.Props) {
+			next.Kids = prev.Kids
+		} else {
+			next.Kids = nil
+			if output := ctx.host.RenderComponent(next, ctx.owner); output != nil {
+				next.Kids = []VNode{*output}
+			}
+			diffOutput(ctx, prev.Kids, next, parentID, beforeID, kidsNS)
+		}
+		ctx.host.AttachComponent(next, parentID)
 
 	case KindPortal:
 		// Portal has target and children
 		if prev.PortalTarget != next.PortalTarget {
 			// Portal target changed - need to re-render
-			ctx.addPatch(Patch{
-				Op:     OpRemoveNode,
-				NodeID: nodeID,
-			})
-			nodeID = ctx.getNodeID(next)
+			ctx.remove(prev)
+			nodeID = ctx.mount(next, ns, parentID)
 			ctx.addPatch(Patch{
 				Op:       OpInsertNode,
 				NodeID:   nodeID,
@@ -189,104 +370,95 @@ func diffNode(ctx *DiffContext, prev, next *VNode, parentID uint32) {
 				Node:     next,
 			})
 		} else {
-			diffChildren(ctx, nodeID, prev.Kids, next.Kids)
+			diffChildren(ctx, nodeID, 0, kidsNS, prev.Kids, next.Kids)
 		}
 	}
 }
 
-// diffProps diffs properties/attributes
+// diffProps diffs properties/attributes. Event props never become
+// attributes: a changed handler is swapped with SetHandler, and a change in
+// the events listened to, or their options, sends the full listener set in
+// UpdateEvents.
 func diffProps(ctx *DiffContext, nodeID uint32, prevProps, nextProps Props) {
-	// Track event changes
-	var prevEvents, nextEvents uint32
-
 	// Remove props that are no longer present
-	if prevProps != nil {
-		for key, prevVal := range prevProps {
-			if key == "key" || key == "ref" { // skip special props
-				continue // Skip key property
-			}
-
-			// Track event listeners
-			if isEventProp(key) {
-				prevEvents |= getEventBit(key)
-			}
+	for key, prevVal := range prevProps {
+		if key == "key" || key == "ref" { // skip special props
+			continue
+		}
 
-			nextVal, exists := nextProps[key]
-			if !exists {
-				if isEventProp(key) {
-					// Event removed - will be handled by event update
-				} else {
-					ctx.addPatch(Patch{
-						Op:     OpRemoveAttribute,
-						NodeID: nodeID,
-						Key:    key,
-					})
-				}
-			} else if !propsEqual(prevVal, nextVal) {
-				if isEventProp(key) {
-					// Event handler changed - still need to track it
-					nextEvents |= getEventBit(key)
-				} else {
-					ctx.addPatch(Patch{
-						Op:     OpSetAttribute,
-						NodeID: nodeID,
-						Key:    key,
-						Value:  propToString(nextVal),
-					})
-				}
-			} else if isEventProp(key) {
-				// Event unchanged
-				nextEvents |= getEventBit(key)
+		nextVal, exists := nextProps[key]
+		switch {
+		case isEventProp(key):
+			// The listener stays; only the handler it calls may change
+			prevHandler, _ := ListenerOf(prevVal)
+			nextHandler, _ := ListenerOf(nextVal)
+			if exists && prevVal != nil && nextVal != nil && !handlerEqual(prevHandler, nextHandler) {
+				ctx.addPatch(Patch{
+					Op:      OpSetHandler,
+					NodeID:  nodeID,
+					Key:     key,
+					Handler: nextHandler,
+				})
 			}
+		case !exists:
+			ctx.addPatch(Patch{
+				Op:     OpRemoveAttribute,
+				NodeID: nodeID,
+				Key:    key,
+			})
+		case !propsEqual(prevVal, nextVal):
+			ctx.addPatch(Patch{
+				Op:     OpSetAttribute,
+				NodeID: nodeID,
+				Key:    key,
+				Value:  PropString(nextVal),
+			})
 		}
 	}
 
 	// Add new props
-	if nextProps != nil {
-		for key, nextVal := range nextProps {
-			if key == "key" || key == "ref" { // skip special props
-				continue // Skip key property
-			}
-
-			// Track event listeners (only if not already tracked above)
-			if isEventProp(key) && (prevProps == nil || prevProps[key] == nil) {
-				nextEvents |= getEventBit(key)
-			}
+	for key, nextVal := range nextProps {
+		if key == "key" || key == "ref" { // skip special props
+			continue
+		}
+		if prevVal, exists := prevProps[key]; exists && (prevVal != nil || !isEventProp(key)) {
+			continue
+		}
 
-			if prevProps == nil {
-				if !isEventProp(key) {
-					ctx.addPatch(Patch{
-						Op:     OpSetAttribute,
-						NodeID: nodeID,
-						Key:    key,
-						Value:  propToString(nextVal),
-					})
-				}
-			} else if _, exists := prevProps[key]; !exists {
-				if !isEventProp(key) {
-					ctx.addPatch(Patch{
-						Op:     OpSetAttribute,
-						NodeID: nodeID,
-						Key:    key,
-						Value:  propToString(nextVal),
-					})
-				}
+		if isEventProp(key) {
+			// New listeners get their handler
+			if nextVal != nil {
+				handler, _ := ListenerOf(nextVal)
+				ctx.addPatch(Patch{
+					Op:      OpSetHandler,
+					NodeID:  nodeID,
+					Key:     key,
+					Handler: handler,
+				})
 			}
+		} else {
+			ctx.addPatch(Patch{
+				Op:     OpSetAttribute,
+				NodeID: nodeID,
+				Key:    key,
+				Value:  PropString(nextVal),
+			})
 		}
 	}
 
 	// Update events if changed
-	if prevEvents != nextEvents {
+	if prevEvents, nextEvents := Listeners(prevProps), Listeners(nextProps); !listenersEqual(prevEvents, nextEvents) {
 		ctx.addPatch(Patch{
-			Op:        OpUpdateEvents,
-			NodeID:    nodeID,
-			EventBits: nextEvents,
+			Op:     OpUpdateEvents,
+			NodeID: nodeID,
+			Events: nextEvents,
 		})
 	}
 }
 
-// diffChildren diffs child nodes with keyed and unkeyed reconciliation
-func diffChildren(ctx *DiffContext, parentID uint32, prevKids, nextKids []VNode) {
+// diffChildren diffs child nodes with keyed and unkeyed reconciliation.
+// The children end before the DOM node beforeID, or last if it is 0.
+func diffChildren(ctx *DiffContext, parentID, beforeID uint32, ns string, prevKids, nextKids []VNode) {
 	// Fast path: no children
 	if len(prevKids) == 0 && len(nextKids) == 0 {
 		return
@@ -295,7 +467,7 @@ func diffChildren(ctx *DiffContext, parentID uint32, prevKids, nextKids []VNode)
 	// Fast path: all children removed
 	if len(nextKids) == 0 {
 		for i := range prevKids {
-			diffNode(ctx, &prevKids[i], nil, parentID)
+			diffNode(ctx, &prevKids[i], nil, parentID, 0, ns)
 		}
 		return
 	}
@@ -303,7 +475,7 @@ func diffChildren(ctx *DiffContext, parentID uint32, prevKids, nextKids []VNode)
 	// Fast path: all children added
 	if len(prevKids) == 0 {
 		for i := range nextKids {
-			diffNode(ctx, nil, &nextKids[i], parentID)
+			diffNode(ctx, nil, &nextKids[i], parentID, beforeID, ns)
 		}
 		return
 	}
@@ -318,14 +490,15 @@ func diffChildren(ctx *DiffContext, parentID uint32, prevKids, nextKids []VNode)
 	}
 
 	if hasKeys {
-		diffKeyedChildren(ctx, parentID, prevKids, nextKids)
+		diffKeyedChildren(ctx, parentID, beforeID, ns, prevKids, nextKids)
 	} else {
-		diffUnkeyedChildren(ctx, parentID, prevKids, nextKids)
+		diffUnkeyedChildren(ctx, parentID, beforeID, ns, prevKids, nextKids)
 	}
 }
 
-// diffUnkeyedChildren performs simple index-based diffing
-func diffUnkeyedChildren(ctx *DiffContext, parentID uint32, prevKids, nextKids []VNode) {
+// diffUnkeyedChildren performs simple index-based diffing. A child that
+// replaces another goes before the next old child.
+func diffUnkeyedChildren(ctx *DiffContext, parentID, beforeID uint32, ns string, prevKids, nextKids []VNode) {
 	minLen := len(prevKids)
 	if len(nextKids) < minLen {
 		minLen = len(nextKids)
@@ -333,108 +506,192 @@ func diffUnkeyedChildren(ctx *DiffContext, parentID uint32, prevKids, nextKids [
 
 	// Diff common children
 	for i := 0; i < minLen; i++ {
-		diffNode(ctx, &prevKids[i], &nextKids[i], parentID)
+		diffNode(ctx, &prevKids[i], &nextKids[i], parentID, followingID(prevKids[i+1:], beforeID), ns)
 	}
 
 	// Remove extra old children
 	for i := minLen; i < len(prevKids); i++ {
-		diffNode(ctx, &prevKids[i], nil, parentID)
+		diffNode(ctx, &prevKids[i], nil, parentID, 0, ns)
 	}
 
 	// Add extra new children
 	for i := minLen; i < len(nextKids); i++ {
-		diffNode(ctx, nil, &nextKids[i], parentID)
+		diffNode(ctx, nil, &nextKids[i], parentID, beforeID, ns)
 	}
 }
 
-// diffKeyedChildren performs keyed reconciliation for efficient list updates
-func diffKeyedChildren(ctx *DiffContext, parentID uint32, prevKids, nextKids []VNode) {
-	// Build maps of keyed children
-	prevKeyed := make(map[string]int)
-	nextKeyed := make(map[string]int)
-
-	for i, child := range prevKids {
-		if key := child.GetKey(); key != "" {
+// diffKeyedChildren performs keyed reconciliation for efficient list updates.
+// Children are matched by key (unkeyed ones by position), matched pairs are
+// diffed, and unmatched old children removed. Then the matched children
+// whose old positions form the longest increasing subsequence stay put and
+// only the others are moved, so moving one row of a long list costs one
+// move. Moves and inserts are emitted from the last child backwards, each
+// before the next child, whose ID is final by then, the last one before
+// beforeID.
+func diffKeyedChildren(ctx *DiffContext, parentID, beforeID uint32, ns string, prevKids, nextKids []VNode) {
+	// Build map of keyed old children
+	prevKeyed := make(map[string]int, len(prevKids))
+	for i := range prevKids {
+		if key := prevKids[i].GetKey(); key != "" {
 			prevKeyed[key] = i
 		}
 	}
 
-	for i, child := range nextKids {
-		if key := child.GetKey(); key != "" {
-			nextKeyed[key] = i
-		}
-	}
-
 	// Track which old children have been matched
 	matched := make([]bool, len(prevKids))
 
-	// Track nodes that need to be moved
-	var moves []struct {
-		nodeID   uint32
-		newIndex int
-	}
-
-	// Process new children
-	for nextIdx, nextChild := range nextKids {
-		key := nextChild.GetKey()
-
-		if key != "" {
-			// Keyed child - look for match in old children
-			if prevIdx, found := prevKeyed[key]; found {
-				// Found matching key
-				matched[prevIdx] = true
-
-				// Get the node ID before diffing (which updates the mapping)
-				nodeID := ctx.getNodeID(&prevKids[prevIdx])
-
-				// Diff the nodes
-				diffNode(ctx, &prevKids[prevIdx], &nextChild, parentID)
+	// sources[i] is the old index of nextKids[i], or -1 if it is new
+	sources := make([]int, len(nextKids))
 
-				// Check if node needs to be moved
-				if prevIdx != nextIdx {
-					moves = append(moves, struct {
-						nodeID   uint32
-						newIndex int
-					}{nodeID, nextIdx})
-				}
-			} else {
-				// New keyed child
-				diffNode(ctx, nil, &nextChild, parentID)
+	// Match and diff the new children
+	for nextIdx := range nextKids {
+		nextChild := &nextKids[nextIdx]
+		prevIdx := -1
+		if key := nextChild.GetKey(); key != "" {
+			if i, found := prevKeyed[key]; found && !matched[i] {
+				prevIdx = i
 			}
-		} else {
+		} else if nextIdx < len(prevKids) && prevKids[nextIdx].GetKey() == "" && !matched[nextIdx] {
 			// Unkeyed child - match by position
-			if nextIdx < len(prevKids) && prevKids[nextIdx].GetKey() == "" && !matched[nextIdx] {
-				matched[nextIdx] = true
-				diffNode(ctx, &prevKids[nextIdx], &nextChild, parentID)
-			} else {
-				// New unkeyed child
-				diffNode(ctx, nil, &nextChild, parentID)
-			}
+			prevIdx = nextIdx
+		}
+
+		// A match that cannot be patched in place is replaced
+		resolveNamespace(nextChild, ns)
+		if prevIdx >= 0 {
+			resolveNamespace(&prevKids[prevIdx], ns)
+		}
+		if prevIdx >= 0 && !sameNode(&prevKids[prevIdx], nextChild) {
+			prevIdx = -1
+		}
+
+		sources[nextIdx] = prevIdx
+		if prevIdx >= 0 {
+			matched[prevIdx] = true
+			diffNode(ctx, &prevKids[prevIdx], nextChild, parentID, followingID(prevKids[prevIdx+1:], beforeID), ns)
 		}
 	}
 
 	// Remove unmatched old children
 	for i, wasMatched := range matched {
 		if !wasMatched {
-			diffNode(ctx, &prevKids[i], nil, parentID)
+			diffNode(ctx, &prevKids[i], nil, parentID, 0, ns)
 		}
 	}
 
-	// Apply moves
-	for _, move := range moves {
-		var beforeID uint32
-		if move.newIndex+1 < len(nextKids) {
-			// Get the ID of the node that should come after this one
-			beforeID = ctx.getNodeID(&nextKids[move.newIndex+1])
+	// Place children, last first, keeping the longest run already in order
+	stable := longestIncreasingSubsequence(sources)
+	s := len(stable) - 1
+	for i := len(nextKids) - 1; i >= 0; i-- {
+		child := &nextKids[i]
+		switch {
+		case sources[i] < 0:
+			ctx.addPatch(Patch{
+				Op:       OpInsertNode,
+				NodeID:   ctx.mount(child, ns, parentID),
+				ParentID: parentID,
+				BeforeID: beforeID,
+				Node:     child,
+			})
+		case s >= 0 && stable[s] == i:
+			// Already in place relative to the stable children
+			s--
+		default:
+			if id := domID(child); id != 0 {
+				ctx.addPatch(Patch{
+					Op:       OpMoveNode,
+					NodeID:   id,
+					ParentID: parentID,
+					BeforeID: beforeID,
+				})
+			}
+		}
+		if id := domID(child); id != 0 {
+			beforeID = id
 		}
+	}
+}
 
-		ctx.addPatch(Patch{
-			Op:       OpMoveNode,
-			NodeID:   move.nodeID,
-			ParentID: parentID,
-			BeforeID: beforeID,
-		})
+// followingID returns the ID of the first DOM node among mounted siblings,
+// or beforeID if they have none
+func followingID(siblings []VNode, beforeID uint32) uint32 {
+	for i := range siblings {
+		if id := domID(&siblings[i]); id != 0 {
+			return id
+		}
+	}
+	return beforeID
+}
+
+// domID returns the ID of the DOM node standing for a mounted node: the
+// root of a component's output, or 0 for a component that rendered nothing
+func domID(node *VNode) uint32 {
+	for node.Kind == KindComponent {
+		if len(node.Kids) == 0 {
+			return 0
+		}
+		node = &node.Kids[0]
+	}
+	return node.ID
+}
+
+// sameNode reports whether next can be patched into prev's DOM node
+func sameNode(prev, next *VNode) bool {
+	if prev.Kind != next.Kind {
+		return false
+	}
+	switch prev.Kind {
+	case KindElement, KindRaw:
+		return prev.Tag == next.Tag && prev.Namespace == next.Namespace
+	case KindComponent:
+		return sameComponent(prev.Component, next.Component)
+	case KindPortal:
+		return prev.PortalTarget == next.PortalTarget
+	}
+	return true
+}
+
+// longestIncreasingSubsequence returns the indices of a longest strictly
+// increasing subsequence of seq, ignoring negative entries, in ascending
+// order. It runs in O(n log n).
+func longestIncreasingSubsequence(seq []int) []int {
+	// tails[k] is the index of the smallest tail of an increasing run of
+	// length k+1; prev links each index to its predecessor in its run
+	tails := make([]int, 0, len(seq))
+	prev := make([]int, len(seq))
+	for i, v := range seq {
+		if v < 0 {
+			continue
+		}
+		lo, hi := 0, len(tails)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if seq[tails[mid]] < v {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		if lo > 0 {
+			prev[i] = tails[lo-1]
+		} else {
+			prev[i] = -1
+		}
+		if lo == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[lo] = i
+		}
 	}
+
+	result := make([]int, len(tails))
+	if len(tails) > 0 {
+		for k, i := len(tails)-1, tails[len(tails)-1]; k >= 0; k-- {
+			result[k] = i
+			i = prev[i]
+		}
+	}
+	return result
 }
 
 // Helper functions
@@ -443,46 +700,188 @@ func isEventProp(key string) bool {
 	return len(key) > 2 && key[0] == 'o' && key[1] == 'n'
 }
 
-func getEventBit(eventName string) uint32 {
-	// Map event names to bit positions
-	// This is a simplified version - real implementation would have all events
-	switch eventName {
-	case "onClick", "onclick":
-		return 1 << 0
-	case "onChange", "onchange":
-		return 1 << 1
-	case "onInput", "oninput":
-		return 1 << 2
-	case "onSubmit", "onsubmit":
-		return 1 << 3
-	case "onFocus", "onfocus":
-		return 1 << 4
-	case "onBlur", "onblur":
-		return 1 << 5
-	case "onKeyDown", "onkeydown":
-		return 1 << 6
-	case "onKeyUp", "onkeyup":
-		return 1 << 7
-	case "onMouseDown", "onmousedown":
-		return 1 << 8
-	case "onMouseUp", "onmouseup":
-		return 1 << 9
-	case "onMouseMove", "onmousemove":
-		return 1 << 10
-	case "onMouseEnter", "onmouseenter":
-		return 1 << 11
-	case "onMouseLeave", "onmouseleave":
-		return 1 << 12
-	default:
-		return 1 << 31 // Unknown event
+// propsEqual reports whether two attribute values render the same. Strings,
+// bools and numbers are compared by value without formatting; numbers of
+// different types are equal when their values are, but a number never
+// equals a string. Class lists ([]string) and style maps are compared
+// element-wise. Other values fall back to comparing their formatting.
+func propsEqual(a, b any) bool {
+	switch av := a.(type) {
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv
+	case nil:
+		return b == nil
+	case []string:
+		bv, ok := b.([]string)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if av[i] != bv[i] {
+				return false
+			}
+		}
+		return true
+	case map[string]string:
+		bv, ok := b.(map[string]string)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			if w, ok := bv[k]; !ok || v != w {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			if w, ok := bv[k]; !ok || !propsEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if an, ok := toNumber(a); ok {
+		bn, ok := toNumber(b)
+		return ok && an.equal(bn)
+	}
+	if _, ok := toNumber(b); ok {
+		return false
+	}
+	switch b.(type) {
+	case string, bool, nil, []string, map[string]string, map[string]any:
+		return false
 	}
+	return PropString(a) == PropString(b)
 }
 
-func propsEqual(a, b any) bool {
-	// Simple equality check - could be enhanced
-	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+// handlerEqual reports whether an event prop still refers to the same
+// handler. Go cannot compare closures, so function handlers always count
+// as changed; comparable values such as server event names compare by value.
+func handlerEqual(a, b any) bool {
+	if reflect.ValueOf(a).Kind() == reflect.Func || reflect.ValueOf(b).Kind() == reflect.Func {
+		return false
+	}
+	if a == nil || b == nil {
+		return a == b
+	}
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	return ta == tb && ta.Comparable() && a == b
+}
+
+// depsEqual reports whether the deps of two static subtrees match. Each
+// dep compares like a handler: functions and incomparable values never do.
+func depsEqual(a, b []any) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !handlerEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// number is a numeric prop value; integers stay exact
+type number struct {
+	i    int64
+	u    uint64
+	f    float64
+	kind byte // 'i', 'u' or 'f'
+}
+
+// equal compares numbers of the same kind directly and others by the
+// attribute value they render to
+func (n number) equal(o number) bool {
+	if n.kind == o.kind {
+		return n.i == o.i && n.u == o.u && n.f == o.f
+	}
+	return n.String() == o.String()
+}
+
+// String formats the number as PropString does
+func (n number) String() string {
+	switch n.kind {
+	case 'i':
+		return strconv.FormatInt(n.i, 10)
+	case 'u':
+		return strconv.FormatUint(n.u, 10)
+	}
+	return strconv.FormatFloat(n.f, 'g', -1, 64)
+}
+
+// toNumber converts numeric prop values for comparison
+func toNumber(v any) (number, bool) {
+	switch n := v.(type) {
+	case int:
+		return number{i: int64(n), kind: 'i'}, true
+	case int8:
+		return number{i: int64(n), kind: 'i'}, true
+	case int16:
+		return number{i: int64(n), kind: 'i'}, true
+	case int32:
+		return number{i: int64(n), kind: 'i'}, true
+	case int64:
+		return number{i: n, kind: 'i'}, true
+	case uint:
+		return number{u: uint64(n), kind: 'u'}, true
+	case uint8:
+		return number{u: uint64(n), kind: 'u'}, true
+	case uint16:
+		return number{u: uint64(n), kind: 'u'}, true
+	case uint32:
+		return number{u: uint64(n), kind: 'u'}, true
+	case uint64:
+		return number{u: n, kind: 'u'}, true
+	case float32:
+		return number{f: float64(n), kind: 'f'}, true
+	case float64:
+		return number{f: n, kind: 'f'}, true
+	}
+	return number{}, false
 }
 
-func propToString(v any) string {
+// PropString formats a prop as an attribute value. Class lists ([]string)
+// are joined with spaces and style maps become "name: value" declarations
+// sorted by name; everything else is formatted with %v.
+func PropString(v any) string {
+	switch pv := v.(type) {
+	case string:
+		return pv
+	case bool:
+		return strconv.FormatBool(pv)
+	case []string:
+		return strings.Join(pv, " ")
+	case map[string]string:
+		names := make([]string, 0, len(pv))
+		for name := range pv {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		decls := make([]string, len(names))
+		for i, name := range names {
+			decls[i] = name + ": " + pv[name]
+		}
+		return strings.Join(decls, "; ")
+	case map[string]any:
+		styles := make(map[string]string, len(pv))
+		for name, value := range pv {
+			styles[name] = PropString(value)
+		}
+		return PropString(styles)
+	}
+	if n, ok := toNumber(v); ok {
+		return n.String()
+	}
 	return fmt.Sprintf("%v", v)
 }
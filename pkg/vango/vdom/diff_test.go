@@ -1,7 +1,11 @@
 package vdom
 
 import (
+	"fmt"
+	"math/rand"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -448,4 +452,923 @@ func patchesEqual(a, b []Patch) bool {
 	}
 	
 	return reflect.DeepEqual(aMap, bMap)
-}
\ No newline at end of file
+}
+func TestDiff_IDsPersistAcrossDiffs(t *testing.T) {
+	ids := NewIDAllocator()
+	list := func(keys ...string) *VNode {
+		ul := &VNode{Kind: KindElement, Tag: "ul"}
+		for _, k := range keys {
+			ul.Kids = append(ul.Kids, VNode{
+				Kind: KindElement, Tag: "li", Key: k,
+				Kids: []VNode{{Kind: KindText, Text: k}},
+			})
+		}
+		return ul
+	}
+
+	v1 := list("a", "b")
+	if root := Mount(ids, v1); root != 1 {
+		t.Fatalf("Mount() = %d, want 1", root)
+	}
+	// Pre-order: ul=1, li a=2, "a"=3, li b=4, "b"=5
+	if v1.Kids[1].ID != 4 || v1.Kids[1].Kids[0].ID != 5 {
+		t.Fatalf("mounted IDs = %d, %d; want 4, 5", v1.Kids[1].ID, v1.Kids[1].Kids[0].ID)
+	}
+
+	// Matched nodes carry their IDs forward; the insert gets fresh ones
+	v2 := list("b", "a", "c")
+	patches := DiffWithIDs(ids, v1, v2)
+	if v2.ID != 1 || v2.Kids[0].ID != 4 || v2.Kids[1].ID != 2 || v2.Kids[1].Kids[0].ID != 3 {
+		t.Errorf("carried IDs = %d %d %d %d", v2.ID, v2.Kids[0].ID, v2.Kids[1].ID, v2.Kids[1].Kids[0].ID)
+	}
+	var insert *Patch
+	for i := range patches {
+		if patches[i].Op == OpInsertNode {
+			insert = &patches[i]
+		}
+	}
+	if insert == nil || insert.NodeID != 6 || v2.Kids[2].ID != 6 || v2.Kids[2].Kids[0].ID != 7 {
+		t.Fatalf("insert = %v, inserted IDs %d, %d; want 6, 7", insert, v2.Kids[2].ID, v2.Kids[2].Kids[0].ID)
+	}
+
+	// The next diff addresses the nodes by the same IDs
+	v3 := list("b", "a", "c")
+	v3.Kids[2].Kids[0].Text = "C"
+	patches = DiffWithIDs(ids, v2, v3)
+	want := []Patch{{Op: OpReplaceText, NodeID: 7, Value: "C"}}
+	if !patchesEqual(patches, want) {
+		t.Errorf("Diff() = %v, want %v", patches, want)
+	}
+}
+
+func TestDiff_InsertsAvoidPrevIDs(t *testing.T) {
+	prev := &VNode{Kind: KindElement, Tag: "div", ID: 40, Kids: []VNode{
+		{Kind: KindText, Text: "x", ID: 41},
+	}}
+	next := &VNode{Kind: KindElement, Tag: "div", Kids: []VNode{
+		{Kind: KindText, Text: "x"},
+		{Kind: KindElement, Tag: "span"},
+	}}
+
+	patches := Diff(prev, next)
+	if len(patches) != 1 || patches[0].Op != OpInsertNode || patches[0].NodeID != 42 || patches[0].ParentID != 40 {
+		t.Errorf("Diff() = %v, want an insert of node 42 into 40", patches)
+	}
+}
+
+func TestDiff_KeyedMinimalMoves(t *testing.T) {
+	list := func(keys ...string) *VNode {
+		ul := &VNode{Kind: KindElement, Tag: "ul"}
+		for _, k := range keys {
+			ul.Kids = append(ul.Kids, VNode{Kind: KindElement, Tag: "li", Key: k})
+		}
+		return ul
+	}
+	keys := func(n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = string(rune('a' + i))
+		}
+		return out
+	}
+	reversed := func(in []string) []string {
+		out := make([]string, len(in))
+		for i, k := range in {
+			out[len(in)-1-i] = k
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		prev  []string
+		next  []string
+		moves int
+	}{
+		{"move last to front", keys(8), append([]string{"h"}, keys(7)...), 1},
+		{"prepend", keys(4), append([]string{"z"}, keys(4)...), 0},
+		{"reverse", keys(8), reversed(keys(8)), 7},
+		{"swap ends", keys(6), []string{"f", "b", "c", "d", "e", "a"}, 2},
+		{"remove middle", keys(5), []string{"a", "b", "d", "e"}, 0},
+		{"insert and shuffle", []string{"a", "b", "c", "d"}, []string{"d", "x", "a", "c", "b"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev, next := list(tt.prev...), list(tt.next...)
+			Mount(NewIDAllocator(), prev)
+			dom := make([]uint32, len(prev.Kids))
+			for i := range prev.Kids {
+				dom[i] = prev.Kids[i].ID
+			}
+
+			patches := Diff(prev, next)
+			moves := 0
+			for _, p := range patches {
+				if p.Op == OpMoveNode {
+					moves++
+				}
+				dom = applyChildPatch(t, dom, p)
+			}
+
+			if moves != tt.moves {
+				t.Errorf("%d moves, want %d: %v", moves, tt.moves, patches)
+			}
+			for i := range next.Kids {
+				if i >= len(dom) || dom[i] != next.Kids[i].ID {
+					t.Fatalf("children after patching = %v, want IDs of %v", dom, tt.next)
+				}
+			}
+			if len(dom) != len(next.Kids) {
+				t.Errorf("%d children after patching, want %d", len(dom), len(next.Kids))
+			}
+		})
+	}
+}
+
+func TestDiff_ReplaceKeepsPosition(t *testing.T) {
+	prev := NewElement("div", nil,
+		NewElement("span", nil), NewElement("p", nil), NewText("a"), NewElement("i", nil))
+	next := NewElement("div", nil,
+		NewElement("b", nil), NewElement("p", nil), NewElement("em", nil), NewElement("i", nil))
+	Mount(NewIDAllocator(), prev)
+	var dom []uint32
+	for i := range prev.Kids {
+		dom = append(dom, prev.Kids[i].ID)
+	}
+
+	for _, p := range Diff(prev, next) {
+		dom = applyChildPatch(t, dom, p)
+	}
+	for i := range next.Kids {
+		if dom[i] != next.Kids[i].ID {
+			t.Fatalf("children = %v after replacing, want node %d at %d", dom, next.Kids[i].ID, i)
+		}
+	}
+}
+
+// applyChildPatch applies a patch to a list of child IDs the way a DOM
+// applier would
+func applyChildPatch(t *testing.T, dom []uint32, p Patch) []uint32 {
+	t.Helper()
+	remove := func(id uint32) {
+		for i, v := range dom {
+			if v == id {
+				dom = append(dom[:i], dom[i+1:]...)
+				return
+			}
+		}
+		t.Fatalf("%v: node %d is not a child", p, id)
+	}
+	insert := func(id, before uint32) {
+		if before == 0 {
+			dom = append(dom, id)
+			return
+		}
+		for i, v := range dom {
+			if v == before {
+				dom = append(dom[:i], append([]uint32{id}, dom[i:]...)...)
+				return
+			}
+		}
+		t.Fatalf("%v: before node %d is not a child", p, before)
+	}
+
+	switch p.Op {
+	case OpRemoveNode:
+		remove(p.NodeID)
+	case OpInsertNode:
+		insert(p.NodeID, p.BeforeID)
+	case OpMoveNode:
+		remove(p.NodeID)
+		insert(p.NodeID, p.BeforeID)
+	}
+	return dom
+}
+
+func TestPropsEqual_Typed(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b any
+		want bool
+	}{
+		{"same string", "a", "a", true},
+		{"number vs string", 1, "1", false},
+		{"string vs number", "1", 1, false},
+		{"int vs int64", 1, int64(1), true},
+		{"int vs float", 2, 2.0, true},
+		{"different floats", 1.5, 1.25, false},
+		{"bool", true, true, true},
+		{"bool vs string", true, "true", false},
+		{"class list", []string{"btn", "primary"}, []string{"btn", "primary"}, true},
+		{"class list order", []string{"btn", "primary"}, []string{"primary", "btn"}, false},
+		{"style map", map[string]string{"color": "red"}, map[string]string{"color": "red"}, true},
+		{"style map value", map[string]string{"color": "red"}, map[string]string{"color": "blue"}, false},
+		{"style any map", map[string]any{"width": 10}, map[string]any{"width": 10}, true},
+		{"nil", nil, nil, true},
+		{"nil vs empty", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := propsEqual(tt.a, tt.b); got != tt.want {
+				t.Errorf("propsEqual(%#v, %#v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+
+	if got := PropString([]string{"btn", "primary"}); got != "btn primary" {
+		t.Errorf("PropString(class list) = %q", got)
+	}
+	if got := PropString(map[string]string{"margin": "0", "color": "red"}); got != "color: red; margin: 0" {
+		t.Errorf("PropString(style map) = %q", got)
+	}
+}
+
+func TestDiff_HandlerSwap(t *testing.T) {
+	count := 0
+	prev := &VNode{Kind: KindElement, Tag: "button", Props: Props{"onClick": func() { count++ }, "class": "btn"}}
+	next := &VNode{Kind: KindElement, Tag: "button", Props: Props{"onClick": func() { count += 2 }, "class": "btn"}}
+
+	patches := Diff(prev, next)
+	if len(patches) != 1 || patches[0].Op != OpSetHandler || patches[0].Key != "onClick" {
+		t.Fatalf("Diff() = %v, want a single handler swap", patches)
+	}
+	patches[0].Handler.(func())()
+	if count != 2 {
+		t.Errorf("swap carries the wrong handler")
+	}
+
+	// Comparable handler values, like server event names, swap only on change
+	same := &VNode{Kind: KindElement, Tag: "button", Props: Props{"onClick": "save"}}
+	if patches := Diff(same, &VNode{Kind: KindElement, Tag: "button", Props: Props{"onClick": "save"}}); len(patches) != 0 {
+		t.Errorf("unchanged event name produced %v", patches)
+	}
+}
+
+func TestDiff_MemoSkipsEqualDeps(t *testing.T) {
+	ids := NewIDAllocator()
+	renders := 0
+	page := func(user, footer string) *VNode {
+		return NewElement("div", nil,
+			NewText(user),
+			Memo([]any{footer}, func() *VNode {
+				renders++
+				return NewElement("footer", Props{"onClick": func() {}}, NewText(footer))
+			}),
+		)
+	}
+
+	v1 := page("ann", "(c) 2025")
+	Mount(ids, v1)
+	footerID, textID := v1.Kids[1].ID, v1.Kids[1].Kids[0].ID
+
+	// Equal deps: the footer is not compared, not even its handler
+	v2 := page("bob", "(c) 2025")
+	patches := DiffWithIDs(ids, v1, v2)
+	want := []Patch{{Op: OpReplaceText, NodeID: v1.Kids[0].ID, Value: "bob"}}
+	if !patchesEqual(patches, want) {
+		t.Errorf("Diff() = %v, want %v", patches, want)
+	}
+	if v2.Kids[1].ID != footerID || v2.Kids[1].Kids[0].ID != textID {
+		t.Errorf("skipped subtree lost its IDs")
+	}
+
+	// Changed deps are diffed as usual
+	v3 := page("bob", "(c) 2026")
+	patches = DiffWithIDs(ids, v2, v3)
+	if len(patches) != 2 || patches[0].Op != OpSetHandler || patches[1].Op != OpReplaceText || patches[1].NodeID != textID {
+		t.Errorf("Diff() = %v, want a handler swap and a text replace", patches)
+	}
+
+	// FlagDirty forces a comparison despite equal deps
+	v4 := page("bob", "(c) 2026")
+	v4.Kids[1].Flags |= FlagDirty
+	v4.Kids[1].Kids[0].Text = "changed"
+	patches = DiffWithIDs(ids, v3, v4)
+	if len(patches) != 2 || patches[1].Op != OpReplaceText || patches[1].Value != "changed" {
+		t.Errorf("Diff() = %v, want the dirty memo compared", patches)
+	}
+	if renders != 4 {
+		t.Errorf("renders = %d, want 4", renders)
+	}
+}
+
+func TestDiff_StaticSubtreeShared(t *testing.T) {
+	nav := Static(NewElement("nav", nil, NewElement("a", Props{"href": "/"}, NewText("Home"))))
+	page := func(title string) *VNode {
+		return NewElement("div", nil, nav, NewText(title))
+	}
+
+	// Two sessions mount the same hoisted subtree
+	a, b := page("a"), page("b")
+	Mount(NewIDAllocator(), a)
+	idsB := NewIDAllocator()
+	idsB.Reserve(100)
+	Mount(idsB, b)
+	if a.Kids[0].Kids[0].ID == b.Kids[0].Kids[0].ID || nav.Kids[0].ID != 0 {
+		t.Fatalf("mounting wrote IDs into the shared subtree")
+	}
+
+	// Reusing the node skips it; another static node is compared
+	next := page("b2")
+	patches := DiffWithIDs(idsB, b, next)
+	if len(patches) != 1 || patches[0].Op != OpReplaceText {
+		t.Errorf("Diff() = %v, want only the title replaced", patches)
+	}
+	if next.Kids[0].Kids[0].ID != b.Kids[0].Kids[0].ID {
+		t.Errorf("skipped subtree lost its IDs")
+	}
+
+	other := Static(NewElement("nav", nil, NewElement("a", Props{"href": "/about"}, NewText("Home"))))
+	patches = DiffWithIDs(idsB, next, NewElement("div", nil, other, NewText("b2")))
+	if len(patches) != 1 || patches[0].Op != OpSetAttribute || patches[0].Value != "/about" {
+		t.Errorf("Diff() = %v, want the href updated", patches)
+	}
+	if other.Kids[0].ID != 0 {
+		t.Errorf("diffing wrote IDs into the shared subtree")
+	}
+}
+
+func TestDiff_EventRegistry(t *testing.T) {
+	// Every standard event has its own ID, whatever the prop's case
+	seen := make(map[EventID]string)
+	for _, key := range []string{"onClick", "onPointerDown", "onTouchMove", "onWheel", "onDrop", "onCompositionEnd", "onScroll", "onAnimationEnd"} {
+		id, ok := PropEvent(key)
+		if !ok || seen[id] != "" {
+			t.Fatalf("PropEvent(%q) = %d, %v (seen for %q)", key, id, ok, seen[id])
+		}
+		seen[id] = key
+	}
+	if id, _ := PropEvent("onpointerdown"); id.String() != "pointerdown" {
+		t.Errorf("onpointerdown listens to %q", id)
+	}
+
+	// Custom events keep their registered spelling
+	custom := RegisterEvent("selectRow")
+	if id, _ := PropEvent("onSelectRow"); id != custom || id.String() != "selectRow" {
+		t.Errorf("onSelectRow = %q, want selectRow", id)
+	}
+	if id, _ := PropEvent("onrow-moved"); id.String() != "row-moved" {
+		t.Errorf("unregistered event = %q, want row-moved", id)
+	}
+	if _, ok := PropEvent("class"); ok {
+		t.Errorf("class is not an event prop")
+	}
+}
+
+func TestDiff_EventOptions(t *testing.T) {
+	handler := func() {}
+	prev := &VNode{Kind: KindElement, Tag: "div", Props: Props{"onTouchMove": "move"}}
+	next := &VNode{Kind: KindElement, Tag: "div", Props: Props{
+		"onTouchMove": WithOptions("move", EventPassive),
+		"onSelectRow": WithOptions(handler, EventPreventDefault|EventOnce),
+	}}
+
+	patches := Diff(prev, next)
+	if len(patches) != 2 || patches[0].Op != OpSetHandler || patches[1].Op != OpUpdateEvents {
+		t.Fatalf("Diff() = %v, want a handler for the new listener and an event update", patches)
+	}
+	if _, isListener := patches[0].Handler.(Listener); isListener {
+		t.Errorf("SetHandler carries the Listener wrapper, want the bare handler")
+	}
+	want := Listeners(next.Props)
+	if len(want) != 2 || !listenersEqual(patches[1].Events, want) {
+		t.Errorf("UpdateEvents = %v, want %v", patches[1].Events, want)
+	}
+	for _, l := range patches[1].Events {
+		if l.Event.String() == "touchmove" && l.Options != EventPassive {
+			t.Errorf("touchmove options = %v, want passive", l.Options)
+		}
+	}
+
+	// Same listeners and options: nothing to update
+	again := &VNode{Kind: KindElement, Tag: "div", Props: Props{
+		"onTouchMove": WithOptions("move", EventPassive),
+		"onSelectRow": WithOptions("row", EventPreventDefault|EventOnce),
+	}}
+	for _, p := range Diff(next, again) {
+		if p.Op == OpUpdateEvents {
+			t.Errorf("unchanged listeners produced %v", p)
+		}
+	}
+}
+
+func TestDiff_Namespaces(t *testing.T) {
+	icon := func(extra ...*VNode) *VNode {
+		kids := append([]*VNode{
+			NewElement("path", Props{"d": "M0 0"}),
+			NewElement("foreignObject", nil, NewElement("p", nil, NewText("caption"))),
+		}, extra...)
+		return NewElement("div", nil, NewElement("svg", Props{"viewBox": "0 0 10 10"}, kids...), NewElement("math", nil, NewElement("mi", nil, NewText("x"))))
+	}
+
+	// Mount resolves namespaces down the tree
+	prev := icon()
+	Mount(NewIDAllocator(), prev)
+	svg := prev.Kids[0]
+	for _, c := range []struct {
+		node *VNode
+		want string
+	}{
+		{prev, NamespaceHTML},
+		{&svg, NamespaceSVG},
+		{&svg.Kids[0], NamespaceSVG},
+		{&svg.Kids[1], NamespaceSVG},
+		{&svg.Kids[1].Kids[0], NamespaceHTML},
+		{&prev.Kids[1].Kids[0], NamespaceMathML},
+	} {
+		if c.node.Namespace != c.want {
+			t.Errorf("<%s> namespace = %q, want %q", c.node.Tag, c.node.Namespace, c.want)
+		}
+	}
+
+	// Inserted nodes inherit the namespace of their parent
+	next := icon(NewElement("circle", Props{"r": "4"}))
+	patches := Diff(prev, next)
+	if len(patches) != 1 || patches[0].Op != OpInsertNode {
+		t.Fatalf("Diff() = %v, want one insert", patches)
+	}
+	if ns := patches[0].Node.Namespace; ns != NamespaceSVG {
+		t.Errorf("inserted <circle> namespace = %q, want SVG", ns)
+	}
+
+	// The same tag in another namespace is a different element
+	a := NewElement("div", nil, NewElement("a", nil))
+	b := NewElement("div", nil, NewElement("a", nil))
+	b.Kids[0].Namespace = NamespaceSVG
+	Mount(NewIDAllocator(), a)
+	patches = Diff(a, b)
+	if len(patches) != 2 || patches[0].Op != OpRemoveNode || patches[1].Op != OpInsertNode {
+		t.Errorf("Diff() = %v, want the element replaced", patches)
+	}
+}
+
+func TestDiff_RawHTML(t *testing.T) {
+	prev := NewElement("div", nil, NewTrustedHTML("section", Props{"class": "a"}, "<p>one</p>"))
+	Mount(NewIDAllocator(), prev)
+
+	// Changed content and attributes patch the element in place
+	next := NewElement("div", nil, NewTrustedHTML("section", Props{"class": "b"}, "<p>two</p>"))
+	patches := Diff(prev, next)
+	if len(patches) != 2 {
+		t.Fatalf("Diff() = %v, want an attribute and an HTML update", patches)
+	}
+	if patches[0].Op != OpSetAttribute || patches[1].Op != OpSetHTML || patches[1].Value != "<p>two</p>" {
+		t.Errorf("Diff() = %v", patches)
+	}
+	if next.Kids[0].ID != prev.Kids[0].ID {
+		t.Errorf("raw element lost its ID")
+	}
+
+	// A raw element does not patch into a regular one
+	patches = Diff(next, NewElement("div", nil, NewElement("section", Props{"class": "b"})))
+	if len(patches) != 2 || patches[0].Op != OpRemoveNode || patches[1].Op != OpInsertNode {
+		t.Errorf("Diff() = %v, want the element replaced", patches)
+	}
+}
+
+// testHost renders components named by strings into text with their
+// "label" prop, counting renders and unmounts
+type testHost struct {
+	renders, unmounts map[any]int
+}
+
+func (h *testHost) RenderComponent(node, owner *VNode) *VNode {
+	h.renders[node.Component]++
+	node.Instance = node.Component
+	return NewElement("p", nil, NewText(node.Props["label"].(string)))
+}
+
+func (h *testHost) AttachComponent(node *VNode, parentID uint32) {}
+
+func (h *testHost) UnmountComponent(node *VNode) {
+	h.unmounts[node.Component]++
+}
+
+func TestDiff_Components(t *testing.T) {
+	host := &testHost{renders: map[any]int{}, unmounts: map[any]int{}}
+	ids := NewIDAllocator()
+	render := func(a, b string) *VNode {
+		return NewElement("div", nil,
+			NewComponent("a", Props{"label": a}),
+			NewComponent("b", Props{"label": b}),
+		)
+	}
+
+	// Mounting renders every component into its node
+	prev := render("one", "two")
+	patches := DiffWithHost(ids, host, nil, prev)
+	if len(patches) != 1 || patches[0].Op != OpInsertNode {
+		t.Fatalf("DiffWithHost() = %v, want one insert", patches)
+	}
+	if host.renders["a"] != 1 || host.renders["b"] != 1 || prev.Kids[0].Kids[0].Kids[0].Text != "one" {
+		t.Fatalf("components not rendered on mount: %v", host.renders)
+	}
+
+	// Unchanged props keep the output without rendering
+	next := render("one", "two")
+	if patches := DiffWithHost(ids, host, prev, next); len(patches) != 0 {
+		t.Errorf("DiffWithHost() = %v, want no patches", patches)
+	}
+	if host.renders["a"] != 1 || next.Kids[0].Kids[0].ID != prev.Kids[0].Kids[0].ID {
+		t.Errorf("unchanged component rendered again or lost its output")
+	}
+
+	// Changed props render that component only, patched into its output
+	prev, next = next, render("uno", "two")
+	patches = DiffWithHost(ids, host, prev, next)
+	if len(patches) != 1 || patches[0].Op != OpReplaceText || patches[0].NodeID != prev.Kids[0].Kids[0].Kids[0].ID {
+		t.Errorf("DiffWithHost() = %v, want the text of a's output replaced", patches)
+	}
+	if host.renders["a"] != 2 || host.renders["b"] != 1 {
+		t.Errorf("renders = %v, want a rendered again", host.renders)
+	}
+
+	// A removed component is removed through its output and unmounted
+	prev, next = next, NewElement("div", nil, NewComponent("a", Props{"label": "uno"}))
+	patches = DiffWithHost(ids, host, prev, next)
+	if len(patches) != 1 || patches[0].Op != OpRemoveNode || patches[0].NodeID != prev.Kids[1].Kids[0].ID {
+		t.Errorf("DiffWithHost() = %v, want b's output removed", patches)
+	}
+	if host.unmounts["b"] != 1 || host.unmounts["a"] != 0 {
+		t.Errorf("unmounts = %v, want b unmounted", host.unmounts)
+	}
+
+	// Another component in the same place replaces the instance
+	prev, next = next, NewElement("div", nil, NewComponent("c", Props{"label": "uno"}))
+	patches = DiffWithHost(ids, host, prev, next)
+	if len(patches) != 2 || patches[0].Op != OpRemoveNode || patches[1].Op != OpInsertNode || patches[1].ParentID != prev.ID {
+		t.Errorf("DiffWithHost() = %v, want the component replaced", patches)
+	}
+	if host.unmounts["a"] != 1 || host.renders["c"] != 1 {
+		t.Errorf("a was not unmounted or c not rendered")
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"allowed markup", `<p class="x">Hi <strong>you</strong><br></p>`, `<p class="x">Hi <strong>you</strong><br></p>`},
+		{"script content dropped", `a<script>alert("x")</script>b<SCRIPT src=x></SCRIPT >c`, `abc`},
+		{"unknown element keeps text", `<blink>hey</blink>`, `hey`},
+		{"event attributes", `<img src="a.png" onerror="x()" alt='a "b"'>`, `<img src="a.png" alt="a &#34;b&#34;">`},
+		{"javascript urls", `<a href=" jav&#x09;ascript:alert(1)">x</a><a href="/ok?a=b:c">y</a>`, `<a>x</a><a href="/ok?a=b:c">y</a>`},
+		{"allowed protocols", `<a href="mailto:a@b.c" title=t>m</a>`, `<a href="mailto:a@b.c" title="t">m</a>`},
+		{"unclosed and stray tags", `<ul><li>one<li>two</ol>`, `<ul><li>one<li>two</li></li></ul>`},
+		{"comments and doctypes", `<!DOCTYPE html><!-- <script>x</script> -->ok`, `ok`},
+		{"text is escaped", `1 < 2 & 3 &gt; 2`, `1 &lt; 2 &amp; 3 &gt; 2`},
+		{"foreign content dropped", `<svg><script>x</script><a href="#">y</a></svg>z`, `z`},
+		{"disallowed styles", `<div style="background:url(x)">d</div>`, `<div>d</div>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	// Policies extend the default one
+	s := NewSanitizer().AllowAttributes("div", "style").AllowProtocols("data")
+	if got := s.Sanitize(`<div style="color:red"><img src="data:image/png;base64,AA"></div>`); got != `<div style="color:red"><img src="data:image/png;base64,AA"></div>` {
+		t.Errorf("custom Sanitize() = %q", got)
+	}
+}
+
+// simNode is a node of simDOM
+type simNode struct {
+	id     uint32
+	text   string // tag of elements, content of text nodes
+	isText bool
+	attrs  map[string]string
+	events string
+	parent *simNode
+	kids   []*simNode
+}
+
+// simDOM is an in-memory DOM that applies patches strictly, failing on
+// patches for nodes that do not exist
+type simDOM struct {
+	root  *simNode // container with ID 0
+	nodes map[uint32]*simNode
+}
+
+func newSimDOM(tree *VNode) *simDOM {
+	d := &simDOM{root: &simNode{}, nodes: map[uint32]*simNode{}}
+	d.nodes[0] = d.root
+	if tree != nil {
+		d.insert(d.root, d.build(tree, tree.ID), nil)
+	}
+	return d
+}
+
+// build creates the nodes of a subtree numbered in pre-order from id
+func (d *simDOM) build(v *VNode, id uint32) *simNode {
+	n := &simNode{id: id, text: v.Tag, attrs: map[string]string{}}
+	if v.Kind == KindText {
+		n.isText, n.text = true, v.Text
+	}
+	for k, val := range v.Props {
+		n.attrs[k] = PropString(val)
+	}
+	d.nodes[id] = n
+	next := id + 1
+	for i := range v.Kids {
+		kid := d.build(&v.Kids[i], next)
+		kid.parent = n
+		n.kids = append(n.kids, kid)
+		next += countNodes(&v.Kids[i])
+	}
+	return n
+}
+
+func (d *simDOM) insert(parent, n *simNode, before *simNode) {
+	n.parent = parent
+	at := len(parent.kids)
+	for i, k := range parent.kids {
+		if k == before {
+			at = i
+		}
+	}
+	parent.kids = append(parent.kids[:at], append([]*simNode{n}, parent.kids[at:]...)...)
+}
+
+func (d *simDOM) detach(n *simNode) {
+	kids := n.parent.kids
+	for i, k := range kids {
+		if k == n {
+			n.parent.kids = append(kids[:i:i], kids[i+1:]...)
+			return
+		}
+	}
+}
+
+func (d *simDOM) forget(n *simNode) {
+	delete(d.nodes, n.id)
+	for _, k := range n.kids {
+		d.forget(k)
+	}
+}
+
+func (d *simDOM) apply(p Patch) error {
+	n, ok := d.nodes[p.NodeID]
+	if p.Op != OpInsertNode && (!ok || n == d.root) {
+		return fmt.Errorf("%v: no node %d", p, p.NodeID)
+	}
+	switch p.Op {
+	case OpInsertNode, OpMoveNode:
+		parent, ok := d.nodes[p.ParentID]
+		if !ok {
+			return fmt.Errorf("%v: no parent", p)
+		}
+		var before *simNode
+		if p.BeforeID != 0 {
+			if before = d.nodes[p.BeforeID]; before == nil || before.parent != parent {
+				return fmt.Errorf("%v: before is not a child of the parent", p)
+			}
+		}
+		if p.Op == OpInsertNode {
+			n = d.build(p.Node, p.NodeID)
+		} else {
+			for a := parent; a != nil; a = a.parent {
+				if a == n {
+					return fmt.Errorf("%v: moves a node into itself", p)
+				}
+			}
+			d.detach(n)
+		}
+		d.insert(parent, n, before)
+	case OpRemoveNode:
+		d.detach(n)
+		d.forget(n)
+	case OpReplaceText:
+		n.text = p.Value
+	case OpSetHTML:
+		n.attrs["innerHTML"] = p.Value
+	case OpSetAttribute:
+		n.attrs[p.Key] = p.Value
+	case OpRemoveAttribute:
+		delete(n.attrs, p.Key)
+	case OpUpdateEvents:
+		n.events = fmt.Sprint(p.Events)
+	case OpSetHandler:
+		n.attrs["handler:"+p.Key] = fmt.Sprint(p.Handler)
+	}
+	return nil
+}
+
+func (d *simDOM) String() string {
+	var b strings.Builder
+	var write func(n *simNode)
+	write = func(n *simNode) {
+		keys := make([]string, 0, len(n.attrs))
+		for k := range n.attrs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(&b, "(%d %q", n.id, n.text)
+		for _, k := range keys {
+			fmt.Fprintf(&b, " %s=%q", k, n.attrs[k])
+		}
+		b.WriteString(n.events)
+		for _, k := range n.kids {
+			write(k)
+		}
+		b.WriteString(")")
+	}
+	write(d.root)
+	return b.String()
+}
+
+// randomPatches generates a valid batch of n patches against model,
+// applying them as it goes. Nodes touched recently are picked more often
+// so batches are full of redundancy.
+func randomPatches(rng *rand.Rand, ids *IDAllocator, model *simDOM, n int) []Patch {
+	var recent []uint32
+	pick := func(elements bool) *simNode {
+		var pool []*simNode
+		if len(recent) > 0 && rng.Intn(2) == 0 {
+			for _, id := range recent {
+				if node, ok := model.nodes[id]; ok && node != model.root {
+					pool = append(pool, node)
+				}
+			}
+		}
+		if len(pool) == 0 {
+			for _, node := range model.nodes {
+				if node != model.root {
+					pool = append(pool, node)
+				}
+			}
+		}
+		sort.Slice(pool, func(i, j int) bool { return pool[i].id < pool[j].id })
+		var kept []*simNode
+		for _, node := range pool {
+			if !elements || !node.isText {
+				kept = append(kept, node)
+			}
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		return kept[rng.Intn(len(kept))]
+	}
+	placement := func(moving *simNode) (parent, before *simNode) {
+		parent = model.root
+		if p := pick(true); p != nil && rng.Intn(4) > 0 {
+			parent = p
+		}
+		for a := parent; moving != nil && a != nil; a = a.parent {
+			if a == moving {
+				parent = model.root
+				break
+			}
+		}
+		if len(parent.kids) > 0 && rng.Intn(2) == 0 {
+			before = parent.kids[rng.Intn(len(parent.kids))]
+			if before == moving {
+				before = nil
+			}
+		}
+		return parent, before
+	}
+	idOf := func(n *simNode) uint32 {
+		if n == nil {
+			return 0
+		}
+		return n.id
+	}
+
+	var patches []Patch
+	for len(patches) < n {
+		var p Patch
+		switch rng.Intn(7) {
+		case 0, 1:
+			node := pick(false)
+			if node == nil {
+				continue
+			}
+			switch {
+			case node.isText:
+				p = Patch{Op: OpReplaceText, NodeID: node.id, Value: fmt.Sprint(rng.Intn(3))}
+			case rng.Intn(4) == 0:
+				p = Patch{Op: OpRemoveAttribute, NodeID: node.id, Key: "ab"[rng.Intn(2):][:1]}
+			case rng.Intn(6) == 0:
+				p = Patch{Op: OpUpdateEvents, NodeID: node.id, Events: []EventListener{{Event: EventID(1 + rng.Intn(2)), Options: EventOptions(rng.Intn(2))}}}
+			default:
+				p = Patch{Op: OpSetAttribute, NodeID: node.id, Key: "ab"[rng.Intn(2):][:1], Value: fmt.Sprint(rng.Intn(3))}
+			}
+		case 2, 3:
+			tree := NewElement("li", Props{"a": "0"}, NewText("new"))
+			if rng.Intn(2) == 0 {
+				tree = NewText("t")
+			}
+			parent, before := placement(nil)
+			p = Patch{Op: OpInsertNode, NodeID: Mount(ids, tree), ParentID: idOf(parent), BeforeID: idOf(before), Node: tree}
+		case 4:
+			node := pick(false)
+			if node == nil {
+				continue
+			}
+			p = Patch{Op: OpRemoveNode, NodeID: node.id}
+		default:
+			node := pick(false)
+			if node == nil {
+				continue
+			}
+			parent, before := placement(node)
+			p = Patch{Op: OpMoveNode, NodeID: node.id, ParentID: idOf(parent), BeforeID: idOf(before)}
+		}
+		if err := model.apply(p); err != nil {
+			panic(err)
+		}
+		patches = append(patches, p)
+		recent = append(recent, p.NodeID)
+	}
+	return patches
+}
+
+func TestOptimizePatches_SameDOM(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	saved := 0
+	for run := 0; run < 2000; run++ {
+		tree := NewElement("ul", nil,
+			NewElement("li", Props{"a": "1"}, NewText("one")),
+			NewElement("li", nil, NewText("two"), NewElement("b", nil)),
+			NewText("three"),
+		)
+		ids := NewIDAllocator()
+		Mount(ids, tree)
+		patches := randomPatches(rng, ids, newSimDOM(tree), 1+rng.Intn(30))
+		optimized := OptimizePatches(patches)
+		saved += len(patches) - len(optimized)
+
+		want, got := newSimDOM(tree), newSimDOM(tree)
+		for _, p := range patches {
+			if err := want.apply(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, p := range optimized {
+			if err := got.apply(p); err != nil {
+				t.Fatalf("run %d: optimized batch does not apply: %v\noriginal: %v\noptimized: %v", run, err, patches, optimized)
+			}
+		}
+		if got.String() != want.String() {
+			t.Fatalf("run %d: optimized batch builds\n%s\nwant\n%s\noriginal: %v\noptimized: %v", run, got, want, patches, optimized)
+		}
+	}
+	if saved == 0 {
+		t.Errorf("no patches were optimized away")
+	}
+}
+
+func TestOptimizePatches(t *testing.T) {
+	li := NewElement("li", nil)
+	tests := []struct {
+		name    string
+		patches []Patch
+		want    []PatchOp
+	}{
+		{"set then remove attribute", []Patch{
+			{Op: OpSetAttribute, NodeID: 2, Key: "class", Value: "a"},
+			{Op: OpReplaceText, NodeID: 3, Value: "x"},
+			{Op: OpRemoveAttribute, NodeID: 2, Key: "class"},
+		}, []PatchOp{OpReplaceText, OpRemoveAttribute}},
+		{"writes to a removed node", []Patch{
+			{Op: OpReplaceText, NodeID: 3, Value: "x"},
+			{Op: OpRemoveNode, NodeID: 3},
+		}, []PatchOp{OpRemoveNode}},
+		{"move of an inserted node", []Patch{
+			{Op: OpInsertNode, NodeID: 5, ParentID: 1, Node: li},
+			{Op: OpMoveNode, NodeID: 5, ParentID: 1, BeforeID: 2},
+		}, []PatchOp{OpInsertNode}},
+		{"insert then remove", []Patch{
+			{Op: OpInsertNode, NodeID: 5, ParentID: 1, Node: li},
+			{Op: OpSetAttribute, NodeID: 5, Key: "a", Value: "b"},
+			{Op: OpRemoveNode, NodeID: 5},
+		}, nil},
+		{"insert placed before the inserted node", []Patch{
+			{Op: OpInsertNode, NodeID: 5, ParentID: 1, Node: li},
+			{Op: OpInsertNode, NodeID: 6, ParentID: 1, BeforeID: 5, Node: li},
+			{Op: OpRemoveNode, NodeID: 5},
+		}, []PatchOp{OpInsertNode, OpInsertNode, OpRemoveNode}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := OptimizePatches(tt.patches)
+			var ops []PatchOp
+			for _, p := range got {
+				ops = append(ops, p.Op)
+			}
+			if !reflect.DeepEqual(ops, tt.want) {
+				t.Errorf("OptimizePatches() = %v", got)
+			}
+		})
+	}
+	if got := OptimizePatches(tests[2].patches); got[0].BeforeID != 2 {
+		t.Errorf("folded insert goes before %d, want 2", got[0].BeforeID)
+	}
+}
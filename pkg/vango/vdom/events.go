@@ -0,0 +1,226 @@
+package vdom
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EventID identifies an event name in the process-wide event registry.
+// IDs are only meaningful within one process; the live protocol carries
+// event names.
+type EventID uint16
+
+// EventOptions are per-listener options
+type EventOptions uint8
+
+const (
+	// EventPassive promises the handler never cancels the event
+	EventPassive EventOptions = 1 << iota
+	// EventCapture listens during the capture phase
+	EventCapture
+	// EventOnce removes the listener after it first fires
+	EventOnce
+	// EventPreventDefault cancels the event before the handler runs
+	EventPreventDefault
+)
+
+// String lists the options, e.g. "passive|once"
+func (o EventOptions) String() string {
+	var names []string
+	for i, name := range []string{"passive", "capture", "once", "preventDefault"} {
+		if o&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Listener is an event prop value that carries listener options along
+// with the handler, e.g. Props{"onTouchMove": WithOptions(h, EventPassive)}
+type Listener struct {
+	Handler any
+	Options EventOptions
+}
+
+// WithOptions wraps an event handler with listener options
+func WithOptions(handler any, options EventOptions) Listener {
+	return Listener{Handler: handler, Options: options}
+}
+
+// ListenerOf splits an event prop value into its handler and options
+func ListenerOf(v any) (any, EventOptions) {
+	if l, ok := v.(Listener); ok {
+		return l.Handler, l.Options
+	}
+	return v, 0
+}
+
+// EventListener is one event a node listens to
+type EventListener struct {
+	Event   EventID
+	Options EventOptions
+}
+
+// String returns the event name, followed by its options if any
+func (l EventListener) String() string {
+	if l.Options == 0 {
+		return l.Event.String()
+	}
+	return l.Event.String() + "(" + l.Options.String() + ")"
+}
+
+// standardEvents are the DOM events known without registration. The first
+// thirteen keep the order of the original event bitmap.
+var standardEvents = []string{
+	"click", "change", "input", "submit", "focus", "blur", "keydown", "keyup",
+	"mousedown", "mouseup", "mousemove", "mouseenter", "mouseleave",
+	// Mouse
+	"dblclick", "auxclick", "contextmenu", "mouseover", "mouseout",
+	// Pointer
+	"pointerdown", "pointerup", "pointermove", "pointerover", "pointerout",
+	"pointerenter", "pointerleave", "pointercancel", "gotpointercapture", "lostpointercapture",
+	// Touch
+	"touchstart", "touchend", "touchmove", "touchcancel",
+	// Wheel and scroll
+	"wheel", "scroll", "scrollend",
+	// Keyboard and focus
+	"keypress", "focusin", "focusout",
+	// Forms
+	"beforeinput", "reset", "invalid", "select", "formdata",
+	// Composition
+	"compositionstart", "compositionupdate", "compositionend",
+	// Clipboard
+	"copy", "cut", "paste",
+	// Drag and drop
+	"drag", "dragstart", "dragend", "dragenter", "dragleave", "dragover", "drop",
+	// Animations and transitions
+	"animationstart", "animationend", "animationiteration", "animationcancel",
+	"transitionrun", "transitionstart", "transitionend", "transitioncancel",
+	// Media
+	"play", "playing", "pause", "ended", "waiting", "seeking", "seeked",
+	"timeupdate", "durationchange", "ratechange", "volumechange", "progress",
+	"loadstart", "loadeddata", "loadedmetadata", "canplay", "canplaythrough",
+	"stalled", "suspend", "emptied", "abort",
+	// Resources and elements
+	"load", "error", "toggle", "beforetoggle", "cancel", "close", "fullscreenchange",
+}
+
+// eventRegistry maps event names to IDs
+type eventRegistry struct {
+	mu     sync.RWMutex
+	names  []string // names[id-1]
+	byName map[string]EventID
+	folded map[string]EventID // lower-cased names, for matching prop keys
+}
+
+// events is the process-wide event registry
+var events = newEventRegistry()
+
+// newEventRegistry creates a registry holding the standard DOM events
+func newEventRegistry() *eventRegistry {
+	r := &eventRegistry{
+		byName: make(map[string]EventID, len(standardEvents)),
+		folded: make(map[string]EventID, len(standardEvents)),
+	}
+	for _, name := range standardEvents {
+		r.register(name)
+	}
+	return r
+}
+
+// register adds name; the caller holds the lock
+func (r *eventRegistry) register(name string) EventID {
+	if id, ok := r.byName[name]; ok {
+		return id
+	}
+	r.names = append(r.names, name)
+	id := EventID(len(r.names))
+	r.byName[name] = id
+	if _, ok := r.folded[strings.ToLower(name)]; !ok {
+		r.folded[strings.ToLower(name)] = id
+	}
+	return id
+}
+
+// RegisterEvent returns the ID of an event name, registering it if needed.
+// Register custom events whose names are not all lower case, such as
+// "selectRow", so event props like "onSelectRow" listen to that exact name.
+func RegisterEvent(name string) EventID {
+	events.mu.RLock()
+	id, ok := events.byName[name]
+	events.mu.RUnlock()
+	if ok {
+		return id
+	}
+
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	return events.register(name)
+}
+
+// LookupEvent returns the ID of a registered event name
+func LookupEvent(name string) (EventID, bool) {
+	events.mu.RLock()
+	defer events.mu.RUnlock()
+	id, ok := events.byName[name]
+	return id, ok
+}
+
+// String returns the event name
+func (id EventID) String() string {
+	events.mu.RLock()
+	defer events.mu.RUnlock()
+	if id == 0 || int(id) > len(events.names) {
+		return ""
+	}
+	return events.names[id-1]
+}
+
+// PropEvent returns the event an on* prop listens to. The part after "on"
+// matches registered names regardless of case, so "onClick", "onclick" and
+// "onPointerDown" all work; names seen for the first time are registered in
+// lower case. The boolean is false for props that are not events.
+func PropEvent(key string) (EventID, bool) {
+	if !isEventProp(key) {
+		return 0, false
+	}
+	name := strings.ToLower(key[2:])
+
+	events.mu.RLock()
+	id, ok := events.folded[name]
+	events.mu.RUnlock()
+	if ok {
+		return id, true
+	}
+	return RegisterEvent(name), true
+}
+
+// Listeners returns the events a node with props listens to, ordered by ID
+func Listeners(props Props) []EventListener {
+	var listeners []EventListener
+	for key, value := range props {
+		if value == nil {
+			continue
+		}
+		if id, ok := PropEvent(key); ok {
+			_, options := ListenerOf(value)
+			listeners = append(listeners, EventListener{Event: id, Options: options})
+		}
+	}
+	sort.Slice(listeners, func(i, j int) bool { return listeners[i].Event < listeners[j].Event })
+	return listeners
+}
+
+// listenersEqual compares two ordered listener sets
+func listenersEqual(a, b []EventListener) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package vdom
+
+import "strings"
+
+// Element namespaces. HTML is the zero value.
+const (
+	NamespaceHTML   = ""
+	NamespaceSVG    = "http://www.w3.org/2000/svg"
+	NamespaceMathML = "http://www.w3.org/1998/Math/MathML"
+)
+
+// Attribute namespaces, selected by the attribute's prefix
+const (
+	NamespaceXLink = "http://www.w3.org/1999/xlink"
+	NamespaceXML   = "http://www.w3.org/XML/1998/namespace"
+	NamespaceXMLNS = "http://www.w3.org/2000/xmlns/"
+)
+
+// ElementNamespace returns the namespace of an element whose parent passes
+// down inherited. An explicit VNode.Namespace wins; <svg> and <math> start
+// their namespaces; every other element inherits.
+func ElementNamespace(node *VNode, inherited string) string {
+	if node.Namespace != "" {
+		return node.Namespace
+	}
+	switch strings.ToLower(node.Tag) {
+	case "svg":
+		return NamespaceSVG
+	case "math":
+		return NamespaceMathML
+	}
+	return inherited
+}
+
+// ChildNamespace returns the namespace a node in ns passes down to its
+// children: HTML below <foreignObject> and portals, which render into the
+// document elsewhere, and ns otherwise
+func ChildNamespace(node *VNode, ns string) string {
+	switch {
+	case node.Kind == KindPortal:
+		return NamespaceHTML
+	case node.Kind == KindElement && ns == NamespaceSVG && strings.EqualFold(node.Tag, "foreignObject"):
+		return NamespaceHTML
+	}
+	return ns
+}
+
+// resolveNamespace records the namespace of an element inheriting ns, and
+// the namespace a component node's output inherits
+func resolveNamespace(node *VNode, ns string) {
+	switch node.Kind {
+	case KindElement, KindRaw:
+		node.Namespace = ElementNamespace(node, ns)
+	case KindComponent:
+		node.Namespace = ns
+	}
+}
+
+// kidsNamespace returns the namespace a resolved node that inherited ns
+// passes down to its children
+func kidsNamespace(node *VNode, ns string) string {
+	if node.Kind == KindElement {
+		ns = node.Namespace
+	}
+	return ChildNamespace(node, ns)
+}
+
+// AdjustTag returns the case-sensitive name of an element in ns, so that
+// "lineargradient" becomes "linearGradient" inside SVG
+func AdjustTag(ns, tag string) string {
+	if ns == NamespaceSVG {
+		if adjusted, ok := svgTags[strings.ToLower(tag)]; ok {
+			return adjusted
+		}
+	}
+	return tag
+}
+
+// AdjustAttribute returns the case-sensitive name of an attribute on an
+// element in ns, so that "viewbox" becomes "viewBox" inside SVG
+func AdjustAttribute(ns, name string) string {
+	switch ns {
+	case NamespaceSVG:
+		if adjusted, ok := svgAttributes[strings.ToLower(name)]; ok {
+			return adjusted
+		}
+	case NamespaceMathML:
+		if strings.EqualFold(name, "definitionURL") {
+			return "definitionURL"
+		}
+	}
+	return name
+}
+
+// AttributeNamespace returns the namespace of a prefixed attribute such as
+// "xlink:href" or "xml:lang", or "" for plain attributes
+func AttributeNamespace(name string) string {
+	switch {
+	case strings.HasPrefix(name, "xlink:"):
+		return NamespaceXLink
+	case strings.HasPrefix(name, "xml:"):
+		return NamespaceXML
+	case name == "xmlns" || strings.HasPrefix(name, "xmlns:"):
+		return NamespaceXMLNS
+	}
+	return ""
+}
+
+// svgTags maps lower-cased SVG element names to their proper case
+var svgTags = caseTable(
+	"altGlyph", "altGlyphDef", "altGlyphItem", "animateColor", "animateMotion",
+	"animateTransform", "clipPath", "feBlend", "feColorMatrix",
+	"feComponentTransfer", "feComposite", "feConvolveMatrix",
+	"feDiffuseLighting", "feDisplacementMap", "feDistantLight", "feDropShadow",
+	"feFlood", "feFuncA", "feFuncB", "feFuncG", "feFuncR", "feGaussianBlur",
+	"feImage", "feMerge", "feMergeNode", "feMorphology", "feOffset",
+	"fePointLight", "feSpecularLighting", "feSpotLight", "feTile",
+	"feTurbulence", "foreignObject", "glyphRef", "linearGradient",
+	"radialGradient", "textPath",
+)
+
+// svgAttributes maps lower-cased SVG attribute names to their proper case
+var svgAttributes = caseTable(
+	"attributeName", "attributeType", "baseFrequency", "baseProfile",
+	"calcMode", "clipPathUnits", "diffuseConstant", "edgeMode", "filterUnits",
+	"glyphRef", "gradientTransform", "gradientUnits", "kernelMatrix",
+	"kernelUnitLength", "keyPoints", "keySplines", "keyTimes", "lengthAdjust",
+	"limitingConeAngle", "markerHeight", "markerUnits", "markerWidth",
+	"maskContentUnits", "maskUnits", "numOctaves", "pathLength",
+	"patternContentUnits", "patternTransform", "patternUnits", "pointsAtX",
+	"pointsAtY", "pointsAtZ", "preserveAlpha", "preserveAspectRatio",
+	"primitiveUnits", "refX", "refY", "repeatCount", "repeatDur",
+	"requiredExtensions", "requiredFeatures", "specularConstant",
+	"specularExponent", "spreadMethod", "startOffset", "stdDeviation",
+	"stitchTiles", "surfaceScale", "systemLanguage", "tableValues", "targetX",
+	"targetY", "textLength", "viewBox", "viewTarget", "xChannelSelector",
+	"yChannelSelector", "zoomAndPan",
+)
+
+// caseTable indexes names by their lower-case form
+func caseTable(names ...string) map[string]string {
+	table := make(map[string]string, len(names))
+	for _, name := range names {
+		table[strings.ToLower(name)] = name
+	}
+	return table
+}
@@ -0,0 +1,266 @@
+package vdom
+
+import "sort"
+
+// OptimizePatches compacts a batch of patches without changing the DOM it
+// produces when applied in order:
+//
+//   - Of several writes to the same slot of a node (its text, inner HTML,
+//     an attribute, its event set or the handler of one event) only the
+//     last is kept; a SetAttribute followed by a RemoveAttribute of the
+//     same key leaves the removal.
+//   - Writes to a node that is removed later in the batch are dropped.
+//   - A move followed by another move or the removal of the same node is
+//     dropped, as long as no patch in between places a node relative to it
+//     or moves or removes any other node.
+//   - An insert followed by moves of the inserted node becomes one insert
+//     at the final position, as long as nothing in between refers to the
+//     inserted subtree.
+//   - An insert whose node is removed later in the batch is dropped with
+//     the removal and every patch for the subtree in between, as long as no
+//     other node was placed relative to the subtree.
+//
+// Patches keep their relative order. The input slice is not modified.
+func OptimizePatches(patches []Patch) []Patch {
+	if len(patches) < 2 {
+		return patches
+	}
+	o := newPatchOptimizer(patches)
+	o.dropSupersededWrites()
+	o.foldMoves()
+	o.cancelInsertRemoves()
+	return o.result()
+}
+
+// patchOptimizer holds a batch being optimized
+type patchOptimizer struct {
+	patches []Patch
+	dropped []bool
+	refs    map[uint32][]int // ascending indices of the patches naming a node
+	moves   []int            // moves[i] counts the moves and removals before patch i
+}
+
+// newPatchOptimizer copies a batch and indexes the nodes it names
+func newPatchOptimizer(patches []Patch) *patchOptimizer {
+	o := &patchOptimizer{
+		patches: append([]Patch(nil), patches...),
+		dropped: make([]bool, len(patches)),
+		refs:    make(map[uint32][]int),
+		moves:   make([]int, len(patches)+1),
+	}
+	for i, p := range o.patches {
+		o.moves[i+1] = o.moves[i]
+		if p.Op == OpMoveNode || p.Op == OpRemoveNode {
+			o.moves[i+1]++
+		}
+		o.ref(p.NodeID, i)
+		if p.Op == OpInsertNode || p.Op == OpMoveNode {
+			if p.ParentID != 0 && p.ParentID != p.NodeID {
+				o.ref(p.ParentID, i)
+			}
+			if p.BeforeID != 0 {
+				o.ref(p.BeforeID, i)
+			}
+		}
+	}
+	return o
+}
+
+// ref records that patch i names node id
+func (o *patchOptimizer) ref(id uint32, i int) {
+	if refs := o.refs[id]; len(refs) == 0 || refs[len(refs)-1] != i {
+		o.refs[id] = append(refs, i)
+	}
+}
+
+// writeSlot identifies what a write patch overwrites
+type writeSlot struct {
+	op  PatchOp
+	key string
+}
+
+// slotOf returns the slot of a write patch and whether p is a write
+func slotOf(p Patch) (writeSlot, bool) {
+	switch p.Op {
+	case OpReplaceText, OpSetHTML, OpUpdateEvents:
+		return writeSlot{op: p.Op}, true
+	case OpSetAttribute, OpRemoveAttribute:
+		// A removal supersedes a set of the same attribute and vice versa
+		return writeSlot{op: OpSetAttribute, key: p.Key}, true
+	case OpSetHandler:
+		return writeSlot{op: OpSetHandler, key: p.Key}, true
+	}
+	return writeSlot{}, false
+}
+
+// subtree returns the range of IDs an insert mounts: the inserted tree is
+// numbered in pre-order from the patch's NodeID
+func subtree(p Patch) (lo, hi uint32) {
+	n := uint32(1)
+	if p.Node != nil {
+		n = countNodes(p.Node)
+	}
+	return p.NodeID, p.NodeID + n
+}
+
+// dropSupersededWrites keeps the last write to every slot and drops writes
+// to nodes removed later. Walking backwards, an insert starts a node's
+// life, so writes before it concern an earlier node with the same ID.
+func (o *patchOptimizer) dropSupersededWrites() {
+	removed := make(map[uint32]bool)
+	written := make(map[uint32]map[writeSlot]bool)
+	for i := len(o.patches) - 1; i >= 0; i-- {
+		p := o.patches[i]
+		switch p.Op {
+		case OpRemoveNode:
+			removed[p.NodeID] = true
+			continue
+		case OpInsertNode:
+			lo, hi := subtree(p)
+			for id := lo; id < hi; id++ {
+				delete(removed, id)
+				delete(written, id)
+			}
+			continue
+		}
+
+		slot, ok := slotOf(p)
+		switch {
+		case !ok:
+		case removed[p.NodeID] || written[p.NodeID][slot]:
+			o.dropped[i] = true
+		default:
+			if written[p.NodeID] == nil {
+				written[p.NodeID] = make(map[writeSlot]bool)
+			}
+			written[p.NodeID][slot] = true
+		}
+	}
+}
+
+// foldMoves drops moves that a later move or removal of the same node
+// makes moot, and folds moves of inserted nodes into their insert
+func (o *patchOptimizer) foldMoves() {
+	for i := range o.patches {
+		if o.dropped[i] {
+			continue
+		}
+		p := o.patches[i]
+		switch p.Op {
+		case OpMoveNode:
+			k := o.nextPlacement(p.NodeID, i)
+			if k < 0 {
+				break
+			}
+			if next := o.patches[k]; next.NodeID == p.NodeID && (next.Op == OpMoveNode || next.Op == OpRemoveNode) && o.moves[k]-o.moves[i+1] == 0 {
+				o.dropped[i] = true
+			}
+
+		case OpInsertNode:
+			k := o.nextPlacement(p.NodeID, i)
+			if k < 0 {
+				break
+			}
+			if next := o.patches[k]; next.Op == OpMoveNode && next.NodeID == p.NodeID && !o.subtreeNamedBetween(p, i, k) {
+				// Insert straight at the final position; the loop reaches
+				// the new insert later and may fold further moves into it
+				p.ParentID, p.BeforeID = next.ParentID, next.BeforeID
+				o.patches[k] = p
+				o.dropped[i] = true
+			}
+		}
+	}
+}
+
+// cancelInsertRemoves drops inserted subtrees removed in the same batch
+func (o *patchOptimizer) cancelInsertRemoves() {
+	for i := range o.patches {
+		if o.dropped[i] || o.patches[i].Op != OpInsertNode {
+			continue
+		}
+		p := o.patches[i]
+		k := o.nextPlacement(p.NodeID, i)
+		if k < 0 || o.patches[k].Op != OpRemoveNode || o.patches[k].NodeID != p.NodeID {
+			continue
+		}
+
+		// Only writes to the subtree and removals within it may come in
+		// between; they go with it
+		var moot []int
+		lo, hi := subtree(p)
+		cancel := true
+		for id := lo; id < hi && cancel; id++ {
+			for _, j := range o.refsBetween(id, i, k) {
+				q := o.patches[j]
+				if _, write := slotOf(q); q.NodeID == id && (write || q.Op == OpRemoveNode) {
+					moot = append(moot, j)
+				} else {
+					cancel = false
+					break
+				}
+			}
+		}
+		if !cancel {
+			continue
+		}
+		o.dropped[i], o.dropped[k] = true, true
+		for _, j := range moot {
+			o.dropped[j] = true
+		}
+	}
+}
+
+// nextPlacement returns the index of the first patch after i that names
+// node id other than as the target of a write, or -1
+func (o *patchOptimizer) nextPlacement(id uint32, i int) int {
+	refs := o.refs[id]
+	for _, j := range refs[sort.SearchInts(refs, i+1):] {
+		if o.dropped[j] {
+			continue
+		}
+		if _, write := slotOf(o.patches[j]); write && o.patches[j].NodeID == id {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// refsBetween returns the indices of kept patches strictly between i and k
+// that name node id
+func (o *patchOptimizer) refsBetween(id uint32, i, k int) []int {
+	refs := o.refs[id]
+	var between []int
+	for _, j := range refs[sort.SearchInts(refs, i+1):] {
+		if j >= k {
+			break
+		}
+		if !o.dropped[j] {
+			between = append(between, j)
+		}
+	}
+	return between
+}
+
+// subtreeNamedBetween reports whether a kept patch strictly between i and
+// k names a node of the subtree insert mounts
+func (o *patchOptimizer) subtreeNamedBetween(insert Patch, i, k int) bool {
+	lo, hi := subtree(insert)
+	for id := lo; id < hi; id++ {
+		if len(o.refsBetween(id, i, k)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// result returns the kept patches
+func (o *patchOptimizer) result() []Patch {
+	kept := o.patches[:0]
+	for i, p := range o.patches {
+		if !o.dropped[i] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
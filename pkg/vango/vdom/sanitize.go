@@ -0,0 +1,331 @@
+package vdom
+
+import (
+	"html"
+	"strings"
+)
+
+// Sanitizer cleans untrusted HTML against an allowlist. Elements that are
+// not allowed are dropped but their text is kept, except for elements like
+// <script> and <style> whose content is dropped with them. Attributes that
+// are not allowed, event handler attributes and URLs with schemes outside
+// the allowed protocols are removed. Comments, doctypes and processing
+// instructions are removed; text is re-escaped and unclosed elements are
+// closed, so the output is always well formed.
+//
+// Configure a Sanitizer before use; Sanitize is safe for concurrent use.
+type Sanitizer struct {
+	elements   map[string]bool
+	attributes map[string]map[string]bool // by element, "" for every element
+	protocols  map[string]bool
+}
+
+// NewSanitizer creates a sanitizer with the default policy: text formatting,
+// headings, lists, tables, links, images and quotes, with the class, id,
+// title, lang and dir attributes, and http, https, mailto and tel URLs.
+func NewSanitizer() *Sanitizer {
+	s := &Sanitizer{
+		elements:   make(map[string]bool),
+		attributes: make(map[string]map[string]bool),
+		protocols:  make(map[string]bool),
+	}
+	s.AllowElements(
+		"a", "abbr", "b", "bdi", "bdo", "blockquote", "br", "caption", "cite",
+		"code", "col", "colgroup", "dd", "del", "details", "dfn", "div", "dl",
+		"dt", "em", "figcaption", "figure", "h1", "h2", "h3", "h4", "h5", "h6",
+		"hr", "i", "img", "ins", "kbd", "li", "mark", "ol", "p", "pre", "q",
+		"rp", "rt", "ruby", "s", "samp", "small", "span", "strong", "sub",
+		"summary", "sup", "table", "tbody", "td", "tfoot", "th", "thead",
+		"time", "tr", "u", "ul", "var", "wbr",
+	)
+	s.AllowAttributes("", "class", "id", "title", "lang", "dir")
+	s.AllowAttributes("a", "href", "name", "target", "rel")
+	s.AllowAttributes("img", "src", "alt", "width", "height", "loading")
+	s.AllowAttributes("td", "colspan", "rowspan", "align")
+	s.AllowAttributes("th", "colspan", "rowspan", "align", "scope")
+	s.AllowAttributes("col", "span")
+	s.AllowAttributes("colgroup", "span")
+	s.AllowAttributes("ol", "start", "reversed", "type")
+	s.AllowAttributes("li", "value")
+	s.AllowAttributes("blockquote", "cite")
+	s.AllowAttributes("q", "cite")
+	s.AllowAttributes("del", "cite", "datetime")
+	s.AllowAttributes("ins", "cite", "datetime")
+	s.AllowAttributes("time", "datetime")
+	s.AllowAttributes("details", "open")
+	s.AllowProtocols("http", "https", "mailto", "tel")
+	return s
+}
+
+// AllowElements adds elements to the allowlist
+func (s *Sanitizer) AllowElements(names ...string) *Sanitizer {
+	for _, name := range names {
+		s.elements[strings.ToLower(name)] = true
+	}
+	return s
+}
+
+// AllowAttributes allows attributes on element, or on every allowed
+// element if element is "". Event handler attributes (on*) are never
+// allowed.
+func (s *Sanitizer) AllowAttributes(element string, names ...string) *Sanitizer {
+	element = strings.ToLower(element)
+	if s.attributes[element] == nil {
+		s.attributes[element] = make(map[string]bool)
+	}
+	for _, name := range names {
+		s.attributes[element][strings.ToLower(name)] = true
+	}
+	return s
+}
+
+// AllowProtocols adds URL schemes allowed in href, src and cite
+// attributes. Relative URLs are always allowed.
+func (s *Sanitizer) AllowProtocols(schemes ...string) *Sanitizer {
+	for _, scheme := range schemes {
+		s.protocols[strings.ToLower(scheme)] = true
+	}
+	return s
+}
+
+// defaultSanitizer backs Sanitize and NewSanitizedHTML
+var defaultSanitizer = NewSanitizer()
+
+// Sanitize cleans untrusted HTML with the default policy (see NewSanitizer)
+func Sanitize(html string) string {
+	return defaultSanitizer.Sanitize(html)
+}
+
+// droppedContent are elements whose content is dropped along with them
+var droppedContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true,
+	"embed": true, "applet": true, "noscript": true, "noembed": true,
+	"noframes": true, "template": true, "textarea": true, "select": true,
+	"title": true, "xmp": true, "plaintext": true, "svg": true, "math": true,
+	"frameset": true, "head": true,
+}
+
+// sanitizerVoid are void elements, which are never closed
+var sanitizerVoid = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// urlAttributes hold URLs and are checked against the allowed protocols
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "cite": true,
+}
+
+// Sanitize returns the allowed part of src as well-formed HTML
+func (s *Sanitizer) Sanitize(src string) string {
+	var out strings.Builder
+	var open []string
+	for len(src) > 0 {
+		lt := strings.IndexByte(src, '<')
+		if lt < 0 {
+			out.WriteString(html.EscapeString(html.UnescapeString(src)))
+			break
+		}
+		out.WriteString(html.EscapeString(html.UnescapeString(src[:lt])))
+		src = src[lt:]
+
+		switch {
+		case strings.HasPrefix(src, "<!--"):
+			src = skipPast(src[4:], "-->")
+
+		case strings.HasPrefix(src, "<!"), strings.HasPrefix(src, "<?"):
+			src = skipPast(src[2:], ">")
+
+		case strings.HasPrefix(src, "</"):
+			name, rest := tagName(src[2:])
+			if name == "" {
+				// Not a tag: "</" followed by something else
+				out.WriteString("&lt;/")
+				src = src[2:]
+				continue
+			}
+			src = skipPast(rest, ">")
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == name {
+					for len(open) > i {
+						out.WriteString("</" + open[len(open)-1] + ">")
+						open = open[:len(open)-1]
+					}
+					break
+				}
+			}
+
+		default:
+			name, rest := tagName(src[1:])
+			if name == "" {
+				out.WriteString("&lt;")
+				src = src[1:]
+				continue
+			}
+			attrs, selfClosing, rest := parseAttributes(rest)
+			src = rest
+
+			if !s.elements[name] {
+				if droppedContent[name] && !selfClosing && !sanitizerVoid[name] {
+					src = skipElement(src, name)
+				}
+				continue
+			}
+
+			out.WriteString("<" + name)
+			for _, attr := range attrs {
+				if s.allowAttribute(name, attr.name, attr.value) {
+					out.WriteString(" " + attr.name + `="` + html.EscapeString(attr.value) + `"`)
+				}
+			}
+			out.WriteString(">")
+			if sanitizerVoid[name] {
+				continue
+			}
+			if selfClosing {
+				out.WriteString("</" + name + ">")
+				continue
+			}
+			open = append(open, name)
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		out.WriteString("</" + open[i] + ">")
+	}
+	return out.String()
+}
+
+// allowAttribute reports whether an attribute survives sanitizing
+func (s *Sanitizer) allowAttribute(element, name, value string) bool {
+	if strings.HasPrefix(name, "on") {
+		return false
+	}
+	if !s.attributes[""][name] && !s.attributes[element][name] {
+		return false
+	}
+	if urlAttributes[name] {
+		return s.allowURL(value)
+	}
+	return true
+}
+
+// allowURL reports whether a URL is relative or uses an allowed scheme.
+// Browsers ignore whitespace and control characters inside schemes, so
+// they are removed before looking for one.
+func (s *Sanitizer) allowURL(url string) bool {
+	url = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, url)
+	colon := strings.IndexByte(url, ':')
+	if colon < 0 {
+		return true
+	}
+	if end := strings.IndexAny(url, "/?#"); end >= 0 && end < colon {
+		return true
+	}
+	return s.protocols[strings.ToLower(url[:colon])]
+}
+
+// sanitizerAttribute is a parsed attribute with its value unescaped
+type sanitizerAttribute struct {
+	name, value string
+}
+
+// tagName reads a tag name at the start of src, lower-cased. It returns ""
+// if src does not start with a letter.
+func tagName(src string) (string, string) {
+	if src == "" || !isASCIILetter(src[0]) {
+		return "", src
+	}
+	i := 1
+	for i < len(src) && !isTagSpace(src[i]) && src[i] != '/' && src[i] != '>' {
+		i++
+	}
+	return strings.ToLower(src[:i]), src[i:]
+}
+
+// parseAttributes reads the attributes of a start tag up to and including
+// its closing '>'. It reports whether the tag ended in "/>".
+func parseAttributes(src string) ([]sanitizerAttribute, bool, string) {
+	var attrs []sanitizerAttribute
+	for {
+		for len(src) > 0 && (isTagSpace(src[0]) || src[0] == '/') {
+			if src[0] == '/' && len(src) > 1 && src[1] == '>' {
+				return attrs, true, src[2:]
+			}
+			src = src[1:]
+		}
+		if src == "" {
+			return attrs, false, src
+		}
+		if src[0] == '>' {
+			return attrs, false, src[1:]
+		}
+
+		i := 1
+		for i < len(src) && !isTagSpace(src[i]) && src[i] != '/' && src[i] != '>' && src[i] != '=' {
+			i++
+		}
+		attr := sanitizerAttribute{name: strings.ToLower(src[:i])}
+		src = strings.TrimLeft(src[i:], " \t\n\r\f")
+
+		if strings.HasPrefix(src, "=") {
+			src = strings.TrimLeft(src[1:], " \t\n\r\f")
+			var raw string
+			if len(src) > 0 && (src[0] == '"' || src[0] == '\'') {
+				end := strings.IndexByte(src[1:], src[0])
+				if end < 0 {
+					raw, src = src[1:], ""
+				} else {
+					raw, src = src[1:1+end], src[2+end:]
+				}
+			} else {
+				end := 0
+				for end < len(src) && !isTagSpace(src[end]) && src[end] != '>' {
+					end++
+				}
+				raw, src = src[:end], src[end:]
+			}
+			attr.value = html.UnescapeString(raw)
+		}
+		attrs = append(attrs, attr)
+	}
+}
+
+// skipElement drops everything up to and including the end tag of name
+func skipElement(src, name string) string {
+	for {
+		i := strings.Index(src, "</")
+		if i < 0 {
+			return ""
+		}
+		src = src[i+2:]
+		if len(src) >= len(name) && strings.EqualFold(src[:len(name)], name) {
+			rest := src[len(name):]
+			if rest == "" || isTagSpace(rest[0]) || rest[0] == '>' || rest[0] == '/' {
+				return skipPast(rest, ">")
+			}
+		}
+	}
+}
+
+// skipPast drops everything up to and including the first sep
+func skipPast(src, sep string) string {
+	if i := strings.Index(src, sep); i >= 0 {
+		return src[i+len(sep):]
+	}
+	return ""
+}
+
+func isASCIILetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isTagSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -12,13 +12,20 @@ const (
 	KindFragment
 	// KindPortal represents a portal (render children elsewhere in DOM)
 	KindPortal
+	// KindRaw represents an element whose content is an HTML string (Text)
+	// instead of child nodes
+	KindRaw
+	// KindComponent represents a component (Component) rendered with Props.
+	// Its output, once rendered, is its only kid.
+	KindComponent
 )
 
 // VNodeFlags are bitwise flags for VNode optimizations
 type VNodeFlags uint8
 
 const (
-	// FlagStatic indicates this node and its children will never change
+	// FlagStatic indicates this node and its children will not change as
+	// long as its Deps stay equal; Diff skips such subtrees
 	FlagStatic VNodeFlags = 1 << iota
 	// FlagHasKey indicates this node has a key for list reconciliation
 	FlagHasKey
@@ -26,7 +33,8 @@ const (
 	FlagHasRef
 	// FlagHasEvents indicates this node has event listeners
 	FlagHasEvents
-	// FlagDirty indicates this node needs re-rendering
+	// FlagDirty indicates this node needs re-rendering; Diff compares a
+	// static subtree marked dirty even if its Deps are unchanged
 	FlagDirty
 )
 
@@ -35,13 +43,14 @@ const (
 type Props map[string]any
 
 // VNode represents a virtual DOM node
-// This struct is immutable - once created, it should never be modified
+// This struct is immutable - once created, it should never be modified,
+// except for ID, which is assigned when the node is mounted
 type VNode struct {
 	// Kind determines the type of this node
 	Kind VKind
 
 	// Tag is the element tag name (e.g., "div", "span")
-	// Only used when Kind == KindElement
+	// Only used when Kind == KindElement or KindRaw
 	Tag string
 
 	// Props contains all properties/attributes for this node
@@ -59,11 +68,35 @@ type VNode struct {
 	// Flags contains optimization hints
 	Flags VNodeFlags
 
-	// Text content (only used when Kind == KindText)
+	// Text content (only used when Kind == KindText), or the inner HTML
+	// of a KindRaw element
 	Text string
 
 	// Portal target (only used when Kind == KindPortal)
 	PortalTarget string
+
+	// Namespace is the XML namespace of an element (NamespaceSVG,
+	// NamespaceMathML; "" is HTML). Leave it empty to inherit: <svg> and
+	// <math> start their namespaces and Mount and Diff fill it in below them.
+	// Component nodes record the namespace their output inherits.
+	Namespace string
+
+	// Deps identify the content of a static subtree (see Memo and Static)
+	Deps []any
+
+	// Component is what a KindComponent node renders, typically a
+	// vango.Component. Diff only compares it.
+	Component any
+
+	// Instance is the state a ComponentHost keeps for a mounted component
+	// node, such as its fiber. Diff carries it over to the matching node of
+	// the next tree.
+	Instance any
+
+	// ID identifies the mounted DOM node; 0 means not mounted yet.
+	// Mount assigns it and Diff carries it over to the matching node of the
+	// next tree, so patches, appliers and hydration agree on it.
+	ID uint32
 }
 
 // NewElement creates a new element VNode
@@ -148,11 +181,78 @@ func NewPortal(target string, children ...*VNode) *VNode {
 	}
 }
 
+// NewTrustedHTML creates an element whose content is the HTML string html,
+// rendered and inserted as is. Only pass HTML from sources you control;
+// use NewSanitizedHTML for anything else.
+func NewTrustedHTML(tag string, props Props, html string) *VNode {
+	node := NewElement(tag, props)
+	node.Kind = KindRaw
+	node.Kids = nil
+	node.Text = html
+	return node
+}
+
+// NewSanitizedHTML creates an element whose content is html cleaned by the
+// default Sanitizer, for CMS content, rendered markdown and other HTML that
+// may come from users
+func NewSanitizedHTML(tag string, props Props, html string) *VNode {
+	return NewTrustedHTML(tag, props, Sanitize(html))
+}
+
+// Memo marks the tree returned by render as depending only on deps. When
+// the node it replaces was memoized with equal deps, Diff keeps the mounted
+// subtree without comparing it, handlers included. Deps compare with ==;
+// functions and values that are not comparable always count as changed.
+func Memo(deps []any, render func() *VNode) *VNode {
+	node := render()
+	if node == nil {
+		return nil
+	}
+	node.Flags |= FlagStatic
+	node.Deps = deps
+	return node
+}
+
+// staticIdentity gives every Static node a distinct identity
+type staticIdentity struct{ _ byte }
+
+// Static marks a subtree that never changes. Build it once, typically in a
+// package-level variable, and reuse it on every render: Diff skips it
+// whenever it replaces itself. Mounting copies the subtree, so a hoisted
+// node can appear in several places and sessions at once.
+func Static(node *VNode) *VNode {
+	if node == nil {
+		return nil
+	}
+	node.Flags |= FlagStatic
+	node.Deps = []any{&staticIdentity{}}
+	return node
+}
+
+// NewComponent creates a component node rendering component with props.
+// A "key" prop identifies it among keyed siblings like any other node.
+func NewComponent(component any, props Props) *VNode {
+	node := &VNode{
+		Kind:      KindComponent,
+		Props:     props,
+		Component: component,
+	}
+	if _, hasKey := props["key"]; hasKey {
+		node.Flags |= FlagHasKey
+	}
+	return node
+}
+
 // IsElement returns true if this is an element node
 func (v VNode) IsElement() bool {
 	return v.Kind == KindElement
 }
 
+// IsRaw returns true if this is an element with raw HTML content
+func (v VNode) IsRaw() bool {
+	return v.Kind == KindRaw
+}
+
 // IsText returns true if this is a text node
 func (v VNode) IsText() bool {
 	return v.Kind == KindText
@@ -168,6 +268,11 @@ func (v VNode) IsPortal() bool {
 	return v.Kind == KindPortal
 }
 
+// IsComponent returns true if this is a component node
+func (v VNode) IsComponent() bool {
+	return v.Kind == KindComponent
+}
+
 // HasFlag returns true if the specified flag is set
 func (v VNode) HasFlag(flag VNodeFlags) bool {
 	return v.Flags&flag != 0
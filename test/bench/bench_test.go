@@ -80,6 +80,59 @@ func BenchmarkDiffLargeList(b *testing.B) {
 	}
 }
 
+// BenchmarkKeyedListOps benchmarks keyed reconciliation of a 1k-row list
+// for common list operations. moves/op reports the OpMoveNode patches
+// emitted, which minimal-move reconciliation keeps to what the operation
+// needs rather than one per shifted row.
+func BenchmarkKeyedListOps(b *testing.B) {
+	const n = 1000
+	ops := []struct {
+		name  string
+		order func() []int
+	}{
+		{"prepend", func() []int { return append([]int{n}, seq(0, n)...) }},
+		{"move-to-front", func() []int { return append([]int{n - 1}, seq(0, n-1)...) }},
+		{"reverse", func() []int {
+			order := seq(0, n)
+			for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+				order[i], order[j] = order[j], order[i]
+			}
+			return order
+		}},
+		{"swap", func() []int {
+			order := seq(0, n)
+			order[1], order[n-2] = order[n-2], order[1]
+			return order
+		}},
+		{"remove-middle", func() []int { return append(seq(0, n/2), seq(n/2+1, n)...) }},
+	}
+
+	for _, op := range ops {
+		b.Run(op.name, func(b *testing.B) {
+			order := op.order()
+			var moves int
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				prev := generateKeyedList(seq(0, n))
+				next := generateKeyedList(order)
+				b.StartTimer()
+
+				patches := vdom.Diff(prev, next)
+
+				moves = 0
+				for _, p := range patches {
+					if p.Op == vdom.OpMoveNode {
+						moves++
+					}
+				}
+			}
+			b.ReportMetric(float64(moves), "moves/op")
+		})
+	}
+}
+
 // BenchmarkSSRStreaming benchmarks server-side rendering performance
 func BenchmarkSSRStreaming(b *testing.B) {
 	root := generate1kNodeTree()
@@ -157,20 +210,20 @@ func BenchmarkReactiveSignalPropagation(b *testing.B) {
 
 	// Create a chain of computed signals
 	base := reactive.NewState(0, sched)
-	computed1 := reactive.NewComputed(func() int {
-		return base.Get() * 2
+	computed1 := reactive.NewComputed(func(o *reactive.Owner) int {
+		return base.Get(o) * 2
 	}, sched)
-	computed2 := reactive.NewComputed(func() int {
-		return computed1.Get() + 10
+	computed2 := reactive.NewComputed(func(o *reactive.Owner) int {
+		return computed1.Get(o) + 10
 	}, sched)
-	computed3 := reactive.NewComputed(func() int {
-		return computed2.Get() * 3
+	computed3 := reactive.NewComputed(func(o *reactive.Owner) int {
+		return computed2.Get(o) * 3
 	}, sched)
 
 	// Create fibers that depend on the signals
 	for i := 0; i < 10; i++ {
 		sched.CreateFiber(func() *vdom.VNode {
-			value := computed3.Get()
+			value := computed3.Get(nil)
 			return vdom.NewText(fmt.Sprintf("%d", value))
 		}, nil)
 	}
@@ -180,7 +233,7 @@ func BenchmarkReactiveSignalPropagation(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		base.Set(i)
+		base.Set(nil, i)
 		time.Sleep(time.Millisecond) // Allow propagation
 	}
 }
@@ -250,6 +303,27 @@ func generateListWithNItems(n int) *vdom.VNode {
 	return vdom.NewElement("ul", nil, items...)
 }
 
+// generateKeyedList builds a list whose rows carry the given item numbers
+func generateKeyedList(items []int) *vdom.VNode {
+	rows := make([]*vdom.VNode, len(items))
+	for i, item := range items {
+		rows[i] = vdom.NewElement("li", vdom.Props{
+			"key": fmt.Sprintf("item-%d", item),
+		}, vdom.NewText(fmt.Sprintf("Item %d", item)))
+	}
+
+	return vdom.NewElement("ul", nil, rows...)
+}
+
+// seq returns the integers in [from, to)
+func seq(from, to int) []int {
+	out := make([]int, 0, to-from)
+	for i := from; i < to; i++ {
+		out = append(out, i)
+	}
+	return out
+}
+
 func generateShuffledList(n int) *vdom.VNode {
 	items := make([]*vdom.VNode, n)
 
@@ -7,7 +7,7 @@ import (
 	"testing"
 	"time"
 
-	// "github.com/recera/vango/pkg/live"
+	"github.com/recera/vango/pkg/live"
 	"github.com/recera/vango/pkg/scheduler"
 	"github.com/recera/vango/pkg/vango/vdom"
 )
@@ -62,7 +62,7 @@ func BenchmarkPatchEncoding(b *testing.B) {
 		{Op: vdom.OpSetAttribute, NodeID: 2, Key: "class", Value: "active"},
 		{Op: vdom.OpRemoveNode, NodeID: 3},
 		{Op: vdom.OpInsertNode, NodeID: 4, ParentID: 1, Node: vdom.NewElement("div", nil)},
-		{Op: vdom.OpUpdateEvents, NodeID: 5, EventBits: 0x0F},
+		{Op: vdom.OpUpdateEvents, NodeID: 5, Events: vdom.Listeners(vdom.Props{"onClick": "a", "onInput": "b"})},
 	}
 	
 	b.ResetTimer()
@@ -89,30 +89,30 @@ func BenchmarkPatchDecoding(b *testing.B) {
 
 // BenchmarkLivePatchStream benchmarks streaming patches over live protocol
 func BenchmarkLivePatchStream(b *testing.B) {
-	// Skip until live.Codec is implemented
-	b.Skip("Live codec not yet implemented")
-	
-	// // Create a mock live connection
-	// codec := live.NewCodec()
-	// 
-	// // Generate patches
-	// patches := make([]vdom.Patch, 10)
-	// for i := 0; i < 10; i++ {
-	// 	patches[i] = vdom.Patch{
-	// 		Op:     vdom.OpReplaceText,
-	// 		NodeID: uint32(i),
-	// 		Value:  "Updated content",
-	// 	}
-	// }
-	// 
-	// b.ResetTimer()
-	// for i := 0; i < b.N; i++ {
-	// 	// Encode frame
-	// 	frame, _ := codec.EncodePatches(patches)
-	// 	
-	// 	// Decode frame
-	// 	_, _ = codec.DecodePatches(frame)
-	// }
+	// Generate patches, including a structural insert
+	patches := make([]vdom.Patch, 10)
+	for i := 0; i < 9; i++ {
+		patches[i] = vdom.Patch{
+			Op:     vdom.OpReplaceText,
+			NodeID: uint32(i + 1),
+			Value:  "Updated content",
+		}
+	}
+	patches[9] = vdom.Patch{
+		Op:       vdom.OpInsertNode,
+		NodeID:   100,
+		ParentID: 1,
+		Node:     generateTreeWithNNodes(10),
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Encode frame
+		frame, _ := live.EncodePatches(patches)
+
+		// Decode frame
+		_, _ = live.DecodePatches(frame)
+	}
 }
 
 // TestConcurrentPatchApplication tests patch application under concurrent load